@@ -0,0 +1,211 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package boot sets up the typical operator main.go so it only needs to list
+// APIs and controllers.
+//
+//	func main() {
+//		boot.New("my-operator").
+//			API(myv1.AddToScheme).
+//			Controller(controllers.Foo).
+//			Main()
+//	}
+package boot
+
+import (
+	"flag"
+	"os"
+	"strconv"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+type schemeAdder func(*runtime.Scheme) error
+type managerAdder func(ctrl.Manager) error
+
+type bootBuilder struct {
+	name        string
+	apis        []schemeAdder
+	controllers []managerAdder
+	options     manager.Options
+	flags       *flag.FlagSet
+	args        []string
+}
+
+// Start building an operator. The name is used for the leader election ID and
+// the setup logger.
+func New(name string) *bootBuilder {
+	return &bootBuilder{name: name, flags: flag.CommandLine, args: os.Args[1:]}
+}
+
+// Add an API group to the manager's scheme. The client-go scheme is always
+// included.
+func (b *bootBuilder) API(adder schemeAdder) *bootBuilder {
+	b.apis = append(b.apis, adder)
+	return b
+}
+
+// Add a controller, usually a function building a core.Reconciler:
+//
+//	func Foo(mgr ctrl.Manager) error {
+//		return core.NewReconciler(mgr).For(&myv1.Foo{}).Complete()
+//	}
+func (b *bootBuilder) Controller(adder managerAdder) *bootBuilder {
+	b.controllers = append(b.controllers, adder)
+	return b
+}
+
+// Set base manager options. Flags and environment variables override the
+// metrics, health, and leader election fields.
+func (b *bootBuilder) Options(opts manager.Options) *bootBuilder {
+	b.options = opts
+	return b
+}
+
+// Parse flags from a different set and arguments, mostly for testing. The
+// boot flags are defined on a private copy of the set, so Build can be
+// called more than once.
+func (b *bootBuilder) Flags(flags *flag.FlagSet, args []string) *bootBuilder {
+	b.flags = flags
+	b.args = args
+	return b
+}
+
+// Read a boolean environment variable, using the fallback if it's unset or
+// not a boolean.
+func envBool(name string, fallback bool) bool {
+	val, err := strconv.ParseBool(os.Getenv(name))
+	if err != nil {
+		return fallback
+	}
+	return val
+}
+
+func envString(name string, fallback string) string {
+	val := os.Getenv(name)
+	if val == "" {
+		return fallback
+	}
+	return val
+}
+
+// Parse flags and create the manager with all APIs and controllers added, but
+// don't start it.
+func (b *bootBuilder) Build() (ctrl.Manager, error) {
+	opts := b.options
+	metricsAddr := opts.MetricsBindAddress
+	if metricsAddr == "" {
+		metricsAddr = ":8080"
+	}
+	probeAddr := opts.HealthProbeBindAddress
+	if probeAddr == "" {
+		probeAddr = ":8081"
+	}
+	// Defining flags twice panics, so use a fresh set each time with the
+	// parent set's flags, like --kubeconfig, carried over.
+	flags := flag.NewFlagSet(b.flags.Name(), b.flags.ErrorHandling())
+	flags.SetOutput(b.flags.Output())
+	flags.StringVar(&opts.MetricsBindAddress, "metrics-bind-address", envString("METRICS_BIND_ADDRESS", metricsAddr), "The address the metric endpoint binds to.")
+	flags.StringVar(&opts.HealthProbeBindAddress, "health-probe-bind-address", envString("HEALTH_PROBE_BIND_ADDRESS", probeAddr), "The address the probe endpoint binds to.")
+	flags.BoolVar(&opts.LeaderElection, "leader-elect", envBool("LEADER_ELECT", opts.LeaderElection),
+		"Enable leader election for controller manager. Enabling this will ensure there is only one active controller manager.")
+	zapOpts := zap.Options{}
+	zapOpts.BindFlags(flags)
+	b.flags.VisitAll(func(f *flag.Flag) {
+		if flags.Lookup(f.Name) == nil {
+			flags.Var(f.Value, f.Name, f.Usage)
+		}
+	})
+	err := flags.Parse(b.args)
+	if err != nil {
+		return nil, errors.Wrap(err, "error parsing flags")
+	}
+	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&zapOpts)))
+
+	// Leader election settings usually come from the Deployment via the downward API.
+	if opts.LeaderElectionID == "" {
+		opts.LeaderElectionID = envString("LEADER_ELECTION_ID", b.name+"-leader")
+	}
+	if opts.LeaderElectionNamespace == "" {
+		opts.LeaderElectionNamespace = os.Getenv("POD_NAMESPACE")
+	}
+
+	if opts.Scheme == nil {
+		opts.Scheme = runtime.NewScheme()
+	}
+	utilruntime.Must(clientgoscheme.AddToScheme(opts.Scheme))
+	for _, adder := range b.apis {
+		err := adder(opts.Scheme)
+		if err != nil {
+			return nil, errors.Wrap(err, "error adding API to scheme")
+		}
+	}
+
+	config, err := ctrl.GetConfig()
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting Kubernetes config")
+	}
+	mgr, err := ctrl.NewManager(config, opts)
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating manager")
+	}
+	for _, adder := range b.controllers {
+		err := adder(mgr)
+		if err != nil {
+			return nil, errors.Wrap(err, "error creating controller")
+		}
+	}
+	err = mgr.AddHealthzCheck("healthz", healthz.Ping)
+	if err != nil {
+		return nil, errors.Wrap(err, "error adding health check")
+	}
+	err = mgr.AddReadyzCheck("readyz", healthz.Ping)
+	if err != nil {
+		return nil, errors.Wrap(err, "error adding ready check")
+	}
+	return mgr, nil
+}
+
+// Build the manager and run it until SIGTERM or SIGINT.
+func (b *bootBuilder) Run() error {
+	mgr, err := b.Build()
+	if err != nil {
+		return err
+	}
+	ctrl.Log.WithName(b.name).Info("starting manager")
+	err = mgr.Start(ctrl.SetupSignalHandler())
+	if err != nil {
+		return errors.Wrap(err, "error running manager")
+	}
+	return nil
+}
+
+// Run the operator, logging and exiting on failure. Intended to be the whole
+// body of main().
+func (b *bootBuilder) Main() {
+	err := b.Run()
+	if err != nil {
+		ctrl.Log.WithName(b.name).Error(err, "problem running manager")
+		os.Exit(1)
+	}
+}
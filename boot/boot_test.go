@@ -0,0 +1,183 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package boot_test
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/rest"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+
+	"github.com/coderanger/controller-utils/boot"
+)
+
+const kubeconfig = `apiVersion: v1
+kind: Config
+clusters:
+- name: test
+  cluster:
+    server: https://127.0.0.1:6443
+contexts:
+- name: test
+  context:
+    cluster: test
+current-context: test
+`
+
+var _ = Describe("boot", func() {
+	var dir string
+	var oldKubeconfig string
+	// Don't bind any ports or talk to an API server.
+	args := []string{"--metrics-bind-address=0", "--health-probe-bind-address=0"}
+	opts := manager.Options{
+		MapperProvider: func(_ *rest.Config) (meta.RESTMapper, error) {
+			return meta.NewDefaultRESTMapper(nil), nil
+		},
+	}
+
+	BeforeEach(func() {
+		var err error
+		dir, err = os.MkdirTemp("", "boot")
+		Expect(err).ToNot(HaveOccurred())
+		oldKubeconfig = os.Getenv("KUBECONFIG")
+		os.Setenv("KUBECONFIG", filepath.Join(dir, "kubeconfig"))
+	})
+
+	AfterEach(func() {
+		os.Setenv("KUBECONFIG", oldKubeconfig)
+		os.RemoveAll(dir)
+	})
+
+	writeKubeconfig := func() {
+		Expect(os.WriteFile(filepath.Join(dir, "kubeconfig"), []byte(kubeconfig), 0644)).To(Succeed())
+	}
+
+	newFlags := func() *flag.FlagSet {
+		flags := flag.NewFlagSet("testing", flag.ContinueOnError)
+		flags.SetOutput(GinkgoWriter)
+		return flags
+	}
+
+	It("builds a manager with the APIs and controllers", func() {
+		writeKubeconfig()
+		gv := schema.GroupVersion{Group: "test.coderanger.net", Version: "v1"}
+		var controllerMgr ctrl.Manager
+		mgr, err := boot.New("testing").Options(opts).Flags(newFlags(), args).
+			API(func(s *runtime.Scheme) error {
+				s.AddKnownTypeWithName(gv.WithKind("Thing"), &runtime.Unknown{})
+				return nil
+			}).
+			Controller(func(m ctrl.Manager) error {
+				controllerMgr = m
+				return nil
+			}).
+			Build()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(controllerMgr).To(BeIdenticalTo(mgr))
+		Expect(mgr.GetScheme().Recognizes(gv.WithKind("Thing"))).To(BeTrue())
+		Expect(mgr.GetScheme().Recognizes(schema.GroupVersionKind{Version: "v1", Kind: "Pod"})).To(BeTrue())
+	})
+
+	It("returns an error without a Kubernetes config", func() {
+		_, err := boot.New("testing").Options(opts).Flags(newFlags(), args).Build()
+		Expect(err).To(MatchError(ContainSubstring("error getting Kubernetes config")))
+	})
+
+	It("returns controller errors", func() {
+		writeKubeconfig()
+		_, err := boot.New("testing").Options(opts).Flags(newFlags(), args).Controller(func(_ ctrl.Manager) error {
+			return errors.New("controller failed")
+		}).Build()
+		Expect(err).To(MatchError("error creating controller: controller failed"))
+	})
+
+	It("returns API errors", func() {
+		_, err := boot.New("testing").Options(opts).Flags(newFlags(), args).API(func(_ *runtime.Scheme) error {
+			return errors.New("API failed")
+		}).Build()
+		Expect(err).To(MatchError("error adding API to scheme: API failed"))
+	})
+
+	It("can build more than once", func() {
+		writeKubeconfig()
+		builder := boot.New("testing").Options(opts).Flags(newFlags(), args)
+		_, err := builder.Build()
+		Expect(err).ToNot(HaveOccurred())
+		_, err = builder.Build()
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("parses flags from the parent set", func() {
+		writeKubeconfig()
+		flags := newFlags()
+		custom := flags.String("custom", "", "")
+		_, err := boot.New("testing").Options(opts).Flags(flags, append([]string{"--custom=value"}, args...)).Build()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(*custom).To(Equal("value"))
+	})
+
+	Context("with environment variables", func() {
+		var oldMetrics, oldProbe string
+
+		BeforeEach(func() {
+			oldMetrics = os.Getenv("METRICS_BIND_ADDRESS")
+			oldProbe = os.Getenv("HEALTH_PROBE_BIND_ADDRESS")
+		})
+
+		AfterEach(func() {
+			os.Setenv("METRICS_BIND_ADDRESS", oldMetrics)
+			os.Setenv("HEALTH_PROBE_BIND_ADDRESS", oldProbe)
+		})
+
+		// The manager listens as it's created, so a bad address shows which won.
+		It("overrides the options", func() {
+			writeKubeconfig()
+			envOpts := opts
+			envOpts.MetricsBindAddress = "not-an-address"
+			envOpts.HealthProbeBindAddress = "not-an-address"
+			os.Setenv("METRICS_BIND_ADDRESS", "0")
+			os.Setenv("HEALTH_PROBE_BIND_ADDRESS", "0")
+			_, err := boot.New("testing").Options(envOpts).Flags(newFlags(), nil).Build()
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("is overridden by flags", func() {
+			writeKubeconfig()
+			os.Setenv("METRICS_BIND_ADDRESS", "not-an-address")
+			os.Setenv("HEALTH_PROBE_BIND_ADDRESS", "not-an-address")
+			_, err := boot.New("testing").Options(opts).Flags(newFlags(), args).Build()
+			Expect(err).ToNot(HaveOccurred())
+
+			_, err = boot.New("testing").Options(opts).Flags(newFlags(), nil).Build()
+			Expect(err).To(MatchError(ContainSubstring("error creating manager")))
+		})
+	})
+
+	It("returns flag errors", func() {
+		_, err := boot.New("testing").Options(opts).Flags(newFlags(), []string{"--not-a-flag"}).Build()
+		Expect(err).To(MatchError(ContainSubstring("error parsing flags")))
+	})
+})
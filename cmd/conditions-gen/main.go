@@ -0,0 +1,315 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// conditions-gen writes GetConditions() methods for API types, so
+// core.GetConditionsFor doesn't need its reflection fallback. Add this to a
+// file in the API package:
+//
+//	//go:generate go run github.com/coderanger/controller-utils/cmd/conditions-gen
+//
+// Types marked with +kubebuilder:object:root=true whose Status struct has a
+// Conditions field of []conditions.Condition or []metav1.Condition get a
+// method in zz_generated.conditions.go. Other types can opt in with
+// +controller-utils:conditions, and root types can opt out with
+// +controller-utils:conditions=false.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const outputFilename = "zz_generated.conditions.go"
+
+// The packages which define condition types we know how to return.
+var conditionPackages = map[string]bool{
+	"github.com/coderanger/controller-utils/conditions": true,
+	"k8s.io/apimachinery/pkg/apis/meta/v1":              true,
+}
+
+type generatedMethod struct {
+	typeName string
+	// Import path and local name of the package defining the condition type.
+	importPath string
+	importName string
+	condType   string
+}
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [dir...]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	dirs := flag.Args()
+	if len(dirs) == 0 {
+		dirs = []string{"."}
+	}
+	for _, dir := range dirs {
+		err := generateDir(dir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "conditions-gen: %s: %v\n", dir, err)
+			os.Exit(1)
+		}
+	}
+}
+
+func generateDir(dir string) error {
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, dir, func(info os.FileInfo) bool {
+		name := info.Name()
+		return !strings.HasSuffix(name, "_test.go") && name != outputFilename
+	}, parser.ParseComments)
+	if err != nil {
+		return err
+	}
+	for pkgName, pkg := range pkgs {
+		methods, err := findMethods(fset, pkg)
+		if err != nil {
+			return err
+		}
+		output := filepath.Join(dir, outputFilename)
+		if len(methods) == 0 {
+			// Clean up after types which no longer need it.
+			err := os.Remove(output)
+			if err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			continue
+		}
+		src, err := render(pkgName, methods)
+		if err != nil {
+			return err
+		}
+		err = os.WriteFile(output, src, 0644)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type typeInfo struct {
+	spec *ast.TypeSpec
+	doc  []*ast.CommentGroup
+	file *ast.File
+}
+
+func findMethods(fset *token.FileSet, pkg *ast.Package) ([]generatedMethod, error) {
+	// Index all the struct types in the package, and existing methods.
+	types := map[string]typeInfo{}
+	hasMethod := map[string]bool{}
+	for _, file := range pkg.Files {
+		for _, decl := range file.Decls {
+			if funcDecl, ok := decl.(*ast.FuncDecl); ok {
+				if funcDecl.Name.Name == "GetConditions" && funcDecl.Recv != nil {
+					hasMethod[receiverName(funcDecl.Recv)] = true
+				}
+				continue
+			}
+			genDecl, ok := decl.(*ast.GenDecl)
+			if !ok || genDecl.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range genDecl.Specs {
+				typeSpec := spec.(*ast.TypeSpec)
+				doc := []*ast.CommentGroup{typeSpec.Doc}
+				if typeSpec.Doc == nil && len(genDecl.Specs) == 1 {
+					doc = []*ast.CommentGroup{genDecl.Doc, detachedMarkers(fset, file, genDecl)}
+				}
+				types[typeSpec.Name.Name] = typeInfo{spec: typeSpec, doc: doc, file: file}
+			}
+		}
+	}
+
+	methods := []generatedMethod{}
+	for name, info := range types {
+		wanted, explicit := wantsConditions(info.doc)
+		if !wanted || hasMethod[name] {
+			continue
+		}
+		method, ok := conditionsMethod(name, info, types)
+		if !ok {
+			if explicit {
+				return nil, fmt.Errorf("type %s is marked +controller-utils:conditions but has no Status.Conditions field of a known condition type", name)
+			}
+			continue
+		}
+		methods = append(methods, method)
+	}
+	sort.Slice(methods, func(i, j int) bool { return methods[i].typeName < methods[j].typeName })
+	return methods, nil
+}
+
+func receiverName(recv *ast.FieldList) string {
+	if len(recv.List) == 0 {
+		return ""
+	}
+	expr := recv.List[0].Type
+	if star, ok := expr.(*ast.StarExpr); ok {
+		expr = star.X
+	}
+	if ident, ok := expr.(*ast.Ident); ok {
+		return ident.Name
+	}
+	return ""
+}
+
+// Kubebuilder style puts markers in their own comment one blank line above
+// the type, where go/parser doesn't treat them as the doc comment.
+func detachedMarkers(fset *token.FileSet, file *ast.File, decl *ast.GenDecl) *ast.CommentGroup {
+	declLine := fset.Position(decl.Pos()).Line
+	if decl.Doc != nil {
+		declLine = fset.Position(decl.Doc.Pos()).Line
+	}
+	for _, group := range file.Comments {
+		if fset.Position(group.End()).Line == declLine-2 {
+			return group
+		}
+	}
+	return nil
+}
+
+// Check the markers on a type. Returns if it should get a method, and if
+// that was explicitly requested.
+func wantsConditions(docs []*ast.CommentGroup) (bool, bool) {
+	root := false
+	for _, doc := range docs {
+		if doc == nil {
+			continue
+		}
+		for _, comment := range doc.List {
+			text := strings.TrimSpace(strings.TrimPrefix(comment.Text, "//"))
+			switch text {
+			case "+controller-utils:conditions", "+controller-utils:conditions=true":
+				return true, true
+			case "+controller-utils:conditions=false":
+				return false, true
+			case "+kubebuilder:object:root=true", "+kubebuilder:object:root":
+				root = true
+			}
+		}
+	}
+	return root, false
+}
+
+// Find the Status.Conditions field and its type.
+func conditionsMethod(name string, info typeInfo, types map[string]typeInfo) (generatedMethod, bool) {
+	statusType, ok := fieldType(info.spec, "Status")
+	if !ok {
+		return generatedMethod{}, false
+	}
+	statusIdent, ok := statusType.(*ast.Ident)
+	if !ok {
+		return generatedMethod{}, false
+	}
+	statusInfo, ok := types[statusIdent.Name]
+	if !ok {
+		return generatedMethod{}, false
+	}
+	condsType, ok := fieldType(statusInfo.spec, "Conditions")
+	if !ok {
+		return generatedMethod{}, false
+	}
+	array, ok := condsType.(*ast.ArrayType)
+	if !ok || array.Len != nil {
+		return generatedMethod{}, false
+	}
+	selector, ok := array.Elt.(*ast.SelectorExpr)
+	if !ok || selector.Sel.Name != "Condition" {
+		return generatedMethod{}, false
+	}
+	pkgIdent, ok := selector.X.(*ast.Ident)
+	if !ok {
+		return generatedMethod{}, false
+	}
+	importPath, ok := resolveImport(statusInfo.file, pkgIdent.Name)
+	if !ok || !conditionPackages[importPath] {
+		return generatedMethod{}, false
+	}
+	return generatedMethod{typeName: name, importPath: importPath, importName: pkgIdent.Name, condType: "Condition"}, true
+}
+
+func fieldType(spec *ast.TypeSpec, fieldName string) (ast.Expr, bool) {
+	structType, ok := spec.Type.(*ast.StructType)
+	if !ok {
+		return nil, false
+	}
+	for _, field := range structType.Fields.List {
+		for _, ident := range field.Names {
+			if ident.Name == fieldName {
+				return field.Type, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// Find the import path for a package name used in a file.
+func resolveImport(file *ast.File, name string) (string, bool) {
+	for _, imp := range file.Imports {
+		path, err := strconv.Unquote(imp.Path.Value)
+		if err != nil {
+			continue
+		}
+		if imp.Name != nil {
+			if imp.Name.Name == name {
+				return path, true
+			}
+			continue
+		}
+		if filepath.Base(path) == name {
+			return path, true
+		}
+	}
+	return "", false
+}
+
+func render(pkgName string, methods []generatedMethod) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "// Code generated by conditions-gen. DO NOT EDIT.\n\npackage %s\n\n", pkgName)
+
+	imports := map[string]string{}
+	for _, method := range methods {
+		imports[method.importPath] = method.importName
+	}
+	paths := []string{}
+	for path := range imports {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	buf.WriteString("import (\n")
+	for _, path := range paths {
+		fmt.Fprintf(buf, "\t%s %q\n", imports[path], path)
+	}
+	buf.WriteString(")\n")
+
+	for _, method := range methods {
+		fmt.Fprintf(buf, "\n// GetConditions returns a pointer to the status conditions, for core.GetConditionsFor.\n")
+		fmt.Fprintf(buf, "func (in *%s) GetConditions() *[]%s.%s {\n\treturn &in.Status.Conditions\n}\n", method.typeName, method.importName, method.condType)
+	}
+	return format.Source(buf.Bytes())
+}
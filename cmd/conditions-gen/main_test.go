@@ -0,0 +1,71 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = ginkgo.Describe("conditions-gen", func() {
+	var dir string
+
+	ginkgo.BeforeEach(func() {
+		var err error
+		dir, err = os.MkdirTemp("", "conditions-gen")
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	ginkgo.AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	// Copy a package from testdata into the scratch directory and generate it.
+	generate := func(name string) error {
+		src, err := os.ReadFile(filepath.Join("testdata", name, "types.go"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(os.WriteFile(filepath.Join(dir, "types.go"), src, 0644)).To(Succeed())
+		return generateDir(dir)
+	}
+
+	ginkgo.It("matches the golden output", func() {
+		Expect(generate("types")).To(Succeed())
+		out, err := os.ReadFile(filepath.Join(dir, outputFilename))
+		Expect(err).ToNot(HaveOccurred())
+		golden := filepath.Join("testdata", "types", outputFilename+".golden")
+		if os.Getenv("UPDATE_GOLDEN") == "true" {
+			Expect(os.WriteFile(golden, out, 0644)).To(Succeed())
+		}
+		expected, err := os.ReadFile(golden)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(out)).To(Equal(string(expected)))
+	})
+
+	ginkgo.It("removes stale output when nothing needs a method", func() {
+		Expect(os.WriteFile(filepath.Join(dir, outputFilename), []byte("package v1\n"), 0644)).To(Succeed())
+		Expect(generate("none")).To(Succeed())
+		_, err := os.Stat(filepath.Join(dir, outputFilename))
+		Expect(os.IsNotExist(err)).To(BeTrue())
+	})
+
+	ginkgo.It("rejects an explicit marker without conditions", func() {
+		Expect(generate("badmarker")).To(MatchError(ContainSubstring("type Widget is marked +controller-utils:conditions")))
+	})
+})
@@ -0,0 +1,10 @@
+package v1
+
+// +controller-utils:conditions
+type Widget struct {
+	Spec WidgetSpec `json:"spec,omitempty"`
+}
+
+type WidgetSpec struct {
+	Field string `json:"field,omitempty"`
+}
@@ -0,0 +1,10 @@
+package v1
+
+// +kubebuilder:object:root=true
+type Widget struct {
+	Spec WidgetSpec `json:"spec,omitempty"`
+}
+
+type WidgetSpec struct {
+	Field string `json:"field,omitempty"`
+}
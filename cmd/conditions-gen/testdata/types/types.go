@@ -0,0 +1,56 @@
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/coderanger/controller-utils/conditions"
+)
+
+type WidgetStatus struct {
+	Conditions []conditions.Condition `json:"conditions,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+type Widget struct {
+	Status WidgetStatus `json:"status,omitempty"`
+}
+
+type GadgetStatus struct {
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+type Gadget struct {
+	Status GadgetStatus `json:"status,omitempty"`
+}
+
+// Not a root type, but asked for it.
+// +controller-utils:conditions
+type Embedded struct {
+	Status WidgetStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +controller-utils:conditions=false
+type OptedOut struct {
+	Status WidgetStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+type Handwritten struct {
+	Status WidgetStatus `json:"status,omitempty"`
+}
+
+func (in *Handwritten) GetConditions() *[]conditions.Condition {
+	return &in.Status.Conditions
+}
+
+type PlainStatus struct {
+	Conditions []string `json:"conditions,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+type Plain struct {
+	Status PlainStatus `json:"status,omitempty"`
+}
@@ -0,0 +1,100 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package components
+
+import (
+	"github.com/pkg/errors"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	"github.com/coderanger/controller-utils/core"
+)
+
+type absentComponent struct {
+	gvk       schema.GroupVersionKind
+	name      string
+	namespace string
+}
+
+// Create an Absent component. It makes sure an object doesn't exist,
+// deleting it if it's owned by the reconcile object, for cleaning up after
+// children that are no longer needed. The name is a template rendered like a
+// TemplateComponent, if it renders to an empty string nothing is done.
+func NewAbsentComponent(gvk schema.GroupVersionKind, name string) *absentComponent {
+	return &absentComponent{gvk: gvk, name: name}
+}
+
+// Look in a different namespace. Also a template, defaults to the namespace
+// of the object.
+func (comp *absentComponent) WithNamespace(namespace string) *absentComponent {
+	comp.namespace = namespace
+	return comp
+}
+
+func (comp *absentComponent) Setup(_ *core.Context, bldr *ctrl.Builder) error {
+	// Watch so anything recreated gets cleaned up again.
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(comp.gvk)
+	bldr.Owns(obj)
+	return nil
+}
+
+func (comp *absentComponent) Reconcile(ctx *core.Context) (core.Result, error) {
+	data := newTemplateData(ctx)
+	name, err := renderString(ctx, comp.name, data)
+	if err != nil {
+		return core.Result{}, errors.Wrap(err, "error rendering name")
+	}
+	if name == "" {
+		return core.Result{}, nil
+	}
+	namespace := ctx.Object.GetNamespace()
+	if comp.namespace != "" {
+		namespace, err = renderString(ctx, comp.namespace, data)
+		if err != nil {
+			return core.Result{}, errors.Wrap(err, "error rendering namespace")
+		}
+	}
+
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(comp.gvk)
+	err = ctx.Client.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, obj)
+	if err != nil {
+		if kerrors.IsNotFound(err) {
+			return core.Result{}, nil
+		}
+		return core.Result{}, errors.Wrapf(err, "error getting %s %s/%s", comp.gvk.Kind, namespace, name)
+	}
+	if obj.GetDeletionTimestamp() != nil {
+		// Already on its way out, don't delete (and report it) again.
+		return core.Result{}, nil
+	}
+	found, owned, err := deleteIfOwned(ctx, obj, metav1.DeletePropagationBackground, false)
+	if err != nil {
+		return core.Result{}, err
+	}
+	if found && !owned {
+		ctx.Log.Info("Not deleting object owned by someone else", "kind", comp.gvk.Kind, "name", name, "namespace", namespace)
+	} else if found {
+		ctx.Events.Eventf(ctx.Object, "Normal", "Deleted", "Deleted %s %s", comp.gvk.Kind, name)
+	}
+	return core.Result{}, nil
+}
@@ -0,0 +1,120 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package components
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	"github.com/coderanger/controller-utils/tests"
+)
+
+var _ = Describe("Absent component", func() {
+	var helper *tests.FunctionalHelper
+	var obj *TestObject
+	configMapGVK := schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}
+
+	BeforeEach(func() {
+		obj = &TestObject{
+			ObjectMeta: metav1.ObjectMeta{Name: "testing"},
+		}
+	})
+
+	AfterEach(func() {
+		if helper != nil {
+			helper.MustStop()
+		}
+		helper = nil
+	})
+
+	It("deletes an owned object", func() {
+		comp := NewAbsentComponent(configMapGVK, "{{ .Object.Name }}-legacy")
+		afterComp := NewReadyStatusComponent()
+		helper = startTestController(comp, afterComp)
+		c := helper.TestClient
+
+		c.Create(obj)
+		c.EventuallyGetName(obj.Name, obj, c.EventuallyCondition("Ready", "True"))
+
+		configMap := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "testing-legacy"}}
+		err := controllerutil.SetControllerReference(obj, configMap, helper.Client.Scheme())
+		Expect(err).ToNot(HaveOccurred())
+		c.Create(configMap)
+
+		Eventually(func() bool {
+			err := helper.Client.Get(context.Background(), types.NamespacedName{Name: "testing-legacy", Namespace: helper.Namespace}, configMap)
+			return kerrors.IsNotFound(err)
+		}).Should(BeTrue())
+	})
+
+	It("reports the delete once for an object stuck terminating", func() {
+		comp := NewAbsentComponent(configMapGVK, "{{ .Object.Name }}-legacy")
+		helper = startTestController(comp)
+		c := helper.TestClient
+
+		c.Create(obj)
+		configMap := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "testing-legacy", Finalizers: []string{"testing/block"}}}
+		err := controllerutil.SetControllerReference(obj, configMap, helper.Client.Scheme())
+		Expect(err).ToNot(HaveOccurred())
+		c.Create(configMap)
+		c.EventuallyGetName("testing-legacy", configMap, c.EventuallyValue(BeTrue(), func(o client.Object) (interface{}, error) {
+			return o.GetDeletionTimestamp() != nil, nil
+		}))
+
+		deletedEvents := func() int32 {
+			count := int32(0)
+			for _, event := range helper.Events(obj) {
+				if event.Reason == "Deleted" {
+					count += event.Count
+				}
+			}
+			return count
+		}
+		Eventually(deletedEvents).Should(Equal(int32(1)))
+		c.UpdateWithRetry(obj, func() {
+			obj.Spec.Field = "again"
+		})
+		Consistently(deletedEvents).Should(Equal(int32(1)))
+
+		c.UpdateWithRetry(configMap, func() {
+			configMap.Finalizers = nil
+		})
+	})
+
+	It("does not delete an unowned object", func() {
+		comp := NewAbsentComponent(configMapGVK, "{{ .Object.Name }}-legacy")
+		afterComp := NewReadyStatusComponent()
+		helper = startTestController(comp, afterComp)
+		c := helper.TestClient
+
+		configMap := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "testing-legacy"}}
+		c.Create(configMap)
+		c.Create(obj)
+		c.EventuallyGetName(obj.Name, obj, c.EventuallyCondition("Ready", "True"))
+
+		c.ConsistentlyGetName("testing-legacy", configMap)
+	})
+})
@@ -0,0 +1,117 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package components
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/coderanger/controller-utils/core"
+)
+
+type cascadeDeleteComponent struct {
+	gvks         []schema.GroupVersionKind
+	selector     map[string]string
+	pollInterval time.Duration
+}
+
+// Create a CascadeDelete component. When the object is deleted, it deletes
+// all objects of the given kinds that it owns and waits for them to be fully
+// gone, including their own finalizers, before letting finalization
+// continue. Later finalizers don't run until it's done, so put it before
+// anything the owned objects depend on.
+func NewCascadeDeleteComponent(gvks ...schema.GroupVersionKind) *cascadeDeleteComponent {
+	return &cascadeDeleteComponent{gvks: gvks, pollInterval: 5 * time.Second}
+}
+
+// Only delete objects matching these labels.
+func (comp *cascadeDeleteComponent) WithSelector(selector map[string]string) *cascadeDeleteComponent {
+	comp.selector = selector
+	return comp
+}
+
+// How often to check while waiting, in case an event is missed. Defaults to 5 seconds.
+func (comp *cascadeDeleteComponent) WithPollInterval(interval time.Duration) *cascadeDeleteComponent {
+	comp.pollInterval = interval
+	return comp
+}
+
+func (comp *cascadeDeleteComponent) Setup(_ *core.Context, bldr *ctrl.Builder) error {
+	// Watch so we notice as soon as things are gone.
+	for _, gvk := range comp.gvks {
+		obj := &unstructured.Unstructured{}
+		obj.SetGroupVersionKind(gvk)
+		bldr.Owns(obj)
+	}
+	return nil
+}
+
+func (comp *cascadeDeleteComponent) Reconcile(_ *core.Context) (core.Result, error) {
+	// Nothing to do until deletion, this just gets the finalizer added.
+	return core.Result{}, nil
+}
+
+func (comp *cascadeDeleteComponent) Finalize(ctx *core.Context) (core.Result, bool, error) {
+	remaining := 0
+	for _, gvk := range comp.gvks {
+		list := &unstructured.UnstructuredList{}
+		list.SetGroupVersionKind(gvk.GroupVersion().WithKind(gvk.Kind + "List"))
+		err := ctx.Client.List(ctx, list, client.InNamespace(ctx.Object.GetNamespace()), client.MatchingLabels(comp.selector))
+		if err != nil {
+			return core.Result{}, false, errors.Wrapf(err, "error listing %s", gvk.Kind)
+		}
+		for i := range list.Items {
+			child := &list.Items[i]
+			if !isOwnedBy(child, ctx.Object) {
+				continue
+			}
+			remaining++
+			if child.GetDeletionTimestamp() != nil {
+				// Already going, just waiting on it.
+				continue
+			}
+			propagation := metav1.DeletePropagationForeground
+			err = ctx.Client.Delete(ctx, child, &client.DeleteOptions{PropagationPolicy: &propagation})
+			if err != nil && !kerrors.IsNotFound(err) {
+				return core.Result{}, false, errors.Wrapf(err, "error deleting %s %s", gvk.Kind, child.GetName())
+			}
+			ctx.Log.Info("Deleting owned object", "kind", gvk.Kind, "name", child.GetName())
+		}
+	}
+	if remaining != 0 {
+		ctx.Log.V(1).Info("Waiting for owned objects to be deleted", "remaining", remaining)
+		return core.Result{RequeueAfter: comp.pollInterval, SkipRemaining: true}, false, nil
+	}
+	return core.Result{}, true, nil
+}
+
+// Check if an object has an owner reference pointing at owner.
+func isOwnedBy(obj client.Object, owner client.Object) bool {
+	for _, ref := range obj.GetOwnerReferences() {
+		if ref.UID == owner.GetUID() {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,84 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package components
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	"github.com/coderanger/controller-utils/tests"
+)
+
+var _ = Describe("CascadeDelete component", func() {
+	var helper *tests.FunctionalHelper
+	var obj *TestObject
+
+	BeforeEach(func() {
+		obj = &TestObject{
+			ObjectMeta: metav1.ObjectMeta{Name: "testing"},
+		}
+	})
+
+	AfterEach(func() {
+		if helper != nil {
+			helper.MustStop()
+		}
+		helper = nil
+	})
+
+	It("waits for owned objects to be gone", func() {
+		comp := NewCascadeDeleteComponent(schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}).WithPollInterval(time.Second)
+		helper = startTestController(comp)
+		c := helper.TestClient
+
+		c.Create(obj)
+		c.EventuallyGetName(obj.Name, obj, c.EventuallyValue(Not(BeEmpty()), func(obj client.Object) (interface{}, error) {
+			return obj.GetFinalizers(), nil
+		}))
+
+		configMap := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "testing-consumer", Finalizers: []string{"test/hold"}}}
+		err := controllerutil.SetControllerReference(obj, configMap, helper.Client.Scheme())
+		Expect(err).ToNot(HaveOccurred())
+		c.Create(configMap)
+
+		c.Delete(obj)
+		c.EventuallyGetName("testing-consumer", configMap, c.EventuallyValue(Not(BeNil()), func(obj client.Object) (interface{}, error) {
+			return obj.GetDeletionTimestamp(), nil
+		}))
+		Consistently(func() error {
+			return helper.Client.Get(context.Background(), types.NamespacedName{Name: obj.Name, Namespace: helper.Namespace}, obj)
+		}, "2s").Should(Succeed())
+
+		configMapClean := configMap.DeepCopy()
+		configMap.Finalizers = nil
+		c.Patch(configMap, client.MergeFrom(configMapClean))
+		Eventually(func() bool {
+			err := helper.Client.Get(context.Background(), types.NamespacedName{Name: obj.Name, Namespace: helper.Namespace}, obj)
+			return kerrors.IsNotFound(err)
+		}).Should(BeTrue())
+	})
+})
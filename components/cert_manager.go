@@ -0,0 +1,101 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package components
+
+import (
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	"github.com/coderanger/controller-utils/core"
+)
+
+type certManagerComponent struct {
+	template  *templateComponent
+	available bool
+}
+
+// Create a CertManager component. It applies a cert-manager Certificate from
+// a template, waits for it to be Ready, and then sets ctx.Data["caBundle"]
+// and ctx.Data["certificateSecretName"] the same as a Certificate component.
+// If cert-manager isn't installed when the controller starts, the condition
+// is set to False with reason CertManagerNotInstalled rather than failing.
+func NewCertManagerComponent(template string, conditionType string) *certManagerComponent {
+	return &certManagerComponent{template: NewTemplateComponent(template, conditionType)}
+}
+
+func (comp *certManagerComponent) GetReadyCondition() string {
+	return comp.template.GetReadyCondition()
+}
+
+func (comp *certManagerComponent) Setup(ctx *core.Context, bldr *ctrl.Builder) error {
+	// Render with a fake, blank object just to find the object type.
+	obj, err := comp.template.renderTemplate(ctx, true)
+	if err != nil {
+		return errors.Wrap(err, "error rendering setup template")
+	}
+	gvk := obj.GetObjectKind().GroupVersionKind()
+	available, err := apiAvailable(ctx, gvk)
+	if err != nil {
+		return err
+	}
+	if !available {
+		// Can't watch an API that doesn't exist, so don't try.
+		ctx.Log.Info("cert-manager is not installed, certificates will not be created", "gvk", gvk)
+		return nil
+	}
+	comp.available = true
+	return comp.template.Setup(ctx, bldr)
+}
+
+func (comp *certManagerComponent) Reconcile(ctx *core.Context) (core.Result, error) {
+	if !comp.available {
+		ctx.Conditions.SetFalse(comp.template.conditionType, "CertManagerNotInstalled", "cert-manager is not installed")
+		return core.Result{}, nil
+	}
+	res, err := comp.template.Reconcile(ctx)
+	if err != nil {
+		return res, err
+	}
+
+	obj, err := comp.template.renderTemplate(ctx, true)
+	if err != nil {
+		return core.Result{}, errors.Wrap(err, "error rendering template")
+	}
+	secretName, _, _ := unstructured.NestedString(obj.(*unstructured.Unstructured).Object, "spec", "secretName")
+	if secretName == "" {
+		return res, nil
+	}
+	secret := &corev1.Secret{}
+	err = ctx.Client.Get(ctx, types.NamespacedName{Name: secretName, Namespace: ctx.Object.GetNamespace()}, secret)
+	if err != nil {
+		if kerrors.IsNotFound(err) {
+			// Not issued yet.
+			return res, nil
+		}
+		return core.Result{}, errors.Wrapf(err, "error getting secret %s", secretName)
+	}
+	// Store the values into context for use by later components.
+	ctx.Data["certificateSecretName"] = secretName
+	if caCert, ok := secret.Data[CA_CERT_KEY]; ok {
+		ctx.Data["caBundle"] = string(caCert)
+	}
+	return res, nil
+}
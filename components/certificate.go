@@ -0,0 +1,452 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package components
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	"github.com/coderanger/controller-utils/core"
+	"github.com/coderanger/controller-utils/predicates"
+)
+
+const CA_CERT_KEY = "ca.crt"
+const CA_KEY_KEY = "ca.key"
+
+type certificateComponent struct {
+	secretName   string
+	dnsNames     []string
+	caBundleName string
+	validity     time.Duration
+	caValidity   time.Duration
+	renewBefore  time.Duration
+}
+
+// Create a Certificate component. It generates a self-signed CA and a leaf
+// certificate signed by it, stored in a kubernetes.io/tls Secret along with the
+// CA. The DNS names are templates rendered with the same data as a
+// TemplateComponent, e.g. `{{ .Object.Name }}.{{ .Object.Namespace }}.svc`.
+// The secret name may contain a %s to be replaced with the object name.
+func NewCertificateComponent(secretName string, dnsNames ...string) *certificateComponent {
+	return &certificateComponent{
+		secretName:  secretName,
+		dnsNames:    dnsNames,
+		validity:    365 * 24 * time.Hour,
+		caValidity:  10 * 365 * 24 * time.Hour,
+		renewBefore: 30 * 24 * time.Hour,
+	}
+}
+
+// Also publish the CA certificate into a ConfigMap with this name, under the
+// ca.crt key. The name may contain a %s to be replaced with the object name.
+func (comp *certificateComponent) WithCABundle(configMapName string) *certificateComponent {
+	comp.caBundleName = configMapName
+	return comp
+}
+
+// Set how long the leaf and CA certificates are valid for.
+func (comp *certificateComponent) WithValidity(leaf time.Duration, ca time.Duration) *certificateComponent {
+	comp.validity = leaf
+	comp.caValidity = ca
+	return comp
+}
+
+// Set how long before expiration to rotate certificates.
+func (comp *certificateComponent) WithRenewBefore(renewBefore time.Duration) *certificateComponent {
+	comp.renewBefore = renewBefore
+	return comp
+}
+
+func (comp *certificateComponent) GetReadyCondition() string {
+	return "CertificateReady"
+}
+
+// A stale read would mean generating a new CA and certificate, so always read
+// secrets from the API server.
+func (comp *certificateComponent) GetUncachedTypes() []client.Object {
+	return []client.Object{&corev1.Secret{}}
+}
+
+func (comp *certificateComponent) Setup(_ *core.Context, bldr *ctrl.Builder) error {
+	// Certificates would never be fresh, so we'd regenerate them on every reconcile.
+	if comp.renewBefore >= comp.validity || comp.renewBefore >= comp.caValidity {
+		return errors.Errorf("certificate renewBefore %s must be shorter than the validity %s and CA validity %s", comp.renewBefore, comp.validity, comp.caValidity)
+	}
+	secretPred, err := predicates.SecretField([]string{corev1.TLSCertKey, corev1.TLSPrivateKeyKey, CA_CERT_KEY, CA_KEY_KEY})
+	if err != nil {
+		return err
+	}
+	bldr.Owns(&corev1.Secret{}, builder.WithPredicates(secretPred))
+	if comp.caBundleName != "" {
+		bldr.Owns(&corev1.ConfigMap{})
+	}
+	return nil
+}
+
+func (comp *certificateComponent) Reconcile(ctx *core.Context) (core.Result, error) {
+	now := ctx.Now()
+	secretName := types.NamespacedName{
+		Name:      comp.formatName(comp.secretName, ctx),
+		Namespace: ctx.Object.GetNamespace(),
+	}
+
+	dnsNames := make([]string, 0, len(comp.dnsNames))
+	for _, dnsName := range comp.dnsNames {
+		rendered, err := renderString(ctx, dnsName, newTemplateData(ctx))
+		if err != nil {
+			return core.Result{}, errors.Wrapf(err, "error rendering DNS name %s", dnsName)
+		}
+		if rendered != "" {
+			dnsNames = append(dnsNames, rendered)
+		}
+	}
+	sort.Strings(dnsNames)
+
+	existingSecret := &corev1.Secret{}
+	// Secrets are read uncached to avoid race conditions, see GetUncachedTypes.
+	err := ctx.Client.Get(ctx, secretName, existingSecret)
+	if err != nil && !kerrors.IsNotFound(err) {
+		return core.Result{}, errors.Wrapf(err, "error getting secret %s", secretName)
+	}
+
+	caCertPEM := existingSecret.Data[CA_CERT_KEY]
+	caKeyPEM := existingSecret.Data[CA_KEY_KEY]
+	certPEM := existingSecret.Data[corev1.TLSCertKey]
+	keyPEM := existingSecret.Data[corev1.TLSPrivateKeyKey]
+
+	changed := false
+	caCert, caKey, err := parseCertificateAndKey(caCertPEM, caKeyPEM)
+	if err != nil || !comp.certificateFresh(caCert, now) {
+		caCertPEM, caKeyPEM, err = comp.generateCA(ctx, now)
+		if err != nil {
+			return core.Result{}, errors.Wrap(err, "error generating CA")
+		}
+		caCert, caKey, err = parseCertificateAndKey(caCertPEM, caKeyPEM)
+		if err != nil {
+			return core.Result{}, errors.Wrap(err, "error parsing generated CA")
+		}
+		ctx.Events.Eventf(ctx.Object, "Normal", "GeneratedCertificateAuthority", "Generated a new certificate authority in secret %s", secretName.Name)
+		changed = true
+	}
+
+	cert, _, err := parseCertificateAndKey(certPEM, keyPEM)
+	if changed || err != nil || !comp.certificateFresh(cert, now) || !comp.certificateMatches(cert, caCert, dnsNames) {
+		certPEM, keyPEM, err = comp.generateLeaf(ctx, caCert, caKey, dnsNames, now)
+		if err != nil {
+			return core.Result{}, errors.Wrap(err, "error generating certificate")
+		}
+		cert, _, err = parseCertificateAndKey(certPEM, keyPEM)
+		if err != nil {
+			return core.Result{}, errors.Wrap(err, "error parsing generated certificate")
+		}
+		ctx.Events.Eventf(ctx.Object, "Normal", "GeneratedCertificate", "Generated a new certificate in secret %s", secretName.Name)
+		changed = true
+	}
+
+	if changed {
+		secret := &unstructured.Unstructured{
+			Object: map[string]interface{}{
+				"type": string(corev1.SecretTypeTLS),
+				"data": map[string][]byte{
+					corev1.TLSCertKey:       certPEM,
+					corev1.TLSPrivateKeyKey: keyPEM,
+					CA_CERT_KEY:             caCertPEM,
+					CA_KEY_KEY:              caKeyPEM,
+				},
+			},
+		}
+		secret.SetName(secretName.Name)
+		secret.SetNamespace(secretName.Namespace)
+		secret.SetGroupVersionKind(schema.GroupVersionKind{Group: "", Version: "v1", Kind: "Secret"})
+		err = comp.apply(ctx, secret)
+		if err != nil {
+			return core.Result{}, errors.Wrapf(err, "error applying secret %s", secretName)
+		}
+	}
+
+	caBundle := caCertPEM
+	var pruneAt time.Time
+	if comp.caBundleName != "" {
+		caBundle, pruneAt, err = comp.reconcileCABundle(ctx, caCert, caCertPEM, now)
+		if err != nil {
+			return core.Result{}, err
+		}
+	}
+
+	// Store the values into context for use by later components.
+	ctx.Data["caBundle"] = string(caBundle)
+	ctx.Data["certificateSecretName"] = secretName.Name
+
+	ctx.Conditions.SetfTrue(comp.GetReadyCondition(), "CertificateValid", "Certificate in secret %s is valid until %s", secretName.Name, cert.NotAfter.UTC().Format(time.RFC3339))
+
+	// Come back when it's time to rotate.
+	renewAt := cert.NotAfter.Add(-comp.renewBefore)
+	if caCert.NotAfter.Add(-comp.renewBefore).Before(renewAt) {
+		renewAt = caCert.NotAfter.Add(-comp.renewBefore)
+	}
+	// Also come back to drop a previous CA from the bundle once it expires.
+	if !pruneAt.IsZero() && pruneAt.Before(renewAt) {
+		renewAt = pruneAt
+	}
+	requeueAfter := renewAt.Sub(now)
+	if requeueAfter < time.Second {
+		requeueAfter = time.Second
+	}
+	return core.Result{RequeueAfter: requeueAfter}, nil
+}
+
+// Publish the CA bundle. When the CA rotates, the previous CA stays in the
+// bundle until it expires so clients keep trusting certificates it signed
+// while they are being replaced. Returns the bundle and when the next previous
+// CA expires, or the zero time if there are none.
+func (comp *certificateComponent) reconcileCABundle(ctx *core.Context, caCert *x509.Certificate, caCertPEM []byte, now time.Time) ([]byte, time.Time, error) {
+	configMapName := types.NamespacedName{
+		Name:      comp.formatName(comp.caBundleName, ctx),
+		Namespace: ctx.Object.GetNamespace(),
+	}
+	existingConfigMap := &corev1.ConfigMap{}
+	err := ctx.Client.Get(ctx, configMapName, existingConfigMap)
+	if err != nil && !kerrors.IsNotFound(err) {
+		return nil, time.Time{}, errors.Wrapf(err, "error getting configmap %s", configMapName)
+	}
+
+	bundle := append([]byte{}, caCertPEM...)
+	var pruneAt time.Time
+	rest := []byte(existingConfigMap.Data[CA_CERT_KEY])
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		oldCert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil || bytes.Equal(oldCert.Raw, caCert.Raw) || !now.Before(oldCert.NotAfter) {
+			continue
+		}
+		bundle = append(bundle, pem.EncodeToMemory(block)...)
+		if pruneAt.IsZero() || oldCert.NotAfter.Before(pruneAt) {
+			pruneAt = oldCert.NotAfter
+		}
+	}
+	if existingConfigMap.Data[CA_CERT_KEY] == string(bundle) {
+		return bundle, pruneAt, nil
+	}
+
+	configMap := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"data": map[string]interface{}{
+				CA_CERT_KEY: string(bundle),
+			},
+		},
+	}
+	configMap.SetName(configMapName.Name)
+	configMap.SetNamespace(configMapName.Namespace)
+	configMap.SetGroupVersionKind(schema.GroupVersionKind{Group: "", Version: "v1", Kind: "ConfigMap"})
+	err = comp.apply(ctx, configMap)
+	if err != nil {
+		return nil, time.Time{}, errors.Wrapf(err, "error applying configmap %s", configMapName)
+	}
+	return bundle, pruneAt, nil
+}
+
+func (comp *certificateComponent) apply(ctx *core.Context, obj client.Object) error {
+	err := controllerutil.SetControllerReference(ctx.Object, obj, ctx.Scheme)
+	if err != nil {
+		return errors.Wrap(err, "error setting owner reference")
+	}
+
+	force := true // Sigh *bool.
+	return ctx.Client.Patch(ctx, obj, client.Apply, &client.PatchOptions{Force: &force, FieldManager: ctx.FieldManager})
+}
+
+func (comp *certificateComponent) formatName(name string, ctx *core.Context) string {
+	if strings.Contains(name, "%s") {
+		return fmt.Sprintf(name, ctx.Object.GetName())
+	}
+	return name
+}
+
+// Check if a certificate exists and isn't close to expiring.
+func (comp *certificateComponent) certificateFresh(cert *x509.Certificate, now time.Time) bool {
+	return cert != nil && now.After(cert.NotBefore) && now.Add(comp.renewBefore).Before(cert.NotAfter)
+}
+
+// Check if a leaf certificate was signed by the CA and has the requested names.
+func (comp *certificateComponent) certificateMatches(cert *x509.Certificate, caCert *x509.Certificate, names []string) bool {
+	if cert == nil || caCert == nil || cert.CheckSignatureFrom(caCert) != nil {
+		return false
+	}
+	dnsNames, ips := splitSubjectAltNames(names)
+	certNames := append([]string{}, cert.DNSNames...)
+	sort.Strings(certNames)
+	sort.Strings(dnsNames)
+	if len(certNames) != len(dnsNames) {
+		return false
+	}
+	for i := range certNames {
+		if certNames[i] != dnsNames[i] {
+			return false
+		}
+	}
+	if len(cert.IPAddresses) != len(ips) {
+		return false
+	}
+	for _, ip := range ips {
+		found := false
+		for _, certIP := range cert.IPAddresses {
+			if certIP.Equal(ip) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// Split requested names into DNS names and IP addresses.
+func splitSubjectAltNames(names []string) ([]string, []net.IP) {
+	dnsNames := []string{}
+	ips := []net.IP{}
+	for _, name := range names {
+		ip := net.ParseIP(name)
+		if ip != nil {
+			ips = append(ips, ip)
+		} else {
+			dnsNames = append(dnsNames, name)
+		}
+	}
+	return dnsNames, ips
+}
+
+func (comp *certificateComponent) generateCA(ctx *core.Context, now time.Time) ([]byte, []byte, error) {
+	template := &x509.Certificate{
+		Subject: pkix.Name{
+			CommonName:   fmt.Sprintf("%s-ca", ctx.Object.GetName()),
+			Organization: []string{ctx.Object.GetNamespace()},
+		},
+		NotBefore:             now.Add(-5 * time.Minute),
+		NotAfter:              now.Add(comp.caValidity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	return generateCertificate(template, nil, nil)
+}
+
+func (comp *certificateComponent) generateLeaf(ctx *core.Context, caCert *x509.Certificate, caKey crypto.Signer, dnsNames []string, now time.Time) ([]byte, []byte, error) {
+	commonName := ctx.Object.GetName()
+	if len(dnsNames) > 0 {
+		commonName = dnsNames[0]
+	}
+	template := &x509.Certificate{
+		Subject: pkix.Name{
+			CommonName: commonName,
+		},
+		NotBefore:   now.Add(-5 * time.Minute),
+		NotAfter:    now.Add(comp.validity),
+		KeyUsage:    x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	template.DNSNames, template.IPAddresses = splitSubjectAltNames(dnsNames)
+	return generateCertificate(template, caCert, caKey)
+}
+
+// Generate a new key and certificate, signed by the parent or self-signed if parent is nil.
+func generateCertificate(template *x509.Certificate, parent *x509.Certificate, parentKey crypto.Signer) ([]byte, []byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "error generating key")
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "error generating serial number")
+	}
+	template.SerialNumber = serial
+	if parent == nil {
+		parent = template
+		parentKey = key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, key.Public(), parentKey)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "error creating certificate")
+	}
+	keyDer, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "error encoding key")
+	}
+	certBuf := bytes.Buffer{}
+	err = pem.Encode(&certBuf, &pem.Block{Type: "CERTIFICATE", Bytes: der})
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "error encoding certificate")
+	}
+	keyBuf := bytes.Buffer{}
+	err = pem.Encode(&keyBuf, &pem.Block{Type: "PRIVATE KEY", Bytes: keyDer})
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "error encoding key")
+	}
+	return certBuf.Bytes(), keyBuf.Bytes(), nil
+}
+
+func parseCertificateAndKey(certPEM []byte, keyPEM []byte) (*x509.Certificate, crypto.Signer, error) {
+	certBlock, _ := pem.Decode(certPEM)
+	if certBlock == nil {
+		return nil, nil, errors.New("no certificate found")
+	}
+	cert, err := x509.ParseCertificate(certBlock.Bytes)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "error parsing certificate")
+	}
+	keyBlock, _ := pem.Decode(keyPEM)
+	if keyBlock == nil {
+		return nil, nil, errors.New("no key found")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(keyBlock.Bytes)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "error parsing key")
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, nil, errors.New("key is not a signer")
+	}
+	return cert, signer, nil
+}
@@ -0,0 +1,195 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package components
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/coderanger/controller-utils/core"
+	"github.com/coderanger/controller-utils/tests"
+)
+
+var _ = Describe("Certificate component", func() {
+	var helper *tests.FunctionalHelper
+	var obj *TestObject
+
+	parseCert := func(data []byte) *x509.Certificate {
+		block, _ := pem.Decode(data)
+		Expect(block).ToNot(BeNil())
+		cert, err := x509.ParseCertificate(block.Bytes)
+		Expect(err).ToNot(HaveOccurred())
+		return cert
+	}
+
+	BeforeEach(func() {
+		obj = &TestObject{
+			ObjectMeta: metav1.ObjectMeta{Name: "testing"},
+		}
+	})
+
+	AfterEach(func() {
+		if helper != nil {
+			helper.MustStop()
+		}
+		helper = nil
+	})
+
+	It("creates a certificate", func() {
+		var contextData core.ContextData
+		exposeDataComp := &exposeDataComponent{dest: &contextData}
+		comp := NewCertificateComponent("%s-tls", "{{ .Object.Name }}.{{ .Object.Namespace }}.svc").WithCABundle("%s-ca")
+		helper = startTestController(comp, exposeDataComp)
+		exposeDataComp.namespace = helper.Namespace
+		c := helper.TestClient
+
+		c.Create(obj)
+		c.EventuallyGetName(obj.Name, obj, c.EventuallyCondition("CertificateReady", "True"))
+
+		secret := &corev1.Secret{}
+		c.GetName("testing-tls", secret)
+		Expect(secret.Type).To(Equal(corev1.SecretTypeTLS))
+		Expect(secret.Data).To(HaveKey(corev1.TLSPrivateKeyKey))
+		caCert := parseCert(secret.Data[CA_CERT_KEY])
+		Expect(caCert.IsCA).To(BeTrue())
+		cert := parseCert(secret.Data[corev1.TLSCertKey])
+		Expect(cert.DNSNames).To(ConsistOf("testing." + helper.Namespace + ".svc"))
+		Expect(cert.CheckSignatureFrom(caCert)).To(Succeed())
+
+		configMap := &corev1.ConfigMap{}
+		c.EventuallyGetName("testing-ca", configMap)
+		Expect(configMap.Data).To(HaveKeyWithValue(CA_CERT_KEY, string(secret.Data[CA_CERT_KEY])))
+		Expect(contextData).To(HaveKeyWithValue("caBundle", string(secret.Data[CA_CERT_KEY])))
+	})
+
+	It("reissues the certificate when the names change", func() {
+		comp := NewCertificateComponent("%s-tls", "{{ .Object.Spec.Field | default \"one\" }}.example.com")
+		helper = startTestController(comp)
+		c := helper.TestClient
+
+		c.Create(obj)
+		c.EventuallyGetName(obj.Name, obj, c.EventuallyCondition("CertificateReady", "True"))
+
+		secret := &corev1.Secret{}
+		c.GetName("testing-tls", secret)
+		Expect(parseCert(secret.Data[corev1.TLSCertKey]).DNSNames).To(ConsistOf("one.example.com"))
+		caData := secret.Data[CA_CERT_KEY]
+
+		objClean := obj.DeepCopy()
+		obj.Spec.Field = "two"
+		c.Patch(obj, client.MergeFrom(objClean))
+
+		Eventually(func() []string {
+			c.GetName("testing-tls", secret)
+			return parseCert(secret.Data[corev1.TLSCertKey]).DNSNames
+		}).Should(ConsistOf("two.example.com"))
+		Expect(secret.Data[CA_CERT_KEY]).To(Equal(caData))
+	})
+
+	It("does not reissue a certificate with an IP address", func() {
+		comp := NewCertificateComponent("%s-tls", "127.0.0.1", "testing.example.com")
+		helper = startTestController(comp)
+		c := helper.TestClient
+
+		c.Create(obj)
+		c.EventuallyGetName(obj.Name, obj, c.EventuallyCondition("CertificateReady", "True"))
+
+		secret := &corev1.Secret{}
+		c.GetName("testing-tls", secret)
+		cert := parseCert(secret.Data[corev1.TLSCertKey])
+		Expect(cert.DNSNames).To(ConsistOf("testing.example.com"))
+		Expect(cert.IPAddresses).To(HaveLen(1))
+		Expect(cert.IPAddresses[0].String()).To(Equal("127.0.0.1"))
+		certData := secret.Data[corev1.TLSCertKey]
+
+		// Poke the object to force another reconcile.
+		objClean := obj.DeepCopy()
+		obj.Spec.Field = "poke"
+		c.Patch(obj, client.MergeFrom(objClean))
+
+		Consistently(func() []byte {
+			c.GetName("testing-tls", secret)
+			return secret.Data[corev1.TLSCertKey]
+		}).Should(Equal(certData))
+	})
+
+	It("rotates the certificate and CA", func() {
+		comp := NewCertificateComponent("%s-tls", "testing.example.com").WithCABundle("%s-ca").WithValidity(time.Hour, 24*time.Hour).WithRenewBefore(10 * time.Minute)
+		helper = startTestController(comp)
+		c := helper.TestClient
+
+		c.Create(obj)
+		c.EventuallyGetName(obj.Name, obj, c.EventuallyCondition("CertificateReady", "True"))
+
+		secret := &corev1.Secret{}
+		c.GetName("testing-tls", secret)
+		caData := secret.Data[CA_CERT_KEY]
+		certData := secret.Data[corev1.TLSCertKey]
+		caBundle := func() string {
+			configMap := &corev1.ConfigMap{}
+			c.GetName("testing-ca", configMap)
+			return configMap.Data[CA_CERT_KEY]
+		}
+		Eventually(caBundle).Should(Equal(string(caData)))
+		poke := func(value string) {
+			c.UpdateWithRetry(obj, func() {
+				obj.Spec.Field = value
+			})
+		}
+
+		// Past the leaf renewal time, only the leaf is reissued.
+		helper.Clock.Step(55 * time.Minute)
+		poke("leaf")
+		Eventually(func() []byte {
+			c.GetName("testing-tls", secret)
+			return secret.Data[corev1.TLSCertKey]
+		}).ShouldNot(Equal(certData))
+		Expect(secret.Data[CA_CERT_KEY]).To(Equal(caData))
+		Expect(parseCert(secret.Data[corev1.TLSCertKey]).CheckSignatureFrom(parseCert(caData))).To(Succeed())
+
+		// Past the CA renewal time, both are reissued and the old CA stays in the bundle.
+		helper.Clock.Step(23 * time.Hour)
+		poke("ca")
+		Eventually(func() []byte {
+			c.GetName("testing-tls", secret)
+			return secret.Data[CA_CERT_KEY]
+		}).ShouldNot(Equal(caData))
+		newCAData := secret.Data[CA_CERT_KEY]
+		Expect(parseCert(secret.Data[corev1.TLSCertKey]).CheckSignatureFrom(parseCert(newCAData))).To(Succeed())
+		Eventually(caBundle).Should(Equal(string(newCAData) + string(caData)))
+
+		// Once the old CA expires, it is dropped from the bundle.
+		helper.Clock.Step(15 * time.Minute)
+		poke("prune")
+		Eventually(caBundle).Should(Equal(string(newCAData)))
+		c.GetName("testing-tls", secret)
+		Expect(secret.Data[CA_CERT_KEY]).To(Equal(newCAData))
+	})
+
+	It("rejects renewBefore longer than the validity", func() {
+		comp := NewCertificateComponent("%s-tls", "testing.example.com").WithValidity(time.Hour, 24*time.Hour).WithRenewBefore(2 * time.Hour)
+		err := comp.Setup(nil, nil)
+		Expect(err).To(MatchError(ContainSubstring("renewBefore")))
+	})
+})
@@ -0,0 +1,212 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package components
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/coderanger/controller-utils/core"
+)
+
+const DEFAULT_CHECKSUM_ANNOTATION = "controller-utils/config-checksum"
+
+type checksumComponent struct {
+	key         string
+	configMaps  []string
+	secrets     []string
+	deployment  string
+	annotation  string
+	missingOkay bool
+}
+
+// Create a Checksum component. It hashes the data of the selected ConfigMaps
+// and Secrets and stores the result in ctx.Data under key, so a template can
+// put it in a pod template annotation and roll the workload when the
+// configuration changes. All names may contain a %s to be replaced with the
+// object name.
+func NewChecksumComponent(key string) *checksumComponent {
+	return &checksumComponent{key: key, annotation: DEFAULT_CHECKSUM_ANNOTATION}
+}
+
+// Include ConfigMaps in the checksum.
+func (comp *checksumComponent) WithConfigMaps(names ...string) *checksumComponent {
+	comp.configMaps = append(comp.configMaps, names...)
+	return comp
+}
+
+// Include Secrets in the checksum.
+func (comp *checksumComponent) WithSecrets(names ...string) *checksumComponent {
+	comp.secrets = append(comp.secrets, names...)
+	return comp
+}
+
+// Also patch the checksum into the pod template annotations of a Deployment,
+// for when it isn't rendered by a template that can use ctx.Data. An empty
+// annotation uses controller-utils/config-checksum.
+func (comp *checksumComponent) WithDeployment(name string, annotation string) *checksumComponent {
+	comp.deployment = name
+	if annotation != "" {
+		comp.annotation = annotation
+	}
+	return comp
+}
+
+// Treat missing objects as empty rather than an error.
+func (comp *checksumComponent) WithMissingOkay() *checksumComponent {
+	comp.missingOkay = true
+	return comp
+}
+
+func (comp *checksumComponent) formatName(ctx *core.Context, name string) string {
+	return formatChecksumName(name, ctx.Object)
+}
+
+func formatChecksumName(name string, obj client.Object) string {
+	if strings.Contains(name, "%s") {
+		return fmt.Sprintf(name, obj.GetName())
+	}
+	return name
+}
+
+// Watch the hashed objects so the checksum is updated when they change.
+func (comp *checksumComponent) GetReferences() []core.Reference {
+	refs := []core.Reference{}
+	if len(comp.configMaps) != 0 {
+		refs = append(refs, core.ConfigMapReference(checksumNames(comp.configMaps)))
+	}
+	if len(comp.secrets) != 0 {
+		refs = append(refs, core.SecretReference(checksumNames(comp.secrets)))
+	}
+	return refs
+}
+
+func checksumNames(names []string) func(obj client.Object) []string {
+	return func(obj client.Object) []string {
+		formatted := make([]string, 0, len(names))
+		for _, name := range names {
+			formatted = append(formatted, formatChecksumName(name, obj))
+		}
+		return formatted
+	}
+}
+
+func (comp *checksumComponent) Reconcile(ctx *core.Context) (core.Result, error) {
+	hash := sha256.New()
+	for _, name := range comp.configMaps {
+		configMapName := types.NamespacedName{Name: comp.formatName(ctx, name), Namespace: ctx.Object.GetNamespace()}
+		configMap := &corev1.ConfigMap{}
+		err := ctx.Client.Get(ctx, configMapName, configMap)
+		if err != nil && !(comp.missingOkay && kerrors.IsNotFound(err)) {
+			return core.Result{}, errors.Wrapf(err, "error getting configmap %s", configMapName)
+		}
+		data := map[string][]byte{}
+		for k, v := range configMap.Data {
+			data[k] = []byte(v)
+		}
+		for k, v := range configMap.BinaryData {
+			data[k] = v
+		}
+		hashData(hash, "configmap/"+configMapName.Name, data)
+	}
+	for _, name := range comp.secrets {
+		secretName := types.NamespacedName{Name: comp.formatName(ctx, name), Namespace: ctx.Object.GetNamespace()}
+		secret := &corev1.Secret{}
+		err := ctx.Client.Get(ctx, secretName, secret)
+		if err != nil && !(comp.missingOkay && kerrors.IsNotFound(err)) {
+			return core.Result{}, errors.Wrapf(err, "error getting secret %s", secretName)
+		}
+		hashData(hash, "secret/"+secretName.Name, secret.Data)
+	}
+	checksum := hex.EncodeToString(hash.Sum(nil))
+	// Store the value into context for use by later components.
+	ctx.Data[comp.key] = checksum
+
+	if comp.deployment != "" {
+		return comp.reconcileDeployment(ctx, checksum)
+	}
+	return core.Result{}, nil
+}
+
+// Write a stable representation of some data into the hash.
+func hashData(hash io.Writer, name string, data map[string][]byte) {
+	keys := make([]string, 0, len(data))
+	for k := range data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	fmt.Fprintf(hash, "%s\n", name)
+	for _, k := range keys {
+		fmt.Fprintf(hash, "%s=%d:", k, len(data[k]))
+		_, _ = hash.Write(data[k])
+		fmt.Fprint(hash, "\n")
+	}
+}
+
+func (comp *checksumComponent) reconcileDeployment(ctx *core.Context, checksum string) (core.Result, error) {
+	deploymentName := types.NamespacedName{Name: comp.formatName(ctx, comp.deployment), Namespace: ctx.Object.GetNamespace()}
+	existing := &appsv1.Deployment{}
+	err := ctx.Client.Get(ctx, deploymentName, existing)
+	if err != nil {
+		if kerrors.IsNotFound(err) {
+			// Nothing to patch yet, hopefully it will be created soon.
+			ctx.Log.Info("Deployment not found, will retry", "deployment", deploymentName)
+			return core.Result{Requeue: true}, nil
+		}
+		return core.Result{}, errors.Wrapf(err, "error getting deployment %s", deploymentName)
+	}
+	if existing.Spec.Template.Annotations[comp.annotation] == checksum {
+		return core.Result{}, nil
+	}
+
+	// Apply just the one annotation so this component only owns that field.
+	deployment := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"spec": map[string]interface{}{
+				"template": map[string]interface{}{
+					"metadata": map[string]interface{}{
+						"annotations": map[string]interface{}{
+							comp.annotation: checksum,
+						},
+					},
+				},
+			},
+		},
+	}
+	deployment.SetName(deploymentName.Name)
+	deployment.SetNamespace(deploymentName.Namespace)
+	deployment.SetGroupVersionKind(schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"})
+	force := true // Sigh *bool.
+	err = ctx.Client.Patch(ctx, deployment, client.Apply, &client.PatchOptions{Force: &force, FieldManager: ctx.FieldManager})
+	if err != nil {
+		return core.Result{}, errors.Wrapf(err, "error applying checksum to deployment %s", deploymentName)
+	}
+	return core.Result{}, nil
+}
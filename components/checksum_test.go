@@ -0,0 +1,95 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package components
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/coderanger/controller-utils/core"
+	"github.com/coderanger/controller-utils/tests"
+)
+
+var _ = Describe("Checksum component", func() {
+	var helper *tests.FunctionalHelper
+	var obj *TestObject
+
+	BeforeEach(func() {
+		obj = &TestObject{
+			ObjectMeta: metav1.ObjectMeta{Name: "testing"},
+		}
+	})
+
+	AfterEach(func() {
+		if helper != nil {
+			helper.MustStop()
+		}
+		helper = nil
+	})
+
+	It("patches the checksum into a deployment", func() {
+		var contextData core.ContextData
+		exposeDataComp := &exposeDataComponent{dest: &contextData}
+		dataComp := &injectDataComponent{key: "FOO", value: "bar"}
+		configMapComp := NewTemplateComponent("configmap.yml", "")
+		deploymentComp := NewTemplateComponent("deployment.yml", "")
+		comp := NewChecksumComponent("configChecksum").WithConfigMaps("%s").WithDeployment("%s-webserver", "")
+		helper = startTestController(dataComp, configMapComp, deploymentComp, comp, exposeDataComp)
+		exposeDataComp.namespace = helper.Namespace
+		c := helper.TestClient
+
+		c.Create(obj)
+
+		deployment := &appsv1.Deployment{}
+		c.EventuallyGetName("testing-webserver", deployment, c.EventuallyValue(HaveKey(DEFAULT_CHECKSUM_ANNOTATION), func(obj client.Object) (interface{}, error) {
+			return obj.(*appsv1.Deployment).Spec.Template.Annotations, nil
+		}))
+		Expect(deployment.Spec.Template.Annotations[DEFAULT_CHECKSUM_ANNOTATION]).To(HaveLen(64))
+		Expect(contextData).To(HaveKeyWithValue("configChecksum", deployment.Spec.Template.Annotations[DEFAULT_CHECKSUM_ANNOTATION]))
+		Expect(deployment.Spec.Template.Spec.Containers[0].Image).To(Equal("nginx"))
+	})
+
+	It("updates the checksum when a referenced ConfigMap changes", func() {
+		deploymentComp := NewTemplateComponent("deployment.yml", "")
+		comp := NewChecksumComponent("configChecksum").WithConfigMaps("checksum-external").WithDeployment("%s-webserver", "")
+		helper = startTestController(deploymentComp, comp)
+		c := helper.TestClient
+
+		configMap := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "checksum-external"},
+			Data:       map[string]string{"key": "one"},
+		}
+		c.Create(configMap)
+		c.Create(obj)
+
+		getChecksum := func(obj client.Object) (interface{}, error) {
+			return obj.(*appsv1.Deployment).Spec.Template.Annotations[DEFAULT_CHECKSUM_ANNOTATION], nil
+		}
+		deployment := &appsv1.Deployment{}
+		c.EventuallyGetName("testing-webserver", deployment, c.EventuallyValue(HaveLen(64), getChecksum))
+		first := deployment.Spec.Template.Annotations[DEFAULT_CHECKSUM_ANNOTATION]
+
+		c.UpdateWithRetry(configMap, func() {
+			configMap.Data["key"] = "two"
+		})
+		c.EventuallyGetName("testing-webserver", deployment, c.EventuallyValue(And(HaveLen(64), Not(Equal(first))), getChecksum))
+	})
+})
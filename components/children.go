@@ -0,0 +1,216 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package components
+
+import (
+	"strconv"
+
+	"github.com/pkg/errors"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	"github.com/coderanger/controller-utils/core"
+)
+
+const CHILD_OF_LABEL = "controller-utils/child-of"
+const CHILD_INDEX_LABEL = "controller-utils/child-index"
+
+type childrenComponent struct {
+	template      string
+	conditionType string
+	count         func(*core.Context) int
+	status        func(*core.Context, int, int)
+	// Set during Setup.
+	gvk schema.GroupVersionKind
+}
+
+type childTemplateData struct {
+	Object       client.Object
+	Data         map[string]interface{}
+	Capabilities core.Capabilities
+	Index        int
+}
+
+// Create a Children component. It renders the template once per child, with
+// `.Index` set to the child number, and rolls up the readiness of all of them
+// into conditionType. Children are considered ready when the condition named
+// by the controller-utils/condition annotation (default Ready) is True.
+func NewChildrenComponent(template string, conditionType string) *childrenComponent {
+	return &childrenComponent{template: template, conditionType: conditionType}
+}
+
+// Set how many children to create. Children with an index past the count
+// are deleted. Defaults to 1.
+func (comp *childrenComponent) WithCount(count func(*core.Context) int) *childrenComponent {
+	comp.count = count
+	return comp
+}
+
+// Set a callback to receive the ready and total counts, usually to copy them
+// into the object status.
+func (comp *childrenComponent) WithStatus(status func(ctx *core.Context, ready int, total int)) *childrenComponent {
+	comp.status = status
+	return comp
+}
+
+func (comp *childrenComponent) GetReadyCondition() string {
+	return comp.conditionType
+}
+
+func (comp *childrenComponent) Setup(ctx *core.Context, bldr *ctrl.Builder) error {
+	// Render with a fake, blank object just to find the object type.
+	obj, err := comp.renderTemplate(ctx, 0)
+	if err != nil {
+		return errors.Wrap(err, "error rendering setup template")
+	}
+	comp.gvk = obj.GroupVersionKind()
+	bldr.Owns(obj)
+	return nil
+}
+
+// The kind of the children, from Setup or by rendering the first child if
+// Setup wasn't run. Rendering during Reconcile isn't enough as there might be
+// no children to render.
+func (comp *childrenComponent) childGVK(ctx *core.Context) (schema.GroupVersionKind, error) {
+	if comp.gvk.Kind != "" {
+		return comp.gvk, nil
+	}
+	obj, err := comp.renderTemplate(ctx, 0)
+	if err != nil {
+		return schema.GroupVersionKind{}, errors.Wrap(err, "error rendering template to find the child kind")
+	}
+	return obj.GroupVersionKind(), nil
+}
+
+func (comp *childrenComponent) renderTemplate(ctx *core.Context, index int) (*unstructured.Unstructured, error) {
+	obj, err := getTemplate(ctx, comp.template, true, childTemplateData{Object: ctx.Object, Data: ctx.Data, Capabilities: ctx.Capabilities, Index: index})
+	if err != nil {
+		return nil, err
+	}
+	return obj.(*unstructured.Unstructured), nil
+}
+
+func (comp *childrenComponent) Reconcile(ctx *core.Context) (core.Result, error) {
+	count := 1
+	if comp.count != nil {
+		count = comp.count(ctx)
+	}
+
+	ready := 0
+	for i := 0; i < count; i++ {
+		obj, err := comp.renderTemplate(ctx, i)
+		if err != nil {
+			return core.Result{}, errors.Wrapf(err, "error rendering template for child %d", i)
+		}
+		if obj.GetNamespace() == "" {
+			obj.SetNamespace(ctx.Object.GetNamespace())
+		}
+		labels := obj.GetLabels()
+		if labels == nil {
+			labels = map[string]string{}
+		}
+		labels[CHILD_OF_LABEL] = string(ctx.Object.GetUID())
+		labels[CHILD_INDEX_LABEL] = strconv.Itoa(i)
+		obj.SetLabels(labels)
+		gvk := obj.GroupVersionKind()
+
+		err = controllerutil.SetControllerReference(ctx.Object, obj, ctx.Scheme)
+		if err != nil {
+			return core.Result{}, errors.Wrap(err, "error setting owner reference")
+		}
+
+		force := true // Sigh *bool.
+		err = ctx.Client.Patch(ctx, obj, client.Apply, &client.PatchOptions{Force: &force, FieldManager: ctx.FieldManager})
+		if err != nil {
+			return core.Result{}, errors.Wrapf(err, "error applying child %s", obj.GetName())
+		}
+
+		currentObj := &unstructured.Unstructured{}
+		currentObj.SetGroupVersionKind(gvk)
+		err = ctx.Client.Get(ctx, types.NamespacedName{Name: obj.GetName(), Namespace: obj.GetNamespace()}, currentObj)
+		if err != nil {
+			if kerrors.IsNotFound(err) {
+				// Cache hasn't caught up yet, so it's not ready.
+				continue
+			}
+			return core.Result{}, errors.Wrapf(err, "error getting current child %s/%s for status", obj.GetNamespace(), obj.GetName())
+		}
+		conditionType, ok := obj.GetAnnotations()[CONDITION_ANNOTATION]
+		if !ok {
+			conditionType = "Ready"
+		}
+		status, _ := getStatusFromUnstructured(currentObj, conditionType)
+		if status == metav1.ConditionTrue {
+			ready++
+		}
+	}
+
+	// Clean up any children past the current count.
+	gvk, err := comp.childGVK(ctx)
+	if err != nil {
+		return core.Result{}, err
+	}
+	err = comp.pruneChildren(ctx, gvk, count)
+	if err != nil {
+		return core.Result{}, err
+	}
+
+	if comp.status != nil {
+		comp.status(ctx, ready, count)
+	}
+	if comp.conditionType != "" {
+		if ready == count {
+			ctx.Conditions.SetfTrue(comp.conditionType, "ChildrenReady", "%d/%d children ready", ready, count)
+		} else {
+			ctx.Conditions.SetfFalse(comp.conditionType, "ChildrenNotReady", "%d/%d children ready", ready, count)
+		}
+	}
+	return core.Result{}, nil
+}
+
+func (comp *childrenComponent) pruneChildren(ctx *core.Context, gvk schema.GroupVersionKind, count int) error {
+	children := &unstructured.UnstructuredList{}
+	children.SetGroupVersionKind(gvk.GroupVersion().WithKind(gvk.Kind + "List"))
+	err := ctx.Client.List(ctx, children, client.InNamespace(ctx.Object.GetNamespace()), client.MatchingLabels{CHILD_OF_LABEL: string(ctx.Object.GetUID())})
+	if err != nil {
+		return errors.Wrap(err, "error listing children")
+	}
+	for i := range children.Items {
+		child := &children.Items[i]
+		index, err := strconv.Atoi(child.GetLabels()[CHILD_INDEX_LABEL])
+		if err == nil && index < count {
+			continue
+		}
+		controllerRef := metav1.GetControllerOf(child)
+		if controllerRef == nil || controllerRef.UID != ctx.Object.GetUID() {
+			// Labels match but we don't own it, leave it alone.
+			continue
+		}
+		propagation := metav1.DeletePropagationBackground
+		err = ctx.Client.Delete(ctx, child, &client.DeleteOptions{PropagationPolicy: &propagation})
+		if err != nil && !kerrors.IsNotFound(err) {
+			return errors.Wrapf(err, "error deleting child %s/%s", child.GetNamespace(), child.GetName())
+		}
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package components
+
+import (
+	"context"
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/coderanger/controller-utils/conditions"
+	"github.com/coderanger/controller-utils/core"
+	"github.com/coderanger/controller-utils/tests"
+)
+
+var _ = Describe("Children component", func() {
+	var helper *tests.FunctionalHelper
+	var obj *TestObject
+
+	BeforeEach(func() {
+		obj = &TestObject{
+			ObjectMeta: metav1.ObjectMeta{Name: "testing"},
+		}
+	})
+
+	AfterEach(func() {
+		if helper != nil {
+			helper.MustStop()
+		}
+		helper = nil
+	})
+
+	It("creates, aggregates, and prunes children", func() {
+		var lastReady, lastTotal int
+		comp := NewChildrenComponent("child_deployment.yml", "ShardsReady").
+			WithCount(func(ctx *core.Context) int {
+				if ctx.Object.(*TestObject).Spec.Field == "two" {
+					return 2
+				}
+				return 3
+			}).
+			WithStatus(func(_ *core.Context, ready int, total int) {
+				lastReady = ready
+				lastTotal = total
+			})
+		helper = startTestController(comp)
+		c := helper.TestClient
+
+		c.Create(obj)
+		c.EventuallyGetName(obj.Name, obj, c.EventuallyCondition("ShardsReady", "False"))
+		cond := conditions.FindStatusCondition(obj.Status.Conditions, "ShardsReady")
+		Expect(cond.Message).To(Equal("0/3 children ready"))
+		Eventually(func() int { return lastTotal }).Should(Equal(3))
+		c.EventuallyList(&appsv1.DeploymentList{}, c.EventuallyItems(HaveLen(3)), client.MatchingLabels{CHILD_OF_LABEL: string(obj.UID)})
+
+		// Fake all the shards being available.
+		for i := 0; i < 3; i++ {
+			deployment := &appsv1.Deployment{}
+			c.EventuallyGetName(fmt.Sprintf("testing-shard-%d", i), deployment)
+			Expect(deployment.Labels).To(HaveKeyWithValue(CHILD_INDEX_LABEL, fmt.Sprint(i)))
+			deploymentClean := deployment.DeepCopy()
+			deployment.Status.Conditions = []appsv1.DeploymentCondition{{Type: appsv1.DeploymentAvailable, Status: corev1.ConditionTrue, Reason: "Fake"}}
+			c.Status().Patch(deployment, client.MergeFrom(deploymentClean))
+		}
+		c.EventuallyGetName(obj.Name, obj, c.EventuallyCondition("ShardsReady", "True"))
+		cond = conditions.FindStatusCondition(obj.Status.Conditions, "ShardsReady")
+		Expect(cond.Message).To(Equal("3/3 children ready"))
+		Eventually(func() int { return lastReady }).Should(Equal(3))
+
+		// Scale down.
+		objClean := obj.DeepCopy()
+		obj.Spec.Field = "two"
+		c.Patch(obj, client.MergeFrom(objClean))
+		Eventually(func() bool {
+			deployment := &appsv1.Deployment{}
+			err := helper.Client.Get(context.Background(), types.NamespacedName{Name: "testing-shard-2", Namespace: helper.Namespace}, deployment)
+			return err != nil || deployment.DeletionTimestamp != nil
+		}).Should(BeTrue())
+		c.EventuallyGetName(obj.Name, obj, c.EventuallyCondition("ShardsReady", "True"))
+	})
+
+	It("prunes every child when scaled to zero", func() {
+		comp := NewChildrenComponent("child_deployment.yml", "ShardsReady").
+			WithCount(func(ctx *core.Context) int {
+				if ctx.Object.(*TestObject).Spec.Field == "zero" {
+					return 0
+				}
+				return 2
+			})
+		helper = startTestController(comp)
+		c := helper.TestClient
+
+		c.Create(obj)
+		c.EventuallyList(&appsv1.DeploymentList{}, c.EventuallyItems(HaveLen(2)), client.MatchingLabels{CHILD_OF_LABEL: string(obj.UID)})
+
+		objClean := obj.DeepCopy()
+		obj.Spec.Field = "zero"
+		c.Patch(obj, client.MergeFrom(objClean))
+		Eventually(func() int {
+			deployments := &appsv1.DeploymentList{}
+			c.List(deployments, client.MatchingLabels{CHILD_OF_LABEL: string(obj.UID)})
+			live := 0
+			for _, deployment := range deployments.Items {
+				if deployment.DeletionTimestamp == nil {
+					live++
+				}
+			}
+			return live
+		}).Should(Equal(0))
+		c.EventuallyGetName(obj.Name, obj, c.EventuallyCondition("ShardsReady", "True"))
+		cond := conditions.FindStatusCondition(obj.Status.Conditions, "ShardsReady")
+		Expect(cond.Message).To(Equal("0/0 children ready"))
+	})
+})
@@ -0,0 +1,74 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package components
+
+import (
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/coderanger/controller-utils/core"
+)
+
+// A function to fill in defaults on the object in place.
+type DefaultFunc func(ctx *core.Context, obj client.Object) error
+
+type defaultingComponent struct {
+	defaultFuncs []DefaultFunc
+}
+
+// Create a Defaulting component. It runs the object's Default() method, if
+// it implements admission.Defaulter, plus any extra funcs and saves any
+// changes. This keeps things working when the mutating webhook isn't
+// installed or is down. Put it first so later components see the defaults.
+func NewDefaultingComponent() *defaultingComponent {
+	return &defaultingComponent{}
+}
+
+// Add a defaulting func, run after the Defaulter.
+func (comp *defaultingComponent) WithFunc(fn DefaultFunc) *defaultingComponent {
+	comp.defaultFuncs = append(comp.defaultFuncs, fn)
+	return comp
+}
+
+func (comp *defaultingComponent) Reconcile(ctx *core.Context) (core.Result, error) {
+	cleanObj := ctx.Object.DeepCopyObject().(client.Object)
+	if defaulter, ok := ctx.Object.(admission.Defaulter); ok {
+		defaulter.Default()
+	}
+	for _, fn := range comp.defaultFuncs {
+		err := fn(ctx, ctx.Object)
+		if err != nil {
+			return core.Result{}, errors.Wrap(err, "error defaulting object")
+		}
+	}
+	if equality.Semantic.DeepEqual(cleanObj, ctx.Object) {
+		return core.Result{}, nil
+	}
+
+	// Patch a copy so the response doesn't clobber any status changes made by earlier components.
+	patchObj := ctx.Object.DeepCopyObject().(client.Object)
+	err := ctx.Client.Patch(ctx, patchObj, client.MergeFrom(cleanObj), &client.PatchOptions{FieldManager: ctx.FieldManager})
+	if err != nil {
+		return core.Result{}, errors.Wrap(err, "error patching defaults")
+	}
+	ctx.Object.SetResourceVersion(patchObj.GetResourceVersion())
+	ctx.Object.SetGeneration(patchObj.GetGeneration())
+	ctx.Events.Eventf(ctx.Object, "Normal", "Defaulted", "Applied default values")
+	return core.Result{}, nil
+}
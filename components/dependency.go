@@ -0,0 +1,128 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package components
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/coderanger/controller-utils/core"
+)
+
+type dependencyComponent struct {
+	gvk            schema.GroupVersionKind
+	name           string
+	namespace      string
+	conditionType  string
+	readyCondition string
+	requeueAfter   time.Duration
+	blocking       bool
+}
+
+// Create a Dependency component. It waits for another object, usually
+// managed elsewhere, to exist before marking conditionType as True. The name
+// is a template rendered like a TemplateComponent so it can come from the
+// spec, e.g. `{{ .Object.Spec.DatabaseRef.Name }}`.
+func NewDependencyComponent(gvk schema.GroupVersionKind, name string, conditionType string) *dependencyComponent {
+	return &dependencyComponent{gvk: gvk, name: name, conditionType: conditionType, requeueAfter: 30 * time.Second}
+}
+
+// Look in a different namespace. Also a template, defaults to the namespace
+// of the object.
+func (comp *dependencyComponent) WithNamespace(namespace string) *dependencyComponent {
+	comp.namespace = namespace
+	return comp
+}
+
+// Also wait for a status condition on the dependency to be True.
+func (comp *dependencyComponent) WithReadyCondition(conditionType string) *dependencyComponent {
+	comp.readyCondition = conditionType
+	return comp
+}
+
+// How long to wait before checking again. Defaults to 30 seconds.
+func (comp *dependencyComponent) WithRequeueAfter(requeueAfter time.Duration) *dependencyComponent {
+	comp.requeueAfter = requeueAfter
+	return comp
+}
+
+// Skip the remaining components until the dependency is available.
+func (comp *dependencyComponent) WithBlocking() *dependencyComponent {
+	comp.blocking = true
+	return comp
+}
+
+func (comp *dependencyComponent) GetReadyCondition() string {
+	return comp.conditionType
+}
+
+func (comp *dependencyComponent) Reconcile(ctx *core.Context) (core.Result, error) {
+	data := newTemplateData(ctx)
+	name, err := renderString(ctx, comp.name, data)
+	if err != nil {
+		return core.Result{}, errors.Wrap(err, "error rendering name")
+	}
+	namespace := ctx.Object.GetNamespace()
+	if comp.namespace != "" {
+		namespace, err = renderString(ctx, comp.namespace, data)
+		if err != nil {
+			return core.Result{}, errors.Wrap(err, "error rendering namespace")
+		}
+	}
+	waiting := core.Result{RequeueAfter: comp.requeueAfter, SkipRemaining: comp.blocking}
+	if name == "" {
+		ctx.Conditions.SetfFalse(comp.conditionType, "DependencyNotSet", "No %s name is set", comp.gvk.Kind)
+		return waiting, nil
+	}
+
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(comp.gvk)
+	err = ctx.Client.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, obj)
+	if err != nil {
+		if kerrors.IsNotFound(err) {
+			ctx.Conditions.SetfFalse(comp.conditionType, "DependencyNotFound", "%s %s/%s does not exist", comp.gvk.Kind, namespace, name)
+			return waiting, nil
+		}
+		if meta.IsNoMatchError(err) {
+			ctx.Conditions.SetfFalse(comp.conditionType, "DependencyAPINotFound", "API for %s is not installed", comp.gvk)
+			return waiting, nil
+		}
+		return core.Result{}, errors.Wrapf(err, "error getting %s %s/%s", comp.gvk.Kind, namespace, name)
+	}
+
+	if comp.readyCondition != "" {
+		status, ok := getStatusFromUnstructured(obj, comp.readyCondition)
+		if !ok {
+			ctx.Conditions.SetfUnknown(comp.conditionType, "DependencyConditionNotSet", "Condition %s on %s %s/%s is not set", comp.readyCondition, comp.gvk.Kind, namespace, name)
+			return waiting, nil
+		}
+		if status != metav1.ConditionTrue {
+			ctx.Conditions.SetfFalse(comp.conditionType, "DependencyNotReady", "Condition %s on %s %s/%s is %s", comp.readyCondition, comp.gvk.Kind, namespace, name, status)
+			return waiting, nil
+		}
+	}
+
+	ctx.Conditions.SetfTrue(comp.conditionType, "DependencyReady", "%s %s/%s is available", comp.gvk.Kind, namespace, name)
+	return core.Result{}, nil
+}
@@ -0,0 +1,72 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package components
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/coderanger/controller-utils/conditions"
+	"github.com/coderanger/controller-utils/tests"
+)
+
+var _ = Describe("Dependency component", func() {
+	var helper *tests.FunctionalHelper
+	var obj *TestObject
+
+	BeforeEach(func() {
+		obj = &TestObject{
+			ObjectMeta: metav1.ObjectMeta{Name: "testing"},
+			Spec: TestObjectSpec{
+				Field: "shared-db",
+			},
+		}
+	})
+
+	AfterEach(func() {
+		if helper != nil {
+			helper.MustStop()
+		}
+		helper = nil
+	})
+
+	It("waits for the dependency to exist", func() {
+		gvk := schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}
+		comp := NewDependencyComponent(gvk, "{{ .Object.Spec.Field }}", "DatabaseAvailable").
+			WithRequeueAfter(time.Second).
+			WithBlocking()
+		afterComp := NewReadyStatusComponent("DatabaseAvailable")
+		helper = startTestController(comp, afterComp)
+		c := helper.TestClient
+
+		c.Create(obj)
+		c.EventuallyGetName(obj.Name, obj, c.EventuallyCondition("DatabaseAvailable", "False"))
+		cond := conditions.FindStatusCondition(obj.Status.Conditions, "DatabaseAvailable")
+		Expect(cond.Reason).To(Equal("DependencyNotFound"))
+		// Blocked, so the later component hasn't run.
+		Expect(conditions.FindStatusCondition(obj.Status.Conditions, "Ready")).To(BeNil())
+
+		c.Create(&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "shared-db"}})
+		c.EventuallyGetName(obj.Name, obj, c.EventuallyCondition("DatabaseAvailable", "True"))
+		c.EventuallyGetName(obj.Name, obj, c.EventuallyCondition("Ready", "True"))
+	})
+})
@@ -0,0 +1,283 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package components
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	"github.com/coderanger/controller-utils/core"
+	"github.com/coderanger/controller-utils/predicates"
+)
+
+// A function to compute a derived value. It is passed the currently stored
+// value (or nil) so that salted hashes can be kept stable when the source
+// value hasn't changed.
+type DeriveFunc func(ctx *core.Context, existing []byte) ([]byte, error)
+
+type derivation struct {
+	key    string
+	derive DeriveFunc
+}
+
+type derivedSecretComponent struct {
+	name         string
+	derivations  []derivation
+	metadataOnly bool
+}
+
+// Create a DerivedSecret component. Each derived value is stored in the
+// secret and in ctx.Data under its key for use by later components. The name
+// may contain a %s to be replaced with the object name.
+func NewDerivedSecretComponent(name string) *derivedSecretComponent {
+	return &derivedSecretComponent{name: name}
+}
+
+// Add a derived key.
+func (comp *derivedSecretComponent) Derive(key string, derive DeriveFunc) *derivedSecretComponent {
+	comp.derivations = append(comp.derivations, derivation{key: key, derive: derive})
+	return comp
+}
+
+// Only cache Secret metadata rather than every Secret body, for controllers
+// owning many secrets. Any change to the secret triggers a reconcile.
+func (comp *derivedSecretComponent) WithMetadataOnly() *derivedSecretComponent {
+	comp.metadataOnly = true
+	return comp
+}
+
+func (comp *derivedSecretComponent) keys() []string {
+	keys := make([]string, len(comp.derivations))
+	for i, d := range comp.derivations {
+		keys[i] = d.key
+	}
+	return keys
+}
+
+// A stale read would mean generating a new value, so always read secrets from
+// the API server.
+func (comp *derivedSecretComponent) GetUncachedTypes() []client.Object {
+	return []client.Object{&corev1.Secret{}}
+}
+
+func (comp *derivedSecretComponent) Setup(_ *core.Context, bldr *ctrl.Builder) error {
+	if comp.metadataOnly {
+		bldr.Owns(&corev1.Secret{}, builder.OnlyMetadata)
+	} else {
+		secretPred, err := predicates.SecretField(comp.keys())
+		if err != nil {
+			return err
+		}
+		bldr.Owns(&corev1.Secret{}, builder.WithPredicates(secretPred))
+	}
+	return nil
+}
+
+func (comp *derivedSecretComponent) Reconcile(ctx *core.Context) (core.Result, error) {
+	name := comp.name
+	if strings.Contains(name, "%s") {
+		name = fmt.Sprintf(name, ctx.Object.GetName())
+	}
+
+	secretName := types.NamespacedName{
+		Name:      name,
+		Namespace: ctx.Object.GetNamespace(),
+	}
+	existingSecret := &corev1.Secret{}
+	// Secrets are read uncached to avoid race conditions, see GetUncachedTypes.
+	err := ctx.Client.Get(ctx, secretName, existingSecret)
+	if err != nil && !kerrors.IsNotFound(err) {
+		return core.Result{}, errors.Wrapf(err, "error getting secret %s", secretName)
+	}
+
+	data := map[string][]byte{}
+	changed := false
+	for _, d := range comp.derivations {
+		existing := existingSecret.Data[d.key]
+		val, err := d.derive(ctx, existing)
+		if err != nil {
+			return core.Result{}, errors.Wrapf(err, "error deriving value for key %s", d.key)
+		}
+		if !bytes.Equal(existing, val) {
+			changed = true
+		}
+		data[d.key] = val
+		// Store the values into context for use by later components.
+		ctx.Data[d.key] = string(val)
+	}
+
+	if changed {
+		secret := &unstructured.Unstructured{
+			Object: map[string]interface{}{
+				"type": "Opaque",
+				"data": data,
+			},
+		}
+		secret.SetName(secretName.Name)
+		secret.SetNamespace(secretName.Namespace)
+		secret.SetGroupVersionKind(schema.GroupVersionKind{Group: "", Version: "v1", Kind: "Secret"})
+
+		err = controllerutil.SetControllerReference(ctx.Object, secret, ctx.Scheme)
+		if err != nil {
+			return core.Result{}, errors.Wrap(err, "error setting owner reference")
+		}
+
+		force := true // Sigh *bool.
+		err = ctx.Client.Patch(ctx, secret, client.Apply, &client.PatchOptions{Force: &force, FieldManager: ctx.FieldManager})
+		if err != nil {
+			return core.Result{}, errors.Wrapf(err, "error applying secret %s", secretName)
+		}
+	}
+
+	return core.Result{}, nil
+}
+
+// Look up a string value set by an earlier component.
+func dataValue(ctx *core.Context, key string) (string, error) {
+	val, ok := ctx.Data[key]
+	if !ok {
+		return "", errors.Errorf("key %s not found in context data", key)
+	}
+	switch val := val.(type) {
+	case string:
+		return val, nil
+	case []byte:
+		return string(val), nil
+	default:
+		return "", errors.Errorf("key %s in context data is a %T, not a string", key, val)
+	}
+}
+
+// Derive a bcrypt hash of another value from ctx.Data.
+func BcryptHash(sourceKey string) DeriveFunc {
+	return func(ctx *core.Context, existing []byte) ([]byte, error) {
+		source, err := dataValue(ctx, sourceKey)
+		if err != nil {
+			return nil, err
+		}
+		if existing != nil && bcrypt.CompareHashAndPassword(existing, []byte(source)) == nil {
+			return existing, nil
+		}
+		return bcrypt.GenerateFromPassword([]byte(source), bcrypt.DefaultCost)
+	}
+}
+
+// Parameters for Argon2idHash, matching the RFC 9106 second recommended option.
+const argon2Time = 3
+const argon2Memory = 64 * 1024
+const argon2Threads = 4
+const argon2KeyLen = 32
+
+// Derive an Argon2id hash of another value from ctx.Data, in the PHC string format.
+func Argon2idHash(sourceKey string) DeriveFunc {
+	return func(ctx *core.Context, existing []byte) ([]byte, error) {
+		source, err := dataValue(ctx, sourceKey)
+		if err != nil {
+			return nil, err
+		}
+		if existing != nil && verifyArgon2id(existing, []byte(source)) {
+			return existing, nil
+		}
+		salt := make([]byte, 16)
+		_, err = rand.Read(salt)
+		if err != nil {
+			return nil, errors.Wrap(err, "error generating salt")
+		}
+		hash := argon2.IDKey([]byte(source), salt, argon2Time, argon2Memory, argon2Threads, argon2KeyLen)
+		encoded := fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, argon2Memory, argon2Time, argon2Threads, base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(hash))
+		return []byte(encoded), nil
+	}
+}
+
+func verifyArgon2id(encoded []byte, password []byte) bool {
+	parts := strings.Split(string(encoded), "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return false
+	}
+	var version int
+	_, err := fmt.Sscanf(parts[2], "v=%d", &version)
+	if err != nil || version != argon2.Version {
+		return false
+	}
+	var memory, time uint32
+	var threads uint8
+	_, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &time, &threads)
+	if err != nil {
+		return false
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return false
+	}
+	hash, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return false
+	}
+	otherHash := argon2.IDKey(password, salt, time, memory, threads, uint32(len(hash)))
+	return subtle.ConstantTimeCompare(hash, otherHash) == 1
+}
+
+// Derive an htpasswd line using bcrypt. The username is a template rendered
+// like a TemplateComponent, so it can be a literal or e.g. `{{ .Data.username }}`.
+func Htpasswd(username string, passwordKey string) DeriveFunc {
+	hash := BcryptHash(passwordKey)
+	return func(ctx *core.Context, existing []byte) ([]byte, error) {
+		user, err := renderString(ctx, username, newTemplateData(ctx))
+		if err != nil {
+			return nil, errors.Wrap(err, "error rendering username")
+		}
+		prefix := []byte(user + ":")
+		var existingHash []byte
+		if bytes.HasPrefix(existing, prefix) {
+			existingHash = bytes.TrimSuffix(existing[len(prefix):], []byte("\n"))
+		}
+		hashed, err := hash(ctx, existingHash)
+		if err != nil {
+			return nil, err
+		}
+		return append(prefix, hashed...), nil
+	}
+}
+
+// Derive a value by rendering a template like a TemplateComponent, for things
+// like connection strings, e.g. `postgres://app:{{ .Data.password }}@db/app`.
+func Interpolate(template string) DeriveFunc {
+	return func(ctx *core.Context, _ []byte) ([]byte, error) {
+		out, err := renderString(ctx, template, newTemplateData(ctx))
+		if err != nil {
+			return nil, errors.Wrap(err, "error rendering template")
+		}
+		return []byte(out), nil
+	}
+}
@@ -0,0 +1,248 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package components
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/coderanger/controller-utils/core"
+)
+
+// The result of looking up an external resource.
+type ExternalObservation struct {
+	// The resource still exists. If false, it will be created again.
+	Exists bool
+	// The resource matches the object spec. If false, Update will be called.
+	UpToDate bool
+}
+
+// Callbacks to manage some non-Kubernetes resource. Create returns the ID of
+// the new resource, which is stored in the object status and passed to the
+// other funcs. Observe and Update are optional, without Observe the resource
+// is assumed to always exist and be up to date.
+type ExternalResourceFuncs struct {
+	Observe func(ctx *core.Context, id string) (ExternalObservation, error)
+	Create  func(ctx *core.Context) (string, error)
+	Update  func(ctx *core.Context, id string) error
+	Delete  func(ctx *core.Context, id string) error
+}
+
+type externalResourceComponent struct {
+	conditionType string
+	funcs         ExternalResourceFuncs
+	idField       []string
+	baseBackoff   time.Duration
+	maxBackoff    time.Duration
+	resyncPeriod  time.Duration
+
+	failuresLock sync.Mutex
+	failures     map[types.UID]int
+}
+
+// Create an ExternalResource component. It handles the finalizer, conditions,
+// and retries so the funcs only need to talk to the external API. Failures
+// set conditionType to False and retry with an exponential backoff. Panics if
+// Create or Delete is nil, since it's set at startup.
+func NewExternalResourceComponent(conditionType string, funcs ExternalResourceFuncs) *externalResourceComponent {
+	if funcs.Create == nil || funcs.Delete == nil {
+		panic(errors.New("ExternalResourceFuncs requires Create and Delete"))
+	}
+	return &externalResourceComponent{
+		conditionType: conditionType,
+		funcs:         funcs,
+		idField:       []string{"status", "externalID"},
+		baseBackoff:   5 * time.Second,
+		maxBackoff:    5 * time.Minute,
+		resyncPeriod:  10 * time.Minute,
+		failures:      map[types.UID]int{},
+	}
+}
+
+// Set the dotted field path used to store the external ID. Defaults to
+// status.externalID.
+func (comp *externalResourceComponent) WithIDField(path string) *externalResourceComponent {
+	comp.idField = strings.Split(path, ".")
+	return comp
+}
+
+// Set the retry backoff after failures. Defaults to 5 seconds doubling up to 5 minutes.
+func (comp *externalResourceComponent) WithBackoff(base time.Duration, max time.Duration) *externalResourceComponent {
+	comp.baseBackoff = base
+	comp.maxBackoff = max
+	return comp
+}
+
+// Set how often to check on the external resource when nothing has changed,
+// 0 to disable. Defaults to 10 minutes.
+func (comp *externalResourceComponent) WithResyncPeriod(period time.Duration) *externalResourceComponent {
+	comp.resyncPeriod = period
+	return comp
+}
+
+func (comp *externalResourceComponent) GetReadyCondition() string {
+	return comp.conditionType
+}
+
+func (comp *externalResourceComponent) Reconcile(ctx *core.Context) (core.Result, error) {
+	id, err := comp.getID(ctx.Object)
+	if err != nil {
+		return core.Result{}, err
+	}
+
+	observation := ExternalObservation{Exists: id != "", UpToDate: true}
+	if id != "" && comp.funcs.Observe != nil {
+		observation, err = comp.funcs.Observe(ctx, id)
+		if err != nil {
+			return comp.failed(ctx, "ObserveFailed", errors.Wrapf(err, "error observing external resource %s", id)), nil
+		}
+	}
+
+	if !observation.Exists {
+		id, err = comp.funcs.Create(ctx)
+		if err != nil {
+			return comp.failed(ctx, "CreateFailed", errors.Wrap(err, "error creating external resource")), nil
+		}
+		// Store the ID right away, if it was lost we'd create a duplicate.
+		err = comp.persistID(ctx, id)
+		if err != nil {
+			ctx.Events.Eventf(ctx.Object, "Warning", "ExternalIDNotStored", "Created external resource %s but could not store its ID", id)
+			return core.Result{}, err
+		}
+		ctx.Events.Eventf(ctx.Object, "Normal", "ExternalResourceCreated", "Created external resource %s", id)
+	} else if !observation.UpToDate && comp.funcs.Update != nil {
+		err = comp.funcs.Update(ctx, id)
+		if err != nil {
+			return comp.failed(ctx, "UpdateFailed", errors.Wrapf(err, "error updating external resource %s", id)), nil
+		}
+	}
+
+	comp.resetBackoff(ctx.Object.GetUID())
+	ctx.Conditions.SetfTrue(comp.conditionType, "ExternalResourceReady", "External resource %s is ready", id)
+	return core.Result{RequeueAfter: comp.resyncPeriod}, nil
+}
+
+func (comp *externalResourceComponent) Finalize(ctx *core.Context) (core.Result, bool, error) {
+	id, err := comp.getID(ctx.Object)
+	if err != nil {
+		return core.Result{}, false, err
+	}
+	if id == "" {
+		// Never created, nothing to do.
+		comp.resetBackoff(ctx.Object.GetUID())
+		return core.Result{}, true, nil
+	}
+	err = comp.funcs.Delete(ctx, id)
+	if err != nil {
+		return comp.failed(ctx, "DeleteFailed", errors.Wrapf(err, "error deleting external resource %s", id)), false, nil
+	}
+	err = comp.setID(ctx.Object, "")
+	if err != nil {
+		return core.Result{}, false, err
+	}
+	comp.resetBackoff(ctx.Object.GetUID())
+	ctx.Events.Eventf(ctx.Object, "Normal", "ExternalResourceDeleted", "Deleted external resource %s", id)
+	return core.Result{}, true, nil
+}
+
+// Record a failure and work out when to try again. The error is surfaced
+// through the condition and an event rather than returned, otherwise the
+// controller rate limiter would stack its own backoff on top of ours.
+func (comp *externalResourceComponent) failed(ctx *core.Context, reason string, err error) core.Result {
+	ctx.Log.Error(err, "external resource failure")
+	ctx.Events.Event(ctx.Object, "Warning", reason, err.Error())
+	ctx.Conditions.SetFalse(comp.conditionType, reason, err.Error())
+
+	comp.failuresLock.Lock()
+	defer comp.failuresLock.Unlock()
+	failures := comp.failures[ctx.Object.GetUID()]
+	comp.failures[ctx.Object.GetUID()] = failures + 1
+	backoff := comp.baseBackoff
+	for i := 0; i < failures && backoff < comp.maxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > comp.maxBackoff {
+		backoff = comp.maxBackoff
+	}
+	return core.Result{RequeueAfter: backoff}
+}
+
+func (comp *externalResourceComponent) resetBackoff(uid types.UID) {
+	comp.failuresLock.Lock()
+	defer comp.failuresLock.Unlock()
+	delete(comp.failures, uid)
+}
+
+func (comp *externalResourceComponent) getID(obj client.Object) (string, error) {
+	data, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return "", errors.Wrap(err, "error converting object to unstructured")
+	}
+	id, _, err := unstructured.NestedString(data, comp.idField...)
+	if err != nil {
+		return "", errors.Wrapf(err, "error reading external ID from %s", strings.Join(comp.idField, "."))
+	}
+	return id, nil
+}
+
+// Set the ID and patch it to the API server immediately rather than waiting
+// for the end of the reconcile. The patch is made from a copy so changes
+// earlier components made to the object aren't clobbered by the response.
+func (comp *externalResourceComponent) persistID(ctx *core.Context, id string) error {
+	orig := ctx.Object.DeepCopyObject().(client.Object)
+	obj := ctx.Object.DeepCopyObject().(client.Object)
+	err := comp.setID(obj, id)
+	if err != nil {
+		return err
+	}
+	if comp.idField[0] == "status" {
+		err = ctx.Client.Status().Patch(ctx, obj, client.MergeFrom(orig))
+	} else {
+		err = ctx.Client.Patch(ctx, obj, client.MergeFrom(orig))
+	}
+	if err != nil {
+		return errors.Wrapf(err, "error storing external ID %s in %s", id, strings.Join(comp.idField, "."))
+	}
+	return comp.setID(ctx.Object, id)
+}
+
+func (comp *externalResourceComponent) setID(obj client.Object, id string) error {
+	data, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return errors.Wrap(err, "error converting object to unstructured")
+	}
+	if id == "" {
+		unstructured.RemoveNestedField(data, comp.idField...)
+	} else {
+		err = unstructured.SetNestedField(data, id, comp.idField...)
+		if err != nil {
+			return errors.Wrapf(err, "error setting external ID in %s", strings.Join(comp.idField, "."))
+		}
+	}
+	err = runtime.DefaultUnstructuredConverter.FromUnstructured(data, obj)
+	if err != nil {
+		return errors.Wrap(err, "error converting object from unstructured")
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package components
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/coderanger/controller-utils/conditions"
+	"github.com/coderanger/controller-utils/core"
+	"github.com/coderanger/controller-utils/tests"
+)
+
+// A fake external API to test against.
+type fakeExternalAPI struct {
+	sync.Mutex
+	resources map[string]string
+	failNext  bool
+	creates   int
+}
+
+func (api *fakeExternalAPI) funcs() ExternalResourceFuncs {
+	return ExternalResourceFuncs{
+		Observe: func(ctx *core.Context, id string) (ExternalObservation, error) {
+			api.Lock()
+			defer api.Unlock()
+			val, ok := api.resources[id]
+			return ExternalObservation{Exists: ok, UpToDate: val == ctx.Object.(*TestObject).Spec.Field}, nil
+		},
+		Create: func(ctx *core.Context) (string, error) {
+			api.Lock()
+			defer api.Unlock()
+			if api.failNext {
+				api.failNext = false
+				return "", errors.New("API unavailable")
+			}
+			api.creates++
+			id := "ext-" + ctx.Object.GetName()
+			api.resources[id] = ctx.Object.(*TestObject).Spec.Field
+			return id, nil
+		},
+		Update: func(ctx *core.Context, id string) error {
+			api.Lock()
+			defer api.Unlock()
+			api.resources[id] = ctx.Object.(*TestObject).Spec.Field
+			return nil
+		},
+		Delete: func(ctx *core.Context, id string) error {
+			api.Lock()
+			defer api.Unlock()
+			delete(api.resources, id)
+			return nil
+		},
+	}
+}
+
+func (api *fakeExternalAPI) get(id string) (string, bool) {
+	api.Lock()
+	defer api.Unlock()
+	val, ok := api.resources[id]
+	return val, ok
+}
+
+func (api *fakeExternalAPI) createCount() int {
+	api.Lock()
+	defer api.Unlock()
+	return api.creates
+}
+
+var _ = Describe("ExternalResource component", func() {
+	var helper *tests.FunctionalHelper
+	var obj *TestObject
+	var api *fakeExternalAPI
+
+	BeforeEach(func() {
+		obj = &TestObject{
+			ObjectMeta: metav1.ObjectMeta{Name: "testing"},
+			Spec: TestObjectSpec{
+				Field: "one",
+			},
+		}
+		api = &fakeExternalAPI{resources: map[string]string{}}
+	})
+
+	AfterEach(func() {
+		if helper != nil {
+			helper.MustStop()
+		}
+		helper = nil
+	})
+
+	It("manages an external resource", func() {
+		comp := NewExternalResourceComponent("ExternalReady", api.funcs()).WithIDField("status.field")
+		helper = startTestController(comp)
+		c := helper.TestClient
+
+		c.Create(obj)
+		c.EventuallyGetName(obj.Name, obj, c.EventuallyCondition("ExternalReady", "True"))
+		Expect(obj.Status.Field).To(Equal("ext-testing"))
+		Expect(api.get("ext-testing")).To(Equal("one"))
+		Expect(api.createCount()).To(Equal(1))
+
+		objClean := obj.DeepCopy()
+		obj.Spec.Field = "two"
+		c.Patch(obj, client.MergeFrom(objClean))
+		Eventually(func() string {
+			val, _ := api.get("ext-testing")
+			return val
+		}).Should(Equal("two"))
+
+		c.Delete(obj)
+		Eventually(func() bool {
+			err := helper.Client.Get(context.Background(), types.NamespacedName{Name: obj.Name, Namespace: helper.Namespace}, obj)
+			return kerrors.IsNotFound(err)
+		}).Should(BeTrue())
+		_, ok := api.get("ext-testing")
+		Expect(ok).To(BeFalse())
+	})
+
+	It("retries after a failure", func() {
+		api.failNext = true
+		comp := NewExternalResourceComponent("ExternalReady", api.funcs()).
+			WithIDField("status.field").
+			WithBackoff(100*time.Millisecond, time.Second)
+		helper = startTestController(comp)
+		c := helper.TestClient
+
+		c.Create(obj)
+		c.EventuallyGetName(obj.Name, obj, c.EventuallyValue(Equal("CreateFailed"), func(obj client.Object) (interface{}, error) {
+			cond := conditions.FindStatusCondition(obj.(*TestObject).Status.Conditions, "ExternalReady")
+			if cond == nil {
+				return "", nil
+			}
+			return cond.Reason, nil
+		}))
+		c.EventuallyGetName(obj.Name, obj, c.EventuallyCondition("ExternalReady", "True"))
+		Expect(obj.Status.Field).To(Equal("ext-testing"))
+	})
+
+	It("requires Create and Delete funcs", func() {
+		funcs := api.funcs()
+		funcs.Delete = nil
+		Expect(func() { NewExternalResourceComponent("ExternalReady", funcs) }).To(Panic())
+	})
+})
@@ -0,0 +1,79 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package components
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/coderanger/controller-utils/conditions"
+	"github.com/coderanger/controller-utils/core"
+	"github.com/coderanger/controller-utils/tests"
+)
+
+// Test component that uses a field index registered during Setup.
+type fieldIndexComponent struct{}
+
+func (comp *fieldIndexComponent) Setup(ctx *core.Context, _ *ctrl.Builder) error {
+	return ctx.FieldIndexer.IndexField(ctx, &TestObject{}, "spec.field", func(obj client.Object) []string {
+		return []string{obj.(*TestObject).Spec.Field}
+	})
+}
+
+func (comp *fieldIndexComponent) Reconcile(ctx *core.Context) (core.Result, error) {
+	objs := &TestObjectList{}
+	err := ctx.Client.List(ctx, objs, client.InNamespace(ctx.Object.GetNamespace()), client.MatchingFields{"spec.field": ctx.Object.(*TestObject).Spec.Field})
+	if err != nil {
+		return core.Result{}, err
+	}
+	ctx.Conditions.SetfTrue("Indexed", "Listed", "found %d", len(objs.Items))
+	return core.Result{}, nil
+}
+
+var _ = Describe("Field indexes", func() {
+	var helper *tests.FunctionalHelper
+	var obj *TestObject
+
+	BeforeEach(func() {
+		obj = &TestObject{
+			ObjectMeta: metav1.ObjectMeta{Name: "testing"},
+			Spec: TestObjectSpec{
+				Field: "indexed",
+			},
+		}
+	})
+
+	AfterEach(func() {
+		if helper != nil {
+			helper.MustStop()
+		}
+		helper = nil
+	})
+
+	It("can register an index during setup", func() {
+		helper = startTestController(&fieldIndexComponent{})
+		c := helper.TestClient
+
+		c.Create(obj)
+		c.EventuallyGetName(obj.Name, obj, c.EventuallyCondition("Indexed", "True"))
+		cond := conditions.FindStatusCondition(obj.Status.Conditions, "Indexed")
+		Expect(cond.Message).To(Equal("found 1"))
+	})
+})
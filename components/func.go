@@ -0,0 +1,42 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package components
+
+import (
+	"github.com/coderanger/controller-utils/core"
+)
+
+// Adapter to use a plain function as a component, for small bits of glue
+// logic, e.g. `Component("glue", components.Func(func(ctx *core.Context) (core.Result, error) { ... }))`.
+type Func func(*core.Context) (core.Result, error)
+
+func (f Func) Reconcile(ctx *core.Context) (core.Result, error) {
+	return f(ctx)
+}
+
+// Adapter to use a plain function as a finalizer. It does nothing during a
+// normal reconcile, but still registers the finalizer on the object. Return
+// true once the cleanup is done.
+type FinalizerFunc func(*core.Context) (core.Result, bool, error)
+
+func (f FinalizerFunc) Reconcile(_ *core.Context) (core.Result, error) {
+	return core.Result{}, nil
+}
+
+func (f FinalizerFunc) Finalize(ctx *core.Context) (core.Result, bool, error) {
+	return f(ctx)
+}
@@ -0,0 +1,83 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package components
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/coderanger/controller-utils/core"
+	"github.com/coderanger/controller-utils/tests"
+)
+
+var _ = Describe("Func adapters", func() {
+	var helper *tests.FunctionalHelper
+	var obj *TestObject
+
+	BeforeEach(func() {
+		obj = &TestObject{
+			ObjectMeta: metav1.ObjectMeta{Name: "testing"},
+		}
+	})
+
+	AfterEach(func() {
+		if helper != nil {
+			helper.MustStop()
+		}
+		helper = nil
+	})
+
+	It("runs a function component", func() {
+		comp := Func(func(ctx *core.Context) (core.Result, error) {
+			ctx.Conditions.SetTrue("Glue", "Ran")
+			return core.Result{}, nil
+		})
+		helper = startTestController(comp)
+		c := helper.TestClient
+
+		c.Create(obj)
+		c.EventuallyGetName(obj.Name, obj, c.EventuallyCondition("Glue", "True"))
+	})
+
+	It("runs a function finalizer", func() {
+		finalized := false
+		comp := FinalizerFunc(func(ctx *core.Context) (core.Result, bool, error) {
+			finalized = true
+			return core.Result{}, true, nil
+		})
+		helper = startTestController(comp)
+		c := helper.TestClient
+
+		c.Create(obj)
+		c.EventuallyGetName(obj.Name, obj, c.EventuallyValue(Not(BeEmpty()), func(obj client.Object) (interface{}, error) {
+			return obj.GetFinalizers(), nil
+		}))
+
+		c.Delete(obj)
+		Eventually(func() bool {
+			err := helper.Client.Get(context.Background(), types.NamespacedName{Name: obj.Name, Namespace: helper.Namespace}, obj)
+			return kerrors.IsNotFound(err)
+		}).Should(BeTrue())
+		Expect(finalized).To(BeTrue())
+	})
+})
@@ -0,0 +1,36 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package components
+
+// Create a template component for a monitoring.coreos.com ServiceMonitor.
+// It is skipped on clusters without the Prometheus operator installed, so
+// the same controller works everywhere.
+func NewServiceMonitorComponent(template string, conditionType string) *templateComponent {
+	return newMonitoringComponent(template, conditionType)
+}
+
+// Create a template component for a monitoring.coreos.com PrometheusRule.
+// It is skipped on clusters without the Prometheus operator installed.
+func NewPrometheusRuleComponent(template string, conditionType string) *templateComponent {
+	return newMonitoringComponent(template, conditionType)
+}
+
+// Both kinds come from the Prometheus operator, so they're optional the same
+// way. If the API is missing the condition is set True with APINotInstalled.
+func newMonitoringComponent(template string, conditionType string) *templateComponent {
+	return NewTemplateComponent(template, conditionType).WithOptionalAPI()
+}
@@ -0,0 +1,67 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package components
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/coderanger/controller-utils/conditions"
+	"github.com/coderanger/controller-utils/core"
+	"github.com/coderanger/controller-utils/tests"
+)
+
+var _ = Describe("Monitoring components", func() {
+	var helper *tests.FunctionalHelper
+	var obj *TestObject
+
+	BeforeEach(func() {
+		obj = &TestObject{
+			ObjectMeta: metav1.ObjectMeta{Name: "testing"},
+		}
+	})
+
+	AfterEach(func() {
+		if helper != nil {
+			helper.MustStop()
+		}
+		helper = nil
+	})
+
+	// Run a component for an API which isn't installed in the test cluster.
+	expectSkipped := func(comp core.Component, conditionType string) {
+		afterComp := NewReadyStatusComponent(conditionType)
+		helper = startTestController(comp, afterComp)
+		c := helper.TestClient
+
+		c.Create(obj)
+		c.EventuallyGetName(obj.Name, obj, c.EventuallyCondition("Ready", "True"))
+		cond := conditions.FindStatusCondition(obj.Status.Conditions, conditionType)
+		Expect(cond).ToNot(BeNil())
+		Expect(cond.Status).To(Equal(metav1.ConditionTrue))
+		Expect(cond.Reason).To(Equal("APINotInstalled"))
+	}
+
+	It("skips a ServiceMonitor when the API is not installed", func() {
+		expectSkipped(NewServiceMonitorComponent("servicemonitor.yml", "ServiceMonitorReady"), "ServiceMonitorReady")
+	})
+
+	It("skips a PrometheusRule when the API is not installed", func() {
+		expectSkipped(NewPrometheusRuleComponent("prometheusrule.yml", "PrometheusRuleReady"), "PrometheusRuleReady")
+	})
+})
@@ -0,0 +1,114 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package components
+
+import (
+	"bytes"
+	"os"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	"sigs.k8s.io/yaml"
+
+	"github.com/coderanger/controller-utils/core"
+	"github.com/coderanger/controller-utils/templates"
+)
+
+type templateOverlay struct {
+	name     string
+	optional bool
+}
+
+func (comp *templateComponent) applyOverlays(ctx *core.Context, obj *unstructured.Unstructured) error {
+	if len(comp.overlays) == 0 {
+		return nil
+	}
+	data, err := comp.templateData(ctx)
+	if err != nil {
+		return err
+	}
+	for _, overlay := range comp.overlays {
+		filename, err := renderString(ctx, overlay.name, data)
+		if err != nil {
+			return errors.Wrapf(err, "error rendering overlay name %s", overlay.name)
+		}
+		if filename == "" {
+			continue
+		}
+		f, err := ctx.Templates.Open(filename)
+		if err != nil {
+			if overlay.optional && os.IsNotExist(err) {
+				continue
+			}
+			return errors.Wrapf(err, "error opening overlay %s", filename)
+		}
+		f.Close()
+
+		rawOverlay, err := templates.RenderFile(ctx.Templates, filename, data, comp.templateOptions(ctx))
+		if err != nil {
+			return errors.Wrapf(err, "error rendering overlay %s", filename)
+		}
+		err = applyOverlay(obj, rawOverlay, ctx.Scheme)
+		if err != nil {
+			return errors.Wrapf(err, "error applying overlay %s", filename)
+		}
+	}
+	return nil
+}
+
+// Patch obj in place with a rendered overlay.
+func applyOverlay(obj *unstructured.Unstructured, rawOverlay []byte, scheme *runtime.Scheme) error {
+	patch, err := yaml.YAMLToJSON(rawOverlay)
+	if err != nil {
+		return errors.Wrap(err, "error parsing overlay")
+	}
+	patch = bytes.TrimSpace(patch)
+	if len(patch) == 0 || bytes.Equal(patch, []byte("null")) {
+		return nil
+	}
+	original, err := obj.MarshalJSON()
+	if err != nil {
+		return errors.Wrap(err, "error encoding object")
+	}
+
+	var patched []byte
+	if patch[0] == '[' {
+		jsonPatch, err := jsonpatch.DecodePatch(patch)
+		if err != nil {
+			return errors.Wrap(err, "error decoding JSON patch")
+		}
+		patched, err = jsonPatch.Apply(original)
+		if err != nil {
+			return errors.Wrap(err, "error applying JSON patch")
+		}
+	} else if typed, err := scheme.New(obj.GroupVersionKind()); err == nil {
+		patched, err = strategicpatch.StrategicMergePatch(original, patch, typed)
+		if err != nil {
+			return errors.Wrap(err, "error applying strategic merge patch")
+		}
+	} else {
+		patched, err = jsonpatch.MergePatch(original, patch)
+		if err != nil {
+			return errors.Wrap(err, "error applying merge patch")
+		}
+	}
+
+	return obj.UnmarshalJSON(patched)
+}
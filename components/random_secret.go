@@ -17,13 +17,14 @@ limitations under the License.
 package components
 
 import (
-	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"strings"
 
 	"github.com/pkg/errors"
 	corev1 "k8s.io/api/core/v1"
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
@@ -31,6 +32,9 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	"github.com/coderanger/controller-utils/core"
 	"github.com/coderanger/controller-utils/predicates"
@@ -39,27 +43,116 @@ import (
 
 const RANDOM_BYTES = 32
 
-// Lossy base64 endcoding to make passwords that will work basically anywhere.
-var RandEncoding = base64.NewEncoding("abcdefghijklmnopqrstuvwxyzabcdefghijklmnopqrstuvwxyzabcdefghijkl").WithPadding(base64.NoPadding)
+// Deprecated: Use WithPolicy instead, see randstring.RandEncoding.
+var RandEncoding = randstring.RandEncoding
 
 type randomSecretComponent struct {
-	name string
-	keys []string
+	name        string
+	keys        []string
+	labels      map[string]string
+	annotations map[string]string
+	secretType  corev1.SecretType
+	merge       bool
+	policy      randstring.Policy
+	// Only cache metadata for watched secrets.
+	metadataOnly bool
 }
 
-func NewRandomSecretComponent(name string, keys ...string) core.Component {
+func NewRandomSecretComponent(name string, keys ...string) *randomSecretComponent {
 	if len(keys) == 0 {
 		// Default key if none are specified.
 		keys = []string{"password"}
 	}
-	return &randomSecretComponent{name, keys}
+	return &randomSecretComponent{name: name, keys: keys, secretType: corev1.SecretTypeOpaque, policy: randstring.DefaultPolicy}
+}
+
+// Set the charset and character requirements for generated values, e.g. to
+// satisfy a database's password rules. Existing values are not regenerated.
+func (comp *randomSecretComponent) WithPolicy(policy randstring.Policy) *randomSecretComponent {
+	comp.policy = policy
+	return comp
+}
+
+// Set labels to apply to the generated secret.
+func (comp *randomSecretComponent) WithLabels(labels map[string]string) *randomSecretComponent {
+	comp.labels = labels
+	return comp
+}
+
+// Set annotations to apply to the generated secret.
+func (comp *randomSecretComponent) WithAnnotations(annotations map[string]string) *randomSecretComponent {
+	comp.annotations = annotations
+	return comp
+}
+
+// Set the type of the generated secret, e.g. kubernetes.io/basic-auth. The
+// type of a secret is immutable so changing it on an existing secret will fail.
+func (comp *randomSecretComponent) WithType(secretType corev1.SecretType) *randomSecretComponent {
+	comp.secretType = secretType
+	return comp
+}
+
+// Only fill in missing keys in a secret which may be managed by someone else.
+// The secret is not given a controller reference and only the generated keys
+// are claimed by this component's field manager, so users can pre-provision
+// some keys while the operator supplies the rest.
+func (comp *randomSecretComponent) WithMerge() *randomSecretComponent {
+	comp.merge = true
+	return comp
+}
+
+// Only cache Secret metadata rather than every Secret body, for controllers
+// owning many secrets. Any change to the secret triggers a reconcile.
+func (comp *randomSecretComponent) WithMetadataOnly() *randomSecretComponent {
+	comp.metadataOnly = true
+	return comp
+}
+
+// A stale read would mean generating a new value, so always read secrets from
+// the API server.
+func (comp *randomSecretComponent) GetUncachedTypes() []client.Object {
+	return []client.Object{&corev1.Secret{}}
 }
 
 func (comp *randomSecretComponent) Setup(_ *core.Context, bldr *ctrl.Builder) error {
-	bldr.Owns(&corev1.Secret{}, builder.WithPredicates(predicates.SecretField(comp.keys)))
+	secretPred, err := predicates.SecretField(comp.keys)
+	if err != nil {
+		return err
+	}
+	// Data predicates can't see anything but metadata.
+	var opt interface {
+		builder.OwnsOption
+		builder.WatchesOption
+	} = builder.WithPredicates(secretPred)
+	if comp.metadataOnly {
+		opt = builder.OnlyMetadata
+	}
+	if comp.merge {
+		// The secret won't have an owner reference so map back to the owner by name.
+		bldr.Watches(&source.Kind{Type: &corev1.Secret{}}, handler.EnqueueRequestsFromMapFunc(comp.mapSecretToOwner), opt)
+	} else {
+		bldr.Owns(&corev1.Secret{}, opt)
+	}
 	return nil
 }
 
+// Reverse the name template to find which object a secret belongs to. If the
+// name is static there is no way to know so changes will be noticed on the
+// next reconcile instead.
+func (comp *randomSecretComponent) mapSecretToOwner(obj client.Object) []reconcile.Request {
+	idx := strings.Index(comp.name, "%s")
+	if idx == -1 {
+		return nil
+	}
+	prefix, suffix := comp.name[:idx], comp.name[idx+2:]
+	name := obj.GetName()
+	if len(name) <= len(prefix)+len(suffix) || !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, suffix) {
+		return nil
+	}
+	ownerName := name[len(prefix) : len(name)-len(suffix)]
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{Name: ownerName, Namespace: obj.GetNamespace()}}}
+}
+
 func (comp *randomSecretComponent) Reconcile(ctx *core.Context) (core.Result, error) {
 	name := comp.name
 	if strings.Contains(name, "%s") {
@@ -71,8 +164,8 @@ func (comp *randomSecretComponent) Reconcile(ctx *core.Context) (core.Result, er
 		Namespace: ctx.Object.GetNamespace(),
 	}
 	existingSecret := &corev1.Secret{}
-	// Use the uncached client to avoid race conditions.
-	err := ctx.UncachedClient.Get(ctx, secretName, existingSecret)
+	// Secrets are read uncached to avoid race conditions, see GetUncachedTypes.
+	err := ctx.Client.Get(ctx, secretName, existingSecret)
 	if err != nil {
 		if kerrors.IsNotFound(err) {
 			// Patch will create it so no need for anything else specific.
@@ -82,41 +175,63 @@ func (comp *randomSecretComponent) Reconcile(ctx *core.Context) (core.Result, er
 	}
 
 	data := map[string][]byte{}
+	// In merge mode only apply keys we generated, now or on an earlier
+	// reconcile, so pre-provisioned keys stay with whoever created them.
+	var generatedKeys map[string]bool
+	if comp.merge {
+		generatedKeys, err = appliedDataKeys(existingSecret, ctx.FieldManager)
+		if err != nil {
+			return core.Result{}, err
+		}
+	}
 
 	changed := false
 	for _, key := range comp.keys {
 		val, ok := existingSecret.Data[key]
 		if !ok || len(val) == 0 {
-			val, err = randstring.RandomBytes(RANDOM_BYTES)
+			val, err = comp.policy.RandomBytes(RANDOM_BYTES)
 			if err != nil {
 				return core.Result{}, errors.Wrap(err, "error generating random bytes")
 			}
 			ctx.Events.Eventf(ctx.Object, "Normal", "GeneratedRandomValue", "Generated a random value for key %s", key)
 			changed = true
+		} else if comp.merge && !generatedKeys[key] {
+			ctx.Data[key] = string(val)
+			continue
 		}
 		data[key] = val
 		// Store the values into context for use by later components.
 		ctx.Data[key] = string(val)
 	}
 
+	// Check if the metadata needs an update too.
+	if !changed && !comp.metadataMatches(existingSecret) {
+		changed = true
+	}
+
 	if changed {
 		secret := &unstructured.Unstructured{
 			Object: map[string]interface{}{
-				"type": "Opaque",
 				"data": data,
 			},
 		}
 		secret.SetName(secretName.Name)
 		secret.SetNamespace(secretName.Namespace)
+		secret.SetLabels(comp.labels)
+		secret.SetAnnotations(comp.annotations)
 		secret.SetGroupVersionKind(schema.GroupVersionKind{Group: "", Version: "v1", Kind: "Secret"})
 
-		err = controllerutil.SetControllerReference(ctx.Object, secret, ctx.Scheme)
-		if err != nil {
-			return core.Result{}, errors.Wrap(err, "error setting owner reference")
+		// In merge mode the secret belongs to the user, so leave the type and ownership alone and
+		// don't force so we never take over fields from another manager.
+		force := !comp.merge // Sigh *bool.
+		if !comp.merge {
+			secret.Object["type"] = string(comp.secretType)
+			err = controllerutil.SetControllerReference(ctx.Object, secret, ctx.Scheme)
+			if err != nil {
+				return core.Result{}, errors.Wrap(err, "error setting owner reference")
+			}
 		}
 
-		// Sigh *bool.
-		force := true
 		err = ctx.Client.Patch(ctx, secret, client.Apply, &client.PatchOptions{Force: &force, FieldManager: ctx.FieldManager})
 		if err != nil {
 			return core.Result{}, errors.Wrapf(err, "error applying secret %s", secretName)
@@ -126,7 +241,50 @@ func (comp *randomSecretComponent) Reconcile(ctx *core.Context) (core.Result, er
 	return core.Result{}, nil
 }
 
+// Find the data keys a field manager has applied to a secret.
+func appliedDataKeys(secret *corev1.Secret, fieldManager string) (map[string]bool, error) {
+	keys := map[string]bool{}
+	for _, entry := range secret.ManagedFields {
+		if entry.Manager != fieldManager || entry.Operation != metav1.ManagedFieldsOperationApply || entry.FieldsV1 == nil {
+			continue
+		}
+		fields := map[string]interface{}{}
+		err := json.Unmarshal(entry.FieldsV1.Raw, &fields)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error parsing managed fields for secret %s", secret.Name)
+		}
+		dataFields, _ := fields["f:data"].(map[string]interface{})
+		for field := range dataFields {
+			if strings.HasPrefix(field, "f:") {
+				keys[strings.TrimPrefix(field, "f:")] = true
+			}
+		}
+	}
+	return keys, nil
+}
+
+func (comp *randomSecretComponent) metadataMatches(existingSecret *corev1.Secret) bool {
+	if !comp.merge && existingSecret.Type != "" && existingSecret.Type != comp.secretType {
+		return false
+	}
+	for key, value := range comp.labels {
+		existingValue, ok := existingSecret.Labels[key]
+		if !ok || existingValue != value {
+			return false
+		}
+	}
+	for key, value := range comp.annotations {
+		existingValue, ok := existingSecret.Annotations[key]
+		if !ok || existingValue != value {
+			return false
+		}
+	}
+	return true
+}
+
 func init() {
 	// Avoid import loops.
-	core.NewRandomSecretComponent = NewRandomSecretComponent
+	core.NewRandomSecretComponent = func(name string, keys ...string) core.Component {
+		return NewRandomSecretComponent(name, keys...)
+	}
 }
@@ -28,6 +28,7 @@ import (
 
 	"github.com/coderanger/controller-utils/core"
 	"github.com/coderanger/controller-utils/tests"
+	"github.com/coderanger/controller-utils/tests/matchers"
 )
 
 type exposeDataComponent struct {
@@ -77,6 +78,7 @@ var _ = Describe("RandomSecret component", func() {
 		c.GetName("random", secret)
 		Expect(secret.Data).To(HaveKeyWithValue("key", HaveLen(43)))
 		Expect(contextData).To(HaveKeyWithValue("key", BeEquivalentTo(secret.Data["key"])))
+		Eventually(func() []corev1.Event { return helper.Events(obj) }).Should(matchers.HaveEvent("Normal", "GeneratedRandomValue").WithMessage("key key"))
 	})
 
 	It("uses password as the default key", func() {
@@ -206,6 +208,64 @@ var _ = Describe("RandomSecret component", func() {
 		Expect(secret.Data).To(HaveKeyWithValue("other", Equal([]byte("foo"))))
 	})
 
+	It("sets labels, annotations, and type", func() {
+		comp := NewRandomSecretComponent("random", "username", "password").
+			WithLabels(map[string]string{"app": "testing"}).
+			WithAnnotations(map[string]string{"example.com/rotate": "false"}).
+			WithType(corev1.SecretTypeBasicAuth)
+		helper = startTestController(comp, readyStatusComp)
+		c := helper.TestClient
+
+		c.Create(obj)
+		c.EventuallyGetName(obj.Name, obj, c.EventuallyCondition("Ready", "True"))
+
+		secret := &corev1.Secret{}
+		c.GetName("random", secret)
+		Expect(secret.Type).To(Equal(corev1.SecretTypeBasicAuth))
+		Expect(secret.Labels).To(HaveKeyWithValue("app", "testing"))
+		Expect(secret.Annotations).To(HaveKeyWithValue("example.com/rotate", "false"))
+		Expect(secret.Data).To(HaveKeyWithValue("username", HaveLen(43)))
+		Expect(secret.Data).To(HaveKeyWithValue("password", HaveLen(43)))
+	})
+
+	It("fills in missing keys in merge mode", func() {
+		var contextData core.ContextData
+		exposeDataComp := &exposeDataComponent{dest: &contextData}
+		comp := NewRandomSecretComponent("random", "username", "password").WithMerge()
+		helper = startTestController(comp, exposeDataComp, readyStatusComp)
+		exposeDataComp.namespace = helper.Namespace
+		c := helper.TestClient
+
+		preSecret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "random"},
+			Data: map[string][]byte{
+				"username": []byte("admin"),
+			},
+		}
+		c.Create(preSecret)
+
+		c.Create(obj)
+		c.EventuallyGetName(obj.Name, obj, c.EventuallyCondition("Ready", "True"))
+
+		secret := &corev1.Secret{}
+		c.GetName("random", secret)
+		Expect(secret.OwnerReferences).To(BeEmpty())
+		Expect(secret.Data).To(HaveKeyWithValue("username", Equal([]byte("admin"))))
+		Expect(secret.Data).To(HaveKeyWithValue("password", HaveLen(43)))
+		Expect(contextData).To(HaveKeyWithValue("username", "admin"))
+		Expect(contextData).To(HaveKeyWithValue("password", BeEquivalentTo(secret.Data["password"])))
+		// Only the generated key is claimed.
+		applied := false
+		for _, entry := range secret.ManagedFields {
+			if entry.Operation == metav1.ManagedFieldsOperationApply {
+				applied = true
+				Expect(string(entry.FieldsV1.Raw)).To(ContainSubstring(`"f:password"`))
+				Expect(string(entry.FieldsV1.Raw)).ToNot(ContainSubstring(`"f:username"`))
+			}
+		}
+		Expect(applied).To(BeTrue())
+	})
+
 	It("cleans up the secret if the owner is deleted", func() {
 		Skip("Requires controller-manager for gc controller")
 		var contextData core.ContextData
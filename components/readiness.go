@@ -0,0 +1,109 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package components
+
+import (
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/status"
+)
+
+// Work out if common built-in kinds are ready without needing an annotation.
+// Returns false if the kind isn't known.
+func getNativeReadiness(obj *unstructured.Unstructured) (metav1.ConditionStatus, string, bool) {
+	gvk := obj.GroupVersionKind()
+	// Don't trust status from before the last spec change.
+	observedGeneration, found, _ := unstructured.NestedInt64(obj.Object, "status", "observedGeneration")
+	stale := found && observedGeneration < obj.GetGeneration()
+
+	switch {
+	case gvk.Group == "apps" && gvk.Kind == "Deployment":
+		if stale {
+			return metav1.ConditionFalse, "rollout has not been observed yet", true
+		}
+		status, ok := getStatusFromUnstructured(obj, "Available")
+		if !ok {
+			return metav1.ConditionUnknown, "Available condition is not set", true
+		}
+		return status, fmt.Sprintf("Available condition is %s", status), true
+
+	case gvk.Group == "apps" && gvk.Kind == "StatefulSet":
+		if stale {
+			return metav1.ConditionFalse, "rollout has not been observed yet", true
+		}
+		replicas, found, _ := unstructured.NestedInt64(obj.Object, "spec", "replicas")
+		if !found {
+			replicas = 1
+		}
+		readyReplicas, _, _ := unstructured.NestedInt64(obj.Object, "status", "readyReplicas")
+		if readyReplicas >= replicas {
+			return metav1.ConditionTrue, fmt.Sprintf("%d/%d replicas ready", readyReplicas, replicas), true
+		}
+		return metav1.ConditionFalse, fmt.Sprintf("%d/%d replicas ready", readyReplicas, replicas), true
+
+	case gvk.Group == "batch" && gvk.Kind == "Job":
+		if status, ok := getStatusFromUnstructured(obj, "Failed"); ok && status == metav1.ConditionTrue {
+			return metav1.ConditionFalse, "job failed", true
+		}
+		if status, ok := getStatusFromUnstructured(obj, "Complete"); ok && status == metav1.ConditionTrue {
+			return metav1.ConditionTrue, "job complete", true
+		}
+		return metav1.ConditionFalse, "job is not complete", true
+
+	case gvk.Group == "cert-manager.io" && gvk.Kind == "Certificate":
+		status, ok := getStatusFromUnstructured(obj, "Ready")
+		if !ok {
+			return metav1.ConditionUnknown, "certificate has not been issued", true
+		}
+		return status, fmt.Sprintf("Ready condition is %s", status), true
+
+	case gvk.Group == "" && gvk.Kind == "PersistentVolumeClaim":
+		phase, _, _ := unstructured.NestedString(obj.Object, "status", "phase")
+		if phase == "Bound" {
+			return metav1.ConditionTrue, "claim is bound", true
+		}
+		if phase == "" {
+			phase = "Pending"
+		}
+		return metav1.ConditionFalse, fmt.Sprintf("claim is %s", phase), true
+	}
+	return metav1.ConditionUnknown, "", false
+}
+
+// Work out if any object is ready using kstatus, which understands both
+// standard conditions and the status fields of common built-in kinds.
+// Current is True, InProgress and Failed are False, anything else Unknown.
+func getKstatusReadiness(obj *unstructured.Unstructured) (metav1.ConditionStatus, string) {
+	result, err := status.Compute(obj)
+	if err != nil {
+		return metav1.ConditionUnknown, fmt.Sprintf("error computing status: %s", err)
+	}
+	message := string(result.Status)
+	if result.Message != "" {
+		message = fmt.Sprintf("%s: %s", result.Status, result.Message)
+	}
+	switch result.Status {
+	case status.CurrentStatus:
+		return metav1.ConditionTrue, message
+	case status.InProgressStatus, status.FailedStatus, status.TerminatingStatus:
+		return metav1.ConditionFalse, message
+	default:
+		return metav1.ConditionUnknown, message
+	}
+}
@@ -17,35 +17,37 @@ limitations under the License.
 package components
 
 import (
+	"fmt"
 	"strings"
 
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-
 	"github.com/coderanger/controller-utils/conditions"
 	"github.com/coderanger/controller-utils/core"
 	"github.com/pkg/errors"
 )
 
 type readyStatusComponent struct {
-	keys            []string
-	readyConditions map[string]metav1.ConditionStatus
+	keys []string
+	// Parsed from keys, kept in the same order so messages are stable.
+	conditionTypes []string
+	polarities     map[string]core.Polarity
 }
 
-// Create a ReadyStatus component. Takes 0 or more conditions types. If a type
-// name starts with `-` then the goal is for it to be false, otherwise true. If
-// all requested conditions match, the Ready condition will be set to True.
+// Create a ReadyStatus component. Takes 0 or more conditions types. Each type
+// is expected to have its healthy status as registered with
+// core.RegisterConditionPolarity, True by default. For compatibility a type
+// name starting with `-` is treated as negative. If all requested conditions
+// are healthy, the Ready condition will be set to True, otherwise False. The
+// reason is the reasons of the failing conditions in order, joined with
+// commas, e.g. `Fake,ThreeNotSet`, so each one can be picked out.
 func NewReadyStatusComponent(keys ...string) core.Component {
-	// Parse the keys to a map of the desired statuses so it's faster to check them in the Reconcile.
-	readyConditions := map[string]metav1.ConditionStatus{}
+	conditionTypes := make([]string, 0, len(keys))
+	polarities := map[string]core.Polarity{}
 	for _, key := range keys {
-		target := metav1.ConditionTrue
-		if strings.HasPrefix(key, "-") {
-			key = key[1:]
-			target = metav1.ConditionFalse
-		}
-		readyConditions[key] = target
+		conditionType, polarity := core.ParseConditionPolarity(key, '-')
+		conditionTypes = append(conditionTypes, conditionType)
+		polarities[conditionType] = polarity
 	}
-	return &readyStatusComponent{keys: keys, readyConditions: readyConditions}
+	return &readyStatusComponent{keys: keys, conditionTypes: conditionTypes, polarities: polarities}
 }
 
 func (comp *readyStatusComponent) GetReadyCondition() string {
@@ -58,26 +60,43 @@ func (comp *readyStatusComponent) Reconcile(ctx *core.Context) (core.Result, err
 		return core.Result{}, errors.Wrap(err, "error getting object conditions")
 	}
 	failedKeys := []string{}
-	for conditionType, desiredStatus := range comp.readyConditions {
-		if !conditions.IsStatusConditionPresentAndEqual(*objConditions, conditionType, desiredStatus) {
+	failedDetails := []string{}
+	failedReasons := []string{}
+	for _, conditionType := range comp.conditionTypes {
+		desiredStatus := comp.polarities[conditionType].HealthyStatus()
+		cond := conditions.FindStatusCondition(*objConditions, conditionType)
+		reason := ""
+		if cond == nil {
+			reason = conditionType + "NotSet"
+			failedKeys = append(failedKeys, conditionType)
+			failedDetails = append(failedDetails, fmt.Sprintf("%s is not set, expected %s", conditionType, desiredStatus))
+		} else if cond.ObservedGeneration != 0 && cond.ObservedGeneration < ctx.Object.GetGeneration() {
+			// Status from an older spec can't be trusted, whatever it says.
+			reason = conditionType + "Stale"
 			failedKeys = append(failedKeys, conditionType)
+			failedDetails = append(failedDetails, fmt.Sprintf("%s is stale, observed generation %d but object is at %d", conditionType, cond.ObservedGeneration, ctx.Object.GetGeneration()))
+		} else if cond.Status != desiredStatus {
+			reason = cond.Reason
+			if reason == "" {
+				reason = conditionType + "NotReady"
+			}
+			failedKeys = append(failedKeys, conditionType)
+			detail := fmt.Sprintf("%s is %s, expected %s (%s", conditionType, cond.Status, desiredStatus, cond.Reason)
+			if cond.Message != "" {
+				detail += ": " + cond.Message
+			}
+			failedDetails = append(failedDetails, detail+")")
+		}
+		if reason != "" {
+			failedReasons = append(failedReasons, reason)
 		}
 	}
-	var status metav1.ConditionStatus
-	var reason, message, messageKeys string
 	if len(failedKeys) == 0 {
-		status = metav1.ConditionTrue
-		reason = "CompositeReady"
-		message = "observed"
-		messageKeys = strings.Join(comp.keys, ", ")
+		// TODO The condition type should be configurable somehow.
+		ctx.Conditions.SetfTrue("Ready", "CompositeReady", "ReadyStatusComponent observed correct status of %s", strings.Join(comp.keys, ", "))
 	} else {
-		status = metav1.ConditionFalse
-		reason = "CompositeNotReady"
-		message = "did not observe"
-		messageKeys = strings.Join(failedKeys, ", ")
+		ctx.Conditions.SetfFalse("Ready", strings.Join(failedReasons, ","), "ReadyStatusComponent did not observe correct status of %s: %s", strings.Join(failedKeys, ", "), strings.Join(failedDetails, "; "))
 	}
-	// TODO The condition type should be configurable somehow.
-	ctx.Conditions.Setf("Ready", status, reason, "ReadyStatusComponent %s correct status of %s", message, messageKeys)
 	return core.Result{}, nil
 }
 
@@ -111,7 +111,92 @@ var _ = Describe("ReadyStatus component", func() {
 
 		c.EventuallyGetName(obj.Name, obj, c.EventuallyCondition("Ready", "False"))
 		cond := conditions.FindStatusCondition(obj.Status.Conditions, "Ready")
-		Expect(cond.Message).To(Equal("ReadyStatusComponent did not observe correct status of Two"))
+		Expect(cond.Reason).To(Equal("Fake"))
+		Expect(cond.Message).To(Equal("ReadyStatusComponent did not observe correct status of Two: Two is False, expected True (Fake)"))
+	})
+
+	It("reports all unmet conditions", func() {
+		comp := NewReadyStatusComponent("One", "Two", "-Three")
+		helper = startTestController(comp)
+		c := helper.TestClient
+
+		c.Create(obj)
+		c.EventuallyGetName(obj.Name, obj, c.EventuallyCondition("Ready", "False"))
+
+		objClean := obj.DeepCopy()
+		setCondition("One", metav1.ConditionTrue)
+		setCondition("Two", metav1.ConditionFalse)
+		c.Status().Patch(obj, client.MergeFrom(objClean))
+
+		c.EventuallyGetName(obj.Name, obj, c.EventuallyValue(ContainSubstring("Two is False"), func(obj client.Object) (interface{}, error) {
+			cond := conditions.FindStatusCondition(obj.(*TestObject).Status.Conditions, "Ready")
+			if cond == nil {
+				return "", nil
+			}
+			return cond.Message, nil
+		}))
+		cond := conditions.FindStatusCondition(obj.Status.Conditions, "Ready")
+		Expect(cond.Status).To(Equal(metav1.ConditionFalse))
+		Expect(cond.Reason).To(Equal("Fake,ThreeNotSet"))
+		Expect(cond.Message).To(Equal("ReadyStatusComponent did not observe correct status of Two, Three: Two is False, expected True (Fake); Three is not set, expected False"))
+	})
+
+	It("joins the reasons of two failing conditions", func() {
+		comp := NewReadyStatusComponent("One", "Two")
+		helper = startTestController(comp)
+		c := helper.TestClient
+
+		c.Create(obj)
+		c.EventuallyGetName(obj.Name, obj, c.EventuallyCondition("Ready", "False"))
+		cond := conditions.FindStatusCondition(obj.Status.Conditions, "Ready")
+		Expect(cond.Reason).To(Equal("OneNotSet,TwoNotSet"))
+
+		objClean := obj.DeepCopy()
+		setCondition("One", metav1.ConditionFalse)
+		conditions.SetStatusCondition(&obj.Status.Conditions, conditions.Condition{
+			Type:               "Two",
+			Status:             metav1.ConditionFalse,
+			ObservedGeneration: obj.Generation,
+			Reason:             "Broken",
+			Message:            "it broke",
+		})
+		c.Status().Patch(obj, client.MergeFrom(objClean))
+
+		c.EventuallyGetName(obj.Name, obj, c.EventuallyValue(Equal("Fake,Broken"), func(obj client.Object) (interface{}, error) {
+			cond := conditions.FindStatusCondition(obj.(*TestObject).Status.Conditions, "Ready")
+			if cond == nil {
+				return "", nil
+			}
+			return cond.Reason, nil
+		}))
+		cond = conditions.FindStatusCondition(obj.Status.Conditions, "Ready")
+		Expect(cond.Status).To(Equal(metav1.ConditionFalse))
+		Expect(cond.Message).To(Equal("ReadyStatusComponent did not observe correct status of One, Two: One is False, expected True (Fake); Two is False, expected True (Broken: it broke)"))
+	})
+
+	It("ignores conditions from an older generation", func() {
+		comp := NewReadyStatusComponent("One")
+		helper = startTestController(comp)
+		c := helper.TestClient
+
+		c.Create(obj)
+		c.EventuallyGetName(obj.Name, obj, c.EventuallyCondition("Ready", "False"))
+
+		objClean := obj.DeepCopy()
+		setCondition("One", metav1.ConditionTrue)
+		c.Status().Patch(obj, client.MergeFrom(objClean))
+
+		c.EventuallyGetName(obj.Name, obj, c.EventuallyCondition("Ready", "True"))
+
+		// Change the spec so One is now stale.
+		objClean = obj.DeepCopy()
+		obj.Spec.Field = "changed"
+		c.Patch(obj, client.MergeFrom(objClean))
+
+		c.EventuallyGetName(obj.Name, obj, c.EventuallyCondition("Ready", "False"))
+		cond := conditions.FindStatusCondition(obj.Status.Conditions, "Ready")
+		Expect(cond.Reason).To(Equal("OneStale"))
+		Expect(cond.Message).To(ContainSubstring("One is stale"))
 	})
 
 	It("handles negative polarity", func() {
@@ -121,6 +206,8 @@ var _ = Describe("ReadyStatus component", func() {
 
 		c.Create(obj)
 		c.EventuallyGetName(obj.Name, obj, c.EventuallyCondition("Ready", "False"))
+		cond := conditions.FindStatusCondition(obj.Status.Conditions, "Ready")
+		Expect(cond.Reason).To(Equal("OneNotSet"))
 
 		// Set things to True.
 		objClean := obj.DeepCopy()
@@ -0,0 +1,134 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package components
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/util/workqueue"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	"github.com/coderanger/controller-utils/core"
+)
+
+type statusMetric struct {
+	path  []string
+	gauge *prometheus.GaugeVec
+}
+
+type statusMetricsComponent struct {
+	prefix string
+	fields []statusMetric
+}
+
+// Create a StatusMetrics component. It exports status fields of each object
+// as Prometheus gauges labeled by namespace and name, registered with the
+// controller-runtime metrics registry. All metric names start with prefix,
+// e.g. `myapp_database`. Conditions are already exported for every object
+// by the reconciler as `controller_utils_condition_status`.
+func NewStatusMetricsComponent(prefix string) *statusMetricsComponent {
+	return &statusMetricsComponent{prefix: prefix}
+}
+
+// Export a numeric or boolean field, given as a dotted path like
+// `status.readyReplicas`, as the gauge `<prefix>_<name>`.
+func (comp *statusMetricsComponent) Field(name string, help string, path string) *statusMetricsComponent {
+	gauge := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: comp.prefix + "_" + name,
+		Help: help,
+	}, []string{"namespace", "name"})
+	comp.fields = append(comp.fields, statusMetric{path: strings.Split(path, "."), gauge: gauge})
+	return comp
+}
+
+func (comp *statusMetricsComponent) Setup(ctx *core.Context, bldr *ctrl.Builder) error {
+	for i, field := range comp.fields {
+		gauge, err := registerGaugeVec(field.gauge)
+		if err != nil {
+			return err
+		}
+		comp.fields[i].gauge = gauge
+	}
+	// Clean up the series when an object is deleted so it doesn't linger on dashboards.
+	bldr.Watches(&source.Kind{Type: ctx.Object}, handler.Funcs{DeleteFunc: comp.forget})
+	return nil
+}
+
+// Register a metric, reusing the existing one if it was already registered
+// by another controller instance.
+func registerGaugeVec(gauge *prometheus.GaugeVec) (*prometheus.GaugeVec, error) {
+	err := metrics.Registry.Register(gauge)
+	if err != nil {
+		if existing, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			if existingGauge, ok := existing.ExistingCollector.(*prometheus.GaugeVec); ok {
+				return existingGauge, nil
+			}
+		}
+		return nil, errors.Wrap(err, "error registering metric")
+	}
+	return gauge, nil
+}
+
+func (comp *statusMetricsComponent) Reconcile(ctx *core.Context) (core.Result, error) {
+	namespace := ctx.Object.GetNamespace()
+	name := ctx.Object.GetName()
+
+	data, err := runtime.DefaultUnstructuredConverter.ToUnstructured(ctx.Object)
+	if err != nil {
+		return core.Result{}, errors.Wrap(err, "error converting object to unstructured")
+	}
+	for _, field := range comp.fields {
+		raw, found, err := unstructured.NestedFieldNoCopy(data, field.path...)
+		value, ok := metricValue(raw)
+		if err != nil || !found || !ok {
+			field.gauge.DeleteLabelValues(namespace, name)
+			continue
+		}
+		field.gauge.WithLabelValues(namespace, name).Set(value)
+	}
+	return core.Result{}, nil
+}
+
+func (comp *statusMetricsComponent) forget(evt event.DeleteEvent, _ workqueue.RateLimitingInterface) {
+	for _, field := range comp.fields {
+		field.gauge.DeleteLabelValues(evt.Object.GetNamespace(), evt.Object.GetName())
+	}
+}
+
+func metricValue(raw interface{}) (float64, bool) {
+	switch v := raw.(type) {
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	case bool:
+		if v {
+			return 1, true
+		}
+		return 0, true
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,61 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package components
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/coderanger/controller-utils/tests"
+)
+
+var _ = Describe("StatusMetrics component", func() {
+	var helper *tests.FunctionalHelper
+	var obj *TestObject
+
+	BeforeEach(func() {
+		obj = &TestObject{
+			ObjectMeta: metav1.ObjectMeta{Name: "testing"},
+		}
+	})
+
+	AfterEach(func() {
+		if helper != nil {
+			helper.MustStop()
+		}
+		helper = nil
+	})
+
+	It("exports field metrics", func() {
+		comp := NewStatusMetricsComponent("test_status_metrics").Field("generation", "Generation of the object.", "metadata.generation")
+		helper = startTestController(comp)
+		c := helper.TestClient
+		gauge := comp.fields[0].gauge
+
+		c.Create(obj)
+		Eventually(func() float64 {
+			return testutil.ToFloat64(gauge.WithLabelValues(helper.Namespace, "testing"))
+		}).Should(Equal(1.0))
+
+		c.Delete(obj)
+		Eventually(func() int {
+			return testutil.CollectAndCount(gauge)
+		}).Should(Equal(0))
+	})
+})
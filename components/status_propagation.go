@@ -0,0 +1,119 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package components
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/jsonpath"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	"github.com/coderanger/controller-utils/core"
+)
+
+type statusMapping struct {
+	dest []string
+	path *jsonpath.JSONPath
+}
+
+type statusPropagationComponent struct {
+	gvk      schema.GroupVersionKind
+	name     string
+	mappings []statusMapping
+}
+
+// Create a StatusPropagation component. It copies fields from an owned object
+// into the object status so users can find things like a LoadBalancer IP
+// without digging through child objects. The name is a template rendered like
+// a TemplateComponent.
+func NewStatusPropagationComponent(gvk schema.GroupVersionKind, name string) *statusPropagationComponent {
+	return &statusPropagationComponent{gvk: gvk, name: name}
+}
+
+// Copy the result of a JSONPath expression on the owned object to a dotted
+// field path on the object, e.g.
+// `Field("status.loadBalancerIP", "{.status.loadBalancer.ingress[0].ip}")`.
+// If the expression matches nothing, the field is removed. Panics if the
+// expression is invalid, since it's set at startup.
+func (comp *statusPropagationComponent) Field(dest string, path string) *statusPropagationComponent {
+	if !strings.HasPrefix(path, "{") {
+		path = "{" + path + "}"
+	}
+	jp := jsonpath.New(dest).AllowMissingKeys(true)
+	err := jp.Parse(path)
+	if err != nil {
+		panic(errors.Wrapf(err, "error parsing JSONPath %s", path))
+	}
+	comp.mappings = append(comp.mappings, statusMapping{dest: strings.Split(dest, "."), path: jp})
+	return comp
+}
+
+func (comp *statusPropagationComponent) Setup(_ *core.Context, bldr *ctrl.Builder) error {
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(comp.gvk)
+	bldr.Owns(obj)
+	return nil
+}
+
+func (comp *statusPropagationComponent) Reconcile(ctx *core.Context) (core.Result, error) {
+	name, err := renderString(ctx, comp.name, newTemplateData(ctx))
+	if err != nil {
+		return core.Result{}, errors.Wrap(err, "error rendering name")
+	}
+	source := &unstructured.Unstructured{}
+	source.SetGroupVersionKind(comp.gvk)
+	err = ctx.Client.Get(ctx, types.NamespacedName{Name: name, Namespace: ctx.Object.GetNamespace()}, source)
+	if err != nil {
+		if kerrors.IsNotFound(err) {
+			// Nothing to copy yet, we'll get another event when it's created.
+			return core.Result{}, nil
+		}
+		return core.Result{}, errors.Wrapf(err, "error getting %s %s", comp.gvk.Kind, name)
+	}
+
+	// Round trip the object through unstructured so the mappings can use field paths.
+	data, err := runtime.DefaultUnstructuredConverter.ToUnstructured(ctx.Object)
+	if err != nil {
+		return core.Result{}, errors.Wrap(err, "error converting object to unstructured")
+	}
+	for _, mapping := range comp.mappings {
+		results, err := mapping.path.FindResults(source.Object)
+		if err != nil {
+			return core.Result{}, errors.Wrapf(err, "error evaluating JSONPath for %s", strings.Join(mapping.dest, "."))
+		}
+		if len(results) == 0 || len(results[0]) == 0 {
+			unstructured.RemoveNestedField(data, mapping.dest...)
+			continue
+		}
+		val := runtime.DeepCopyJSONValue(results[0][0].Interface())
+		err = unstructured.SetNestedField(data, val, mapping.dest...)
+		if err != nil {
+			return core.Result{}, errors.Wrapf(err, "error setting %s", strings.Join(mapping.dest, "."))
+		}
+	}
+	err = runtime.DefaultUnstructuredConverter.FromUnstructured(data, ctx.Object)
+	if err != nil {
+		return core.Result{}, errors.Wrap(err, "error converting object from unstructured")
+	}
+	return core.Result{}, nil
+}
@@ -0,0 +1,59 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package components
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/coderanger/controller-utils/tests"
+)
+
+var _ = Describe("StatusPropagation component", func() {
+	var helper *tests.FunctionalHelper
+	var obj *TestObject
+
+	BeforeEach(func() {
+		obj = &TestObject{
+			ObjectMeta: metav1.ObjectMeta{Name: "testing"},
+		}
+	})
+
+	AfterEach(func() {
+		if helper != nil {
+			helper.MustStop()
+		}
+		helper = nil
+	})
+
+	It("copies a field into the status", func() {
+		dataComp := &injectDataComponent{key: "FOO", value: "bar"}
+		configMapComp := NewTemplateComponent("configmap.yml", "")
+		comp := NewStatusPropagationComponent(schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}, "{{ .Object.Name }}").
+			Field("status.field", ".data.FOO")
+		helper = startTestController(dataComp, configMapComp, comp)
+		c := helper.TestClient
+
+		c.Create(obj)
+		c.EventuallyGetName(obj.Name, obj, c.EventuallyValue(Equal("bar"), func(obj client.Object) (interface{}, error) {
+			return obj.(*TestObject).Status.Field, nil
+		}))
+	})
+})
@@ -44,12 +44,13 @@ func TestComponents(t *testing.T) {
 }
 
 var _ = BeforeSuite(func(done Done) {
-	ctrl.SetLogger(zap.New(zap.UseDevMode(true), zap.WriteTo(GinkgoWriter)))
+	ctrl.SetLogger(tests.RecordingLogger(zap.New(zap.UseDevMode(true), zap.WriteTo(GinkgoWriter))))
 
 	By("bootstrapping test environment")
 	suiteHelper = tests.Functional().
 		API(TestObjectSchemeBuilder.AddToScheme).
 		CRDPath("test_crds").
+		FakeClock().
 		MustBuild()
 
 	close(done)
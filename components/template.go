@@ -17,21 +17,37 @@ limitations under the License.
 package components
 
 import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
+	"k8s.io/apiextensions-apiserver/pkg/apis/apiextensions"
+	apiextv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apiextensions-apiserver/pkg/apiserver/validation"
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	utiljson "k8s.io/apimachinery/pkg/util/json"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/kube-openapi/pkg/validation/validate"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
+	"github.com/coderanger/controller-utils/conditions"
 	"github.com/coderanger/controller-utils/core"
 	"github.com/coderanger/controller-utils/predicates"
 	"github.com/coderanger/controller-utils/templates"
@@ -41,19 +57,156 @@ const DELETE_ANNOTATION = "controller-utils/delete"
 const CONDITION_ANNOTATION = "controller-utils/condition"
 const DEEPEQUALS_ANNOTATION = "controller-utils/deepEquals"
 const SECRETFIELD_ANNOTATION = "controller-utils/secretField"
+const PROPAGATION_ANNOTATION = "controller-utils/propagationPolicy"
+const KSTATUS_ANNOTATION = "controller-utils/kstatus"
+const WAVE_ANNOTATION = "controller-utils/wave"
+const LIST_TEMPLATE_ANNOTATION = "controller-utils/listTemplate"
 
 type templateComponent struct {
-	template      string
-	conditionType string
+	template         string
+	conditionType    string
+	readinessTimeout time.Duration
+	optionalAPI      bool
+	apiMissing       bool
+	propagation      metav1.DeletionPropagation
+	dataFunc         TemplateDataFunc
+	overlays         []templateOverlay
+	helpers          []string
+	mutators         []TemplateMutator
+	dataSchema       *validate.SchemaValidator
+	dataSchemaErr    error
+	metadataOnly     bool
+	// Set during Setup.
+	kinds []templateKind
+	waves []int
+}
+
+// Information about one kind of object rendered by a template, usually only
+// one unless it renders a List.
+type templateKind struct {
+	gvk             schema.GroupVersionKind
+	clusterScoped   bool
+	annotationOwned bool
 }
 
 type templateData struct {
-	Object client.Object
-	Data   map[string]interface{}
+	Object       client.Object
+	Data         map[string]interface{}
+	Capabilities core.Capabilities
+}
+
+func newTemplateData(ctx *core.Context) templateData {
+	return templateData{Object: ctx.Object, Data: ctx.Data, Capabilities: ctx.Capabilities}
+}
+
+// A function to build the values passed to a template.
+type TemplateDataFunc func(*core.Context) (interface{}, error)
+
+// A function to modify a rendered object before it is applied.
+type TemplateMutator func(*core.Context, client.Object) error
+
+func NewTemplateComponent(template string, conditionType string) *templateComponent {
+	return &templateComponent{template: template, conditionType: conditionType, propagation: metav1.DeletePropagationBackground}
+}
+
+// Create a Template component which renders with the value returned by
+// dataFunc rather than the object and context data, so templates can use a
+// typed struct with documented fields. The func is also called during Setup
+// with a blank object and nil ctx.Data.
+func NewTypedTemplateComponent(template string, conditionType string, dataFunc TemplateDataFunc) *templateComponent {
+	comp := NewTemplateComponent(template, conditionType)
+	comp.dataFunc = dataFunc
+	return comp
 }
 
-func NewTemplateComponent(template string, conditionType string) core.Component {
-	return &templateComponent{template: template, conditionType: conditionType}
+// Set the propagation policy used when deleting the object. Defaults to
+// Background, can be overridden per object with the
+// controller-utils/propagationPolicy annotation.
+func (comp *templateComponent) WithPropagationPolicy(propagation metav1.DeletionPropagation) *templateComponent {
+	comp.propagation = propagation
+	return comp
+}
+
+// Set the condition to False with reason ProgressDeadlineExceeded if the
+// object isn't ready within this long of the last change we applied to it.
+func (comp *templateComponent) WithReadinessTimeout(timeout time.Duration) *templateComponent {
+	comp.readinessTimeout = timeout
+	return comp
+}
+
+// Skip this template if its API isn't installed in the cluster when the
+// controller starts, for optional integrations like the Prometheus operator.
+func (comp *templateComponent) WithOptionalAPI() *templateComponent {
+	comp.optionalAPI = true
+	return comp
+}
+
+// Apply overlay templates on top of the main template, in order. Each
+// overlay name is itself a template so it can be chosen by spec fields, e.g.
+// `overlays/{{ .Object.Spec.Flavor }}.yml`. Overlays rendering to a list are
+// applied as a JSON patch, otherwise as a strategic merge patch (or a JSON
+// merge patch for types not in the scheme). Overlays whose name renders empty
+// are skipped, a missing file is an error.
+func (comp *templateComponent) WithOverlays(overlays ...string) *templateComponent {
+	for _, name := range overlays {
+		comp.overlays = append(comp.overlays, templateOverlay{name: name})
+	}
+	return comp
+}
+
+// Apply overlays like WithOverlays, but skip them if the file doesn't exist,
+// e.g. when only some flavors need one.
+func (comp *templateComponent) WithOptionalOverlays(overlays ...string) *templateComponent {
+	for _, name := range overlays {
+		comp.overlays = append(comp.overlays, templateOverlay{name: name, optional: true})
+	}
+	return comp
+}
+
+// Load helper templates from these globs instead of the ones set on the
+// Reconciler, for templates that use a different helper library.
+func (comp *templateComponent) WithHelpers(globs ...string) *templateComponent {
+	comp.helpers = append(comp.helpers, globs...)
+	return comp
+}
+
+// Run a function on the rendered object before it is applied, for changes
+// that are awkward to write in a template like injecting sidecars. If the
+// type is in the scheme the function gets a typed object, e.g. a
+// *appsv1.Deployment, otherwise an Unstructured. Mutators run in order, after
+// any overlays.
+func (comp *templateComponent) WithMutator(mutator TemplateMutator) *templateComponent {
+	comp.mutators = append(comp.mutators, mutator)
+	return comp
+}
+
+// Check ctx.Data against an OpenAPI schema, in the same format as a CRD
+// schema, before rendering. If another component didn't supply the expected
+// data the condition is set to False with the validation errors rather than
+// rendering a broken object.
+func (comp *templateComponent) WithDataSchema(schema *apiextv1.JSONSchemaProps) *templateComponent {
+	internal := &apiextensions.JSONSchemaProps{}
+	err := apiextv1.Convert_v1_JSONSchemaProps_To_apiextensions_JSONSchemaProps(schema, internal, nil)
+	if err != nil {
+		comp.dataSchemaErr = errors.Wrap(err, "error converting data schema")
+		return comp
+	}
+	validator, _, err := validation.NewSchemaValidator(&apiextensions.CustomResourceValidation{OpenAPIV3Schema: internal})
+	if err != nil {
+		comp.dataSchemaErr = errors.Wrap(err, "error building data schema")
+		return comp
+	}
+	comp.dataSchema = validator
+	return comp
+}
+
+// Only cache the metadata of rendered objects, for templates creating many
+// large objects like Secrets or ConfigMaps. Changes are noticed on any update
+// since data-based predicates like the deepEquals annotation need the full
+// object, and status checks read from the API server directly.
+func (comp *templateComponent) WithMetadataOnly() *templateComponent {
+	comp.metadataOnly = true
+	return comp
 }
 
 func (comp *templateComponent) GetReadyCondition() string {
@@ -61,35 +214,435 @@ func (comp *templateComponent) GetReadyCondition() string {
 }
 
 func (comp *templateComponent) Setup(ctx *core.Context, bldr *ctrl.Builder) error {
+	if comp.dataSchemaErr != nil {
+		return comp.dataSchemaErr
+	}
 	// Render with a fake, blank object just to find the object type.
 	obj, err := comp.renderTemplate(ctx, true)
 	if err != nil {
 		return errors.Wrap(err, "error rendering setup template")
 	}
+	objs := []*unstructured.Unstructured{obj.(*unstructured.Unstructured)}
+	if isList(objs[0]) {
+		objs, err = listItems(objs[0])
+		if err != nil {
+			return err
+		}
+	}
+
+	if comp.optionalAPI {
+		for _, obj := range objs {
+			gvk := obj.GroupVersionKind()
+			available, err := apiAvailable(ctx, gvk)
+			if err != nil {
+				return err
+			}
+			if !available {
+				// Can't watch an API that doesn't exist, so don't try.
+				ctx.Log.Info("API is not installed, template will be skipped", "gvk", gvk)
+				comp.apiMissing = true
+				return nil
+			}
+		}
+	}
+
+	// Record apply waves so the Reconciler can check they are in order.
+	for _, obj := range objs {
+		val, ok := obj.GetAnnotations()[WAVE_ANNOTATION]
+		if !ok || val == "" {
+			continue
+		}
+		wave, err := strconv.Atoi(val)
+		if err != nil {
+			return errors.Wrapf(err, "invalid wave %s", val)
+		}
+		comp.waves = append(comp.waves, wave)
+	}
+
+	seen := map[schema.GroupVersionKind]bool{}
+	for _, obj := range objs {
+		if seen[obj.GroupVersionKind()] {
+			continue
+		}
+		seen[obj.GroupVersionKind()] = true
+		err = comp.setupKind(ctx, bldr, obj)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Set up the watch for one kind of object rendered by the template.
+func (comp *templateComponent) setupKind(ctx *core.Context, bldr *ctrl.Builder, obj *unstructured.Unstructured) error {
+	// Work out if owner references will work or we need annotation ownership.
+	kind := templateKind{gvk: obj.GroupVersionKind()}
+	mapping, err := ctx.Client.RESTMapper().RESTMapping(kind.gvk.GroupKind(), kind.gvk.Version)
+	if err == nil && mapping.Scope.Name() == meta.RESTScopeNameRoot {
+		kind.clusterScoped = true
+	} else if err != nil && !meta.IsNoMatchError(err) {
+		return errors.Wrapf(err, "error getting REST mapping for %s", kind.gvk)
+	}
+	// Any namespace set in the template might not be ours at reconcile time,
+	// even if it is during setup, e.g. when it comes from a spec field which is
+	// empty here. So always be ready for annotation ownership.
+	_, namespaceSet, _ := unstructured.NestedFieldNoCopy(obj.Object, "metadata", "namespace")
+	kind.annotationOwned = kind.clusterScoped || namespaceSet
+	comp.kinds = append(comp.kinds, kind)
+
 	// Check if we should use the slower DeepEquals predicate.
 	annotations := obj.GetAnnotations()
 	deepEquals, ok := annotations[DEEPEQUALS_ANNOTATION]
 	secretField, ok2 := annotations[SECRETFIELD_ANNOTATION]
-	if ok && deepEquals == "true" {
-		bldr.Owns(obj, builder.WithPredicates(predicates.DeepEquals()))
+	preds := []predicate.Predicate{}
+	if comp.metadataOnly {
+		// Data predicates can't see anything but metadata.
+	} else if ok && deepEquals == "true" {
+		preds = append(preds, predicates.DeepEquals())
 	} else if ok2 && secretField != "" {
-		bldr.Owns(obj, builder.WithPredicates(predicates.SecretField(strings.Split(secretField, ","))))
-	} else {
-		bldr.Owns(obj)
+		secretPred, err := predicates.SecretField(strings.Split(secretField, ","))
+		if err != nil {
+			return errors.Wrapf(err, "error parsing %s annotation", SECRETFIELD_ANNOTATION)
+		}
+		preds = append(preds, secretPred)
+	}
+	if kind.annotationOwned {
+		eventHandler, err := core.EnqueueRequestForAnnotationOwner(ctx.Object, ctx.Scheme)
+		if err != nil {
+			return err
+		}
+		opts := []builder.WatchesOption{builder.WithPredicates(preds...)}
+		if comp.metadataOnly {
+			opts = append(opts, builder.OnlyMetadata)
+		}
+		bldr.Watches(&source.Kind{Type: obj}, eventHandler, opts...)
+	}
+	// Objects in our namespace still get owner references.
+	if !kind.clusterScoped {
+		opts := []builder.OwnsOption{builder.WithPredicates(preds...)}
+		if comp.metadataOnly {
+			opts = append(opts, builder.OnlyMetadata)
+		}
+		bldr.Owns(obj, opts...)
 	}
 	return nil
 }
 
+// Check if a kind is cluster scoped, from Setup if it was rendered then or
+// else from the RESTMapper.
+func (comp *templateComponent) isClusterScoped(ctx *core.Context, gvk schema.GroupVersionKind) (bool, error) {
+	for _, kind := range comp.kinds {
+		if kind.gvk == gvk {
+			return kind.clusterScoped, nil
+		}
+	}
+	mapping, err := ctx.Client.RESTMapper().RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return false, errors.Wrapf(err, "error getting REST mapping for %s", gvk)
+	}
+	return mapping.Scope.Name() == meta.RESTScopeNameRoot, nil
+}
+
+// Check if a kind was rendered during Setup, and so is watched.
+func (comp *templateComponent) watchesKind(gvk schema.GroupVersionKind) bool {
+	for _, kind := range comp.kinds {
+		if kind.gvk == gvk {
+			return true
+		}
+	}
+	return false
+}
+
+func (comp *templateComponent) GetWaves() []int {
+	return comp.waves
+}
+
+// Only needed for objects that can't be cleaned up by owner references.
+func (comp *templateComponent) NeedsFinalizer() bool {
+	for _, kind := range comp.kinds {
+		if kind.annotationOwned {
+			return true
+		}
+	}
+	return false
+}
+
+func (comp *templateComponent) Finalize(ctx *core.Context) (core.Result, bool, error) {
+	for _, kind := range comp.kinds {
+		if !kind.annotationOwned {
+			continue
+		}
+		children, err := comp.listAnnotationOwned(ctx, kind.gvk)
+		if err != nil {
+			return core.Result{}, false, errors.Wrapf(err, "error listing %s children", kind.gvk.Kind)
+		}
+		for _, child := range children {
+			err = ctx.Client.Delete(ctx, child, &client.DeleteOptions{PropagationPolicy: &comp.propagation})
+			if err != nil && !kerrors.IsNotFound(err) {
+				return core.Result{}, false, errors.Wrapf(err, "error deleting %s %s", kind.gvk.Kind, child.GetName())
+			}
+		}
+	}
+	return core.Result{}, true, nil
+}
+
+// List objects of one kind owned by the reconcile object via labels.
+func (comp *templateComponent) listAnnotationOwned(ctx *core.Context, gvk schema.GroupVersionKind) ([]client.Object, error) {
+	return comp.listChildren(ctx, gvk, client.MatchingLabels{core.OWNER_UID_LABEL: string(ctx.Object.GetUID())})
+}
+
+// List objects of one kind owned by the reconcile object, either by an owner
+// reference or, for kinds which might be in other namespaces, by labels.
+func (comp *templateComponent) listOwned(ctx *core.Context, kind templateKind) ([]client.Object, error) {
+	owned := []client.Object{}
+	if !kind.clusterScoped {
+		children, err := comp.listChildren(ctx, kind.gvk, client.InNamespace(ctx.Object.GetNamespace()))
+		if err != nil {
+			return nil, err
+		}
+		for _, child := range children {
+			if metav1.IsControlledBy(child, ctx.Object) {
+				owned = append(owned, child)
+			}
+		}
+	}
+	if kind.annotationOwned {
+		children, err := comp.listAnnotationOwned(ctx, kind.gvk)
+		if err != nil {
+			return nil, err
+		}
+		owned = append(owned, children...)
+	}
+	return owned, nil
+}
+
+// List objects of one kind, reading the metadata-only cache if that's what
+// was watched.
+func (comp *templateComponent) listChildren(ctx *core.Context, gvk schema.GroupVersionKind, opts ...client.ListOption) ([]client.Object, error) {
+	listGVK := gvk.GroupVersion().WithKind(gvk.Kind + "List")
+	objs := []client.Object{}
+	if comp.metadataOnly {
+		children := &metav1.PartialObjectMetadataList{}
+		children.SetGroupVersionKind(listGVK)
+		err := ctx.Client.List(ctx, children, opts...)
+		if err != nil {
+			return nil, err
+		}
+		for i := range children.Items {
+			child := &children.Items[i]
+			// List items don't carry their own kind, but Delete needs it.
+			child.SetGroupVersionKind(gvk)
+			objs = append(objs, child)
+		}
+		return objs, nil
+	}
+	children := &unstructured.UnstructuredList{}
+	children.SetGroupVersionKind(listGVK)
+	err := ctx.Client.List(ctx, children, opts...)
+	if err != nil {
+		return nil, err
+	}
+	for i := range children.Items {
+		child := &children.Items[i]
+		child.SetGroupVersionKind(gvk)
+		objs = append(objs, child)
+	}
+	return objs, nil
+}
+
 func (comp *templateComponent) Reconcile(ctx *core.Context) (core.Result, error) {
+	if comp.apiMissing {
+		if comp.conditionType != "" {
+			ctx.Conditions.SetfTrue(comp.conditionType, "APINotInstalled", "API for template %s is not installed", comp.template)
+		}
+		return core.Result{}, nil
+	}
+
+	// Make sure we have all the data the template needs.
+	if comp.dataSchema != nil {
+		errs, err := comp.validateData(ctx)
+		if err != nil {
+			return core.Result{}, err
+		}
+		if len(errs) != 0 {
+			if comp.conditionType == "" {
+				return core.Result{}, errors.Errorf("invalid template data for %s: %s", comp.template, errs.ToAggregate())
+			}
+			ctx.Conditions.SetfFalse(comp.conditionType, "InvalidTemplateData", "Invalid template data for %s: %s", comp.template, errs.ToAggregate())
+			return core.Result{}, nil
+		}
+	}
+
 	// Render the object to an Unstructured.
-	obj, err := comp.renderTemplate(ctx, true)
+	rendered, err := comp.renderTemplate(ctx, true)
 	if err != nil {
 		return core.Result{}, errors.Wrap(err, "error rendering template")
 	}
+	obj := rendered.(*unstructured.Unstructured)
+	if isList(obj) {
+		return comp.reconcileList(ctx, obj)
+	}
+	return comp.reconcileObject(ctx, obj)
+}
+
+// Apply each item of a List separately, carrying on past failures so one
+// bad item doesn't block the rest. The condition is False naming the first
+// failing item if any item failed, and only True if at least one item
+// reported ready and none are pending. Otherwise it is Unknown. Items
+// rendered by an earlier reconcile but not this one are deleted.
+func (comp *templateComponent) reconcileList(ctx *core.Context, list *unstructured.Unstructured) (core.Result, error) {
+	items, err := listItems(list)
+	if err != nil {
+		return core.Result{}, err
+	}
+	result := core.Result{}
+	errs := []error{}
+	var failure, pending *conditions.Condition
+	ready := 0
+	rendered := map[listItemKey]bool{}
+	for i, item := range items {
+		itemResult, cond, err := comp.reconcileListItem(ctx, item)
+		if err != nil {
+			err = errors.Wrapf(err, "error reconciling list item %d (%s %s)", i, item.GetKind(), item.GetName())
+			errs = append(errs, err)
+			if failure == nil {
+				failure = &conditions.Condition{Reason: core.ReasonForError(err), Message: err.Error()}
+			}
+			continue
+		}
+		if cond != nil {
+			switch {
+			case cond.Status == metav1.ConditionTrue:
+				ready++
+			case cond.Status == metav1.ConditionFalse && failure == nil:
+				failure = cond
+				failure.Message = fmt.Sprintf("List item %d (%s %s): %s", i, item.GetKind(), item.GetName(), cond.Message)
+			case cond.Status == metav1.ConditionUnknown && pending == nil:
+				pending = cond
+				pending.Message = fmt.Sprintf("List item %d (%s %s): %s", i, item.GetKind(), item.GetName(), cond.Message)
+			}
+		}
+		rendered[listItemKey{gvk: item.GroupVersionKind(), NamespacedName: types.NamespacedName{Namespace: item.GetNamespace(), Name: item.GetName()}}] = true
+		if itemResult.RequeueAfter != 0 && (result.RequeueAfter == 0 || itemResult.RequeueAfter < result.RequeueAfter) {
+			result.RequeueAfter = itemResult.RequeueAfter
+		}
+		result.Requeue = result.Requeue || itemResult.Requeue
+	}
+
+	// Only prune once every item applied, a failed item might not have the
+	// name or namespace it will end up with.
+	if len(errs) == 0 {
+		err = comp.pruneList(ctx, rendered)
+		if err != nil {
+			errs = append(errs, err)
+			failure = &conditions.Condition{Reason: core.ReasonForError(err), Message: err.Error()}
+		}
+	}
+
+	if comp.conditionType != "" {
+		switch {
+		case failure != nil:
+			ctx.Conditions.SetfFalse(comp.conditionType, failure.Reason, "%s", failure.Message)
+		case pending != nil:
+			ctx.Conditions.SetfUnknown(comp.conditionType, pending.Reason, "%s", pending.Message)
+		case ready == 0:
+			ctx.Conditions.SetfUnknown(comp.conditionType, "UpstreamReadinessUnknown", "None of the %d list items report readiness", len(items))
+		case ready < len(items):
+			ctx.Conditions.SetfTrue(comp.conditionType, "UpstreamReady", "%d of %d list items are ready, the rest don't report readiness", ready, len(items))
+		default:
+			ctx.Conditions.SetfTrue(comp.conditionType, "UpstreamReady", "All %d list items are ready", len(items))
+		}
+	}
+	return result, utilerrors.NewAggregate(errs)
+}
+
+// Identifies an object rendered from a List, for pruning.
+type listItemKey struct {
+	gvk schema.GroupVersionKind
+	types.NamespacedName
+}
+
+// Reconcile one List item, returning the condition it set if any.
+func (comp *templateComponent) reconcileListItem(ctx *core.Context, item *unstructured.Unstructured) (core.Result, *conditions.Condition, error) {
+	if !comp.watchesKind(item.GroupVersionKind()) {
+		// Watches are set up from the setup render and can't be added later.
+		return core.Result{}, nil, errors.Errorf("%s was not rendered during setup so it can't be watched", item.GroupVersionKind())
+	}
+
+	// Mark where the object came from so it can be pruned once it isn't rendered.
+	annotations := item.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[LIST_TEMPLATE_ANNOTATION] = comp.template
+	item.SetAnnotations(annotations)
+
+	if comp.conditionType == "" {
+		result, err := comp.reconcileObject(ctx, item)
+		return result, nil, err
+	}
+	// Clear the condition first so we can tell if this item set it.
+	ctx.Conditions.SetUnknown(comp.conditionType, "")
+	result, err := comp.reconcileObject(ctx, item)
+	cond := ctx.Conditions.Get(comp.conditionType)
+	if cond == nil || cond.Reason == "" {
+		return result, nil, err
+	}
+	return result, cond, err
+}
+
+// Delete objects rendered from this List on an earlier reconcile which
+// aren't rendered anymore.
+func (comp *templateComponent) pruneList(ctx *core.Context, rendered map[listItemKey]bool) error {
+	for _, kind := range comp.kinds {
+		children, err := comp.listOwned(ctx, kind)
+		if err != nil {
+			return errors.Wrapf(err, "error listing %s children", kind.gvk.Kind)
+		}
+		for _, child := range children {
+			if child.GetAnnotations()[LIST_TEMPLATE_ANNOTATION] != comp.template {
+				continue
+			}
+			if rendered[listItemKey{gvk: kind.gvk, NamespacedName: types.NamespacedName{Namespace: child.GetNamespace(), Name: child.GetName()}}] {
+				continue
+			}
+			_, _, err = deleteIfOwned(ctx, child, comp.propagation, comp.metadataOnly)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (comp *templateComponent) reconcileObject(ctx *core.Context, obj *unstructured.Unstructured) (core.Result, error) {
+	// Layer on any variant-specific overlays.
+	err := comp.applyOverlays(ctx, obj)
+	if err != nil {
+		return core.Result{}, err
+	}
+
+	// Run any programmatic tweaks.
+	err = comp.runMutators(ctx, obj)
+	if err != nil {
+		return core.Result{}, err
+	}
+
+	// Secrets can be written with stringData, fold that into data.
+	err = normalizeSecretStringData(obj)
+	if err != nil {
+		return core.Result{}, err
+	}
 
 	// Default the namespace to the controlling object namespace.
 	if obj.GetNamespace() == "" {
-		obj.SetNamespace(ctx.Object.(metav1.Object).GetNamespace())
+		clusterScoped, err := comp.isClusterScoped(ctx, obj.GroupVersionKind())
+		if err != nil {
+			return core.Result{}, err
+		}
+		if !clusterScoped {
+			obj.SetNamespace(ctx.Object.(metav1.Object).GetNamespace())
+		}
 	}
 
 	// Check for delete annotation.
@@ -109,20 +662,247 @@ func (comp *templateComponent) Reconcile(ctx *core.Context) (core.Result, error)
 		obj.SetAnnotations(annotations)
 	}
 
+	// Check for a propagation policy override.
+	propagation := comp.propagation
+	if val, ok := annotations[PROPAGATION_ANNOTATION]; ok {
+		propagation = metav1.DeletionPropagation(val)
+		if propagation != metav1.DeletePropagationBackground && propagation != metav1.DeletePropagationForeground && propagation != metav1.DeletePropagationOrphan {
+			return core.Result{}, errors.Errorf("invalid propagation policy %s", val)
+		}
+		delete(annotations, PROPAGATION_ANNOTATION)
+		obj.SetAnnotations(annotations)
+	}
+
+	// Check for an apply wave, like Argo CD sync waves.
+	var wave *int
+	if val, ok := annotations[WAVE_ANNOTATION]; ok {
+		parsed, err := strconv.Atoi(val)
+		if err != nil {
+			return core.Result{}, errors.Wrapf(err, "invalid wave %s", val)
+		}
+		wave = &parsed
+		delete(annotations, WAVE_ANNOTATION)
+		obj.SetAnnotations(annotations)
+	}
+
 	if shouldDelete == "true" {
-		return comp.reconcileDelete(ctx, obj)
+		return comp.reconcileDelete(ctx, obj, propagation)
 	} else {
-		return comp.reconcileCreate(ctx, obj)
+		return comp.reconcileCreate(ctx, obj, wave)
 	}
 }
 
 func (comp *templateComponent) renderTemplate(ctx *core.Context, unstructured bool) (client.Object, error) {
-	return templates.Get(ctx.Templates, comp.template, unstructured, templateData{Object: ctx.Object, Data: ctx.Data})
+	data, err := comp.templateData(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return templates.GetWithOptions(ctx.Templates, comp.template, unstructured, data, comp.templateOptions(ctx))
+}
+
+func (comp *templateComponent) templateOptions(ctx *core.Context) templates.Options {
+	opts := templateOptions(ctx)
+	if comp.helpers != nil {
+		opts.Helpers = comp.helpers
+	}
+	return opts
+}
+
+func (comp *templateComponent) templateData(ctx *core.Context) (interface{}, error) {
+	if comp.dataFunc != nil {
+		data, err := comp.dataFunc(ctx)
+		if err != nil {
+			return nil, errors.Wrap(err, "error building template data")
+		}
+		return data, nil
+	}
+	return newTemplateData(ctx), nil
 }
 
-func (comp *templateComponent) reconcileCreate(ctx *core.Context, obj client.Object) (core.Result, error) {
-	// Set owner reference.
-	err := controllerutil.SetControllerReference(ctx.Object, obj, ctx.Scheme)
+// Many manifest generators wrap everything in a v1 List.
+func isList(obj *unstructured.Unstructured) bool {
+	return obj.GetAPIVersion() == "v1" && obj.GetKind() == "List"
+}
+
+func listItems(list *unstructured.Unstructured) ([]*unstructured.Unstructured, error) {
+	rawItems, _, err := unstructured.NestedSlice(list.Object, "items")
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading List items")
+	}
+	items := make([]*unstructured.Unstructured, 0, len(rawItems))
+	for i, rawItem := range rawItems {
+		item, ok := rawItem.(map[string]interface{})
+		if !ok {
+			return nil, errors.Errorf("List item %d is not an object", i)
+		}
+		items = append(items, &unstructured.Unstructured{Object: item})
+	}
+	return items, nil
+}
+
+func (comp *templateComponent) validateData(ctx *core.Context) (field.ErrorList, error) {
+	// Round trip through JSON so Go types look like they would in a schema.
+	raw, err := json.Marshal(ctx.Data)
+	if err != nil {
+		return nil, errors.Wrap(err, "error encoding template data")
+	}
+	var data interface{}
+	err = utiljson.Unmarshal(raw, &data)
+	if err != nil {
+		return nil, errors.Wrap(err, "error decoding template data")
+	}
+	return validation.ValidateCustomResource(field.NewPath("Data"), data, comp.dataSchema), nil
+}
+
+func (comp *templateComponent) runMutators(ctx *core.Context, obj *unstructured.Unstructured) error {
+	if len(comp.mutators) == 0 {
+		return nil
+	}
+	var target client.Object = obj
+	typed, err := ctx.Scheme.New(obj.GroupVersionKind())
+	if err == nil {
+		err = runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, typed)
+		if err != nil {
+			return errors.Wrapf(err, "error converting %s to typed object", obj.GetKind())
+		}
+		target = typed.(client.Object)
+	}
+	for _, mutator := range comp.mutators {
+		err = mutator(ctx, target)
+		if err != nil {
+			return errors.Wrap(err, "error running template mutator")
+		}
+	}
+	if target != obj {
+		data, err := runtime.DefaultUnstructuredConverter.ToUnstructured(target)
+		if err != nil {
+			return errors.Wrapf(err, "error converting %s from typed object", obj.GetKind())
+		}
+		gvk := obj.GroupVersionKind()
+		// Typed objects always have these, but we don't want to apply them.
+		pruneConversionZeroes(data, obj.Object)
+		unstructured.RemoveNestedField(data, "status")
+		obj.Object = data
+		obj.SetGroupVersionKind(gvk)
+	}
+	return nil
+}
+
+// Remove the nulls and empty structs a round trip through a typed object adds
+// at every level, like a pod template's `creationTimestamp: null` or
+// `resources: {}`, which would otherwise be applied and owned. Anything
+// which was in the original object is kept, e.g. `emptyDir: {}`.
+func pruneConversionZeroes(data map[string]interface{}, original map[string]interface{}) {
+	for key, val := range data {
+		origVal, inOriginal := original[key]
+		pruneConversionValue(val, origVal)
+		if !inOriginal && isConversionZero(val) {
+			delete(data, key)
+		}
+	}
+}
+
+func pruneConversionValue(val interface{}, original interface{}) {
+	switch v := val.(type) {
+	case map[string]interface{}:
+		origMap, _ := original.(map[string]interface{})
+		pruneConversionZeroes(v, origMap)
+	case []interface{}:
+		origList, _ := original.([]interface{})
+		for i, item := range v {
+			var origItem interface{}
+			if i < len(origList) {
+				origItem = origList[i]
+			}
+			pruneConversionValue(item, origItem)
+		}
+	}
+}
+
+func isConversionZero(val interface{}) bool {
+	if val == nil {
+		return true
+	}
+	m, ok := val.(map[string]interface{})
+	return ok && len(m) == 0
+}
+
+// The API server converts stringData to data on write, so applying stringData
+// means our managed fields never match what is stored and keys removed from
+// the template are never pruned. Do the conversion ourselves so we always
+// apply data, which also keeps SecretField predicates working.
+func normalizeSecretStringData(obj *unstructured.Unstructured) error {
+	gvk := obj.GroupVersionKind()
+	if gvk.Group != "" || gvk.Kind != "Secret" {
+		return nil
+	}
+	stringData, ok, err := unstructured.NestedMap(obj.Object, "stringData")
+	if err != nil {
+		return errors.Wrap(err, "error reading Secret stringData")
+	}
+	if !ok {
+		return nil
+	}
+	data, _, err := unstructured.NestedMap(obj.Object, "data")
+	if err != nil {
+		return errors.Wrap(err, "error reading Secret data")
+	}
+	if data == nil {
+		data = map[string]interface{}{}
+	}
+	for key, val := range stringData {
+		str, ok := val.(string)
+		if !ok {
+			// YAML will happily parse things like `port: 80` as a number.
+			str = fmt.Sprintf("%v", val)
+		}
+		data[key] = base64.StdEncoding.EncodeToString([]byte(str))
+	}
+	unstructured.RemoveNestedField(obj.Object, "stringData")
+	err = unstructured.SetNestedMap(obj.Object, data, "data")
+	if err != nil {
+		return errors.Wrap(err, "error setting Secret data")
+	}
+	return nil
+}
+
+// The template options set on the reconciler.
+func templateOptions(ctx *core.Context) templates.Options {
+	return templates.Options{Funcs: ctx.TemplateFuncs, Helpers: ctx.TemplateHelpers, Scheme: ctx.Scheme}
+}
+
+// Render a template file with the options from the reconciler.
+func getTemplate(ctx *core.Context, filename string, unstructured bool, data interface{}) (client.Object, error) {
+	return templates.GetWithOptions(ctx.Templates, filename, unstructured, data, templateOptions(ctx))
+}
+
+// Render an inline template with the options from the reconciler.
+func renderString(ctx *core.Context, text string, data interface{}) (string, error) {
+	return templates.RenderStringWithOptions(text, data, templateOptions(ctx))
+}
+
+func (comp *templateComponent) reconcileCreate(ctx *core.Context, obj client.Object, wave *int) (core.Result, error) {
+	kind := obj.GetObjectKind().GroupVersionKind().Kind
+	waveName := fmt.Sprintf("%s %s/%s", kind, obj.GetNamespace(), obj.GetName())
+	if wave != nil {
+		// Don't touch objects until everything in earlier waves is ready.
+		blockers := ctx.WaveBlockers(*wave)
+		if len(blockers) != 0 {
+			ctx.RecordWave(*wave, waveName, false)
+			if comp.conditionType != "" {
+				ctx.Conditions.SetfFalse(comp.conditionType, "WaitingForWave", "Upstream %s %s is in wave %d, waiting for %s", kind, obj.GetName(), *wave, strings.Join(blockers, ", "))
+			}
+			return core.Result{}, nil
+		}
+	}
+
+	// Set owner reference, or annotations if an owner reference can't work.
+	var err error
+	if ctx.Object.GetNamespace() != "" && obj.GetNamespace() != ctx.Object.GetNamespace() {
+		err = core.SetAnnotationOwner(ctx.Object, obj, ctx.Scheme)
+	} else {
+		err = controllerutil.SetControllerReference(ctx.Object, obj, ctx.Scheme)
+	}
 	if err != nil {
 		return core.Result{}, errors.Wrap(err, "error setting owner reference")
 	}
@@ -134,65 +914,168 @@ func (comp *templateComponent) reconcileCreate(ctx *core.Context, obj client.Obj
 		return core.Result{}, errors.Wrap(err, "error applying object")
 	}
 
-	// If we have a condition setter, check on the object status.
-	if comp.conditionType != "" {
+	// If we have a condition setter or a wave barrier, check on the object status.
+	if comp.conditionType != "" || wave != nil {
 		currentObj := &unstructured.Unstructured{}
 		currentObj.SetGroupVersionKind(obj.GetObjectKind().GroupVersionKind())
-		err = ctx.Client.Get(ctx, types.NamespacedName{Name: obj.GetName(), Namespace: obj.GetNamespace()}, currentObj)
+		// Only metadata is cached, so status has to come from the API server.
+		reader := client.Reader(ctx.Client)
+		if comp.metadataOnly {
+			reader = ctx.UncachedClient
+		}
+		err = reader.Get(ctx, types.NamespacedName{Name: obj.GetName(), Namespace: obj.GetNamespace()}, currentObj)
 		if err != nil {
 			return core.Result{}, errors.Wrapf(err, "error getting current object %s/%s for status", obj.GetNamespace(), obj.GetName())
 		}
 
-		annotations := obj.GetAnnotations()
-		if val, ok := annotations[CONDITION_ANNOTATION]; ok {
-			status, ok := comp.getStatusFromUnstructured(currentObj, val)
-			if ok {
-				ctx.Conditions.Setf(comp.conditionType, status, "UpstreamConditionSet", "Upstream condition %s on %s %s was set to %s", val, obj.GetObjectKind().GroupVersionKind().Kind, obj.GetName(), status)
-			} else {
-				ctx.Conditions.SetfUnknown(comp.conditionType, "UpstreamConditionNotSet", "Upstream condition %s on %s %s was not set", val, obj.GetObjectKind().GroupVersionKind().Kind, obj.GetName())
+		status, reason, message := upstreamReadiness(obj, currentObj, wave != nil)
+		if wave != nil {
+			ctx.RecordWave(*wave, waveName, status == metav1.ConditionTrue)
+		}
+		if comp.conditionType != "" {
+			if reason != "" {
+				ctx.Conditions.Setf(comp.conditionType, status, reason, "%s", message)
+			}
+			if comp.readinessTimeout != 0 && status != metav1.ConditionTrue {
+				return comp.checkReadinessDeadline(ctx, currentObj), nil
 			}
 		}
+	}
+
+	return core.Result{}, nil
+}
+
+// Work out the readiness of an applied object from its condition annotation,
+// kstatus, or native readiness, in that order. An empty reason means there was
+// nothing to go on. Wave barriers need an answer so fall back to kstatus.
+func upstreamReadiness(obj client.Object, currentObj *unstructured.Unstructured, fallback bool) (metav1.ConditionStatus, string, string) {
+	kind := obj.GetObjectKind().GroupVersionKind().Kind
+	annotations := obj.GetAnnotations()
+	if val, ok := annotations[CONDITION_ANNOTATION]; ok {
+		status, found := getStatusFromUnstructured(currentObj, val)
+		if found {
+			return status, "UpstreamConditionSet", fmt.Sprintf("Upstream condition %s on %s %s was set to %s", val, kind, obj.GetName(), status)
+		}
+		return metav1.ConditionUnknown, "UpstreamConditionNotSet", fmt.Sprintf("Upstream condition %s on %s %s was not set", val, kind, obj.GetName())
+	}
+	var status metav1.ConditionStatus
+	var message string
+	if annotations[KSTATUS_ANNOTATION] == "true" {
+		status, message = getKstatusReadiness(currentObj)
+	} else if nativeStatus, nativeMessage, ok := getNativeReadiness(currentObj); ok {
+		status, message = nativeStatus, nativeMessage
+	} else if fallback {
+		status, message = getKstatusReadiness(currentObj)
+	} else {
 		// TODO some kind of support for an expr or CEL based option to get a status for upstream objects that don't use status conditions.
+		return metav1.ConditionUnknown, "", ""
+	}
+	reason := "UpstreamNotReady"
+	if status == metav1.ConditionTrue {
+		reason = "UpstreamReady"
 	}
+	return status, reason, fmt.Sprintf("Upstream %s %s: %s", kind, obj.GetName(), message)
+}
 
+// Flip the condition to False if the object has been unready for too long
+// since we last changed it. Our last apply time comes from managedFields.
+func (comp *templateComponent) checkReadinessDeadline(ctx *core.Context, currentObj *unstructured.Unstructured) core.Result {
+	var lastApplied time.Time
+	for _, entry := range currentObj.GetManagedFields() {
+		if entry.Manager == ctx.FieldManager && entry.Operation == metav1.ManagedFieldsOperationApply && entry.Time != nil && entry.Time.Time.After(lastApplied) {
+			lastApplied = entry.Time.Time
+		}
+	}
+	if lastApplied.IsZero() {
+		// No timestamp to go on, try again later.
+		return core.Result{RequeueAfter: comp.readinessTimeout}
+	}
+	remaining := lastApplied.Add(comp.readinessTimeout).Sub(ctx.Now())
+	if remaining > 0 {
+		// Check again once the deadline passes.
+		return core.Result{RequeueAfter: remaining}
+	}
+	kind := currentObj.GetKind()
+	// Only warn on the transition, not on every reconcile after the deadline.
+	// The pending condition was already overwritten, so look at the stored one.
+	alreadyExceeded := false
+	if conds, err := core.GetConditionsFor(ctx.Object); err == nil {
+		previous := conditions.FindStatusCondition(*conds, comp.conditionType)
+		alreadyExceeded = previous != nil && previous.Status == metav1.ConditionFalse && previous.Reason == "ProgressDeadlineExceeded"
+	}
+	ctx.Conditions.SetfFalse(comp.conditionType, "ProgressDeadlineExceeded", "Upstream %s %s was not ready within %s", kind, currentObj.GetName(), comp.readinessTimeout)
+	if !alreadyExceeded {
+		ctx.Events.Eventf(ctx.Object, "Warning", "ProgressDeadlineExceeded", "%s %s was not ready within %s", kind, currentObj.GetName(), comp.readinessTimeout)
+	}
+	return core.Result{}
+}
+
+func (comp *templateComponent) reconcileDelete(ctx *core.Context, obj client.Object, propagation metav1.DeletionPropagation) (core.Result, error) {
+	found, owned, err := deleteIfOwned(ctx, obj, propagation, comp.metadataOnly)
+	if err != nil {
+		return core.Result{}, err
+	}
+	if comp.conditionType != "" {
+		if found && !owned {
+			// The object exists but isn't owned by this object so it wasn't purged.
+			ctx.Conditions.SetfTrue(comp.conditionType, "UpstreamNotOwned", "Upstream %s %s is not owned by %s", obj.GetObjectKind().GroupVersionKind().Kind, obj.GetName(), ctx.Object.GetName())
+		} else {
+			ctx.Conditions.SetfTrue(comp.conditionType, "UpstreamDoesNotExist", "Upstream %s %s does not exist", obj.GetObjectKind().GroupVersionKind().Kind, obj.GetName())
+		}
+	}
 	return core.Result{}, nil
 }
 
-func (comp *templateComponent) reconcileDelete(ctx *core.Context, obj client.Object) (core.Result, error) {
-	currentObj := &unstructured.Unstructured{}
-	currentObj.SetGroupVersionKind(obj.GetObjectKind().GroupVersionKind())
+// Delete an object if it exists and is controlled by the reconcile object. If
+// metadataOnly is set, ownership is checked against the metadata-only cache.
+func deleteIfOwned(ctx *core.Context, obj client.Object, propagation metav1.DeletionPropagation, metadataOnly bool) (bool, bool, error) {
+	var currentObj client.Object
+	if metadataOnly {
+		metaObj, err := core.MetadataOnlyObject(obj, ctx.Scheme)
+		if err != nil {
+			return false, false, err
+		}
+		currentObj = metaObj
+	} else {
+		unstructuredObj := &unstructured.Unstructured{}
+		unstructuredObj.SetGroupVersionKind(obj.GetObjectKind().GroupVersionKind())
+		currentObj = unstructuredObj
+	}
 	err := ctx.Client.Get(ctx, types.NamespacedName{Name: obj.GetName(), Namespace: obj.GetNamespace()}, currentObj)
 	if err != nil {
 		if kerrors.IsNotFound(err) {
 			// Didn't exist at all so we're good.
-			if comp.conditionType != "" {
-				ctx.Conditions.SetfTrue(comp.conditionType, "UpstreamDoesNotExist", "Upstream %s %s does not exist", obj.GetObjectKind().GroupVersionKind().Kind, obj.GetName())
-			}
-			return core.Result{}, nil
+			return false, false, nil
 		}
-		return core.Result{}, errors.Wrapf(err, "error getting current object %s/%s for owner", obj.GetNamespace(), obj.GetName())
+		return false, false, errors.Wrapf(err, "error getting current object %s/%s for owner", obj.GetNamespace(), obj.GetName())
 	}
 	controllerRef := metav1.GetControllerOf(currentObj)
-	if controllerRef == nil || !comp.referSameObject(controllerRef, ctx.Object, ctx.Scheme) {
+	owned := controllerRef != nil && referSameObject(controllerRef, ctx.Object, ctx.Scheme)
+	if !owned && !core.IsAnnotationOwnedBy(currentObj, ctx.Object) {
 		// The object exists but isn't owned by this object so don't purge it.
-		if comp.conditionType != "" {
-			ctx.Conditions.SetfTrue(comp.conditionType, "UpstreamNotOwned", "Upstream %s %s is not owned by %s", obj.GetObjectKind().GroupVersionKind().Kind, obj.GetName(), ctx.Object.GetName())
-		}
-		return core.Result{}, nil
+		return true, false, nil
 	}
 
-	propagation := metav1.DeletePropagationBackground
 	err = ctx.Client.Delete(ctx, obj, &client.DeleteOptions{PropagationPolicy: &propagation})
 	if err != nil && !kerrors.IsNotFound(err) {
-		return core.Result{}, errors.Wrapf(err, "error deleting %s/%s", obj.GetNamespace(), obj.GetName())
+		return true, true, errors.Wrapf(err, "error deleting %s/%s", obj.GetNamespace(), obj.GetName())
 	}
-	if comp.conditionType != "" {
-		ctx.Conditions.SetfTrue(comp.conditionType, "UpstreamDoesNotExist", "Upstream %s %s does not exist", obj.GetObjectKind().GroupVersionKind().Kind, obj.GetName())
+	return true, true, nil
+}
+
+// Check if an API is installed in the cluster.
+func apiAvailable(ctx *core.Context, gvk schema.GroupVersionKind) (bool, error) {
+	_, err := ctx.Client.RESTMapper().RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		if meta.IsNoMatchError(err) {
+			return false, nil
+		}
+		return false, errors.Wrapf(err, "error checking for %s API", gvk)
 	}
-	return core.Result{}, nil
+	return true, nil
 }
 
-func (comp *templateComponent) getStatusFromUnstructured(obj client.Object, srcType string) (metav1.ConditionStatus, bool) {
+func getStatusFromUnstructured(obj client.Object, srcType string) (metav1.ConditionStatus, bool) {
 	data := obj.(*unstructured.Unstructured).UnstructuredContent()
 
 	// Ooof this is ugly. Once I am set up with Expr or CEL or even a JSONPath library, try and use that instead.
@@ -243,7 +1126,7 @@ func (comp *templateComponent) getStatusFromUnstructured(obj client.Object, srcT
 
 // Adapted from controller-runtime.
 // Copyright 2018 The Kubernetes Authors.
-func (comp *templateComponent) referSameObject(ownerRef *metav1.OwnerReference, obj client.Object, scheme *runtime.Scheme) bool {
+func referSameObject(ownerRef *metav1.OwnerReference, obj client.Object, scheme *runtime.Scheme) bool {
 	ownerGV, err := schema.ParseGroupVersion(ownerRef.APIVersion)
 	if err != nil {
 		return false
@@ -259,5 +1142,7 @@ func (comp *templateComponent) referSameObject(ownerRef *metav1.OwnerReference,
 
 func init() {
 	// Avoid import loops.
-	core.NewTemplateComponent = NewTemplateComponent
+	core.NewTemplateComponent = func(template string, conditionType string) core.Component {
+		return NewTemplateComponent(template, conditionType)
+	}
 }
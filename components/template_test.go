@@ -18,17 +18,22 @@ package components
 
 import (
 	"context"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	. "github.com/onsi/gomega/gstruct"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apiextv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
+	"github.com/coderanger/controller-utils/conditions"
 	"github.com/coderanger/controller-utils/core"
 	"github.com/coderanger/controller-utils/tests"
 )
@@ -209,47 +214,39 @@ var _ = Describe("Template component", func() {
 		Expect(kerrors.IsNotFound(err)).To(BeTrue())
 	})
 
+	It("deletes an object with a propagation policy", func() {
+		comp := NewTemplateComponent("deployment.yml", "").WithPropagationPolicy(metav1.DeletePropagationForeground)
+		helper = startTestController(comp)
+		c := helper.TestClient
+
+		c.Create(obj)
+		deployment := &appsv1.Deployment{}
+		c.EventuallyGetName("testing-webserver", deployment)
+
+		objClean := obj.DeepCopy()
+		obj.Spec.Field = "true"
+		c.Patch(obj, client.MergeFrom(objClean))
+
+		// Without a garbage collector, foreground deletion leaves the object waiting on its finalizer.
+		c.EventuallyGetName("testing-webserver", deployment, c.EventuallyValue(ContainElement(metav1.FinalizerDeleteDependents), func(obj client.Object) (interface{}, error) {
+			return obj.GetFinalizers(), nil
+		}))
+	})
+
 	It("does not delete an unowned object", func() {
 		comp := NewTemplateComponent("deployment.yml", "DeploymentAvailable")
 		helper = startTestController(comp)
 		c := helper.TestClient
 
-		deployment := &appsv1.Deployment{
-			ObjectMeta: metav1.ObjectMeta{
-				Name: "testing-webserver",
-			},
-			Spec: appsv1.DeploymentSpec{
-				Selector: &metav1.LabelSelector{
-					MatchLabels: map[string]string{
-						"app": "webserver",
-					},
-				},
-				Template: corev1.PodTemplateSpec{
-					ObjectMeta: metav1.ObjectMeta{
-						Labels: map[string]string{
-							"app": "webserver",
-						},
-					},
-					Spec: corev1.PodSpec{
-						Containers: []corev1.Container{
-							{
-								Name:  "default",
-								Image: "something",
-							},
-						},
-					},
-				},
-			},
-		}
-		c.Create(deployment)
+		c.LoadFixtures("test_fixtures/unowned_deployment")
 
 		obj.Spec.Field = "true"
 		c.Create(obj)
 
 		c.EventuallyGetName("testing", obj, c.EventuallyCondition("DeploymentAvailable", "True"))
 
-		err := helper.Client.Get(context.Background(), types.NamespacedName{Name: "testing-webserver", Namespace: helper.Namespace}, deployment)
-		Expect(err).ToNot(HaveOccurred())
+		deployment := &appsv1.Deployment{}
+		c.GetName("testing-webserver", deployment)
 		Expect(deployment.Spec.Template.Spec.Containers[0].Image).To(Equal("something"))
 	})
 
@@ -318,6 +315,137 @@ var _ = Describe("Template component", func() {
 		c.EventuallyGetName("testing", obj, c.EventuallyCondition("DeploymentAvailable", "True"))
 	})
 
+	It("understands native readiness without an annotation", func() {
+		comp := NewTemplateComponent("pvc.yml", "DataReady")
+		helper = startTestController(comp)
+		c := helper.TestClient
+
+		c.Create(obj)
+
+		c.EventuallyGetName("testing", obj, c.EventuallyCondition("DataReady", "False"))
+
+		pvc := &corev1.PersistentVolumeClaim{}
+		c.GetName("testing-data", pvc)
+		pvcClean := pvc.DeepCopy()
+		pvc.Status.Phase = corev1.ClaimBound
+		c.Status().Patch(pvc, client.MergeFrom(pvcClean))
+
+		c.EventuallyGetName("testing", obj, c.EventuallyCondition("DataReady", "True"))
+	})
+
+	It("uses kstatus readiness with an annotation", func() {
+		comp := NewTemplateComponent("kstatus_service.yml", "ServiceReady")
+		helper = startTestController(comp)
+		c := helper.TestClient
+
+		c.Create(obj)
+
+		c.EventuallyGetName("testing", obj, c.EventuallyCondition("ServiceReady", "True"))
+		cond := conditions.FindStatusCondition(obj.Status.Conditions, "ServiceReady")
+		Expect(cond.Message).To(ContainSubstring("Current"))
+	})
+
+	It("waits for earlier waves to be ready", func() {
+		helper = startTestController(
+			NewTemplateComponent("wave_pvc.yml", "DataReady"),
+			NewTemplateComponent("wave_configmap.yml", "ConfigReady"),
+		)
+		c := helper.TestClient
+
+		c.Create(obj)
+
+		c.EventuallyGetName("testing", obj, c.EventuallyCondition("ConfigReady", "False"))
+		cond := conditions.FindStatusCondition(obj.Status.Conditions, "ConfigReady")
+		Expect(cond.Reason).To(Equal("WaitingForWave"))
+		Consistently(func() bool {
+			err := helper.Client.Get(context.Background(), types.NamespacedName{Name: "testing-after-data", Namespace: helper.Namespace}, &corev1.ConfigMap{})
+			return kerrors.IsNotFound(err)
+		}).Should(BeTrue())
+
+		pvc := &corev1.PersistentVolumeClaim{}
+		c.GetName("testing-data", pvc)
+		pvcClean := pvc.DeepCopy()
+		pvc.Status.Phase = corev1.ClaimBound
+		c.Status().Patch(pvc, client.MergeFrom(pvcClean))
+
+		c.EventuallyGetName("testing-after-data", &corev1.ConfigMap{})
+		c.EventuallyGetName("testing", obj, c.EventuallyCondition("ConfigReady", "True"))
+	})
+
+	It("gives up waiting after the readiness timeout", func() {
+		comp := NewTemplateComponent("deployment.yml", "DeploymentAvailable").WithReadinessTimeout(time.Second)
+		helper = startTestController(comp)
+		c := helper.TestClient
+
+		c.Create(obj)
+		c.EventuallyGetName("testing-webserver", &appsv1.Deployment{})
+		helper.Clock.Step(time.Minute)
+
+		c.EventuallyGetName("testing", obj, c.EventuallyCondition("DeploymentAvailable", "False"))
+		cond := conditions.FindStatusCondition(obj.Status.Conditions, "DeploymentAvailable")
+		Expect(cond.Reason).To(Equal("ProgressDeadlineExceeded"))
+
+		// The warning is only emitted when the deadline is first exceeded.
+		deadlineEvents := func() int32 {
+			count := int32(0)
+			for _, event := range helper.Events(obj) {
+				if event.Reason == "ProgressDeadlineExceeded" {
+					count += event.Count
+				}
+			}
+			return count
+		}
+		Eventually(deadlineEvents).Should(Equal(int32(1)))
+		c.UpdateWithRetry(obj, func() {
+			obj.Spec.Field = "again"
+		})
+		Consistently(deadlineEvents).Should(Equal(int32(1)))
+	})
+
+	It("manages a cluster-scoped object", func() {
+		comp := NewTemplateComponent("clusterrole.yml", "")
+		helper = startTestController(comp)
+		c := helper.TestClient
+
+		c.Create(obj)
+
+		role := &rbacv1.ClusterRole{}
+		c.EventuallyGet(types.NamespacedName{Name: helper.Namespace + "-testing"}, role)
+		Expect(role.OwnerReferences).To(BeEmpty())
+		Expect(role.Labels).To(HaveKeyWithValue(core.OWNER_UID_LABEL, string(obj.UID)))
+		Expect(role.Annotations).To(HaveKeyWithValue(core.OWNER_NAME_ANNOTATION, "testing"))
+
+		c.Delete(obj)
+		Eventually(func() bool {
+			err := helper.Client.Get(context.Background(), types.NamespacedName{Name: helper.Namespace + "-testing"}, role)
+			return kerrors.IsNotFound(err)
+		}).Should(BeTrue())
+	})
+
+	It("manages an object in a namespace from the spec", func() {
+		comp := NewTemplateComponent("templated_namespace.yml", "")
+		helper = startTestController(comp)
+		c := helper.TestClient
+		// The namespace renders empty during setup.
+		Expect(comp.NeedsFinalizer()).To(BeTrue())
+
+		otherNamespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: helper.Namespace + "-other"}}
+		c.Create(otherNamespace)
+		obj.Spec.Field = otherNamespace.Name
+		c.Create(obj)
+
+		cmap := &corev1.ConfigMap{}
+		c.EventuallyGet(types.NamespacedName{Name: "testing-remote", Namespace: otherNamespace.Name}, cmap)
+		Expect(cmap.OwnerReferences).To(BeEmpty())
+		Expect(cmap.Labels).To(HaveKeyWithValue(core.OWNER_UID_LABEL, string(obj.UID)))
+
+		c.Delete(obj)
+		Eventually(func() bool {
+			err := helper.Client.Get(context.Background(), types.NamespacedName{Name: "testing-remote", Namespace: otherNamespace.Name}, cmap)
+			return kerrors.IsNotFound(err)
+		}).Should(BeTrue())
+	})
+
 	It("handles template data", func() {
 		dataComp := &injectDataComponent{key: "FOO", value: "bar"}
 		comp := NewTemplateComponent("configmap.yml", "")
@@ -330,4 +458,294 @@ var _ = Describe("Template component", func() {
 		c.EventuallyGetName("testing", cmap)
 		Expect(cmap.Data).To(HaveKeyWithValue("FOO", Equal("bar")))
 	})
+
+	It("renders typed template data", func() {
+		type values struct {
+			Name     string
+			Greeting string
+		}
+		comp := NewTypedTemplateComponent("typed_configmap.yml", "", func(ctx *core.Context) (interface{}, error) {
+			return &values{Name: ctx.Object.GetName(), Greeting: "hello " + ctx.Object.(*TestObject).Spec.Field}, nil
+		})
+		helper = startTestController(comp)
+		c := helper.TestClient
+
+		obj.Spec.Field = "world"
+		c.Create(obj)
+
+		cmap := &corev1.ConfigMap{}
+		c.EventuallyGetName("testing-typed", cmap)
+		Expect(cmap.Data).To(HaveKeyWithValue("greeting", Equal("hello world")))
+	})
+
+	It("converts Secret stringData to data", func() {
+		dataComp := &injectDataComponent{key: "password", value: "hunter2"}
+		comp := NewTemplateComponent("secret_stringdata.yml", "")
+		helper = startTestController(dataComp, comp)
+		c := helper.TestClient
+
+		c.Create(obj)
+
+		secret := &corev1.Secret{}
+		c.EventuallyGetName("testing-credentials", secret)
+		Expect(secret.Data).To(HaveKeyWithValue("username", BeEquivalentTo("admin")))
+		Expect(secret.Data).To(HaveKeyWithValue("password", BeEquivalentTo("hunter2")))
+		Expect(secret.Data).To(HaveKeyWithValue("token", BeEquivalentTo("hunter2")))
+		for _, entry := range secret.GetManagedFields() {
+			Expect(string(entry.FieldsV1.Raw)).ToNot(ContainSubstring("stringData"))
+		}
+	})
+
+	It("applies a strategic merge overlay", func() {
+		dataComp := &injectDataComponent{key: "flavor", value: "large"}
+		comp := NewTemplateComponent("deployment.yml", "").WithOverlays("overlays/{{ .Data.flavor }}.yml")
+		helper = startTestController(dataComp, comp)
+		c := helper.TestClient
+
+		c.Create(obj)
+
+		deployment := &appsv1.Deployment{}
+		c.EventuallyGetName("testing-webserver", deployment)
+		Expect(deployment.Spec.Replicas).To(PointTo(BeEquivalentTo(3)))
+		Expect(deployment.Spec.Template.Spec.Containers).To(HaveLen(1))
+		Expect(deployment.Spec.Template.Spec.Containers[0].Image).To(Equal("nginx"))
+		Expect(deployment.Spec.Template.Spec.Containers[0].Resources.Requests.Cpu().String()).To(Equal("2"))
+	})
+
+	It("applies a JSON patch overlay", func() {
+		dataComp := &injectDataComponent{key: "flavor", value: "debug"}
+		comp := NewTemplateComponent("deployment.yml", "").WithOverlays("overlays/{{ .Data.flavor }}.yml")
+		helper = startTestController(dataComp, comp)
+		c := helper.TestClient
+
+		c.Create(obj)
+
+		deployment := &appsv1.Deployment{}
+		c.EventuallyGetName("testing-webserver", deployment)
+		Expect(deployment.Spec.Replicas).To(PointTo(BeEquivalentTo(0)))
+		Expect(deployment.Spec.Template.Spec.Containers[0].Image).To(Equal("nginx-debug"))
+		Expect(deployment.Labels).To(HaveKeyWithValue("debug", "true"))
+	})
+
+	It("fails on a missing overlay", func() {
+		dataComp := &injectDataComponent{key: "flavor", value: "other"}
+		comp := NewTemplateComponent("deployment.yml", "DeploymentAvailable").WithOverlays("overlays/{{ .Data.flavor }}.yml")
+		helper = startTestController(dataComp, comp)
+		c := helper.TestClient
+
+		c.Create(obj)
+
+		c.EventuallyGetName("testing", obj, c.EventuallyCondition("DeploymentAvailable", "False"))
+		cond := conditions.FindStatusCondition(obj.Status.Conditions, "DeploymentAvailable")
+		Expect(cond.Message).To(ContainSubstring("error opening overlay overlays/other.yml"))
+		err := helper.Client.Get(context.Background(), types.NamespacedName{Name: "testing-webserver", Namespace: helper.Namespace}, &appsv1.Deployment{})
+		Expect(kerrors.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("skips missing optional overlays", func() {
+		dataComp := &injectDataComponent{key: "flavor", value: "other"}
+		comp := NewTemplateComponent("deployment.yml", "").WithOptionalOverlays("overlays/{{ .Data.flavor }}.yml")
+		helper = startTestController(dataComp, comp)
+		c := helper.TestClient
+
+		c.Create(obj)
+
+		deployment := &appsv1.Deployment{}
+		c.EventuallyGetName("testing-webserver", deployment)
+		Expect(deployment.Spec.Replicas).To(PointTo(BeEquivalentTo(0)))
+		Expect(deployment.Spec.Template.Spec.Containers[0].Image).To(Equal("nginx"))
+	})
+
+	It("runs mutators on typed objects", func() {
+		comp := NewTemplateComponent("deployment.yml", "").WithMutator(func(ctx *core.Context, obj client.Object) error {
+			deployment := obj.(*appsv1.Deployment)
+			deployment.Spec.Template.Spec.Containers = append(deployment.Spec.Template.Spec.Containers, corev1.Container{
+				Name:  "sidecar",
+				Image: "envoy",
+			})
+			return nil
+		})
+		helper = startTestController(comp)
+		c := helper.TestClient
+
+		c.Create(obj)
+
+		deployment := &appsv1.Deployment{}
+		c.EventuallyGetName("testing-webserver", deployment)
+		Expect(deployment.Spec.Template.Spec.Containers).To(HaveLen(2))
+		Expect(deployment.Spec.Template.Spec.Containers[1].Name).To(Equal("sidecar"))
+		Expect(deployment.Spec.Template.Spec.Containers[1].Image).To(Equal("envoy"))
+	})
+
+	It("doesn't add empty fields when running mutators", func() {
+		original := map[string]interface{}{
+			"apiVersion": "apps/v1",
+			"kind":       "Deployment",
+			"metadata":   map[string]interface{}{"name": "testing"},
+			"spec": map[string]interface{}{
+				"template": map[string]interface{}{
+					"spec": map[string]interface{}{
+						"containers": []interface{}{map[string]interface{}{"name": "webserver", "image": "nginx"}},
+						"volumes":    []interface{}{map[string]interface{}{"name": "scratch", "emptyDir": map[string]interface{}{}}},
+					},
+				},
+			},
+		}
+		deployment := &appsv1.Deployment{}
+		Expect(runtime.DefaultUnstructuredConverter.FromUnstructured(original, deployment)).To(Succeed())
+		data, err := runtime.DefaultUnstructuredConverter.ToUnstructured(deployment)
+		Expect(err).ToNot(HaveOccurred())
+
+		pruneConversionZeroes(data, original)
+		Expect(data).ToNot(HaveKey("status"))
+		Expect(data["metadata"]).ToNot(HaveKey("creationTimestamp"))
+		Expect(data["spec"]).ToNot(HaveKey("strategy"))
+		template := data["spec"].(map[string]interface{})["template"].(map[string]interface{})
+		Expect(template).ToNot(HaveKey("metadata"))
+		podSpec := template["spec"].(map[string]interface{})
+		Expect(podSpec["containers"].([]interface{})[0]).ToNot(HaveKey("resources"))
+		Expect(podSpec["volumes"].([]interface{})[0]).To(HaveKeyWithValue("emptyDir", map[string]interface{}{}))
+	})
+
+	It("exposes cluster capabilities", func() {
+		comp := NewTemplateComponent("capabilities.yml", "")
+		helper = startTestController(comp)
+		c := helper.TestClient
+
+		c.Create(obj)
+
+		cmap := &corev1.ConfigMap{}
+		c.EventuallyGetName("testing-capabilities", cmap)
+		Expect(cmap.Data).To(HaveKeyWithValue("major", "1"))
+		Expect(cmap.Data).To(HaveKeyWithValue("apps", "true"))
+		Expect(cmap.Data).To(HaveKeyWithValue("deployments", "true"))
+		Expect(cmap.Data).To(HaveKeyWithValue("monitoring", "false"))
+	})
+
+	It("expands a List into its items", func() {
+		comp := NewTemplateComponent("list.yml", "ListReady")
+		helper = startTestController(comp)
+		c := helper.TestClient
+
+		c.Create(obj)
+		// None of the items report readiness.
+		c.EventuallyGetName("testing", obj, c.EventuallyCondition("ListReady", "Unknown"))
+		cond := conditions.FindStatusCondition(obj.Status.Conditions, "ListReady")
+		Expect(cond.Reason).To(Equal("UpstreamReadinessUnknown"))
+
+		one := &corev1.ConfigMap{}
+		c.EventuallyGetName("testing-one", one)
+		Expect(one.Data).To(HaveKeyWithValue("index", "1"))
+		Expect(one.OwnerReferences).To(HaveLen(1))
+		two := &corev1.ConfigMap{}
+		c.EventuallyGetName("testing-two", two)
+		Expect(two.Data).To(HaveKeyWithValue("index", "2"))
+		secret := &corev1.Secret{}
+		c.EventuallyGetName("testing-secret", secret)
+		Expect(secret.Data).To(HaveKeyWithValue("password", BeEquivalentTo("hunter2")))
+		Expect(secret.OwnerReferences).To(HaveLen(1))
+	})
+
+	It("sets the condition False for a failing List item", func() {
+		comp := NewTemplateComponent("list_failing.yml", "ListReady")
+		helper = startTestController(comp)
+		c := helper.TestClient
+
+		c.Create(obj)
+
+		c.EventuallyGetName("testing", obj, c.EventuallyCondition("ListReady", "False"))
+		cond := conditions.FindStatusCondition(obj.Status.Conditions, "ListReady")
+		Expect(cond.Reason).To(Equal("Invalid"))
+		Expect(cond.Message).To(ContainSubstring("testing-broken"))
+		// Items after the failing one are still applied.
+		c.EventuallyGetName("testing-one", &corev1.ConfigMap{})
+		c.EventuallyGetName("testing-two", &corev1.ConfigMap{})
+	})
+
+	It("prunes items removed from a List", func() {
+		comp := NewTemplateComponent("list_prune.yml", "ListReady")
+		helper = startTestController(comp)
+		c := helper.TestClient
+
+		c.Create(obj)
+		c.EventuallyGetName("testing-one", &corev1.ConfigMap{})
+		two := &corev1.ConfigMap{}
+		c.EventuallyGetName("testing-two", two)
+		Expect(two.Annotations).To(HaveKeyWithValue(LIST_TEMPLATE_ANNOTATION, "list_prune.yml"))
+
+		c.UpdateWithRetry(obj, func() { obj.Spec.Field = "prune" })
+
+		Eventually(func() bool {
+			err := helper.Client.Get(context.Background(), types.NamespacedName{Name: "testing-two", Namespace: helper.Namespace}, &corev1.ConfigMap{})
+			return kerrors.IsNotFound(err)
+		}).Should(BeTrue())
+		c.ConsistentlyGetName("testing-one", &corev1.ConfigMap{})
+		c.EventuallyGetName("testing", obj, c.EventuallyCondition("ListReady", "Unknown"))
+	})
+
+	It("sets the condition True once a List item is ready", func() {
+		comp := NewTemplateComponent("list_deployment.yml", "ListReady")
+		helper = startTestController(comp)
+		c := helper.TestClient
+
+		c.Create(obj)
+		c.EventuallyGetName("testing-config", &corev1.ConfigMap{})
+		c.EventuallyGetName("testing", obj, c.EventuallyCondition("ListReady", "Unknown"))
+		cond := conditions.FindStatusCondition(obj.Status.Conditions, "ListReady")
+		Expect(cond.Message).To(ContainSubstring("testing-webserver"))
+
+		deployment := &appsv1.Deployment{}
+		c.GetName("testing-webserver", deployment)
+		deploymentClean := deployment.DeepCopy()
+		deployment.Status.Conditions = []appsv1.DeploymentCondition{
+			{
+				Type:   "Available",
+				Status: corev1.ConditionTrue,
+				Reason: "Fake",
+			},
+		}
+		c.Status().Patch(deployment, client.MergeFrom(deploymentClean))
+
+		c.EventuallyGetName("testing", obj, c.EventuallyCondition("ListReady", "True"))
+		cond = conditions.FindStatusCondition(obj.Status.Conditions, "ListReady")
+		Expect(cond.Message).To(Equal("1 of 2 list items are ready, the rest don't report readiness"))
+	})
+
+	Describe("data schema", func() {
+		schema := &apiextv1.JSONSchemaProps{
+			Type:     "object",
+			Required: []string{"FOO"},
+			Properties: map[string]apiextv1.JSONSchemaProps{
+				"FOO": {Type: "string"},
+			},
+		}
+
+		It("reports missing data", func() {
+			comp := NewTemplateComponent("configmap.yml", "ConfigMapReady").WithDataSchema(schema)
+			helper = startTestController(comp)
+			c := helper.TestClient
+
+			c.Create(obj)
+
+			c.EventuallyGetName("testing", obj, c.EventuallyCondition("ConfigMapReady", "False"))
+			cond := conditions.FindStatusCondition(obj.Status.Conditions, "ConfigMapReady")
+			Expect(cond.Reason).To(Equal("InvalidTemplateData"))
+			Expect(cond.Message).To(ContainSubstring("Data.FOO"))
+			err := helper.Client.Get(context.Background(), types.NamespacedName{Name: "testing", Namespace: obj.Namespace}, &corev1.ConfigMap{})
+			Expect(kerrors.IsNotFound(err)).To(BeTrue())
+		})
+
+		It("renders valid data", func() {
+			dataComp := &injectDataComponent{key: "FOO", value: "bar"}
+			comp := NewTemplateComponent("configmap.yml", "").WithDataSchema(schema)
+			helper = startTestController(dataComp, comp)
+			c := helper.TestClient
+
+			c.Create(obj)
+
+			cmap := &corev1.ConfigMap{}
+			c.EventuallyGetName("testing", cmap)
+			Expect(cmap.Data).To(HaveKeyWithValue("FOO", Equal("bar")))
+		})
+	})
 })
@@ -17,6 +17,7 @@ type TestObjectSpec struct {
 }
 
 type TestObjectStatus struct {
+	Field      string                 `json:"field,omitempty"`
 	Conditions []conditions.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type" protobuf:"bytes,1,rep,name=conditions"`
 }
 
@@ -0,0 +1,118 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package components
+
+import (
+	"strings"
+
+	"github.com/google/cel-go/cel"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	"github.com/coderanger/controller-utils/core"
+)
+
+type validationRule struct {
+	rule    string
+	message string
+	program cel.Program
+}
+
+type validationComponent struct {
+	env     *cel.Env
+	rules   []validationRule
+	initErr error
+}
+
+// Create a Validation component. It checks CEL rules against the object and
+// sets the SpecValid condition, skipping the remaining components if any
+// rule fails. Put it first in the pipeline.
+func NewValidationComponent() *validationComponent {
+	// Match the variables used by x-kubernetes-validations, plus the whole object.
+	env, err := cel.NewEnv(
+		cel.Variable("self", cel.DynType),
+		cel.Variable("object", cel.DynType),
+	)
+	return &validationComponent{env: env, initErr: err}
+}
+
+// Add a rule. Inside the expression `self` is the object spec and `object`
+// is the whole object, e.g. `Rule("self.replicas <= 10", "replicas must be at most 10")`.
+// Compile errors are reported when the controller is built.
+func (comp *validationComponent) Rule(rule string, message string) *validationComponent {
+	if comp.initErr != nil {
+		return comp
+	}
+	ast, issues := comp.env.Compile(rule)
+	if issues != nil && issues.Err() != nil {
+		comp.initErr = errors.Wrapf(issues.Err(), "error compiling rule %s", rule)
+		return comp
+	}
+	program, err := comp.env.Program(ast)
+	if err != nil {
+		comp.initErr = errors.Wrapf(err, "error building rule %s", rule)
+		return comp
+	}
+	if message == "" {
+		message = "failed rule: " + rule
+	}
+	comp.rules = append(comp.rules, validationRule{rule: rule, message: message, program: program})
+	return comp
+}
+
+func (comp *validationComponent) GetReadyCondition() string {
+	return "SpecValid"
+}
+
+func (comp *validationComponent) Setup(_ *core.Context, _ *ctrl.Builder) error {
+	return comp.initErr
+}
+
+func (comp *validationComponent) Reconcile(ctx *core.Context) (core.Result, error) {
+	data, err := runtime.DefaultUnstructuredConverter.ToUnstructured(ctx.Object)
+	if err != nil {
+		return core.Result{}, errors.Wrap(err, "error converting object to unstructured")
+	}
+	spec, ok := data["spec"]
+	if !ok {
+		spec = map[string]interface{}{}
+	}
+	vars := map[string]interface{}{"self": spec, "object": data}
+
+	failures := []string{}
+	for _, rule := range comp.rules {
+		out, _, err := rule.program.Eval(vars)
+		if err != nil {
+			failures = append(failures, errors.Wrapf(err, "error evaluating rule %s", rule.rule).Error())
+			continue
+		}
+		valid, ok := out.Value().(bool)
+		if !ok {
+			failures = append(failures, "rule "+rule.rule+" did not return a bool")
+		} else if !valid {
+			failures = append(failures, rule.message)
+		}
+	}
+
+	if len(failures) != 0 {
+		ctx.Conditions.SetFalse("SpecValid", "ValidationFailed", strings.Join(failures, "; "))
+		return core.Result{SkipRemaining: true}, nil
+	}
+	ctx.Conditions.SetTrue("SpecValid", "Valid")
+	return core.Result{}, nil
+}
@@ -0,0 +1,74 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package components
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/coderanger/controller-utils/conditions"
+	"github.com/coderanger/controller-utils/tests"
+)
+
+var _ = Describe("Validation component", func() {
+	var helper *tests.FunctionalHelper
+	var obj *TestObject
+
+	BeforeEach(func() {
+		obj = &TestObject{
+			ObjectMeta: metav1.ObjectMeta{Name: "testing"},
+			Spec: TestObjectSpec{
+				Field: "bad",
+			},
+		}
+	})
+
+	AfterEach(func() {
+		if helper != nil {
+			helper.MustStop()
+		}
+		helper = nil
+	})
+
+	It("validates the spec", func() {
+		comp := NewValidationComponent().
+			Rule("self.field != 'bad'", "field must not be bad").
+			Rule("object.metadata.name.startsWith('test')", "")
+		afterComp := NewReadyStatusComponent("SpecValid")
+		helper = startTestController(comp, afterComp)
+		c := helper.TestClient
+
+		c.Create(obj)
+		c.EventuallyGetName(obj.Name, obj, c.EventuallyCondition("SpecValid", "False"))
+		cond := conditions.FindStatusCondition(obj.Status.Conditions, "SpecValid")
+		Expect(cond.Message).To(Equal("field must not be bad"))
+		Expect(conditions.FindStatusCondition(obj.Status.Conditions, "Ready")).To(BeNil())
+
+		objClean := obj.DeepCopy()
+		obj.Spec.Field = "good"
+		c.Patch(obj, client.MergeFrom(objClean))
+		c.EventuallyGetName(obj.Name, obj, c.EventuallyCondition("SpecValid", "True"))
+		c.EventuallyGetName(obj.Name, obj, c.EventuallyCondition("Ready", "True"))
+	})
+
+	It("reports invalid rules", func() {
+		comp := NewValidationComponent().Rule("self.field ==", "")
+		Expect(comp.Setup(nil, nil)).ToNot(Succeed())
+	})
+})
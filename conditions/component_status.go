@@ -0,0 +1,59 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package conditions
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Results recorded in ComponentStatus.
+const (
+	ComponentSucceeded = "Succeeded"
+	ComponentFailed    = "Failed"
+	ComponentRequeued  = "Requeued"
+	ComponentSkipped   = "Skipped"
+)
+
+// ComponentStatus records the outcome of the last reconcile of one component,
+// so it's clear which step is failing from the object alone. Intended for
+// direct use as an array at .status.componentStatuses, for example:
+//
+//	type FooStatus struct{
+//	    // +listType=map
+//	    // +listMapKey=name
+//	    ComponentStatuses []conditions.ComponentStatus `json:"componentStatuses,omitempty"`
+//	}
+type ComponentStatus struct {
+	// name of the component in the reconciler.
+	// +required
+	// +kubebuilder:validation:Required
+	Name string `json:"name"`
+	// result of the last reconcile, one of Succeeded, Failed, Requeued, or Skipped.
+	// +required
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Enum=Succeeded;Failed;Requeued;Skipped
+	Result string `json:"result"`
+	// message with the error from a failed reconcile, or details of a requeue.
+	// +optional
+	Message string `json:"message,omitempty"`
+	// lastTransitionTime is the last time the result or message changed.
+	// +required
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Type=string
+	// +kubebuilder:validation:Format=date-time
+	LastTransitionTime metav1.Time `json:"lastTransitionTime"`
+}
@@ -14,9 +14,8 @@ limitations under the License.
 package conditions
 
 import (
-	"time"
-
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/clock"
 )
 
 // SetStatusCondition sets the corresponding condition in conditions to newCondition.
@@ -25,13 +24,19 @@ import (
 //     newCondition, LastTransitionTime is set to now if the new status differs from the old status)
 //  2. if a condition of the specified type does not exist (LastTransitionTime is set to now() if unset, and newCondition is appended)
 func SetStatusCondition(conditions *[]Condition, newCondition Condition) {
+	SetStatusConditionWithClock(conditions, newCondition, clock.RealClock{})
+}
+
+// SetStatusConditionWithClock is SetStatusCondition using the given clock for
+// LastTransitionTime, so tests can control it.
+func SetStatusConditionWithClock(conditions *[]Condition, newCondition Condition, clk clock.PassiveClock) {
 	if conditions == nil {
 		return
 	}
 	existingCondition := FindStatusCondition(*conditions, newCondition.Type)
 	if existingCondition == nil {
 		if newCondition.LastTransitionTime.IsZero() {
-			newCondition.LastTransitionTime = metav1.NewTime(time.Now())
+			newCondition.LastTransitionTime = metav1.NewTime(clk.Now())
 		}
 		*conditions = append(*conditions, newCondition)
 		return
@@ -42,12 +47,13 @@ func SetStatusCondition(conditions *[]Condition, newCondition Condition) {
 		if !newCondition.LastTransitionTime.IsZero() {
 			existingCondition.LastTransitionTime = newCondition.LastTransitionTime
 		} else {
-			existingCondition.LastTransitionTime = metav1.NewTime(time.Now())
+			existingCondition.LastTransitionTime = metav1.NewTime(clk.Now())
 		}
 	}
 
 	existingCondition.Reason = newCondition.Reason
 	existingCondition.Message = newCondition.Message
+	existingCondition.ObservedGeneration = newCondition.ObservedGeneration
 }
 
 // RemoveStatusCondition removes the corresponding conditionType from conditions.
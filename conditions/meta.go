@@ -0,0 +1,110 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package conditions
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/clock"
+)
+
+// The fields are identical, so these are plain conversions for APIs which
+// use the upstream metav1.Condition type.
+
+// FromMetaCondition converts an upstream condition to this package's type.
+func FromMetaCondition(cond metav1.Condition) Condition {
+	return Condition(cond)
+}
+
+// ToMetaCondition converts a condition to the upstream type.
+func ToMetaCondition(cond Condition) metav1.Condition {
+	return metav1.Condition(cond)
+}
+
+// FromMetaConditions converts a list of upstream conditions.
+func FromMetaConditions(conds []metav1.Condition) []Condition {
+	if conds == nil {
+		return nil
+	}
+	converted := make([]Condition, len(conds))
+	for i, cond := range conds {
+		converted[i] = FromMetaCondition(cond)
+	}
+	return converted
+}
+
+// ToMetaConditions converts a list of conditions to the upstream type.
+func ToMetaConditions(conds []Condition) []metav1.Condition {
+	if conds == nil {
+		return nil
+	}
+	converted := make([]metav1.Condition, len(conds))
+	for i, cond := range conds {
+		converted[i] = ToMetaCondition(cond)
+	}
+	return converted
+}
+
+// SetMetaStatusCondition is SetStatusCondition for upstream conditions.
+func SetMetaStatusCondition(conditions *[]metav1.Condition, newCondition metav1.Condition) {
+	SetMetaStatusConditionWithClock(conditions, newCondition, clock.RealClock{})
+}
+
+// SetMetaStatusConditionWithClock is SetStatusConditionWithClock for upstream conditions.
+func SetMetaStatusConditionWithClock(conditions *[]metav1.Condition, newCondition metav1.Condition, clk clock.PassiveClock) {
+	if conditions == nil {
+		return
+	}
+	converted := FromMetaConditions(*conditions)
+	SetStatusConditionWithClock(&converted, FromMetaCondition(newCondition), clk)
+	*conditions = ToMetaConditions(converted)
+}
+
+// RemoveMetaStatusCondition is RemoveStatusCondition for upstream conditions.
+func RemoveMetaStatusCondition(conditions *[]metav1.Condition, conditionType string) {
+	if conditions == nil {
+		return
+	}
+	converted := FromMetaConditions(*conditions)
+	RemoveStatusCondition(&converted, conditionType)
+	*conditions = ToMetaConditions(converted)
+}
+
+// FindMetaStatusCondition is FindStatusCondition for upstream conditions.
+func FindMetaStatusCondition(conditions []metav1.Condition, conditionType string) *metav1.Condition {
+	for i := range conditions {
+		if conditions[i].Type == conditionType {
+			return &conditions[i]
+		}
+	}
+
+	return nil
+}
+
+// IsMetaStatusConditionTrue is IsStatusConditionTrue for upstream conditions.
+func IsMetaStatusConditionTrue(conditions []metav1.Condition, conditionType string) bool {
+	return IsStatusConditionTrue(FromMetaConditions(conditions), conditionType)
+}
+
+// IsMetaStatusConditionFalse is IsStatusConditionFalse for upstream conditions.
+func IsMetaStatusConditionFalse(conditions []metav1.Condition, conditionType string) bool {
+	return IsStatusConditionFalse(FromMetaConditions(conditions), conditionType)
+}
+
+// IsMetaStatusConditionPresentAndEqual is IsStatusConditionPresentAndEqual for upstream conditions.
+func IsMetaStatusConditionPresentAndEqual(conditions []metav1.Condition, conditionType string, status metav1.ConditionStatus) bool {
+	return IsStatusConditionPresentAndEqual(FromMetaConditions(conditions), conditionType, status)
+}
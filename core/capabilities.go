@@ -0,0 +1,132 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/rest"
+	"k8s.io/utils/clock"
+)
+
+// Information about the cluster, like Helm's .Capabilities, so templates can
+// adapt to what is available.
+type Capabilities struct {
+	KubeVersion KubeVersion
+	APIVersions APIVersions
+}
+
+type KubeVersion struct {
+	// Full version string, e.g. v1.25.0.
+	Version string
+	Major   string
+	Minor   string
+}
+
+// All group/versions and group/version/kinds served by the cluster.
+type APIVersions []string
+
+// Check if an API is available, either as a group/version like
+// `networking.k8s.io/v1` or a group/version/kind like `apps/v1/Deployment`.
+func (a APIVersions) Has(apiVersion string) bool {
+	for _, v := range a {
+		if v == apiVersion {
+			return true
+		}
+	}
+	return false
+}
+
+// Gather capabilities via discovery. APIs which fail discovery, usually a
+// broken aggregated API, are left out rather than failing entirely.
+func DiscoverCapabilities(config *rest.Config) (Capabilities, error) {
+	caps := Capabilities{}
+	client, err := discovery.NewDiscoveryClientForConfig(config)
+	if err != nil {
+		return caps, errors.Wrap(err, "error creating discovery client")
+	}
+	version, err := client.ServerVersion()
+	if err != nil {
+		return caps, errors.Wrap(err, "error getting server version")
+	}
+	caps.KubeVersion = KubeVersion{Version: version.GitVersion, Major: version.Major, Minor: version.Minor}
+
+	_, resourceLists, err := client.ServerGroupsAndResources()
+	if err != nil && !discovery.IsGroupDiscoveryFailedError(err) {
+		return caps, errors.Wrap(err, "error discovering APIs")
+	}
+	for _, resourceList := range resourceLists {
+		caps.APIVersions = append(caps.APIVersions, resourceList.GroupVersion)
+		for _, resource := range resourceList.APIResources {
+			caps.APIVersions = append(caps.APIVersions, resourceList.GroupVersion+"/"+resource.Kind)
+		}
+	}
+	return caps, nil
+}
+
+// How long discovered capabilities are used before discovering them again, so
+// APIs installed after the controller starts are noticed.
+var CapabilitiesRefreshInterval = 10 * time.Minute
+
+// Capabilities discovered on first use and refreshed periodically. A failed
+// refresh is logged and keeps the last good value, so a blip in discovery
+// doesn't break every reconcile.
+type capabilitiesCache struct {
+	discover func() (Capabilities, error)
+	clock    clock.PassiveClock
+	log      logr.Logger
+
+	lock         sync.Mutex
+	caps         Capabilities
+	discovered   bool
+	discoveredAt time.Time
+}
+
+func newCapabilitiesCache(config *rest.Config, clk clock.PassiveClock, log logr.Logger) *capabilitiesCache {
+	return &capabilitiesCache{
+		discover: func() (Capabilities, error) { return DiscoverCapabilities(config) },
+		clock:    clk,
+		log:      log,
+	}
+}
+
+// Get the current capabilities, discovering them if they are missing or
+// stale. Only returns an error if discovery has never succeeded.
+func (c *capabilitiesCache) Get() (Capabilities, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	now := c.clock.Now()
+	if c.discovered && now.Sub(c.discoveredAt) < CapabilitiesRefreshInterval {
+		return c.caps, nil
+	}
+	caps, err := c.discover()
+	if err != nil {
+		if c.discovered {
+			c.log.Error(err, "keeping previous cluster capabilities")
+			return c.caps, nil
+		}
+		return Capabilities{}, errors.Wrap(err, "error discovering cluster capabilities")
+	}
+	c.caps = caps
+	c.discovered = true
+	c.discoveredAt = now
+	return caps, nil
+}
@@ -0,0 +1,134 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/go-logr/logr/funcr"
+	"github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+	clocktesting "k8s.io/utils/clock/testing"
+)
+
+var _ = ginkgo.Describe("capabilitiesCache", func() {
+	var clk *clocktesting.FakeClock
+	var cache *capabilitiesCache
+	var calls int
+	var discoverErr error
+	var version string
+
+	ginkgo.BeforeEach(func() {
+		clk = clocktesting.NewFakeClock(time.Now())
+		calls = 0
+		discoverErr = nil
+		version = "v1.25.0"
+		cache = &capabilitiesCache{
+			clock: clk,
+			log:   logr.Discard(),
+			discover: func() (Capabilities, error) {
+				calls++
+				if discoverErr != nil {
+					return Capabilities{}, discoverErr
+				}
+				return Capabilities{KubeVersion: KubeVersion{Version: version}}, nil
+			},
+		}
+	})
+
+	ginkgo.It("discovers on first use", func() {
+		Expect(calls).To(Equal(0))
+		caps, err := cache.Get()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(caps.KubeVersion.Version).To(Equal("v1.25.0"))
+		Expect(calls).To(Equal(1))
+	})
+
+	ginkgo.It("refreshes after the interval", func() {
+		_, err := cache.Get()
+		Expect(err).ToNot(HaveOccurred())
+		version = "v1.26.0"
+		caps, err := cache.Get()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(caps.KubeVersion.Version).To(Equal("v1.25.0"))
+
+		clk.Step(CapabilitiesRefreshInterval)
+		caps, err = cache.Get()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(caps.KubeVersion.Version).To(Equal("v1.26.0"))
+		Expect(calls).To(Equal(2))
+	})
+
+	ginkgo.It("keeps the last good value when a refresh fails", func() {
+		_, err := cache.Get()
+		Expect(err).ToNot(HaveOccurred())
+		discoverErr = errors.New("discovery is down")
+		clk.Step(CapabilitiesRefreshInterval)
+		caps, err := cache.Get()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(caps.KubeVersion.Version).To(Equal("v1.25.0"))
+	})
+
+	ginkgo.It("retries until discovery succeeds", func() {
+		discoverErr = errors.New("discovery is down")
+		_, err := cache.Get()
+		Expect(err).To(MatchError(ContainSubstring("discovery is down")))
+
+		discoverErr = nil
+		caps, err := cache.Get()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(caps.KubeVersion.Version).To(Equal("v1.25.0"))
+	})
+
+	ginkgo.Context("in the reconciler", func() {
+		var r *Reconciler
+		var logged []string
+
+		ginkgo.BeforeEach(func() {
+			logged = nil
+			r = &Reconciler{capabilities: cache}
+		})
+
+		log := func() logr.Logger {
+			return funcr.New(func(_, args string) { logged = append(logged, args) }, funcr.Options{})
+		}
+
+		ginkgo.It("uses empty capabilities and logs until discovery succeeds", func() {
+			discoverErr = errors.New("discovery is down")
+			caps := r.getCapabilities(log())
+			Expect(caps).To(Equal(Capabilities{}))
+			Expect(logged).To(ConsistOf(ContainSubstring("discovery is down")))
+
+			discoverErr = nil
+			caps = r.getCapabilities(log())
+			Expect(caps.KubeVersion.Version).To(Equal("v1.25.0"))
+			Expect(logged).To(HaveLen(1))
+		})
+
+		ginkgo.It("logs a failed refresh and keeps the last good value", func() {
+			cache.log = log()
+			Expect(r.getCapabilities(log()).KubeVersion.Version).To(Equal("v1.25.0"))
+			discoverErr = errors.New("discovery is down")
+			version = "v1.26.0"
+			clk.Step(CapabilitiesRefreshInterval)
+			Expect(r.getCapabilities(log()).KubeVersion.Version).To(Equal("v1.25.0"))
+			Expect(logged).To(ConsistOf(ContainSubstring("keeping previous cluster capabilities")))
+		})
+	})
+})
@@ -0,0 +1,47 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"context"
+	"time"
+
+	"k8s.io/utils/clock"
+)
+
+type clockKey struct{}
+
+// Returns a context which makes reconciles use the given clock, overriding
+// Reconciler.Clock. This is how the test helpers inject a fake clock, via the
+// manager's BaseContext.
+func WithClock(ctx context.Context, c clock.Clock) context.Context {
+	return context.WithValue(ctx, clockKey{}, c)
+}
+
+func clockFrom(ctx context.Context) clock.Clock {
+	c, _ := ctx.Value(clockKey{}).(clock.Clock)
+	return c
+}
+
+// The current time according to the context's clock. Components should use
+// this rather than time.Now() so time-based behavior can be tested.
+func (c *Context) Now() time.Time {
+	if c.Clock == nil {
+		return time.Now()
+	}
+	return c.Clock.Now()
+}
@@ -0,0 +1,73 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"fmt"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/coderanger/controller-utils/conditions"
+)
+
+// An object which records the outcome of each component in its status. The
+// reconciler maintains the list for any object implementing this.
+type ComponentStatusesObject interface {
+	GetComponentStatuses() *[]conditions.ComponentStatus
+}
+
+// The status for one component run.
+func newComponentStatus(name string, res Result, err error) conditions.ComponentStatus {
+	status := conditions.ComponentStatus{Name: name, Result: conditions.ComponentSucceeded}
+	switch {
+	case err != nil:
+		status.Result = conditions.ComponentFailed
+		status.Message = messageForError(err)
+	case res.RequeueAfter != 0:
+		status.Result = conditions.ComponentRequeued
+		status.Message = fmt.Sprintf("Requeued after %s", res.RequeueAfter)
+	case res.Requeue:
+		status.Result = conditions.ComponentRequeued
+		status.Message = "Requeued"
+	}
+	return status
+}
+
+// Replace the component statuses with ones for the given components, in
+// order. Components missing from ran are marked Skipped. The transition time
+// only changes when the result or message does, so status doesn't churn.
+func updateComponentStatuses(statuses *[]conditions.ComponentStatus, names []string, ran map[string]conditions.ComponentStatus, now time.Time) {
+	previous := map[string]conditions.ComponentStatus{}
+	for _, status := range *statuses {
+		previous[status.Name] = status
+	}
+	updated := make([]conditions.ComponentStatus, 0, len(names))
+	for _, name := range names {
+		status, ok := ran[name]
+		if !ok {
+			status = conditions.ComponentStatus{Name: name, Result: conditions.ComponentSkipped}
+		}
+		if prev, ok := previous[name]; ok && prev.Result == status.Result && prev.Message == status.Message {
+			status.LastTransitionTime = prev.LastTransitionTime
+		} else {
+			status.LastTransitionTime = metav1.NewTime(now)
+		}
+		updated = append(updated, status)
+	}
+	*statuses = updated
+}
@@ -0,0 +1,72 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"time"
+
+	"github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+
+	"github.com/coderanger/controller-utils/conditions"
+)
+
+var _ = ginkgo.Describe("component statuses", func() {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	later := start.Add(time.Minute)
+	names := []string{"one", "two", "three"}
+
+	ginkgo.It("describes component results", func() {
+		Expect(newComponentStatus("one", Result{}, nil).Result).To(Equal(conditions.ComponentSucceeded))
+		failed := newComponentStatus("one", Result{}, errors.New("boom"))
+		Expect(failed.Result).To(Equal(conditions.ComponentFailed))
+		Expect(failed.Message).To(Equal("boom"))
+		requeued := newComponentStatus("one", Result{RequeueAfter: time.Minute}, nil)
+		Expect(requeued.Result).To(Equal(conditions.ComponentRequeued))
+		Expect(requeued.Message).To(Equal("Requeued after 1m0s"))
+	})
+
+	ginkgo.It("records every component in order", func() {
+		statuses := []conditions.ComponentStatus{}
+		updateComponentStatuses(&statuses, names, map[string]conditions.ComponentStatus{
+			"two": newComponentStatus("two", Result{}, errors.New("boom")),
+			"one": newComponentStatus("one", Result{}, nil),
+		}, start)
+		Expect(statuses).To(HaveLen(3))
+		Expect(statuses[0].Name).To(Equal("one"))
+		Expect(statuses[0].Result).To(Equal(conditions.ComponentSucceeded))
+		Expect(statuses[1].Result).To(Equal(conditions.ComponentFailed))
+		Expect(statuses[2].Result).To(Equal(conditions.ComponentSkipped))
+		Expect(statuses[2].LastTransitionTime.Time).To(Equal(start))
+	})
+
+	ginkgo.It("only changes the time on transitions", func() {
+		statuses := []conditions.ComponentStatus{}
+		ran := map[string]conditions.ComponentStatus{
+			"one":   newComponentStatus("one", Result{}, nil),
+			"two":   newComponentStatus("two", Result{}, nil),
+			"three": newComponentStatus("three", Result{}, nil),
+		}
+		updateComponentStatuses(&statuses, names, ran, start)
+		ran["two"] = newComponentStatus("two", Result{}, errors.New("boom"))
+		updateComponentStatuses(&statuses, names, ran, later)
+		Expect(statuses[0].LastTransitionTime.Time).To(Equal(start))
+		Expect(statuses[1].LastTransitionTime.Time).To(Equal(later))
+		Expect(statuses[2].LastTransitionTime.Time).To(Equal(start))
+	})
+})
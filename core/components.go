@@ -20,6 +20,7 @@ import (
 	"time"
 
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 type Component interface {
@@ -34,10 +35,37 @@ type FinalizerComponent interface {
 	Finalize(*Context) (Result, bool, error)
 }
 
+// A finalizer component that only needs the finalizer in some
+// configurations. Checked after Setup.
+type OptionalFinalizerComponent interface {
+	FinalizerComponent
+	NeedsFinalizer() bool
+}
+
+// A component which applies objects in apply waves, see RecordWave. Returns
+// the waves of its objects in the order they are reconciled. Checked after
+// Setup.
+type WaveComponent interface {
+	GetWaves() []int
+}
+
 type ReadyConditionComponent interface {
 	GetReadyCondition() string
 }
 
+// A component which fills in defaults on admission, see Reconciler.Webhook.
+// The object being admitted is ctx.Object and should be modified in place.
+type DefaultingComponent interface {
+	Default(*Context) error
+}
+
+// A component which validates objects on admission, see Reconciler.Webhook.
+// The object being admitted is ctx.Object. On updates oldObj is the current
+// object, on creates it is nil. Deletes are not validated.
+type ValidatingComponent interface {
+	Validate(ctx *Context, oldObj client.Object) error
+}
+
 type Result struct {
 	Requeue       bool
 	RequeueAfter  time.Duration
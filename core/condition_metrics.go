@@ -0,0 +1,115 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"reflect"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	"github.com/coderanger/controller-utils/conditions"
+)
+
+// Current status of every condition on every reconciled object, so alerts
+// like "not Ready for 10 minutes" work without per-controller metrics.
+var conditionStatusGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "controller_utils_condition_status",
+	Help: "Status of each object condition, 1 for True, 0 for False, and -1 for Unknown.",
+}, []string{"kind", "namespace", "name", "type"})
+
+// Whether each condition is at its healthy status per the registered polarity,
+// so one alert rule covers both Ready and Degraded style conditions.
+var conditionHealthyGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "controller_utils_condition_healthy",
+	Help: "Whether each object condition has its healthy status, 1 for yes and 0 for no.",
+}, []string{"kind", "namespace", "name", "type"})
+
+type conditionMetricsKey struct {
+	kind string
+	types.NamespacedName
+}
+
+// Condition types exported for each object, to clean up after deletes.
+var conditionMetricsLock sync.Mutex
+var conditionMetricsTypes = map[conditionMetricsKey]map[string]bool{}
+
+func init() {
+	metrics.Registry.MustRegister(conditionStatusGauge, conditionHealthyGauge)
+}
+
+// The kind label for an object. Typed objects usually have no TypeMeta set,
+// but their Go type is named after the kind.
+func conditionMetricsKind(obj client.Object) string {
+	kind := obj.GetObjectKind().GroupVersionKind().Kind
+	if kind == "" {
+		kind = reflect.Indirect(reflect.ValueOf(obj)).Type().Name()
+	}
+	return kind
+}
+
+func conditionStatusValue(status metav1.ConditionStatus) float64 {
+	switch status {
+	case metav1.ConditionTrue:
+		return 1
+	case metav1.ConditionFalse:
+		return 0
+	default:
+		return -1
+	}
+}
+
+// Export the current conditions of an object.
+func updateConditionMetrics(obj client.Object, conds []conditions.Condition) {
+	key := conditionMetricsKey{kind: conditionMetricsKind(obj), NamespacedName: client.ObjectKeyFromObject(obj)}
+	conditionMetricsLock.Lock()
+	defer conditionMetricsLock.Unlock()
+	current := map[string]bool{}
+	for _, cond := range conds {
+		conditionStatusGauge.WithLabelValues(key.kind, key.Namespace, key.Name, cond.Type).Set(conditionStatusValue(cond.Status))
+		healthy := 0.0
+		if cond.Status == HealthyStatus(cond.Type) {
+			healthy = 1
+		}
+		conditionHealthyGauge.WithLabelValues(key.kind, key.Namespace, key.Name, cond.Type).Set(healthy)
+		current[cond.Type] = true
+	}
+	// Drop series for conditions which were removed.
+	for conditionType := range conditionMetricsTypes[key] {
+		if !current[conditionType] {
+			conditionStatusGauge.DeleteLabelValues(key.kind, key.Namespace, key.Name, conditionType)
+			conditionHealthyGauge.DeleteLabelValues(key.kind, key.Namespace, key.Name, conditionType)
+		}
+	}
+	conditionMetricsTypes[key] = current
+}
+
+// Remove all condition series for an object which no longer exists.
+func forgetConditionMetrics(obj client.Object, name types.NamespacedName) {
+	key := conditionMetricsKey{kind: conditionMetricsKind(obj), NamespacedName: name}
+	conditionMetricsLock.Lock()
+	defer conditionMetricsLock.Unlock()
+	for conditionType := range conditionMetricsTypes[key] {
+		conditionStatusGauge.DeleteLabelValues(key.kind, key.Namespace, key.Name, conditionType)
+		conditionHealthyGauge.DeleteLabelValues(key.kind, key.Namespace, key.Name, conditionType)
+	}
+	delete(conditionMetricsTypes, key)
+}
@@ -0,0 +1,84 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/coderanger/controller-utils/conditions"
+)
+
+var _ = ginkgo.Describe("condition metrics", func() {
+	var obj *corev1.ConfigMap
+
+	ginkgo.BeforeEach(func() {
+		obj = &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "metrics", Namespace: "default"}}
+	})
+
+	ginkgo.AfterEach(func() {
+		forgetConditionMetrics(obj, client.ObjectKeyFromObject(obj))
+	})
+
+	status := func(conditionType string) float64 {
+		return testutil.ToFloat64(conditionStatusGauge.WithLabelValues("ConfigMap", "default", "metrics", conditionType))
+	}
+
+	// Count (and remove) the series for the test object, ignoring other tests.
+	series := func() int {
+		count := 0
+		for _, conditionType := range []string{"Ready", "MetricsTestDegraded"} {
+			if conditionStatusGauge.DeleteLabelValues("ConfigMap", "default", "metrics", conditionType) {
+				count++
+			}
+		}
+		return count
+	}
+
+	ginkgo.It("exports each condition", func() {
+		updateConditionMetrics(obj, []conditions.Condition{
+			{Type: "Ready", Status: metav1.ConditionTrue},
+			{Type: "MetricsTestDegraded", Status: metav1.ConditionUnknown},
+		})
+		Expect(status("Ready")).To(Equal(1.0))
+		Expect(status("MetricsTestDegraded")).To(Equal(-1.0))
+		Expect(testutil.ToFloat64(conditionHealthyGauge.WithLabelValues("ConfigMap", "default", "metrics", "Ready"))).To(Equal(1.0))
+	})
+
+	ginkgo.It("drops removed conditions", func() {
+		updateConditionMetrics(obj, []conditions.Condition{
+			{Type: "Ready", Status: metav1.ConditionTrue},
+			{Type: "MetricsTestDegraded", Status: metav1.ConditionFalse},
+		})
+		updateConditionMetrics(obj, []conditions.Condition{
+			{Type: "Ready", Status: metav1.ConditionFalse},
+		})
+		Expect(series()).To(Equal(1))
+	})
+
+	ginkgo.It("drops everything for a deleted object", func() {
+		updateConditionMetrics(obj, []conditions.Condition{
+			{Type: "Ready", Status: metav1.ConditionTrue},
+		})
+		forgetConditionMetrics(&corev1.ConfigMap{}, client.ObjectKeyFromObject(obj))
+		Expect(series()).To(Equal(0))
+	})
+})
@@ -17,12 +17,15 @@ limitations under the License.
 package core
 
 import (
+	"context"
 	"fmt"
 	"reflect"
 	"strings"
 
 	"github.com/pkg/errors"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/clock"
 
 	"github.com/coderanger/controller-utils/conditions"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -32,40 +35,100 @@ type ConditionsObject interface {
 	GetConditions() *[]conditions.Condition
 }
 
+// For APIs using the upstream condition type.
+type MetaConditionsObject interface {
+	GetConditions() *[]metav1.Condition
+}
+
+// Get the status conditions of an object. For objects using metav1.Condition
+// this is a converted copy, so changes must be written back with
+// SetConditionsFor.
 func GetConditionsFor(obj client.Object) (*[]conditions.Condition, error) {
+	conds, metaConds, err := conditionsPointerFor(obj)
+	if err != nil {
+		return nil, err
+	}
+	if metaConds != nil {
+		converted := conditions.FromMetaConditions(*metaConds)
+		return &converted, nil
+	}
+	return conds, nil
+}
+
+// Replace the status conditions of an object, converting if needed.
+func SetConditionsFor(obj client.Object, conds []conditions.Condition) error {
+	ourConds, metaConds, err := conditionsPointerFor(obj)
+	if err != nil {
+		return err
+	}
+	if metaConds != nil {
+		*metaConds = conditions.ToMetaConditions(conds)
+	} else {
+		*ourConds = conds
+	}
+	return nil
+}
+
+// Find the conditions field, returning exactly one of the two pointers.
+func conditionsPointerFor(obj client.Object) (*[]conditions.Condition, *[]metav1.Condition, error) {
 	// Try the simple and correct way.
-	condObj, ok := obj.(ConditionsObject)
-	if ok {
-		return condObj.GetConditions(), nil
+	switch condObj := obj.(type) {
+	case ConditionsObject:
+		return condObj.GetConditions(), nil, nil
+	case MetaConditionsObject:
+		return nil, condObj.GetConditions(), nil
 	}
 
-	// Supply a dynamic fallback until I can get some code generation in place.
-	// Yes, I know this code is awful.
-	statusVal := reflect.ValueOf(obj).FieldByName("Status")
+	// Supply a dynamic fallback for types without a GetConditions method, see
+	// cmd/conditions-gen. Yes, I know this code is awful.
+	statusVal := reflect.Indirect(reflect.ValueOf(obj)).FieldByName("Status")
 	if statusVal.IsValid() {
 		conditionsVal := statusVal.FieldByName("Conditions")
 		if conditionsVal.IsValid() {
-			maybeConditions := conditionsVal.Addr().Interface()
-			conditions, ok := maybeConditions.(*[]conditions.Condition)
-			if ok {
-				return conditions, nil
+			switch maybeConditions := conditionsVal.Addr().Interface().(type) {
+			case *[]conditions.Condition:
+				return maybeConditions, nil, nil
+			case *[]metav1.Condition:
+				return nil, maybeConditions, nil
 			}
 		}
 	}
 
-	return nil, errors.New("unable to get conditions")
+	return nil, nil, errors.New("unable to get conditions")
 }
 
 type conditionsHelper struct {
 	obj               client.Object
 	pendingConditions map[string]*conditions.Condition
+	clock             clock.PassiveClock
+	// Types set since the helper was created, and types to keep when pruning.
+	setTypes  map[string]bool
+	pruneKeep map[string]bool
+	// Conditions on the object when the helper was created.
+	original map[string]conditions.Condition
 }
 
 func NewConditionsHelper(obj client.Object) *conditionsHelper {
-	return &conditionsHelper{
+	return NewConditionsHelperWithClock(obj, clock.RealClock{})
+}
+
+// Create a helper which uses the given clock for LastTransitionTime, usually
+// the reconcile's Context.Clock.
+func NewConditionsHelperWithClock(obj client.Object, clk clock.PassiveClock) *conditionsHelper {
+	h := &conditionsHelper{
 		obj:               obj,
 		pendingConditions: map[string]*conditions.Condition{},
+		clock:             clk,
+		setTypes:          map[string]bool{},
+		original:          map[string]conditions.Condition{},
+	}
+	conds, err := GetConditionsFor(obj)
+	if err == nil {
+		for _, cond := range *conds {
+			h.original[cond.Type] = cond
+		}
 	}
+	return h
 }
 
 func (h *conditionsHelper) Flush() error {
@@ -75,8 +138,30 @@ func (h *conditionsHelper) Flush() error {
 	}
 	// Apply all pending conditions.
 	for _, cond := range h.pendingConditions {
-		conditions.SetStatusCondition(conds, *cond)
+		conditions.SetStatusConditionWithClock(conds, *cond, h.clock)
 	}
+	// Components can flush more than once per reconcile, e.g. the reconciler
+	// sets ready conditions to Unknown before each component. Only bump the
+	// transition time if the status differs from the start of the reconcile.
+	for i := range *conds {
+		cond := &(*conds)[i]
+		original, ok := h.original[cond.Type]
+		if ok && original.Status == cond.Status {
+			cond.LastTransitionTime = original.LastTransitionTime
+		}
+	}
+	if h.pruneKeep != nil {
+		for _, cond := range *conds {
+			if !h.pruneKeep[cond.Type] && !h.setTypes[cond.Type] {
+				conditions.RemoveStatusCondition(conds, cond.Type)
+			}
+		}
+	}
+	err = SetConditionsFor(h.obj, *conds)
+	if err != nil {
+		return errors.Wrap(err, "error setting status conditions")
+	}
+	updateConditionMetrics(h.obj, *conds)
 	// Zero out the pending map.
 	h.pendingConditions = map[string]*conditions.Condition{}
 	return nil
@@ -87,6 +172,102 @@ func (h *conditionsHelper) SetCondition(cond *conditions.Condition) {
 		cond.ObservedGeneration = h.obj.GetGeneration()
 	}
 	h.pendingConditions[cond.Type] = cond
+	h.setTypes[cond.Type] = true
+}
+
+// Longest condition message set from an error, longer ones are truncated.
+var MaxErrorMessageLength = 1024
+
+// A condition reason describing an error, like Conflict or Forbidden for API
+// errors or SecretNotFound for a ReferenceError, falling back to Error.
+func ReasonForError(err error) string {
+	var refErr *ReferenceError
+	switch {
+	case errors.As(err, &refErr):
+		return refErr.Reason()
+	case kerrors.IsConflict(err):
+		return "Conflict"
+	case kerrors.IsForbidden(err):
+		return "Forbidden"
+	case kerrors.IsUnauthorized(err):
+		return "Unauthorized"
+	case kerrors.IsNotFound(err):
+		return "NotFound"
+	case kerrors.IsAlreadyExists(err):
+		return "AlreadyExists"
+	case kerrors.IsInvalid(err):
+		return "Invalid"
+	case kerrors.IsTimeout(err), kerrors.IsServerTimeout(err), errors.Is(err, context.DeadlineExceeded):
+		return "Timeout"
+	case kerrors.IsTooManyRequests(err):
+		return "TooManyRequests"
+	default:
+		return "Error"
+	}
+}
+
+func messageForError(err error) string {
+	message := err.Error()
+	if len(message) > MaxErrorMessageLength {
+		message = message[:MaxErrorMessageLength-3] + "..."
+	}
+	return message
+}
+
+// Set a condition from an error, with a reason from ReasonForError and the
+// error as the message.
+func (h *conditionsHelper) SetFromError(conditionType string, status metav1.ConditionStatus, err error) {
+	h.Set(conditionType, status, ReasonForError(err), messageForError(err))
+}
+
+func (h *conditionsHelper) MarkFalseFromError(conditionType string, err error) {
+	h.SetFromError(conditionType, metav1.ConditionFalse, err)
+}
+
+func (h *conditionsHelper) MarkUnknownFromError(conditionType string, err error) {
+	h.SetFromError(conditionType, metav1.ConditionUnknown, err)
+}
+
+// Get the current value of a condition, preferring one set earlier in this
+// reconcile over the one on the object. Returns nil if neither is set.
+func (h *conditionsHelper) Get(conditionType string) *conditions.Condition {
+	pending, ok := h.pendingConditions[conditionType]
+	if ok {
+		cond := *pending
+		return &cond
+	}
+	conds, err := GetConditionsFor(h.obj)
+	if err != nil {
+		return nil
+	}
+	existing := conditions.FindStatusCondition(*conds, conditionType)
+	if existing == nil {
+		return nil
+	}
+	cond := *existing
+	return &cond
+}
+
+// Check if a condition is currently True, see Get.
+func (h *conditionsHelper) IsTrue(conditionType string) bool {
+	cond := h.Get(conditionType)
+	return cond != nil && cond.Status == metav1.ConditionTrue
+}
+
+// Check if a condition is currently False, see Get.
+func (h *conditionsHelper) IsFalse(conditionType string) bool {
+	cond := h.Get(conditionType)
+	return cond != nil && cond.Status == metav1.ConditionFalse
+}
+
+// Remove conditions on the next Flush unless they are of the given types or
+// were set through this helper, so objects don't accumulate conditions which
+// nothing produces anymore, e.g. after an upgrade removes a component.
+func (h *conditionsHelper) Prune(keep ...string) {
+	h.pruneKeep = map[string]bool{}
+	for _, conditionType := range keep {
+		h.pruneKeep[conditionType] = true
+	}
 }
 
 func (h *conditionsHelper) Set(conditionType string, status metav1.ConditionStatus, reason string, message ...string) {
@@ -0,0 +1,128 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core_test
+
+import (
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	clocktesting "k8s.io/utils/clock/testing"
+
+	"github.com/coderanger/controller-utils/conditions"
+	"github.com/coderanger/controller-utils/core"
+)
+
+type conditionsObjectStatus struct {
+	Conditions []conditions.Condition
+}
+
+type conditionsObject struct {
+	metav1.TypeMeta
+	metav1.ObjectMeta
+	Status conditionsObjectStatus
+}
+
+func (o *conditionsObject) DeepCopyObject() runtime.Object {
+	out := *o
+	o.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	out.Status.Conditions = append([]conditions.Condition{}, o.Status.Conditions...)
+	return &out
+}
+
+var _ = Describe("conditionsHelper", func() {
+	var obj *conditionsObject
+	var clock *clocktesting.FakeClock
+	var start metav1.Time
+
+	BeforeEach(func() {
+		clock = clocktesting.NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+		start = metav1.NewTime(clock.Now())
+		obj = &conditionsObject{}
+		obj.Status.Conditions = []conditions.Condition{
+			{Type: "Ready", Status: metav1.ConditionTrue, Reason: "Ok", LastTransitionTime: start},
+		}
+	})
+
+	It("keeps the transition time when the status doesn't change", func() {
+		helper := core.NewConditionsHelperWithClock(obj, clock)
+		clock.Step(time.Minute)
+		helper.SetTrue("Ready", "StillOk")
+		Expect(helper.Flush()).To(Succeed())
+		cond := conditions.FindStatusCondition(obj.Status.Conditions, "Ready")
+		Expect(cond.Reason).To(Equal("StillOk"))
+		Expect(cond.LastTransitionTime).To(Equal(start))
+	})
+
+	It("keeps the transition time across an intermediate flush", func() {
+		helper := core.NewConditionsHelperWithClock(obj, clock)
+		clock.Step(time.Minute)
+		helper.SetUnknown("Ready", "Unknown")
+		Expect(helper.Flush()).To(Succeed())
+		clock.Step(time.Minute)
+		helper.SetTrue("Ready", "Ok")
+		Expect(helper.Flush()).To(Succeed())
+		cond := conditions.FindStatusCondition(obj.Status.Conditions, "Ready")
+		Expect(cond.Status).To(Equal(metav1.ConditionTrue))
+		Expect(cond.LastTransitionTime).To(Equal(start))
+	})
+
+	It("bumps the transition time on a real change", func() {
+		helper := core.NewConditionsHelperWithClock(obj, clock)
+		clock.Step(time.Minute)
+		helper.SetFalse("Ready", "Broken")
+		Expect(helper.Flush()).To(Succeed())
+		cond := conditions.FindStatusCondition(obj.Status.Conditions, "Ready")
+		Expect(cond.Status).To(Equal(metav1.ConditionFalse))
+		Expect(cond.LastTransitionTime.Time).To(Equal(clock.Now()))
+	})
+
+	It("sets the transition time on a new condition", func() {
+		helper := core.NewConditionsHelperWithClock(obj, clock)
+		clock.Step(time.Minute)
+		helper.SetTrue("Available", "Ok")
+		Expect(helper.Flush()).To(Succeed())
+		cond := conditions.FindStatusCondition(obj.Status.Conditions, "Available")
+		Expect(cond.LastTransitionTime.Time).To(Equal(clock.Now()))
+	})
+
+	It("derives the reason from an API error", func() {
+		helper := core.NewConditionsHelperWithClock(obj, clock)
+		err := errors.Wrap(kerrors.NewConflict(schema.GroupResource{Resource: "things"}, "foo", errors.New("oops")), "error updating")
+		helper.MarkFalseFromError("Ready", err)
+		Expect(helper.Flush()).To(Succeed())
+		cond := conditions.FindStatusCondition(obj.Status.Conditions, "Ready")
+		Expect(cond.Status).To(Equal(metav1.ConditionFalse))
+		Expect(cond.Reason).To(Equal("Conflict"))
+		Expect(cond.Message).To(HavePrefix("error updating: "))
+	})
+
+	It("truncates long error messages", func() {
+		helper := core.NewConditionsHelperWithClock(obj, clock)
+		helper.MarkFalseFromError("Ready", errors.New(strings.Repeat("x", 5000)))
+		Expect(helper.Flush()).To(Succeed())
+		cond := conditions.FindStatusCondition(obj.Status.Conditions, "Ready")
+		Expect(cond.Reason).To(Equal("Error"))
+		Expect(cond.Message).To(HaveLen(core.MaxErrorMessageLength))
+	})
+})
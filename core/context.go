@@ -19,11 +19,13 @@ package core
 import (
 	"context"
 	"net/http"
+	"text/template"
 
 	"github.com/go-logr/logr"
 	"github.com/pkg/errors"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/clock"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
@@ -42,6 +44,13 @@ type Context struct {
 	errors []error
 	// Templates filesystem, mostly used through helpers but accessible directly too.
 	Templates http.FileSystem
+	// Extra functions available in templates.
+	TemplateFuncs template.FuncMap
+	// Globs for helper templates, nil to use the default.
+	TemplateHelpers []string
+	// Information about the cluster, available in templates as .Capabilities.
+	// Empty during Setup, and until discovery first succeeds.
+	Capabilities Capabilities
 	// Name to use as the field manager with Apply.
 	FieldManager string
 	// API Scheme for use with other helpers.
@@ -52,6 +61,13 @@ type Context struct {
 	Events record.EventRecorder
 	// Helper for setting status conditions.
 	Conditions *conditionsHelper
+	// Cache field indexer, only available during Setup. Indexes let a Watches
+	// mapper find the objects referencing a changed object with a List.
+	FieldIndexer client.FieldIndexer
+	// Source of the current time, see Now.
+	Clock clock.Clock
+	// Objects in apply waves, see RecordWave.
+	waves map[string]waveObject
 }
 
 func (c *Context) mergeResult(name string, componentResult Result, err error) {
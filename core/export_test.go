@@ -0,0 +1,33 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+// Hooks for the external tests.
+
+// A component and the name it was registered with.
+type NamedComponent struct {
+	Name string
+	Comp Component
+}
+
+func CheckWaveOrder(comps ...NamedComponent) error {
+	rcs := make([]*reconcilerComponent, 0, len(comps))
+	for _, nc := range comps {
+		rcs = append(rcs, &reconcilerComponent{name: nc.Name, comp: nc.Comp})
+	}
+	return checkWaveOrder(rcs)
+}
@@ -0,0 +1,136 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"context"
+	"reflect"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// Objects are memoized per Go type as well as kind, since one component may
+// read a Deployment as Unstructured and another as *appsv1.Deployment.
+type memoKey struct {
+	gvk    schema.GroupVersionKind
+	goType reflect.Type
+	types.NamespacedName
+}
+
+// A client which remembers the result of each Get for the rest of a reconcile,
+// so several components reading the same child only hit the cache once. Any
+// write through the client forgets that object. Reads of types declared by an
+// UncachedComponent never go through the memo.
+type memoClient struct {
+	client.Client
+	objects map[memoKey]runtime.Object
+}
+
+func newMemoClient(c client.Client) *memoClient {
+	return &memoClient{Client: c, objects: map[memoKey]runtime.Object{}}
+}
+
+func (c *memoClient) keyFor(obj client.Object, key types.NamespacedName) (memoKey, bool) {
+	gvk, err := apiutil.GVKForObject(obj, c.Scheme())
+	if err != nil {
+		return memoKey{}, false
+	}
+	return memoKey{gvk: gvk, goType: reflect.TypeOf(obj), NamespacedName: key}, true
+}
+
+func (c *memoClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+	mk, ok := c.keyFor(obj, key)
+	if !ok {
+		return c.Client.Get(ctx, key, obj, opts...)
+	}
+	if memoized, ok := c.objects[mk]; ok {
+		reflect.ValueOf(obj).Elem().Set(reflect.ValueOf(memoized.DeepCopyObject()).Elem())
+		return nil
+	}
+	err := c.Client.Get(ctx, key, obj, opts...)
+	if err != nil {
+		return err
+	}
+	c.objects[mk] = obj.DeepCopyObject()
+	return nil
+}
+
+// Drop all memoized copies of an object, whatever Go type they were read as.
+func (c *memoClient) forget(obj client.Object) {
+	gvk, err := apiutil.GVKForObject(obj, c.Scheme())
+	if err != nil {
+		// Can't tell what it was, so forget everything to be safe.
+		c.objects = map[memoKey]runtime.Object{}
+		return
+	}
+	name := client.ObjectKeyFromObject(obj)
+	for mk := range c.objects {
+		// No name means a DeleteAllOf.
+		if mk.gvk == gvk && (mk.NamespacedName == name || name.Name == "") {
+			delete(c.objects, mk)
+		}
+	}
+}
+
+func (c *memoClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	c.forget(obj)
+	return c.Client.Create(ctx, obj, opts...)
+}
+
+func (c *memoClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	c.forget(obj)
+	return c.Client.Update(ctx, obj, opts...)
+}
+
+func (c *memoClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	c.forget(obj)
+	return c.Client.Patch(ctx, obj, patch, opts...)
+}
+
+func (c *memoClient) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+	c.forget(obj)
+	return c.Client.Delete(ctx, obj, opts...)
+}
+
+func (c *memoClient) DeleteAllOf(ctx context.Context, obj client.Object, opts ...client.DeleteAllOfOption) error {
+	// The object has no name, so this forgets the whole kind.
+	c.forget(obj)
+	return c.Client.DeleteAllOf(ctx, obj, opts...)
+}
+
+func (c *memoClient) Status() client.StatusWriter {
+	return &memoStatusWriter{StatusWriter: c.Client.Status(), memo: c}
+}
+
+type memoStatusWriter struct {
+	client.StatusWriter
+	memo *memoClient
+}
+
+func (w *memoStatusWriter) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	w.memo.forget(obj)
+	return w.StatusWriter.Update(ctx, obj, opts...)
+}
+
+func (w *memoStatusWriter) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	w.memo.forget(obj)
+	return w.StatusWriter.Patch(ctx, obj, patch, opts...)
+}
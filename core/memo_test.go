@@ -0,0 +1,90 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"context"
+
+	"github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = ginkgo.Describe("memoClient", func() {
+	var backing client.Client
+	var memo *memoClient
+	key := types.NamespacedName{Name: "testing", Namespace: "default"}
+	ctx := context.Background()
+
+	ginkgo.BeforeEach(func() {
+		backing = fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(
+			&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "testing", Namespace: "default"}, Data: map[string]string{"version": "1"}},
+		).Build()
+		memo = newMemoClient(backing)
+	})
+
+	// Change the object behind the memo's back.
+	changeBacking := func(version string) {
+		configMap := &corev1.ConfigMap{}
+		Expect(backing.Get(ctx, key, configMap)).To(Succeed())
+		configMap.Data["version"] = version
+		Expect(backing.Update(ctx, configMap)).To(Succeed())
+	}
+
+	ginkgo.It("returns the first read for later gets", func() {
+		configMap := &corev1.ConfigMap{}
+		Expect(memo.Get(ctx, key, configMap)).To(Succeed())
+		changeBacking("2")
+		again := &corev1.ConfigMap{}
+		Expect(memo.Get(ctx, key, again)).To(Succeed())
+		Expect(again.Data["version"]).To(Equal("1"))
+	})
+
+	ginkgo.It("returns copies", func() {
+		configMap := &corev1.ConfigMap{}
+		Expect(memo.Get(ctx, key, configMap)).To(Succeed())
+		configMap.Data["version"] = "changed"
+		again := &corev1.ConfigMap{}
+		Expect(memo.Get(ctx, key, again)).To(Succeed())
+		Expect(again.Data["version"]).To(Equal("1"))
+	})
+
+	ginkgo.It("memoizes each Go type separately", func() {
+		Expect(memo.Get(ctx, key, &corev1.ConfigMap{})).To(Succeed())
+		changeBacking("2")
+		u := &unstructured.Unstructured{}
+		u.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("ConfigMap"))
+		Expect(memo.Get(ctx, key, u)).To(Succeed())
+		Expect(u.Object["data"]).To(HaveKeyWithValue("version", "2"))
+	})
+
+	ginkgo.It("forgets objects written through it", func() {
+		configMap := &corev1.ConfigMap{}
+		Expect(memo.Get(ctx, key, configMap)).To(Succeed())
+		configMap.Data["version"] = "2"
+		Expect(memo.Update(ctx, configMap)).To(Succeed())
+		again := &corev1.ConfigMap{}
+		Expect(memo.Get(ctx, key, again)).To(Succeed())
+		Expect(again.Data["version"]).To(Equal("2"))
+	})
+})
@@ -0,0 +1,41 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// Build a PartialObjectMetadata with the same kind, name, and namespace as
+// obj. Reading one through the cached client uses a metadata-only informer,
+// so controllers owning thousands of Secrets or ConfigMaps (watched with
+// builder.OnlyMetadata) don't also cache the full bodies.
+func MetadataOnlyObject(obj client.Object, scheme *runtime.Scheme) (*metav1.PartialObjectMetadata, error) {
+	gvk, err := apiutil.GVKForObject(obj, scheme)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error getting GVK for object %#v", obj)
+	}
+	metaObj := &metav1.PartialObjectMetadata{}
+	metaObj.SetGroupVersionKind(gvk)
+	metaObj.SetName(obj.GetName())
+	metaObj.SetNamespace(obj.GetNamespace())
+	return metaObj, nil
+}
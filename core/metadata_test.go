@@ -0,0 +1,42 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/scheme"
+
+	"github.com/coderanger/controller-utils/core"
+)
+
+var _ = Describe("MetadataOnlyObject", func() {
+	It("copies the kind and name", func() {
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "testing", Namespace: "default"},
+			Data:       map[string][]byte{"password": []byte("hunter2")},
+		}
+		metaObj, err := core.MetadataOnlyObject(secret, scheme.Scheme)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(metaObj.GroupVersionKind()).To(Equal(schema.GroupVersionKind{Version: "v1", Kind: "Secret"}))
+		Expect(metaObj.GetName()).To(Equal("testing"))
+		Expect(metaObj.GetNamespace()).To(Equal("default"))
+	})
+})
@@ -0,0 +1,42 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"context"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// Notified after every reconcile, mostly so tests can count them.
+type ReconcileObserver interface {
+	ObserveReconcile(controller string, req ctrl.Request, result ctrl.Result, err error)
+}
+
+type reconcileObserverKey struct{}
+
+// Attach an observer to a context. Reconcile contexts are derived from the
+// manager BaseContext, so returning this from BaseContext sees every
+// reconcile by that manager.
+func WithReconcileObserver(ctx context.Context, observer ReconcileObserver) context.Context {
+	return context.WithValue(ctx, reconcileObserverKey{}, observer)
+}
+
+func reconcileObserverFrom(ctx context.Context) ReconcileObserver {
+	observer, _ := ctx.Value(reconcileObserverKey{}).(ReconcileObserver)
+	return observer
+}
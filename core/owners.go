@@ -0,0 +1,85 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// Owner references can't point across namespaces or from cluster-scoped
+// objects to namespaced ones, so those children are tracked with these
+// instead. The UID label allows finding all children with a List, the
+// annotations allow mapping a child back to its owner in a watch.
+const OWNER_UID_LABEL = "controller-utils/owner-uid"
+const OWNER_KIND_ANNOTATION = "controller-utils/owner-kind"
+const OWNER_NAMESPACE_ANNOTATION = "controller-utils/owner-namespace"
+const OWNER_NAME_ANNOTATION = "controller-utils/owner-name"
+
+// Mark obj as owned by owner using labels and annotations.
+func SetAnnotationOwner(owner client.Object, obj client.Object, scheme *runtime.Scheme) error {
+	gvk, err := apiutil.GVKForObject(owner, scheme)
+	if err != nil {
+		return errors.Wrap(err, "error getting owner GVK")
+	}
+	labels := obj.GetLabels()
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	labels[OWNER_UID_LABEL] = string(owner.GetUID())
+	obj.SetLabels(labels)
+	annotations := obj.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[OWNER_KIND_ANNOTATION] = gvk.GroupKind().String()
+	annotations[OWNER_NAMESPACE_ANNOTATION] = owner.GetNamespace()
+	annotations[OWNER_NAME_ANNOTATION] = owner.GetName()
+	obj.SetAnnotations(annotations)
+	return nil
+}
+
+// Check if obj was marked as owned by owner with SetAnnotationOwner.
+func IsAnnotationOwnedBy(obj client.Object, owner client.Object) bool {
+	uid := obj.GetLabels()[OWNER_UID_LABEL]
+	return uid != "" && uid == string(owner.GetUID())
+}
+
+// Create an event handler to enqueue the owner of objects marked with
+// SetAnnotationOwner, like handler.EnqueueRequestForOwner.
+func EnqueueRequestForAnnotationOwner(ownerType client.Object, scheme *runtime.Scheme) (handler.EventHandler, error) {
+	gvk, err := apiutil.GVKForObject(ownerType, scheme)
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting owner GVK")
+	}
+	ownerKind := gvk.GroupKind().String()
+	return handler.EnqueueRequestsFromMapFunc(func(obj client.Object) []reconcile.Request {
+		annotations := obj.GetAnnotations()
+		if annotations[OWNER_KIND_ANNOTATION] != ownerKind || annotations[OWNER_NAME_ANNOTATION] == "" {
+			return nil
+		}
+		return []reconcile.Request{{NamespacedName: types.NamespacedName{
+			Name:      annotations[OWNER_NAME_ANNOTATION],
+			Namespace: annotations[OWNER_NAMESPACE_ANNOTATION],
+		}}}
+	}), nil
+}
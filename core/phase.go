@@ -0,0 +1,108 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/coderanger/controller-utils/conditions"
+)
+
+// An object with a `status.phase` string, for UIs and printer columns which
+// predate conditions. The reconciler keeps the phase in sync with the
+// conditions using its PhaseMapping.
+type PhaseObject interface {
+	GetPhase() string
+	SetPhase(string)
+}
+
+// Phase names for each aggregate condition state. Empty fields use the
+// defaults from DefaultPhaseMapping.
+type PhaseMapping struct {
+	// Condition type deciding if the object is ready, defaults to Ready.
+	ReadyCondition string
+	// The ready condition is not True.
+	Pending string
+	// The ready condition is True and nothing is degraded.
+	Ready string
+	// A condition registered as negative with RegisterConditionPolarity, like
+	// Degraded or Stalled, is True. Positive conditions which are False only
+	// mean the object is still Pending.
+	Degraded string
+	// The object is being deleted.
+	Deleting string
+}
+
+var DefaultPhaseMapping = PhaseMapping{
+	ReadyCondition: "Ready",
+	Pending:        "Pending",
+	Ready:          "Ready",
+	Degraded:       "Degraded",
+	Deleting:       "Deleting",
+}
+
+func (m PhaseMapping) withDefaults() PhaseMapping {
+	if m.ReadyCondition == "" {
+		m.ReadyCondition = DefaultPhaseMapping.ReadyCondition
+	}
+	if m.Pending == "" {
+		m.Pending = DefaultPhaseMapping.Pending
+	}
+	if m.Ready == "" {
+		m.Ready = DefaultPhaseMapping.Ready
+	}
+	if m.Degraded == "" {
+		m.Degraded = DefaultPhaseMapping.Degraded
+	}
+	if m.Deleting == "" {
+		m.Deleting = DefaultPhaseMapping.Deleting
+	}
+	return m
+}
+
+// Compute the phase for an object from its conditions.
+func ComputePhase(obj client.Object, conds []conditions.Condition, mapping PhaseMapping) string {
+	mapping = mapping.withDefaults()
+	if obj.GetDeletionTimestamp() != nil {
+		return mapping.Deleting
+	}
+	for _, cond := range conds {
+		if ConditionPolarity(cond.Type) == NegativePolarity && cond.Status == metav1.ConditionTrue {
+			return mapping.Degraded
+		}
+	}
+	if conditions.IsStatusConditionTrue(conds, mapping.ReadyCondition) {
+		return mapping.Ready
+	}
+	return mapping.Pending
+}
+
+// Set the phase of a PhaseObject from its current conditions. Does nothing
+// for other objects.
+func UpdatePhase(obj client.Object, mapping PhaseMapping) error {
+	phaseObj, ok := obj.(PhaseObject)
+	if !ok {
+		return nil
+	}
+	conds, err := GetConditionsFor(obj)
+	if err != nil {
+		return err
+	}
+	phaseObj.SetPhase(ComputePhase(obj, *conds, mapping))
+	return nil
+}
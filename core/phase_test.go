@@ -0,0 +1,106 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/coderanger/controller-utils/conditions"
+	"github.com/coderanger/controller-utils/core"
+)
+
+type phaseObject struct {
+	conditionsObject
+	phase string
+}
+
+func (o *phaseObject) GetPhase() string {
+	return o.phase
+}
+
+func (o *phaseObject) SetPhase(phase string) {
+	o.phase = phase
+}
+
+var _ = Describe("phases", func() {
+	var obj *phaseObject
+
+	BeforeEach(func() {
+		obj = &phaseObject{}
+		core.RegisterNegativeConditions("PhaseTestDegraded")
+	})
+
+	AfterEach(func() {
+		core.RegisterConditionPolarity("PhaseTestDegraded", core.PositivePolarity)
+	})
+
+	setConditions := func(conds ...conditions.Condition) {
+		obj.Status.Conditions = conds
+	}
+
+	It("is pending without a ready condition", func() {
+		Expect(core.UpdatePhase(obj, core.DefaultPhaseMapping)).To(Succeed())
+		Expect(obj.GetPhase()).To(Equal("Pending"))
+	})
+
+	It("is pending when a positive condition is false", func() {
+		setConditions(
+			conditions.Condition{Type: "Ready", Status: metav1.ConditionFalse},
+			conditions.Condition{Type: "DeploymentAvailable", Status: metav1.ConditionFalse},
+		)
+		Expect(core.UpdatePhase(obj, core.DefaultPhaseMapping)).To(Succeed())
+		Expect(obj.GetPhase()).To(Equal("Pending"))
+	})
+
+	It("is ready when the ready condition is true", func() {
+		setConditions(
+			conditions.Condition{Type: "Ready", Status: metav1.ConditionTrue},
+			conditions.Condition{Type: "PhaseTestDegraded", Status: metav1.ConditionFalse},
+		)
+		Expect(core.UpdatePhase(obj, core.DefaultPhaseMapping)).To(Succeed())
+		Expect(obj.GetPhase()).To(Equal("Ready"))
+	})
+
+	It("is degraded when a negative condition is true", func() {
+		setConditions(
+			conditions.Condition{Type: "Ready", Status: metav1.ConditionTrue},
+			conditions.Condition{Type: "PhaseTestDegraded", Status: metav1.ConditionTrue},
+		)
+		Expect(core.UpdatePhase(obj, core.DefaultPhaseMapping)).To(Succeed())
+		Expect(obj.GetPhase()).To(Equal("Degraded"))
+	})
+
+	It("is deleting once deleted", func() {
+		now := metav1.Now()
+		obj.SetDeletionTimestamp(&now)
+		setConditions(conditions.Condition{Type: "Ready", Status: metav1.ConditionTrue})
+		Expect(core.UpdatePhase(obj, core.DefaultPhaseMapping)).To(Succeed())
+		Expect(obj.GetPhase()).To(Equal("Deleting"))
+	})
+
+	It("uses a custom mapping", func() {
+		setConditions(conditions.Condition{Type: "Available", Status: metav1.ConditionTrue})
+		mapping := core.PhaseMapping{ReadyCondition: "Available", Ready: "Running"}
+		Expect(core.UpdatePhase(obj, mapping)).To(Succeed())
+		Expect(obj.GetPhase()).To(Equal("Running"))
+		setConditions()
+		Expect(core.UpdatePhase(obj, mapping)).To(Succeed())
+		Expect(obj.GetPhase()).To(Equal("Pending"))
+	})
+})
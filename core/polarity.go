@@ -0,0 +1,101 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"sync"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Polarity describes which status of a condition is the healthy one.
+type Polarity int
+
+const (
+	// The condition is healthy when True, like Ready or Available. This is
+	// the default for any unregistered condition type.
+	PositivePolarity Polarity = iota
+	// The condition is healthy when False, like Degraded or Stalled.
+	NegativePolarity
+)
+
+var polarityLock sync.RWMutex
+var polarities = map[string]Polarity{}
+
+// Register the polarity of a condition type. This is global for the process
+// and usually called from an init function next to the API types.
+func RegisterConditionPolarity(conditionType string, polarity Polarity) {
+	polarityLock.Lock()
+	defer polarityLock.Unlock()
+	if polarity == PositivePolarity {
+		delete(polarities, conditionType)
+	} else {
+		polarities[conditionType] = polarity
+	}
+}
+
+// Register condition types which are healthy when False.
+func RegisterNegativeConditions(conditionTypes ...string) {
+	for _, conditionType := range conditionTypes {
+		RegisterConditionPolarity(conditionType, NegativePolarity)
+	}
+}
+
+// Get the registered polarity of a condition type.
+func ConditionPolarity(conditionType string) Polarity {
+	polarityLock.RLock()
+	defer polarityLock.RUnlock()
+	return polarities[conditionType]
+}
+
+// The status a condition with this polarity has when everything is working.
+func (p Polarity) HealthyStatus() metav1.ConditionStatus {
+	if p == NegativePolarity {
+		return metav1.ConditionFalse
+	}
+	return metav1.ConditionTrue
+}
+
+// The status a condition with this polarity has when something is wrong.
+func (p Polarity) UnhealthyStatus() metav1.ConditionStatus {
+	if p == NegativePolarity {
+		return metav1.ConditionTrue
+	}
+	return metav1.ConditionFalse
+}
+
+// The status a registered condition type has when everything is working.
+func HealthyStatus(conditionType string) metav1.ConditionStatus {
+	return ConditionPolarity(conditionType).HealthyStatus()
+}
+
+// The status a registered condition type has when something is wrong.
+func UnhealthyStatus(conditionType string) metav1.ConditionStatus {
+	return ConditionPolarity(conditionType).UnhealthyStatus()
+}
+
+// Parse a condition type which may start with the legacy `prefix` marking it
+// as healthy when False (`!` for GetReadyCondition, `-` for
+// NewReadyStatusComponent). Returns the type without the prefix and its
+// polarity, which is negative if prefixed or otherwise the registered one.
+// Nothing is registered, callers keep the polarity themselves.
+func ParseConditionPolarity(conditionType string, prefix byte) (string, Polarity) {
+	if conditionType != "" && conditionType[0] == prefix {
+		return conditionType[1:], NegativePolarity
+	}
+	return conditionType, ConditionPolarity(conditionType)
+}
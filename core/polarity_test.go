@@ -0,0 +1,65 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/coderanger/controller-utils/core"
+)
+
+var _ = Describe("condition polarity", func() {
+	AfterEach(func() {
+		core.RegisterConditionPolarity("PolarityTest", core.PositivePolarity)
+	})
+
+	It("defaults to positive", func() {
+		Expect(core.ConditionPolarity("PolarityTest")).To(Equal(core.PositivePolarity))
+		Expect(core.HealthyStatus("PolarityTest")).To(Equal(metav1.ConditionTrue))
+		Expect(core.UnhealthyStatus("PolarityTest")).To(Equal(metav1.ConditionFalse))
+	})
+
+	It("inverts registered negative conditions", func() {
+		core.RegisterNegativeConditions("PolarityTest")
+		Expect(core.ConditionPolarity("PolarityTest")).To(Equal(core.NegativePolarity))
+		Expect(core.HealthyStatus("PolarityTest")).To(Equal(metav1.ConditionFalse))
+		Expect(core.UnhealthyStatus("PolarityTest")).To(Equal(metav1.ConditionTrue))
+	})
+
+	It("parses a legacy prefix without registering it", func() {
+		conditionType, polarity := core.ParseConditionPolarity("!PolarityTest", '!')
+		Expect(conditionType).To(Equal("PolarityTest"))
+		Expect(polarity).To(Equal(core.NegativePolarity))
+		Expect(polarity.HealthyStatus()).To(Equal(metav1.ConditionFalse))
+		Expect(polarity.UnhealthyStatus()).To(Equal(metav1.ConditionTrue))
+		Expect(core.ConditionPolarity("PolarityTest")).To(Equal(core.PositivePolarity))
+	})
+
+	It("ignores a different prefix", func() {
+		conditionType, polarity := core.ParseConditionPolarity("PolarityTest", '!')
+		Expect(conditionType).To(Equal("PolarityTest"))
+		Expect(polarity).To(Equal(core.PositivePolarity))
+	})
+
+	It("returns the registered polarity without a prefix", func() {
+		core.RegisterNegativeConditions("PolarityTest")
+		_, polarity := core.ParseConditionPolarity("PolarityTest", '!')
+		Expect(polarity).To(Equal(core.NegativePolarity))
+	})
+})
@@ -21,12 +21,14 @@ import (
 	"fmt"
 	"net/http"
 	"strings"
+	"text/template"
 
 	"github.com/go-logr/logr"
 	"github.com/pkg/errors"
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/clock"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -36,6 +38,8 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	"github.com/coderanger/controller-utils/conditions"
 )
 
 // Supporting mocking out functions for testing
@@ -57,9 +61,15 @@ type Reconciler struct {
 	client            client.Client
 	uncachedClient    client.Client
 	templates         http.FileSystem
+	templateFuncs     template.FuncMap
+	templateHelpers   []string
+	capabilities      *capabilitiesCache
 	events            record.EventRecorder
 	webhook           bool
 	finalizerBaseName string
+	clock             clock.Clock
+	pruneConditions   []string
+	phaseMapping      PhaseMapping
 }
 
 // Concrete component instance.
@@ -70,8 +80,8 @@ type reconcilerComponent struct {
 	finalizer     FinalizerComponent
 	finalizerName string
 	// Tracking data for status conditions.
-	readyCondition       string
-	errorConditionStatus metav1.ConditionStatus
+	readyCondition string
+	readyPolarity  Polarity
 }
 
 func NewReconciler(mgr ctrl.Manager) *Reconciler {
@@ -85,6 +95,7 @@ func NewReconciler(mgr ctrl.Manager) *Reconciler {
 		components:        []*reconcilerComponent{},
 		client:            mgr.GetClient(),
 		uncachedClient:    rawClient,
+		clock:             clock.RealClock{},
 	}
 }
 
@@ -104,6 +115,50 @@ func (r *Reconciler) Templates(t http.FileSystem) *Reconciler {
 	return r
 }
 
+// Add extra functions for use in templates, like cloud-specific naming
+// helpers. These override the built-in functions with the same name.
+func (r *Reconciler) TemplateFuncs(funcs template.FuncMap) *Reconciler {
+	if r.templateFuncs == nil {
+		r.templateFuncs = template.FuncMap{}
+	}
+	for name, fn := range funcs {
+		r.templateFuncs[name] = fn
+	}
+	return r
+}
+
+// Set where to load helper templates from, as globs which may use `**`.
+// Defaults to helpers/**/*.tpl.
+func (r *Reconciler) TemplateHelpers(globs ...string) *Reconciler {
+	r.templateHelpers = append(r.templateHelpers, globs...)
+	return r
+}
+
+// Remove conditions not set by any component during a reconcile, other than
+// the given types and the components' ready conditions. Pruning is skipped
+// when a component stops the reconcile early.
+func (r *Reconciler) PruneConditions(keep ...string) *Reconciler {
+	r.pruneConditions = append([]string{}, keep...)
+	return r
+}
+
+// Set the phase names used for objects implementing PhaseObject, defaults to
+// DefaultPhaseMapping.
+func (r *Reconciler) PhaseMapping(mapping PhaseMapping) *Reconciler {
+	r.phaseMapping = mapping
+	return r
+}
+
+// Set the clock used by components, mostly for testing time-based behavior.
+// Defaults to the real clock.
+func (r *Reconciler) Clock(c clock.Clock) *Reconciler {
+	r.clock = c
+	return r
+}
+
+// Register admission webhooks for the API type. Components implementing
+// DefaultingComponent or ValidatingComponent are aggregated into a single
+// mutating and validating handler, after any hooks on the type itself.
 func (r *Reconciler) Webhook() *Reconciler {
 	r.webhook = true
 	return r
@@ -117,13 +172,8 @@ func (r *Reconciler) Component(name string, comp Component) *Reconciler {
 	}
 	readyCond, ok := comp.(ReadyConditionComponent)
 	if ok {
-		rc.readyCondition = readyCond.GetReadyCondition()
-		rc.errorConditionStatus = metav1.ConditionFalse
-		// If the first character is !, trim it off and invert the error status.
-		if rc.readyCondition != "" && rc.readyCondition[0] == '!' {
-			rc.readyCondition = rc.readyCondition[1:]
-			rc.errorConditionStatus = metav1.ConditionTrue
-		}
+		// A leading ! marks a condition which is healthy when False.
+		rc.readyCondition, rc.readyPolarity = ParseConditionPolarity(readyCond.GetReadyCondition(), '!')
 	}
 	r.components = append(r.components, rc)
 	return r
@@ -181,6 +231,18 @@ func (r *Reconciler) Build() (controller.Controller, error) {
 		r.finalizerBaseName = fmt.Sprintf("%s.%s/", name, gvk.Group)
 	}
 
+	// Some template filesystems need to watch the cluster, like templates.ConfigMapFileSystem.
+	if templateSetup, ok := r.templates.(interface{ SetupWithManager(ctrl.Manager) error }); ok {
+		err := templateSetup.SetupWithManager(r.mgr)
+		if err != nil {
+			return nil, errors.Wrap(err, "error setting up templates")
+		}
+	}
+
+	// Find out what the cluster supports for use in templates. Discovery
+	// waits for the first reconcile, Setup only needs the object types.
+	r.capabilities = newCapabilitiesCache(r.mgr.GetConfig(), r.clock, r.log.WithName("capabilities"))
+
 	// Check if we have more than component with the same name.
 	compMap := map[string]Component{}
 	for _, rc := range r.components {
@@ -192,12 +254,16 @@ func (r *Reconciler) Build() (controller.Controller, error) {
 	}
 
 	setupCtx := &Context{
-		Context:        context.Background(),
-		Client:         r.client,
-		UncachedClient: r.uncachedClient,
-		Templates:      r.templates,
-		Scheme:         r.mgr.GetScheme(),
-		Object:         r.apiType.DeepCopyObject().(client.Object),
+		Context:         context.Background(),
+		Client:          r.client,
+		UncachedClient:  r.uncachedClient,
+		Templates:       r.templates,
+		TemplateFuncs:   r.templateFuncs,
+		TemplateHelpers: r.templateHelpers,
+		Scheme:          r.mgr.GetScheme(),
+		Object:          r.apiType.DeepCopyObject().(client.Object),
+		FieldIndexer:    r.mgr.GetFieldIndexer(),
+		Clock:           r.clock,
 	}
 	// Provide some bare minimum data
 	setupObj := setupCtx.Object.(metav1.Object)
@@ -206,6 +272,10 @@ func (r *Reconciler) Build() (controller.Controller, error) {
 	log := r.log.WithName("components")
 	for _, rc := range r.components {
 		rc.finalizerName = r.finalizerBaseName + rc.name
+		setupCtx.Client, err = ClientForComponent(rc.comp, r.client, r.uncachedClient, r.mgr.GetScheme())
+		if err != nil {
+			return nil, errors.Wrapf(err, "error building client for component %s in controller %s", rc.name, r.name)
+		}
 		setupComp, ok := rc.comp.(InitializerComponent)
 		if !ok {
 			continue
@@ -217,6 +287,23 @@ func (r *Reconciler) Build() (controller.Controller, error) {
 			return nil, errors.Wrapf(err, "error initializing component %s in controller %s", rc.name, r.name)
 		}
 	}
+	// Watch anything components read from the spec.
+	err = r.setupReferences()
+	if err != nil {
+		return nil, errors.Wrapf(err, "error setting up references in controller %s", r.name)
+	}
+	// Barriers only see earlier waves, so make sure they come first.
+	err = checkWaveOrder(r.components)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error checking apply waves in controller %s", r.name)
+	}
+	// Now that setup is done, check if optional finalizers are needed.
+	for _, rc := range r.components {
+		optionalFinalizer, ok := rc.comp.(OptionalFinalizerComponent)
+		if ok && !optionalFinalizer.NeedsFinalizer() {
+			rc.finalizer = nil
+		}
+	}
 	controller, err := r.controllerBuilder.Build(r)
 	if err != nil {
 		return nil, errors.Wrapf(err, "error building controller %s", r.name)
@@ -225,7 +312,7 @@ func (r *Reconciler) Build() (controller.Controller, error) {
 	r.events = r.mgr.GetEventRecorderFor(r.name + "-controller")
 	// If requested, set up a webhook runable too.
 	if r.webhook {
-		err := ctrl.NewWebhookManagedBy(r.mgr).For(r.apiType).Complete()
+		err := r.setupWebhook()
 		if err != nil {
 			return nil, errors.Wrap(err, "error initializing webhook")
 		}
@@ -233,18 +320,42 @@ func (r *Reconciler) Build() (controller.Controller, error) {
 	return controller, nil
 }
 
+// Get what the cluster supports. Discovery being down shouldn't block
+// reconciles, so until it first succeeds carry on with empty capabilities.
+func (r *Reconciler) getCapabilities(log logr.Logger) Capabilities {
+	caps, err := r.capabilities.Get()
+	if err != nil {
+		log.Error(err, "continuing without cluster capabilities")
+	}
+	return caps
+}
+
 func (r *Reconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	result, err := r.reconcile(ctx, req)
+	if observer := reconcileObserverFrom(ctx); observer != nil {
+		observer.ObserveReconcile(r.name, req, result, err)
+	}
+	return result, err
+}
+
+func (r *Reconciler) reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	log := r.log.WithValues("object", req)
 	log.Info("Starting reconcile")
 
 	recCtx := &Context{
-		Context:        ctx,
-		Client:         r.client,
-		UncachedClient: r.uncachedClient,
-		Templates:      r.templates,
-		Scheme:         r.mgr.GetScheme(),
-		Events:         r.events,
-		Data:           ContextData{},
+		Context:         ctx,
+		Client:          r.client,
+		UncachedClient:  r.uncachedClient,
+		Templates:       r.templates,
+		TemplateFuncs:   r.templateFuncs,
+		TemplateHelpers: r.templateHelpers,
+		Scheme:          r.mgr.GetScheme(),
+		Events:          r.events,
+		Data:            ContextData{},
+		Clock:           r.clock,
+	}
+	if c := clockFrom(ctx); c != nil {
+		recCtx.Clock = c
 	}
 
 	obj := r.apiType.DeepCopyObject().(client.Object)
@@ -253,13 +364,14 @@ func (r *Reconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Resu
 		if kerrors.IsNotFound(err) {
 			// Object not found, likely already deleted, just silenty bail.
 			log.Info("Aborting reconcile, object already deleted")
+			forgetConditionMetrics(obj, req.NamespacedName)
 			return reconcile.Result{}, nil
 		}
 		return reconcile.Result{Requeue: true}, errors.Wrap(err, "error getting reconcile object")
 	}
 	recCtx.Object = obj.(client.Object)
 
-	recCtx.Conditions = NewConditionsHelper(recCtx.Object)
+	recCtx.Conditions = NewConditionsHelperWithClock(recCtx.Object, recCtx.Clock)
 	cleanObj := obj.DeepCopyObject().(client.Object)
 
 	// Check for annotation that blocks reconciles, exit early if found.
@@ -270,17 +382,28 @@ func (r *Reconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Resu
 		return reconcile.Result{}, nil
 	}
 
-	// Reconcile the components.
+	// Templates need to know what the cluster supports.
+	recCtx.Capabilities = r.getCapabilities(log)
+
+	// Reconcile the components, sharing reads between them.
+	memo := newMemoClient(r.client)
 	compLog := log.WithName("components")
+	skipped := false
+	componentStatuses := map[string]conditions.ComponentStatus{}
 	for _, rc := range r.components {
 		// Create the per-component logger.
 		recCtx.Log = compLog.WithName(rc.name)
 		recCtx.FieldManager = fmt.Sprintf("%s/%s", r.name, rc.name)
+		recCtx.Client, err = ClientForComponent(rc.comp, memo, r.uncachedClient, r.mgr.GetScheme())
+		if err != nil {
+			return reconcile.Result{}, errors.Wrapf(err, "error building client for component %s", rc.name)
+		}
 		isAlive := recCtx.Object.GetDeletionTimestamp() == nil
 		if rc.readyCondition != "" {
 			recCtx.Conditions.SetUnknown(rc.readyCondition, "Unknown")
 		}
 		var res Result
+		ran := true
 		if isAlive {
 			log.V(1).Info("Reconciling component", "component", rc.name)
 			res, err = rc.comp.Reconcile(recCtx)
@@ -294,22 +417,58 @@ func (r *Reconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Resu
 			if done {
 				controllerutil.RemoveFinalizer(recCtx.Object, rc.finalizerName)
 			}
+		} else {
+			ran = false
 		}
 		if err != nil && rc.readyCondition != "" {
 			// Mark the status condition for this component as bad.
-			recCtx.Conditions.Set(rc.readyCondition, rc.errorConditionStatus, "Error", err.Error())
+			recCtx.Conditions.SetFromError(rc.readyCondition, rc.readyPolarity.UnhealthyStatus(), err)
 		}
 		recCtx.mergeResult(rc.name, res, err)
+		if ran {
+			componentStatuses[rc.name] = newComponentStatus(rc.name, res, err)
+		}
 		if err != nil {
 			log.Error(err, "error in component reconcile", "component", rc.name)
 		}
 		if res.SkipRemaining {
 			// Abort reconcile to skip remaining components.
 			log.V(1).Info("Skipping remaining components")
+			skipped = true
 			break
 		}
 	}
 
+	// Clean up conditions which no component produces anymore.
+	if r.pruneConditions != nil && !skipped {
+		keep := append([]string{}, r.pruneConditions...)
+		for _, rc := range r.components {
+			if rc.readyCondition != "" {
+				keep = append(keep, rc.readyCondition)
+			}
+		}
+		recCtx.Conditions.Prune(keep...)
+		err := recCtx.Conditions.Flush()
+		if err != nil {
+			recCtx.errors = append(recCtx.errors, errors.Wrap(err, "error pruning conditions"))
+		}
+	}
+
+	// Record how each component did, if the object wants to know.
+	if statusesObj, ok := recCtx.Object.(ComponentStatusesObject); ok {
+		names := make([]string, len(r.components))
+		for i, rc := range r.components {
+			names[i] = rc.name
+		}
+		updateComponentStatuses(statusesObj.GetComponentStatuses(), names, componentStatuses, recCtx.Now())
+	}
+
+	// Keep status.phase in sync with the final conditions.
+	err = UpdatePhase(recCtx.Object, r.phaseMapping)
+	if err != nil {
+		recCtx.errors = append(recCtx.errors, errors.Wrap(err, "error updating phase"))
+	}
+
 	// Check if we need to patch metadata, only looking at labels, annotations, and finalizers.
 	currentMeta := r.apiType.DeepCopyObject().(client.Object)
 	currentMeta.SetName(recCtx.Object.GetName())
@@ -0,0 +1,144 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+// A reference from the reconcile object to objects it reads but doesn't own,
+// like a Secret named in the spec.
+type Reference struct {
+	// Type of the referenced objects, e.g. &corev1.Secret{}.
+	Type client.Object
+	// Names of the referenced objects in the same namespace as obj, which is
+	// the reconcile object type.
+	Names func(obj client.Object) []string
+}
+
+// A component which reads objects named in the spec. The reconciler indexes
+// the names and watches the referenced kinds, so the object is reconciled
+// when a referenced Secret or ConfigMap changes.
+type ReferencingComponent interface {
+	GetReferences() []Reference
+}
+
+// A reference to Secrets, usually read with Context.ResolveSecretRef.
+func SecretReference(names func(obj client.Object) []string) Reference {
+	return Reference{Type: &corev1.Secret{}, Names: names}
+}
+
+// A reference to ConfigMaps, usually read with Context.ResolveConfigMapRef.
+func ConfigMapReference(names func(obj client.Object) []string) Reference {
+	return Reference{Type: &corev1.ConfigMap{}, Names: names}
+}
+
+// Register indexes and watches for all component references. Must be called
+// before the controller is built.
+func (r *Reconciler) setupReferences() error {
+	for _, rc := range r.components {
+		refComp, ok := rc.comp.(ReferencingComponent)
+		if !ok {
+			continue
+		}
+		for _, ref := range refComp.GetReferences() {
+			gvk, err := apiutil.GVKForObject(ref.Type, r.mgr.GetScheme())
+			if err != nil {
+				return errors.Wrapf(err, "error getting GVK for reference in component %s", rc.name)
+			}
+			field := fmt.Sprintf("controller-utils.references.%s.%s.%s", r.name, rc.name, gvk.GroupKind())
+			names := ref.Names
+			err = r.mgr.GetFieldIndexer().IndexField(context.Background(), r.apiType, field, func(obj client.Object) []string {
+				return names(obj)
+			})
+			if err != nil {
+				return errors.Wrapf(err, "error indexing %s references in component %s", gvk.Kind, rc.name)
+			}
+			mapper, err := r.referenceMapper(field)
+			if err != nil {
+				return err
+			}
+			r.controllerBuilder.Watches(&source.Kind{Type: ref.Type}, handler.EnqueueRequestsFromMapFunc(mapper))
+		}
+	}
+	return nil
+}
+
+// Build a mapper from a referenced object to the reconcile objects naming it
+// in the given index.
+func (r *Reconciler) referenceMapper(field string) (handler.MapFunc, error) {
+	return newReferenceMapper(r.client, r.mgr.GetScheme(), r.apiType, field, r.log)
+}
+
+func newReferenceMapper(reader client.Reader, scheme *runtime.Scheme, apiType client.Object, field string, log logr.Logger) (handler.MapFunc, error) {
+	gvk, err := apiutil.GVKForObject(apiType, scheme)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error getting GVK for object %#v", apiType)
+	}
+	listGVK := gvk.GroupVersion().WithKind(gvk.Kind + "List")
+	return func(obj client.Object) []reconcile.Request {
+		list, err := scheme.New(listGVK)
+		if err != nil {
+			log.Error(err, "error creating list for references", "gvk", listGVK)
+			return nil
+		}
+		objList := list.(client.ObjectList)
+		// This only uses the name, so deletes map the same as any other event.
+		err = reader.List(context.Background(), objList, client.InNamespace(obj.GetNamespace()), client.MatchingFields{field: obj.GetName()})
+		if err != nil {
+			log.Error(err, "error listing referencing objects", "field", field, "name", obj.GetName())
+			return nil
+		}
+		requests := []reconcile.Request{}
+		err = forEachListItem(objList, func(item client.Object) {
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: item.GetName(), Namespace: item.GetNamespace()}})
+		})
+		if err != nil {
+			log.Error(err, "error reading referencing objects", "field", field)
+			return nil
+		}
+		return requests
+	}, nil
+}
+
+func forEachListItem(list client.ObjectList, f func(client.Object)) error {
+	items, err := meta.ExtractList(list)
+	if err != nil {
+		return err
+	}
+	for _, item := range items {
+		obj, ok := item.(client.Object)
+		if !ok {
+			return errors.Errorf("list item %#v is not an object", item)
+		}
+		f(obj)
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"context"
+
+	"github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// The fake client doesn't support field indexes, so emulate the one the
+// Reconciler would register.
+type indexedReader struct {
+	client.Client
+	field string
+	names func(obj client.Object) []string
+}
+
+func (r *indexedReader) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	listOpts := &client.ListOptions{}
+	listOpts.ApplyOptions(opts)
+	value, ok := listOpts.FieldSelector.RequiresExactMatch(r.field)
+	Expect(ok).To(BeTrue())
+	err := r.Client.List(ctx, list, client.InNamespace(listOpts.Namespace))
+	if err != nil {
+		return err
+	}
+	matched := []runtime.Object{}
+	err = forEachListItem(list, func(item client.Object) {
+		for _, name := range r.names(item) {
+			if name == value {
+				matched = append(matched, item)
+				return
+			}
+		}
+	})
+	if err != nil {
+		return err
+	}
+	return meta.SetList(list, matched)
+}
+
+func podSecretNames(obj client.Object) []string {
+	names := []string{}
+	for _, volume := range obj.(*corev1.Pod).Spec.Volumes {
+		if volume.Secret != nil {
+			names = append(names, volume.Secret.SecretName)
+		}
+	}
+	return names
+}
+
+func secretPod(name string, namespace string, secretName string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		Spec: corev1.PodSpec{
+			Volumes: []corev1.Volume{{
+				Name:         "secret",
+				VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: secretName}},
+			}},
+		},
+	}
+}
+
+var _ = ginkgo.Describe("reference mapper", func() {
+	var mapper func(client.Object) []reconcile.Request
+	field := "controller-utils.references.test.comp.Secret"
+
+	ginkgo.BeforeEach(func() {
+		c := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(
+			secretPod("one", "default", "shared"),
+			secretPod("two", "default", "shared"),
+			secretPod("three", "default", "other"),
+			secretPod("four", "elsewhere", "shared"),
+			&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "shared", Namespace: "default"}},
+		).Build()
+		reader := &indexedReader{Client: c, field: field, names: podSecretNames}
+		var err error
+		mapper, err = newReferenceMapper(reader, scheme.Scheme, &corev1.Pod{}, field, ctrl.Log)
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	request := func(name string) reconcile.Request {
+		return reconcile.Request{NamespacedName: types.NamespacedName{Name: name, Namespace: "default"}}
+	}
+
+	ginkgo.It("maps a referenced object to everything naming it in its namespace", func() {
+		secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "shared", Namespace: "default"}}
+		Expect(mapper(secret)).To(ConsistOf(request("one"), request("two")))
+	})
+
+	ginkgo.It("maps a deleted reference", func() {
+		// Nothing named other exists, like the object from a delete event.
+		secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "default"}}
+		Expect(mapper(secret)).To(ConsistOf(request("three")))
+	})
+
+	ginkgo.It("maps an unreferenced object to nothing", func() {
+		secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "unused", Namespace: "default"}}
+		Expect(mapper(secret)).To(BeEmpty())
+	})
+})
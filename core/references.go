@@ -0,0 +1,122 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// A user-provided reference which couldn't be resolved because the object or
+// key doesn't exist. ReasonForError maps it to a reason like SecretNotFound or
+// SecretKeyNotFound so conditions explain what's missing.
+type ReferenceError struct {
+	Kind string
+	types.NamespacedName
+	// Empty if the object itself is missing.
+	Key string
+}
+
+func (e *ReferenceError) Error() string {
+	if e.Key == "" {
+		return fmt.Sprintf("%s %s not found", e.Kind, e.NamespacedName)
+	}
+	return fmt.Sprintf("key %s not found in %s %s", e.Key, e.Kind, e.NamespacedName)
+}
+
+// A condition reason for the error.
+func (e *ReferenceError) Reason() string {
+	if e.Key == "" {
+		return e.Kind + "NotFound"
+	}
+	return e.Kind + "KeyNotFound"
+}
+
+func IsReferenceError(err error) bool {
+	var refErr *ReferenceError
+	return errors.As(err, &refErr)
+}
+
+// Get a referenced object in the same namespace as the reconcile object. The
+// cache is tried first, falling back to the API server if it's not found there
+// in case the object was created moments ago or the kind isn't being watched.
+func (c *Context) getReference(kind string, name string, obj client.Object) error {
+	key := types.NamespacedName{Name: name, Namespace: c.Object.GetNamespace()}
+	err := c.Client.Get(c, key, obj)
+	if kerrors.IsNotFound(err) && c.UncachedClient != nil {
+		err = c.UncachedClient.Get(c, key, obj)
+	}
+	if kerrors.IsNotFound(err) {
+		return &ReferenceError{Kind: kind, NamespacedName: key}
+	} else if err != nil {
+		return errors.Wrapf(err, "error getting %s %s", kind, key)
+	}
+	return nil
+}
+
+// Read the value of a key in a Secret referenced from the object spec. If the
+// selector is Optional and the Secret or key is missing, returns nil with no
+// error. Otherwise missing data returns a *ReferenceError.
+func (c *Context) ResolveSecretRef(ref corev1.SecretKeySelector) ([]byte, error) {
+	optional := ref.Optional != nil && *ref.Optional
+	secret := &corev1.Secret{}
+	err := c.getReference("Secret", ref.Name, secret)
+	if err != nil {
+		if optional && IsReferenceError(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	val, ok := secret.Data[ref.Key]
+	if !ok {
+		if optional {
+			return nil, nil
+		}
+		return nil, &ReferenceError{Kind: "Secret", NamespacedName: client.ObjectKeyFromObject(secret), Key: ref.Key}
+	}
+	return val, nil
+}
+
+// Read the value of a key in a ConfigMap referenced from the object spec,
+// checking both Data and BinaryData. Optional selectors behave as with
+// ResolveSecretRef.
+func (c *Context) ResolveConfigMapRef(ref corev1.ConfigMapKeySelector) (string, error) {
+	optional := ref.Optional != nil && *ref.Optional
+	configMap := &corev1.ConfigMap{}
+	err := c.getReference("ConfigMap", ref.Name, configMap)
+	if err != nil {
+		if optional && IsReferenceError(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	if val, ok := configMap.Data[ref.Key]; ok {
+		return val, nil
+	}
+	if val, ok := configMap.BinaryData[ref.Key]; ok {
+		return string(val), nil
+	}
+	if optional {
+		return "", nil
+	}
+	return "", &ReferenceError{Kind: "ConfigMap", NamespacedName: client.ObjectKeyFromObject(configMap), Key: ref.Key}
+}
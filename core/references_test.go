@@ -0,0 +1,105 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/coderanger/controller-utils/core"
+)
+
+var _ = Describe("reference resolution", func() {
+	var ctx *core.Context
+	optional := true
+
+	BeforeEach(func() {
+		cached := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(
+			&corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "cached", Namespace: "default"},
+				Data:       map[string][]byte{"password": []byte("hunter2")},
+			},
+			&corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: "config", Namespace: "default"},
+				Data:       map[string]string{"url": "http://example.com"},
+				BinaryData: map[string][]byte{"blob": []byte("binary")},
+			},
+		).Build()
+		uncached := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(
+			&corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "uncached", Namespace: "default"},
+				Data:       map[string][]byte{"password": []byte("swordfish")},
+			},
+		).Build()
+		ctx = &core.Context{
+			Context:        context.Background(),
+			Object:         &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "testing", Namespace: "default"}},
+			Client:         cached,
+			UncachedClient: uncached,
+		}
+	})
+
+	It("reads a secret key from the cache", func() {
+		val, err := ctx.ResolveSecretRef(corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "cached"}, Key: "password"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(val).To(Equal([]byte("hunter2")))
+	})
+
+	It("falls back to the uncached client", func() {
+		val, err := ctx.ResolveSecretRef(corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "uncached"}, Key: "password"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(val).To(Equal([]byte("swordfish")))
+	})
+
+	It("returns a reference error for a missing secret", func() {
+		_, err := ctx.ResolveSecretRef(corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "missing"}, Key: "password"})
+		Expect(core.IsReferenceError(err)).To(BeTrue())
+		Expect(core.ReasonForError(err)).To(Equal("SecretNotFound"))
+		Expect(err.Error()).To(Equal("Secret default/missing not found"))
+	})
+
+	It("returns a reference error for a missing key", func() {
+		_, err := ctx.ResolveSecretRef(corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "cached"}, Key: "other"})
+		Expect(core.ReasonForError(err)).To(Equal("SecretKeyNotFound"))
+	})
+
+	It("allows optional references to be missing", func() {
+		val, err := ctx.ResolveSecretRef(corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "missing"}, Key: "password", Optional: &optional})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(val).To(BeNil())
+	})
+
+	It("reads config map data and binary data", func() {
+		val, err := ctx.ResolveConfigMapRef(corev1.ConfigMapKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "config"}, Key: "url"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(val).To(Equal("http://example.com"))
+		val, err = ctx.ResolveConfigMapRef(corev1.ConfigMapKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "config"}, Key: "blob"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(val).To(Equal("binary"))
+	})
+
+	It("returns a reference error for a missing config map key", func() {
+		_, err := ctx.ResolveConfigMapRef(corev1.ConfigMapKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "config"}, Key: "other"})
+		Expect(core.ReasonForError(err)).To(Equal("ConfigMapKeyNotFound"))
+	})
+})
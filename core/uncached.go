@@ -0,0 +1,81 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"context"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// A component which needs strongly consistent reads of some types, like
+// generated Secrets where a stale cache would mean generating a new value.
+// ctx.Client for the component reads those types from the API server.
+type UncachedComponent interface {
+	GetUncachedTypes() []client.Object
+}
+
+// A client reading some kinds from the uncached client and everything else,
+// including all writes, from the normal one.
+type uncachedTypesClient struct {
+	client.Client
+	uncached client.Client
+	gvks     map[schema.GroupVersionKind]bool
+}
+
+// The client to use as ctx.Client for a component. Returns cached unless the
+// component implements UncachedComponent.
+func ClientForComponent(comp Component, cached client.Client, uncached client.Client, scheme *runtime.Scheme) (client.Client, error) {
+	uncachedComp, ok := comp.(UncachedComponent)
+	if !ok || uncached == nil {
+		return cached, nil
+	}
+	gvks := map[schema.GroupVersionKind]bool{}
+	for _, obj := range uncachedComp.GetUncachedTypes() {
+		gvk, err := apiutil.GVKForObject(obj, scheme)
+		if err != nil {
+			return nil, err
+		}
+		gvks[gvk] = true
+	}
+	return &uncachedTypesClient{Client: cached, uncached: uncached, gvks: gvks}, nil
+}
+
+func (c *uncachedTypesClient) readerFor(obj runtime.Object) client.Reader {
+	gvk, err := apiutil.GVKForObject(obj, c.Scheme())
+	if err != nil {
+		// Let the normal client return the error.
+		return c.Client
+	}
+	gvk.Kind = strings.TrimSuffix(gvk.Kind, "List")
+	if c.gvks[gvk] {
+		return c.uncached
+	}
+	return c.Client
+}
+
+func (c *uncachedTypesClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+	return c.readerFor(obj).Get(ctx, key, obj, opts...)
+}
+
+func (c *uncachedTypesClient) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	return c.readerFor(list).List(ctx, list, opts...)
+}
@@ -0,0 +1,87 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/coderanger/controller-utils/core"
+)
+
+type plainComponent struct{}
+
+func (*plainComponent) Reconcile(*core.Context) (core.Result, error) {
+	return core.Result{}, nil
+}
+
+type uncachedSecretsComponent struct {
+	plainComponent
+}
+
+func (*uncachedSecretsComponent) GetUncachedTypes() []client.Object {
+	return []client.Object{&corev1.Secret{}}
+}
+
+var _ = Describe("ClientForComponent", func() {
+	var cached, uncached client.Client
+
+	BeforeEach(func() {
+		meta := metav1.ObjectMeta{Name: "testing", Namespace: "default"}
+		cached = fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(
+			&corev1.Secret{ObjectMeta: meta, Data: map[string][]byte{"source": []byte("cached")}},
+			&corev1.ConfigMap{ObjectMeta: meta, Data: map[string]string{"source": "cached"}},
+		).Build()
+		uncached = fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(
+			&corev1.Secret{ObjectMeta: meta, Data: map[string][]byte{"source": []byte("uncached")}},
+			&corev1.ConfigMap{ObjectMeta: meta, Data: map[string]string{"source": "uncached"}},
+		).Build()
+	})
+
+	It("returns the cached client for normal components", func() {
+		c, err := core.ClientForComponent(&plainComponent{}, cached, uncached, scheme.Scheme)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(c).To(BeIdenticalTo(cached))
+	})
+
+	It("reads declared types uncached", func() {
+		c, err := core.ClientForComponent(&uncachedSecretsComponent{}, cached, uncached, scheme.Scheme)
+		Expect(err).ToNot(HaveOccurred())
+		key := types.NamespacedName{Name: "testing", Namespace: "default"}
+
+		secret := &corev1.Secret{}
+		Expect(c.Get(context.Background(), key, secret)).To(Succeed())
+		Expect(secret.Data["source"]).To(Equal([]byte("uncached")))
+
+		secrets := &corev1.SecretList{}
+		Expect(c.List(context.Background(), secrets)).To(Succeed())
+		Expect(secrets.Items).To(HaveLen(1))
+		Expect(secrets.Items[0].Data["source"]).To(Equal([]byte("uncached")))
+
+		configMap := &corev1.ConfigMap{}
+		Expect(c.Get(context.Background(), key, configMap)).To(Succeed())
+		Expect(configMap.Data["source"]).To(Equal("cached"))
+	})
+})
@@ -0,0 +1,75 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// Readiness of one object in an apply wave.
+type waveObject struct {
+	wave  int
+	ready bool
+}
+
+// Record that an object in an apply wave was reconciled, and whether it's
+// ready. Later waves wait for all objects in earlier ones, see WaveBlockers.
+// The name only needs to be unique within the reconcile, like Kind/ns/name.
+func (c *Context) RecordWave(wave int, name string, ready bool) {
+	if c.waves == nil {
+		c.waves = map[string]waveObject{}
+	}
+	c.waves[name] = waveObject{wave: wave, ready: ready}
+}
+
+// Names of objects recorded in waves before this one which aren't ready yet,
+// sorted. Objects in an empty result are safe to apply. Only objects already
+// recorded in this reconcile are seen, so waves must be reconciled in order,
+// which the Reconciler checks for components implementing WaveComponent.
+func (c *Context) WaveBlockers(wave int) []string {
+	blockers := []string{}
+	for name, obj := range c.waves {
+		if obj.wave < wave && !obj.ready {
+			blockers = append(blockers, name)
+		}
+	}
+	sort.Strings(blockers)
+	return blockers
+}
+
+// Check that wave objects are reconciled in wave order, otherwise an object
+// in an early wave reconciled after a later one couldn't block it.
+func checkWaveOrder(components []*reconcilerComponent) error {
+	lastWave := 0
+	lastName := ""
+	for _, rc := range components {
+		waveComp, ok := rc.comp.(WaveComponent)
+		if !ok {
+			continue
+		}
+		for _, wave := range waveComp.GetWaves() {
+			if lastName != "" && wave < lastWave {
+				return errors.Errorf("component %s has an object in wave %d after wave %d from component %s, waves must be registered in order", rc.name, wave, lastWave, lastName)
+			}
+			lastWave = wave
+			lastName = rc.name
+		}
+	}
+	return nil
+}
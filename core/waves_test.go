@@ -0,0 +1,103 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/coderanger/controller-utils/core"
+)
+
+type fakeWaveComponent struct {
+	waves []int
+}
+
+func (comp *fakeWaveComponent) Reconcile(_ *core.Context) (core.Result, error) {
+	return core.Result{}, nil
+}
+
+func (comp *fakeWaveComponent) GetWaves() []int {
+	return comp.waves
+}
+
+type fakePlainComponent struct{}
+
+func (comp *fakePlainComponent) Reconcile(_ *core.Context) (core.Result, error) {
+	return core.Result{}, nil
+}
+
+var _ = Describe("apply waves", func() {
+	var ctx *core.Context
+
+	BeforeEach(func() {
+		ctx = &core.Context{}
+	})
+
+	It("has no blockers by default", func() {
+		Expect(ctx.WaveBlockers(5)).To(BeEmpty())
+	})
+
+	It("blocks on unready objects in earlier waves", func() {
+		ctx.RecordWave(0, "CustomResourceDefinition /foos", false)
+		ctx.RecordWave(0, "Namespace /app", true)
+		ctx.RecordWave(1, "Foo app/one", false)
+		Expect(ctx.WaveBlockers(0)).To(BeEmpty())
+		Expect(ctx.WaveBlockers(1)).To(Equal([]string{"CustomResourceDefinition /foos"}))
+		Expect(ctx.WaveBlockers(2)).To(Equal([]string{"CustomResourceDefinition /foos", "Foo app/one"}))
+	})
+
+	It("updates an object's readiness", func() {
+		ctx.RecordWave(0, "Namespace /app", false)
+		ctx.RecordWave(0, "Namespace /app", true)
+		Expect(ctx.WaveBlockers(1)).To(BeEmpty())
+	})
+})
+
+var _ = Describe("checkWaveOrder", func() {
+	It("allows waves in order", func() {
+		err := core.CheckWaveOrder(
+			core.NamedComponent{Name: "crds", Comp: &fakeWaveComponent{waves: []int{0, 0}}},
+			core.NamedComponent{Name: "plain", Comp: &fakePlainComponent{}},
+			core.NamedComponent{Name: "app", Comp: &fakeWaveComponent{waves: []int{1, 2}}},
+		)
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("allows negative waves first", func() {
+		err := core.CheckWaveOrder(
+			core.NamedComponent{Name: "early", Comp: &fakeWaveComponent{waves: []int{-1}}},
+			core.NamedComponent{Name: "app", Comp: &fakeWaveComponent{waves: []int{0}}},
+		)
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("rejects a component in an earlier wave registered later", func() {
+		err := core.CheckWaveOrder(
+			core.NamedComponent{Name: "app", Comp: &fakeWaveComponent{waves: []int{1}}},
+			core.NamedComponent{Name: "crds", Comp: &fakeWaveComponent{waves: []int{0}}},
+		)
+		Expect(err).To(MatchError(ContainSubstring("component crds has an object in wave 0 after wave 1 from component app")))
+	})
+
+	It("rejects waves out of order within a component", func() {
+		err := core.CheckWaveOrder(
+			core.NamedComponent{Name: "list", Comp: &fakeWaveComponent{waves: []int{2, 1}}},
+		)
+		Expect(err).To(HaveOccurred())
+	})
+})
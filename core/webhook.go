@@ -0,0 +1,167 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// Admission handler aggregating the DefaultingComponent and
+// ValidatingComponent implementations of a reconciler's components. Hooks
+// implemented by the API type itself run first.
+type componentWebhook struct {
+	r *Reconciler
+}
+
+var _ admission.CustomDefaulter = &componentWebhook{}
+var _ admission.CustomValidator = &componentWebhook{}
+
+func (r *Reconciler) hasWebhookComponents() (defaulting bool, validating bool) {
+	for _, rc := range r.components {
+		if _, ok := rc.comp.(DefaultingComponent); ok {
+			defaulting = true
+		}
+		if _, ok := rc.comp.(ValidatingComponent); ok {
+			validating = true
+		}
+	}
+	return
+}
+
+func (r *Reconciler) setupWebhook() error {
+	bldr := ctrl.NewWebhookManagedBy(r.mgr).For(r.apiType)
+	defaulting, validating := r.hasWebhookComponents()
+	wh := &componentWebhook{r: r}
+	if defaulting {
+		bldr = bldr.WithDefaulter(wh)
+	}
+	if validating {
+		bldr = bldr.WithValidator(wh)
+	}
+	return bldr.Complete()
+}
+
+func (wh *componentWebhook) newContext(ctx context.Context, obj runtime.Object) (*Context, error) {
+	clientObj, ok := obj.(client.Object)
+	if !ok {
+		return nil, errors.Errorf("unable to admit non-object %#v", obj)
+	}
+	r := wh.r
+	whCtx := &Context{
+		Context:         ctx,
+		Object:          clientObj,
+		Client:          r.client,
+		UncachedClient:  r.uncachedClient,
+		Templates:       r.templates,
+		TemplateFuncs:   r.templateFuncs,
+		TemplateHelpers: r.templateHelpers,
+		Capabilities:    r.getCapabilities(r.log),
+		Scheme:          r.mgr.GetScheme(),
+		Data:            ContextData{},
+		Clock:           r.clock,
+	}
+	if c := clockFrom(ctx); c != nil {
+		whCtx.Clock = c
+	}
+	return whCtx, nil
+}
+
+func (wh *componentWebhook) Default(ctx context.Context, obj runtime.Object) error {
+	if defaulter, ok := obj.(admission.Defaulter); ok {
+		defaulter.Default()
+	}
+	whCtx, err := wh.newContext(ctx, obj)
+	if err != nil {
+		return err
+	}
+	log := wh.r.log.WithName("components")
+	for _, rc := range wh.r.components {
+		comp, ok := rc.comp.(DefaultingComponent)
+		if !ok {
+			continue
+		}
+		whCtx.Log = log.WithName(rc.name)
+		whCtx.FieldManager = fmt.Sprintf("%s/%s", wh.r.name, rc.name)
+		err := comp.Default(whCtx)
+		if err != nil {
+			return errors.Wrapf(err, "error in %s component defaulting", rc.name)
+		}
+	}
+	return nil
+}
+
+// Run all validations, returning every failure so users can fix them in one go.
+func (wh *componentWebhook) validate(ctx context.Context, obj runtime.Object, oldObj client.Object) error {
+	whCtx, err := wh.newContext(ctx, obj)
+	if err != nil {
+		return err
+	}
+	errs := []error{}
+	log := wh.r.log.WithName("components")
+	for _, rc := range wh.r.components {
+		comp, ok := rc.comp.(ValidatingComponent)
+		if !ok {
+			continue
+		}
+		whCtx.Log = log.WithName(rc.name)
+		whCtx.FieldManager = fmt.Sprintf("%s/%s", wh.r.name, rc.name)
+		err := comp.Validate(whCtx, oldObj)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+func (wh *componentWebhook) ValidateCreate(ctx context.Context, obj runtime.Object) error {
+	if validator, ok := obj.(admission.Validator); ok {
+		err := validator.ValidateCreate()
+		if err != nil {
+			return err
+		}
+	}
+	return wh.validate(ctx, obj, nil)
+}
+
+func (wh *componentWebhook) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) error {
+	if validator, ok := newObj.(admission.Validator); ok {
+		err := validator.ValidateUpdate(oldObj)
+		if err != nil {
+			return err
+		}
+	}
+	oldClientObj, ok := oldObj.(client.Object)
+	if !ok {
+		return errors.Errorf("unable to admit non-object %#v", oldObj)
+	}
+	return wh.validate(ctx, newObj, oldClientObj)
+}
+
+func (wh *componentWebhook) ValidateDelete(ctx context.Context, obj runtime.Object) error {
+	if validator, ok := obj.(admission.Validator); ok {
+		return validator.ValidateDelete()
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package predicates
+
+import (
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+// Predicate that passes an event only if all the given predicates do. The
+// same as predicate.And, here so combinations read the same as Not.
+func And(preds ...predicate.Predicate) predicate.Predicate {
+	return predicate.And(preds...)
+}
+
+// Predicate that passes an event if any of the given predicates do. The same
+// as predicate.Or.
+func Or(preds ...predicate.Predicate) predicate.Predicate {
+	return predicate.Or(preds...)
+}
+
+// Predicate that inverts another predicate.
+type notPredicate struct {
+	pred predicate.Predicate
+}
+
+func Not(pred predicate.Predicate) *notPredicate {
+	return &notPredicate{pred: pred}
+}
+
+var _ predicate.Predicate = &notPredicate{}
+
+// Create returns true if the Create event should be processed
+func (p *notPredicate) Create(evt event.CreateEvent) bool {
+	return !p.pred.Create(evt)
+}
+
+// Delete returns true if the Delete event should be processed
+func (p *notPredicate) Delete(evt event.DeleteEvent) bool {
+	return !p.pred.Delete(evt)
+}
+
+// Update returns true if the Update event should be processed
+func (p *notPredicate) Update(evt event.UpdateEvent) bool {
+	return !p.pred.Update(evt)
+}
+
+// Generic returns true if the Generic event should be processed
+func (p *notPredicate) Generic(evt event.GenericEvent) bool {
+	return !p.pred.Generic(evt)
+}
@@ -0,0 +1,83 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package predicates_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	"github.com/coderanger/controller-utils/predicates"
+)
+
+// A predicate giving the same answer for every event type.
+func constPredicate(result bool) predicate.Predicate {
+	return predicate.NewPredicateFuncs(func(_ client.Object) bool { return result })
+}
+
+var _ = Describe("predicate combinators", func() {
+	obj := &corev1.ConfigMap{}
+	yes := constPredicate(true)
+	no := constPredicate(false)
+
+	// Run every event type through a predicate, all should agree.
+	expectAll := func(p predicate.Predicate, expected bool) {
+		ExpectWithOffset(1, p.Create(event.CreateEvent{Object: obj})).To(Equal(expected), "Create")
+		ExpectWithOffset(1, p.Update(event.UpdateEvent{ObjectOld: obj, ObjectNew: obj})).To(Equal(expected), "Update")
+		ExpectWithOffset(1, p.Delete(event.DeleteEvent{Object: obj})).To(Equal(expected), "Delete")
+		ExpectWithOffset(1, p.Generic(event.GenericEvent{Object: obj})).To(Equal(expected), "Generic")
+	}
+
+	It("passes And only if all predicates do", func() {
+		expectAll(predicates.And(yes, yes), true)
+		expectAll(predicates.And(yes, no), false)
+		expectAll(predicates.And(no, no), false)
+		expectAll(predicates.And(), true)
+	})
+
+	It("passes Or if any predicate does", func() {
+		expectAll(predicates.Or(no, yes), true)
+		expectAll(predicates.Or(no, no), false)
+		expectAll(predicates.Or(), false)
+	})
+
+	It("inverts with Not", func() {
+		expectAll(predicates.Not(yes), false)
+		expectAll(predicates.Not(no), true)
+	})
+
+	It("nests", func() {
+		expectAll(predicates.And(yes, predicates.Not(predicates.Or(no, no))), true)
+	})
+
+	It("checks each event type separately", func() {
+		onlyUpdates := predicate.Funcs{
+			UpdateFunc:  func(_ event.UpdateEvent) bool { return true },
+			CreateFunc:  func(_ event.CreateEvent) bool { return false },
+			DeleteFunc:  func(_ event.DeleteEvent) bool { return false },
+			GenericFunc: func(_ event.GenericEvent) bool { return false },
+		}
+		p := predicates.Not(onlyUpdates)
+		Expect(p.Create(event.CreateEvent{Object: obj})).To(BeTrue())
+		Expect(p.Update(event.UpdateEvent{ObjectOld: obj, ObjectNew: obj})).To(BeFalse())
+		Expect(p.Delete(event.DeleteEvent{Object: obj})).To(BeTrue())
+		Expect(p.Generic(event.GenericEvent{Object: obj})).To(BeTrue())
+	})
+})
@@ -17,18 +17,35 @@ limitations under the License.
 package predicates
 
 import (
+	"fmt"
 	"reflect"
+	"strings"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
 	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
 )
 
 // Predicate that uses DeepEquals to work around https://github.com/kubernetes/kubernetes/issues/95460.
-type deepEqualsPredicate struct{}
+type deepEqualsPredicate struct {
+	ignorePaths [][]string
+}
 
-func DeepEquals() *deepEqualsPredicate {
-	return &deepEqualsPredicate{}
+// Create a DeepEquals predicate. Extra fields to ignore can be given as simple
+// JSONPaths like `.status` or `.metadata.annotations['example.com/revision']`.
+// Panics if a path is invalid, since they are set at startup.
+func DeepEquals(ignorePaths ...string) *deepEqualsPredicate {
+	p := &deepEqualsPredicate{}
+	for _, path := range ignorePaths {
+		fields, err := parseFieldPath(path)
+		if err != nil {
+			panic(err)
+		}
+		p.ignorePaths = append(p.ignorePaths, fields)
+	}
+	return p
 }
 
 var _ predicate.Predicate = &deepEqualsPredicate{}
@@ -44,7 +61,7 @@ func (_ *deepEqualsPredicate) Delete(_ event.DeleteEvent) bool {
 }
 
 // Update returns true if the Update event should be processed
-func (_ *deepEqualsPredicate) Update(evt event.UpdateEvent) bool {
+func (p *deepEqualsPredicate) Update(evt event.UpdateEvent) bool {
 	cleanOld := evt.ObjectOld.DeepCopyObject().(metav1.Object)
 	cleanNew := evt.ObjectNew.DeepCopyObject().(metav1.Object)
 	cleanOld.SetGeneration(0)
@@ -53,10 +70,66 @@ func (_ *deepEqualsPredicate) Update(evt event.UpdateEvent) bool {
 	cleanNew.SetResourceVersion("")
 	cleanOld.SetManagedFields([]metav1.ManagedFieldsEntry{})
 	cleanNew.SetManagedFields([]metav1.ManagedFieldsEntry{})
-	return !reflect.DeepEqual(cleanNew, cleanOld)
+	if len(p.ignorePaths) == 0 {
+		return !reflect.DeepEqual(cleanNew, cleanOld)
+	}
+
+	// Go through unstructured so we can remove arbitrary fields.
+	oldData, err := runtime.DefaultUnstructuredConverter.ToUnstructured(cleanOld)
+	if err != nil {
+		return true
+	}
+	newData, err := runtime.DefaultUnstructuredConverter.ToUnstructured(cleanNew)
+	if err != nil {
+		return true
+	}
+	for _, fields := range p.ignorePaths {
+		unstructured.RemoveNestedField(oldData, fields...)
+		unstructured.RemoveNestedField(newData, fields...)
+	}
+	return !reflect.DeepEqual(newData, oldData)
 }
 
 // Generic returns true if the Generic event should be processed
 func (_ *deepEqualsPredicate) Generic(_ event.GenericEvent) bool {
 	return true
 }
+
+// Split a JSONPath made of plain field names and quoted subscripts into the
+// fields for unstructured.RemoveNestedField.
+func parseFieldPath(path string) ([]string, error) {
+	rest := strings.TrimSuffix(strings.TrimPrefix(path, "{"), "}")
+	if rest != "" && rest[0] != '.' && rest[0] != '[' {
+		rest = "." + rest
+	}
+	fields := []string{}
+	for rest != "" {
+		switch {
+		case strings.HasPrefix(rest, "['") || strings.HasPrefix(rest, "[\""):
+			quote := rest[1:2]
+			end := strings.Index(rest[2:], quote+"]")
+			if end == -1 {
+				return nil, fmt.Errorf("unterminated subscript in path %s", path)
+			}
+			fields = append(fields, rest[2:2+end])
+			rest = rest[2+end+2:]
+		case strings.HasPrefix(rest, "."):
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end == -1 {
+				end = len(rest)
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("empty field name in path %s", path)
+			}
+			fields = append(fields, rest[:end])
+			rest = rest[end:]
+		default:
+			return nil, fmt.Errorf("unsupported syntax in path %s", path)
+		}
+	}
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("empty path %s", path)
+	}
+	return fields, nil
+}
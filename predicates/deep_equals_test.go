@@ -0,0 +1,84 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package predicates
+
+import (
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+var _ = ginkgo.Describe("parseFieldPath", func() {
+	valid := []struct {
+		name     string
+		path     string
+		expected []string
+	}{
+		{"a single field", ".status", []string{"status"}},
+		{"no leading dot", "status.phase", []string{"status", "phase"}},
+		{"braces", "{.status.phase}", []string{"status", "phase"}},
+		{"a single quoted subscript", ".metadata.annotations['example.com/revision']", []string{"metadata", "annotations", "example.com/revision"}},
+		{"a double quoted subscript", `.metadata.labels["app.kubernetes.io/name"]`, []string{"metadata", "labels", "app.kubernetes.io/name"}},
+		{"a leading subscript", "['status'].phase", []string{"status", "phase"}},
+	}
+	for _, tc := range valid {
+		tc := tc
+		ginkgo.It("parses "+tc.name, func() {
+			fields, err := parseFieldPath(tc.path)
+			gomega.Expect(err).ToNot(gomega.HaveOccurred())
+			gomega.Expect(fields).To(gomega.Equal(tc.expected))
+		})
+	}
+
+	malformed := []struct {
+		name    string
+		path    string
+		message string
+	}{
+		{"an empty path", "", "empty path"},
+		{"empty braces", "{}", "empty path"},
+		{"only a dot", ".", "empty field name"},
+		{"a double dot", ".metadata..name", "empty field name"},
+		{"a trailing dot", ".metadata.", "empty field name"},
+		{"an unterminated subscript", ".metadata.annotations['example.com", "unterminated subscript"},
+		{"mismatched quotes", `.metadata.annotations['example.com"]`, "unterminated subscript"},
+		{"an index", ".spec.containers[0]", "unsupported syntax"},
+		{"an unquoted subscript", ".metadata.labels[app]", "unsupported syntax"},
+		{"text after a subscript", ".metadata.labels['app']name", "unsupported syntax"},
+	}
+	for _, tc := range malformed {
+		tc := tc
+		ginkgo.It("rejects "+tc.name, func() {
+			_, err := parseFieldPath(tc.path)
+			gomega.Expect(err).To(gomega.MatchError(gomega.ContainSubstring(tc.message)))
+		})
+	}
+
+	ginkgo.It("panics on a malformed path in DeepEquals", func() {
+		gomega.Expect(func() { DeepEquals(".spec.containers[0]") }).To(gomega.Panic())
+	})
+
+	ginkgo.It("ignores the parsed fields", func() {
+		oldObj := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{"example.com/revision": "1"}}}
+		newObj := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{"example.com/revision": "2"}}}
+		evt := event.UpdateEvent{ObjectOld: oldObj, ObjectNew: newObj}
+		gomega.Expect(DeepEquals().Update(evt)).To(gomega.BeTrue())
+		gomega.Expect(DeepEquals(".metadata.annotations['example.com/revision']").Update(evt)).To(gomega.BeFalse())
+	})
+})
@@ -0,0 +1,78 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package predicates
+
+import (
+	"reflect"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+// Predicate that drops updates which only touch the status, for owned
+// objects whose own controllers write status heartbeats.
+type ignoreStatusPredicate struct{}
+
+func IgnoreStatusChanges() *ignoreStatusPredicate {
+	return &ignoreStatusPredicate{}
+}
+
+var _ predicate.Predicate = &ignoreStatusPredicate{}
+
+// Create returns true if the Create event should be processed
+func (_ *ignoreStatusPredicate) Create(_ event.CreateEvent) bool {
+	return true
+}
+
+// Delete returns true if the Delete event should be processed
+func (_ *ignoreStatusPredicate) Delete(_ event.DeleteEvent) bool {
+	return true
+}
+
+// Update returns true if the Update event should be processed
+func (p *ignoreStatusPredicate) Update(evt event.UpdateEvent) bool {
+	cleanOld, ok := p.withoutStatus(evt.ObjectOld)
+	if !ok {
+		return true
+	}
+	cleanNew, ok := p.withoutStatus(evt.ObjectNew)
+	if !ok {
+		return true
+	}
+	return !reflect.DeepEqual(cleanNew, cleanOld)
+}
+
+// Generic returns true if the Generic event should be processed
+func (_ *ignoreStatusPredicate) Generic(_ event.GenericEvent) bool {
+	return true
+}
+
+func (_ *ignoreStatusPredicate) withoutStatus(obj runtime.Object) (map[string]interface{}, bool) {
+	if obj == nil {
+		return nil, false
+	}
+	data, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return nil, false
+	}
+	delete(data, "status")
+	unstructured.RemoveNestedField(data, "metadata", "resourceVersion")
+	unstructured.RemoveNestedField(data, "metadata", "managedFields")
+	return data, true
+}
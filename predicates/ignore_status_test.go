@@ -0,0 +1,90 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package predicates_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+
+	"github.com/coderanger/controller-utils/predicates"
+)
+
+var _ = Describe("IgnoreStatusChanges predicate", func() {
+	var oldObj, newObj *appsv1.Deployment
+
+	BeforeEach(func() {
+		oldObj = &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            "testing",
+				ResourceVersion: "1",
+				Labels:          map[string]string{"app": "testing"},
+				Annotations:     map[string]string{"example.com/revision": "1"},
+			},
+			Spec:   appsv1.DeploymentSpec{Replicas: pointer.Int32(1)},
+			Status: appsv1.DeploymentStatus{ReadyReplicas: 0},
+		}
+		newObj = oldObj.DeepCopy()
+	})
+
+	update := func() bool {
+		return predicates.IgnoreStatusChanges().Update(event.UpdateEvent{ObjectOld: oldObj, ObjectNew: newObj})
+	}
+
+	It("drops a status-only change", func() {
+		newObj.Status.ReadyReplicas = 1
+		newObj.Status.Conditions = []appsv1.DeploymentCondition{{Type: appsv1.DeploymentAvailable, Status: "True"}}
+		Expect(update()).To(BeFalse())
+	})
+
+	It("drops a resourceVersion and managedFields change", func() {
+		newObj.ResourceVersion = "2"
+		newObj.ManagedFields = []metav1.ManagedFieldsEntry{{Manager: "other", Operation: metav1.ManagedFieldsOperationUpdate}}
+		Expect(update()).To(BeFalse())
+	})
+
+	It("passes a spec change", func() {
+		newObj.Spec.Replicas = pointer.Int32(2)
+		Expect(update()).To(BeTrue())
+	})
+
+	It("passes a spec change along with a status change", func() {
+		newObj.Spec.Replicas = pointer.Int32(2)
+		newObj.Status.ReadyReplicas = 1
+		Expect(update()).To(BeTrue())
+	})
+
+	It("passes a labels change", func() {
+		newObj.Labels["app"] = "other"
+		Expect(update()).To(BeTrue())
+	})
+
+	It("passes an annotations change", func() {
+		newObj.Annotations["example.com/revision"] = "2"
+		Expect(update()).To(BeTrue())
+	})
+
+	It("passes create, delete, and generic events", func() {
+		pred := predicates.IgnoreStatusChanges()
+		Expect(pred.Create(event.CreateEvent{Object: newObj})).To(BeTrue())
+		Expect(pred.Delete(event.DeleteEvent{Object: newObj})).To(BeTrue())
+		Expect(pred.Generic(event.GenericEvent{Object: newObj})).To(BeTrue())
+	})
+})
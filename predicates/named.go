@@ -0,0 +1,80 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package predicates
+
+import (
+	"github.com/go-logr/logr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+// Predicate that wraps another and logs each decision at V(1), for working
+// out why a controller did or didn't reconcile.
+type namedPredicate struct {
+	name string
+	pred predicate.Predicate
+	log  logr.Logger
+}
+
+func Named(name string, pred predicate.Predicate) *namedPredicate {
+	return &namedPredicate{
+		name: name,
+		pred: pred,
+		log:  logf.Log.WithName("predicates").WithName(name),
+	}
+}
+
+var _ predicate.Predicate = &namedPredicate{}
+
+// Create returns true if the Create event should be processed
+func (p *namedPredicate) Create(evt event.CreateEvent) bool {
+	return p.logDecision("Create", evt.Object, p.pred.Create(evt))
+}
+
+// Delete returns true if the Delete event should be processed
+func (p *namedPredicate) Delete(evt event.DeleteEvent) bool {
+	return p.logDecision("Delete", evt.Object, p.pred.Delete(evt))
+}
+
+// Update returns true if the Update event should be processed
+func (p *namedPredicate) Update(evt event.UpdateEvent) bool {
+	return p.logDecision("Update", evt.ObjectNew, p.pred.Update(evt))
+}
+
+// Generic returns true if the Generic event should be processed
+func (p *namedPredicate) Generic(evt event.GenericEvent) bool {
+	return p.logDecision("Generic", evt.Object, p.pred.Generic(evt))
+}
+
+func (p *namedPredicate) logDecision(eventType string, obj client.Object, allowed bool) bool {
+	log := p.log.V(1)
+	if !log.Enabled() {
+		return allowed
+	}
+	decision := "allow"
+	if !allowed {
+		decision = "deny"
+	}
+	if obj == nil {
+		log.Info("Predicate decision", "predicate", p.name, "event", eventType, "decision", decision)
+	} else {
+		log.Info("Predicate decision", "predicate", p.name, "event", eventType, "decision", decision, "kind", objectKind(obj), "object", client.ObjectKeyFromObject(obj))
+	}
+	return allowed
+}
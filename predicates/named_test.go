@@ -0,0 +1,79 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package predicates
+
+import (
+	"github.com/go-logr/logr/funcr"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+var _ = ginkgo.Describe("Named", func() {
+	obj := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "testing", Namespace: "default"}}
+
+	// A predicate that only allows one event type.
+	only := func(eventType string) predicate.Predicate {
+		return predicate.Funcs{
+			CreateFunc:  func(_ event.CreateEvent) bool { return eventType == "Create" },
+			DeleteFunc:  func(_ event.DeleteEvent) bool { return eventType == "Delete" },
+			UpdateFunc:  func(_ event.UpdateEvent) bool { return eventType == "Update" },
+			GenericFunc: func(_ event.GenericEvent) bool { return eventType == "Generic" },
+		}
+	}
+
+	decisions := func(pred predicate.Predicate) map[string]bool {
+		return map[string]bool{
+			"Create":  pred.Create(event.CreateEvent{Object: obj}),
+			"Delete":  pred.Delete(event.DeleteEvent{Object: obj}),
+			"Update":  pred.Update(event.UpdateEvent{ObjectOld: obj, ObjectNew: obj}),
+			"Generic": pred.Generic(event.GenericEvent{Object: obj}),
+		}
+	}
+
+	for _, eventType := range []string{"Create", "Delete", "Update", "Generic"} {
+		eventType := eventType
+		ginkgo.It("passes through the decision for "+eventType, func() {
+			gomega.Expect(decisions(Named("test", only(eventType)))).To(gomega.Equal(map[string]bool{
+				"Create":  eventType == "Create",
+				"Delete":  eventType == "Delete",
+				"Update":  eventType == "Update",
+				"Generic": eventType == "Generic",
+			}))
+		})
+	}
+
+	ginkgo.It("passes through the decision with logging enabled", func() {
+		lines := []string{}
+		pred := Named("test", only("Update"))
+		pred.log = funcr.New(func(_, args string) { lines = append(lines, args) }, funcr.Options{Verbosity: 1})
+		gomega.Expect(decisions(pred)).To(gomega.Equal(map[string]bool{"Create": false, "Delete": false, "Update": true, "Generic": false}))
+		gomega.Expect(lines).To(gomega.HaveLen(4))
+		gomega.Expect(lines).To(gomega.ContainElement(gomega.And(
+			gomega.ContainSubstring(`"event"="Update"`),
+			gomega.ContainSubstring(`"decision"="allow"`),
+			gomega.ContainSubstring(`"kind"="ConfigMap"`),
+		)))
+		gomega.Expect(lines).To(gomega.ContainElement(gomega.And(
+			gomega.ContainSubstring(`"event"="Create"`),
+			gomega.ContainSubstring(`"decision"="deny"`),
+		)))
+	})
+})
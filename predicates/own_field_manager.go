@@ -0,0 +1,113 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package predicates
+
+import (
+	"bytes"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+// Predicate that drops updates made only by the given field managers, usually
+// ctx.FieldManager, so a controller doesn't keep triggering itself when the
+// API server normalizes what it applied. If the managedFields don't show who
+// made the change, the update is passed through.
+type ownFieldManagerPredicate struct {
+	managers map[string]bool
+}
+
+func OwnFieldManager(managers ...string) *ownFieldManagerPredicate {
+	p := &ownFieldManagerPredicate{managers: map[string]bool{}}
+	for _, manager := range managers {
+		p.managers[manager] = true
+	}
+	return p
+}
+
+var _ predicate.Predicate = &ownFieldManagerPredicate{}
+
+// Create returns true if the Create event should be processed
+func (_ *ownFieldManagerPredicate) Create(_ event.CreateEvent) bool {
+	return true
+}
+
+// Delete returns true if the Delete event should be processed
+func (_ *ownFieldManagerPredicate) Delete(_ event.DeleteEvent) bool {
+	return true
+}
+
+// Update returns true if the Update event should be processed
+func (p *ownFieldManagerPredicate) Update(evt event.UpdateEvent) bool {
+	if evt.ObjectOld == nil || evt.ObjectNew == nil {
+		return true
+	}
+	changed := changedManagers(evt.ObjectOld.GetManagedFields(), evt.ObjectNew.GetManagedFields())
+	if len(changed) == 0 {
+		return true
+	}
+	for _, manager := range changed {
+		if !p.managers[manager] {
+			return true
+		}
+	}
+	return false
+}
+
+// Generic returns true if the Generic event should be processed
+func (_ *ownFieldManagerPredicate) Generic(_ event.GenericEvent) bool {
+	return true
+}
+
+type managedFieldsKey struct {
+	manager     string
+	operation   metav1.ManagedFieldsOperationType
+	subresource string
+}
+
+// Find the managers whose entries were added, removed, or modified.
+func changedManagers(oldFields, newFields []metav1.ManagedFieldsEntry) []string {
+	oldEntries := map[managedFieldsKey]metav1.ManagedFieldsEntry{}
+	for _, entry := range oldFields {
+		oldEntries[managedFieldsKey{entry.Manager, entry.Operation, entry.Subresource}] = entry
+	}
+	changed := []string{}
+	for _, entry := range newFields {
+		key := managedFieldsKey{entry.Manager, entry.Operation, entry.Subresource}
+		oldEntry, ok := oldEntries[key]
+		delete(oldEntries, key)
+		if ok && managedFieldsEntryEqual(oldEntry, entry) {
+			continue
+		}
+		changed = append(changed, entry.Manager)
+	}
+	for key := range oldEntries {
+		changed = append(changed, key.manager)
+	}
+	return changed
+}
+
+func managedFieldsEntryEqual(a, b metav1.ManagedFieldsEntry) bool {
+	if a.APIVersion != b.APIVersion || !a.Time.Equal(b.Time) {
+		return false
+	}
+	if a.FieldsV1 == nil || b.FieldsV1 == nil {
+		return a.FieldsV1 == b.FieldsV1
+	}
+	return bytes.Equal(a.FieldsV1.Raw, b.FieldsV1.Raw)
+}
@@ -0,0 +1,100 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package predicates_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+
+	"github.com/coderanger/controller-utils/predicates"
+)
+
+var _ = Describe("OwnFieldManager predicate", func() {
+	now := metav1.NewTime(time.Now())
+	later := metav1.NewTime(now.Add(time.Minute))
+
+	entry := func(manager string, at metav1.Time, fields string) metav1.ManagedFieldsEntry {
+		return metav1.ManagedFieldsEntry{
+			Manager:    manager,
+			Operation:  metav1.ManagedFieldsOperationApply,
+			APIVersion: "v1",
+			Time:       &at,
+			FieldsType: "FieldsV1",
+			FieldsV1:   &metav1.FieldsV1{Raw: []byte(fields)},
+		}
+	}
+
+	update := func(oldFields, newFields []metav1.ManagedFieldsEntry) bool {
+		oldObj := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "testing", ManagedFields: oldFields}}
+		newObj := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "testing", ManagedFields: newFields}}
+		return predicates.OwnFieldManager("mine").Update(event.UpdateEvent{ObjectOld: oldObj, ObjectNew: newObj})
+	}
+
+	It("drops updates only from our own manager", func() {
+		Expect(update(
+			[]metav1.ManagedFieldsEntry{entry("mine", now, `{"f:data":{}}`), entry("other", now, `{}`)},
+			[]metav1.ManagedFieldsEntry{entry("mine", later, `{"f:data":{}}`), entry("other", now, `{}`)},
+		)).To(BeFalse())
+	})
+
+	It("passes updates from another manager", func() {
+		Expect(update(
+			[]metav1.ManagedFieldsEntry{entry("mine", now, `{}`), entry("other", now, `{}`)},
+			[]metav1.ManagedFieldsEntry{entry("mine", now, `{}`), entry("other", later, `{}`)},
+		)).To(BeTrue())
+	})
+
+	It("passes updates from both managers", func() {
+		Expect(update(
+			[]metav1.ManagedFieldsEntry{entry("mine", now, `{}`)},
+			[]metav1.ManagedFieldsEntry{entry("mine", later, `{}`), entry("other", later, `{}`)},
+		)).To(BeTrue())
+	})
+
+	It("notices changed fields without a new timestamp", func() {
+		Expect(update(
+			[]metav1.ManagedFieldsEntry{entry("other", now, `{}`)},
+			[]metav1.ManagedFieldsEntry{entry("other", now, `{"f:data":{}}`)},
+		)).To(BeTrue())
+	})
+
+	It("notices a removed manager", func() {
+		Expect(update(
+			[]metav1.ManagedFieldsEntry{entry("mine", now, `{}`), entry("other", now, `{}`)},
+			[]metav1.ManagedFieldsEntry{entry("mine", now, `{}`)},
+		)).To(BeTrue())
+	})
+
+	It("passes updates when managedFields don't change", func() {
+		fields := []metav1.ManagedFieldsEntry{entry("mine", now, `{}`)}
+		Expect(update(fields, fields)).To(BeTrue())
+		Expect(update(nil, nil)).To(BeTrue())
+	})
+
+	It("passes other events", func() {
+		p := predicates.OwnFieldManager("mine")
+		obj := &corev1.ConfigMap{}
+		Expect(p.Create(event.CreateEvent{Object: obj})).To(BeTrue())
+		Expect(p.Delete(event.DeleteEvent{Object: obj})).To(BeTrue())
+		Expect(p.Generic(event.GenericEvent{Object: obj})).To(BeTrue())
+	})
+})
@@ -19,7 +19,10 @@ package predicates
 import (
 	"bytes"
 	"encoding/base64"
+	"regexp"
+	"strings"
 
+	"github.com/pkg/errors"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -28,11 +31,46 @@ import (
 )
 
 type secretFieldPredicate struct {
-	keys []string
+	keys     []string
+	patterns []*regexp.Regexp
 }
 
-func SecretField(keys []string) *secretFieldPredicate {
-	return &secretFieldPredicate{keys: keys}
+// Create a SecretField predicate, which only passes updates when one of the
+// given keys changes. Keys can be exact names, globs like `tls.*`, or regular
+// expressions wrapped in slashes like `/^db-.+$/`. Returns an error if a
+// pattern doesn't compile.
+func SecretField(keys []string) (*secretFieldPredicate, error) {
+	p := &secretFieldPredicate{}
+	for _, key := range keys {
+		pattern, err := keyPattern(key)
+		if err != nil {
+			return nil, err
+		}
+		if pattern != nil {
+			p.patterns = append(p.patterns, pattern)
+		} else {
+			p.keys = append(p.keys, key)
+		}
+	}
+	return p, nil
+}
+
+// Compile a key into a pattern, or return nil if it is an exact name.
+func keyPattern(key string) (*regexp.Regexp, error) {
+	var expr string
+	if len(key) > 2 && strings.HasPrefix(key, "/") && strings.HasSuffix(key, "/") {
+		expr = key[1 : len(key)-1]
+	} else if strings.ContainsAny(key, "*?[") {
+		expr = "^" + strings.ReplaceAll(strings.ReplaceAll(regexp.QuoteMeta(key), `\*`, ".*"), `\?`, ".") + "$"
+		expr = strings.ReplaceAll(strings.ReplaceAll(expr, `\[`, "["), `\]`, "]")
+	} else {
+		return nil, nil
+	}
+	pattern, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error compiling secret key pattern %s", key)
+	}
+	return pattern, nil
 }
 
 var _ predicate.Predicate = &secretFieldPredicate{}
@@ -57,7 +95,7 @@ func (p *secretFieldPredicate) Update(evt event.UpdateEvent) bool {
 	if !ok {
 		return true
 	}
-	for _, key := range p.keys {
+	for _, key := range p.matchingKeys(oldData, newData) {
 		oldVal, oldOk := oldData[key]
 		newVal, newOk := newData[key]
 		if oldOk != newOk || !bytes.Equal(oldVal, newVal) {
@@ -72,29 +110,70 @@ func (_ *secretFieldPredicate) Generic(_ event.GenericEvent) bool {
 	return true
 }
 
+func (p *secretFieldPredicate) matchingKeys(datas ...map[string][]byte) []string {
+	keys := append([]string{}, p.keys...)
+	if len(p.patterns) == 0 {
+		return keys
+	}
+	for _, data := range datas {
+		for key := range data {
+			for _, pattern := range p.patterns {
+				if pattern.MatchString(key) {
+					keys = append(keys, key)
+					break
+				}
+			}
+		}
+	}
+	return keys
+}
+
+// Get the effective data of a Secret, with stringData layered on top like
+// the API server would. Returns false if the data can't be read.
 func (_ *secretFieldPredicate) secretData(obj runtime.Object) (map[string][]byte, bool) {
 	secret, ok := obj.(*corev1.Secret)
 	if ok {
-		return secret.Data, true
+		cleanData := map[string][]byte{}
+		for k, v := range secret.Data {
+			cleanData[k] = v
+		}
+		for k, v := range secret.StringData {
+			cleanData[k] = []byte(v)
+		}
+		return cleanData, true
 	}
 	unstructured, ok := obj.(*unstructured.Unstructured)
 	if ok {
 		gvk := obj.GetObjectKind().GroupVersionKind()
 		if gvk.Group == "" && gvk.Kind == "Secret" {
-			data, ok := unstructured.UnstructuredContent()["data"]
-			if ok {
+			content := unstructured.UnstructuredContent()
+			cleanData := map[string][]byte{}
+			if data, ok := content["data"].(map[string]interface{}); ok {
 				// Because unstructured skips the base64 decode, we have to do that now.
-				cleanData := map[string][]byte{}
-				for k, v := range data.(map[string]interface{}) {
-					cleanV, err := base64.StdEncoding.DecodeString(v.(string))
+				for k, v := range data {
+					str, ok := v.(string)
+					if !ok {
+						return nil, false
+					}
+					cleanV, err := base64.StdEncoding.DecodeString(str)
 					if err != nil {
-						// kube-apiserver sent us corrupted data, fuck it.
-						panic(err)
+						return nil, false
 					}
 					cleanData[k] = cleanV
 				}
-				return cleanData, true
+			} else if content["data"] != nil {
+				return nil, false
+			}
+			if stringData, ok := content["stringData"].(map[string]interface{}); ok {
+				for k, v := range stringData {
+					str, ok := v.(string)
+					if !ok {
+						return nil, false
+					}
+					cleanData[k] = []byte(str)
+				}
 			}
+			return cleanData, true
 		}
 	}
 	return nil, false
@@ -0,0 +1,138 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package predicates
+
+import (
+	"encoding/base64"
+
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+var _ = ginkgo.Describe("SecretField", func() {
+	secret := func(data map[string]string) *corev1.Secret {
+		s := &corev1.Secret{Data: map[string][]byte{}}
+		for k, v := range data {
+			s.Data[k] = []byte(v)
+		}
+		return s
+	}
+
+	unstructuredSecret := func(data interface{}) *unstructured.Unstructured {
+		u := &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "Secret",
+		}}
+		if data != nil {
+			u.Object["data"] = data
+		}
+		return u
+	}
+
+	update := func(keys []string, oldObj, newObj client.Object) bool {
+		pred, err := SecretField(keys)
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+		return pred.Update(event.UpdateEvent{ObjectOld: oldObj, ObjectNew: newObj})
+	}
+
+	ginkgo.It("passes changes to watched keys", func() {
+		gomega.Expect(update([]string{"password"}, secret(map[string]string{"password": "a"}), secret(map[string]string{"password": "b"}))).To(gomega.BeTrue())
+		gomega.Expect(update([]string{"password"}, secret(map[string]string{}), secret(map[string]string{"password": "b"}))).To(gomega.BeTrue())
+		gomega.Expect(update([]string{"password"}, secret(map[string]string{"password": "a"}), secret(map[string]string{}))).To(gomega.BeTrue())
+	})
+
+	ginkgo.It("ignores changes to other keys", func() {
+		gomega.Expect(update([]string{"password"}, secret(map[string]string{"password": "a", "other": "1"}), secret(map[string]string{"password": "a", "other": "2"}))).To(gomega.BeFalse())
+	})
+
+	ginkgo.It("layers stringData over data", func() {
+		oldObj := secret(map[string]string{"password": "a"})
+		newObj := secret(map[string]string{"password": "a"})
+		newObj.StringData = map[string]string{"password": "b"}
+		gomega.Expect(update([]string{"password"}, oldObj, newObj)).To(gomega.BeTrue())
+
+		newObj.StringData = map[string]string{"password": "a"}
+		gomega.Expect(update([]string{"password"}, oldObj, newObj)).To(gomega.BeFalse())
+	})
+
+	ginkgo.It("layers stringData over data in unstructured secrets", func() {
+		oldObj := unstructuredSecret(map[string]interface{}{"password": base64.StdEncoding.EncodeToString([]byte("a"))})
+		newObj := unstructuredSecret(map[string]interface{}{"password": base64.StdEncoding.EncodeToString([]byte("a"))})
+		gomega.Expect(update([]string{"password"}, oldObj, newObj)).To(gomega.BeFalse())
+
+		newObj.Object["stringData"] = map[string]interface{}{"password": "b"}
+		gomega.Expect(update([]string{"password"}, oldObj, newObj)).To(gomega.BeTrue())
+	})
+
+	undecodable := []struct {
+		name string
+		data interface{}
+	}{
+		{"invalid base64", map[string]interface{}{"password": "not base64!"}},
+		{"a non-string value", map[string]interface{}{"password": int64(1)}},
+		{"a non-map data", "password"},
+	}
+	for _, tc := range undecodable {
+		tc := tc
+		ginkgo.It("passes unstructured secrets with "+tc.name, func() {
+			oldObj := unstructuredSecret(map[string]interface{}{"password": base64.StdEncoding.EncodeToString([]byte("a"))})
+			newObj := unstructuredSecret(tc.data)
+			gomega.Expect(func() { update([]string{"password"}, oldObj, newObj) }).ToNot(gomega.Panic())
+			gomega.Expect(update([]string{"password"}, oldObj, newObj)).To(gomega.BeTrue())
+			gomega.Expect(update([]string{"password"}, newObj, oldObj)).To(gomega.BeTrue())
+		})
+	}
+
+	ginkgo.It("passes updates to objects that aren't secrets", func() {
+		gomega.Expect(update([]string{"password"}, &corev1.ConfigMap{}, &corev1.ConfigMap{})).To(gomega.BeTrue())
+	})
+
+	patterns := []struct {
+		name    string
+		key     string
+		matches []string
+		skips   []string
+	}{
+		{"an exact name", "tls.crt", []string{"tls.crt"}, []string{"tls.key", "xtls.crt"}},
+		{"a star glob", "tls.*", []string{"tls.crt", "tls.key"}, []string{"ca.crt", "xtls.crt"}},
+		{"a question mark glob", "db-?", []string{"db-1", "db-a"}, []string{"db-10", "db-"}},
+		{"a bracket glob", "db-[ab]", []string{"db-a", "db-b"}, []string{"db-c"}},
+		{"a regex", "/^db-[0-9]+$/", []string{"db-1", "db-10"}, []string{"db-a", "xdb-1"}},
+	}
+	for _, tc := range patterns {
+		tc := tc
+		ginkgo.It("matches "+tc.name, func() {
+			for _, key := range tc.matches {
+				gomega.Expect(update([]string{tc.key}, secret(map[string]string{key: "a"}), secret(map[string]string{key: "b"}))).To(gomega.BeTrue(), key)
+			}
+			for _, key := range tc.skips {
+				gomega.Expect(update([]string{tc.key}, secret(map[string]string{key: "a"}), secret(map[string]string{key: "b"}))).To(gomega.BeFalse(), key)
+			}
+		})
+	}
+
+	ginkgo.It("rejects a pattern that doesn't compile", func() {
+		_, err := SecretField([]string{"/db-(/"})
+		gomega.Expect(err).To(gomega.MatchError(gomega.ContainSubstring("/db-(/")))
+		_, err = SecretField([]string{"db-[a"})
+		gomega.Expect(err).To(gomega.HaveOccurred())
+	})
+})
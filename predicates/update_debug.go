@@ -18,49 +18,183 @@ package predicates
 
 import (
 	"fmt"
+	"net/http"
 	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/event"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
 )
 
 var updateDebugLog = logf.Log.WithName("predicates").WithName("UpdateDebug")
 
-type updateDebugPredicate struct{}
+var updateDebugEvents = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "controller_utils_update_debug_events_total",
+	Help: "Update events seen by UpdateDebug predicates, by kind and whether they were passed or suppressed.",
+}, []string{"kind", "result"})
 
-func UpdateDebug() *updateDebugPredicate {
-	return &updateDebugPredicate{}
+// Diff logging is off unless DEBUG_UPDATE=true at startup, and can be
+// toggled at runtime with SetUpdateDebug or UpdateDebugHandler.
+var updateDebugEnabled atomic.Bool
+var updateDebugKindsLock sync.RWMutex
+var updateDebugKinds map[string]bool
+
+func init() {
+	metrics.Registry.MustRegister(updateDebugEvents)
+	updateDebugEnabled.Store(os.Getenv("DEBUG_UPDATE") == "true")
+}
+
+// Turn update diff logging on or off. If any kinds are given, only updates
+// to objects of those kinds (e.g. `Deployment` or `Deployment.apps`) are logged.
+func SetUpdateDebug(enabled bool, kinds ...string) {
+	updateDebugKindsLock.Lock()
+	defer updateDebugKindsLock.Unlock()
+	updateDebugKinds = nil
+	if len(kinds) != 0 {
+		updateDebugKinds = map[string]bool{}
+		for _, kind := range kinds {
+			updateDebugKinds[kind] = true
+		}
+	}
+	updateDebugEnabled.Store(enabled)
+}
+
+// Check if update diff logging is enabled for a kind.
+func UpdateDebugEnabled(kind string) bool {
+	if !updateDebugEnabled.Load() {
+		return false
+	}
+	updateDebugKindsLock.RLock()
+	defer updateDebugKindsLock.RUnlock()
+	if updateDebugKinds == nil {
+		return true
+	}
+	return updateDebugKinds[kind] || updateDebugKinds[strings.SplitN(kind, ".", 2)[0]]
+}
+
+// An HTTP handler to toggle update diff logging, usually mounted with
+// mgr.AddMetricsExtraHandler. GET shows the current state, POST sets it from
+// the `enabled` and `kinds` (comma separated) query parameters.
+func UpdateDebugHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			enabled, err := strconv.ParseBool(r.URL.Query().Get("enabled"))
+			if err != nil {
+				http.Error(w, "enabled must be true or false", http.StatusBadRequest)
+				return
+			}
+			kinds := []string{}
+			if val := r.URL.Query().Get("kinds"); val != "" {
+				kinds = strings.Split(val, ",")
+			}
+			SetUpdateDebug(enabled, kinds...)
+		} else if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		updateDebugKindsLock.RLock()
+		kinds := []string{}
+		for kind := range updateDebugKinds {
+			kinds = append(kinds, kind)
+		}
+		updateDebugKindsLock.RUnlock()
+		fmt.Fprintf(w, "enabled=%t kinds=%s\n", updateDebugEnabled.Load(), strings.Join(kinds, ","))
+	})
+}
+
+// Predicate that logs a diff of each update. Any predicates given are
+// evaluated as with And, and the decision is logged and counted in the
+// controller_utils_update_debug_events_total metric.
+type updateDebugPredicate struct {
+	preds []predicate.Predicate
+}
+
+func UpdateDebug(preds ...predicate.Predicate) *updateDebugPredicate {
+	return &updateDebugPredicate{preds: preds}
 }
 
 var _ predicate.Predicate = &updateDebugPredicate{}
 
 // Create returns true if the Create event should be processed
-func (_ *updateDebugPredicate) Create(_ event.CreateEvent) bool {
+func (p *updateDebugPredicate) Create(evt event.CreateEvent) bool {
+	for _, pred := range p.preds {
+		if !pred.Create(evt) {
+			return false
+		}
+	}
 	return true
 }
 
 // Delete returns true if the Delete event should be processed
-func (_ *updateDebugPredicate) Delete(_ event.DeleteEvent) bool {
+func (p *updateDebugPredicate) Delete(evt event.DeleteEvent) bool {
+	for _, pred := range p.preds {
+		if !pred.Delete(evt) {
+			return false
+		}
+	}
 	return true
 }
 
 // Update returns true if the Update event should be processed
-func (_ *updateDebugPredicate) Update(evt event.UpdateEvent) bool {
-	if os.Getenv("DEBUG_UPDATE") == "true" {
+func (p *updateDebugPredicate) Update(evt event.UpdateEvent) bool {
+	passed := true
+	for _, pred := range p.preds {
+		if !pred.Update(evt) {
+			passed = false
+			break
+		}
+	}
+	if evt.ObjectNew == nil {
+		return passed
+	}
+
+	kind := objectKind(evt.ObjectNew)
+	result := "passed"
+	if !passed {
+		result = "suppressed"
+	}
+	updateDebugEvents.WithLabelValues(kind, result).Inc()
+
+	if UpdateDebugEnabled(kind) && evt.ObjectOld != nil {
 		obj := fmt.Sprintf("%s/%s", evt.ObjectNew.GetNamespace(), evt.ObjectNew.GetName())
 		diff, err := client.MergeFrom(evt.ObjectOld).Data(evt.ObjectNew)
 		if err != nil {
-			updateDebugLog.Info("error generating diff", "err", err, "obj", obj)
+			updateDebugLog.Info("error generating diff", "err", err, "obj", obj, "kind", kind)
 		} else {
-			updateDebugLog.Info("Update diff", "diff", string(diff), "obj", obj)
+			updateDebugLog.Info("Update diff", "diff", string(diff), "obj", obj, "kind", kind, "result", result)
 		}
 	}
-	return true
+	return passed
 }
 
 // Generic returns true if the Generic event should be processed
-func (_ *updateDebugPredicate) Generic(_ event.GenericEvent) bool {
+func (p *updateDebugPredicate) Generic(evt event.GenericEvent) bool {
+	for _, pred := range p.preds {
+		if !pred.Generic(evt) {
+			return false
+		}
+	}
 	return true
 }
+
+// Typed objects from the cache usually have an empty TypeMeta, so fall back
+// to the Go type name.
+func objectKind(obj client.Object) string {
+	gvk := obj.GetObjectKind().GroupVersionKind()
+	if gvk.Kind != "" {
+		return gvk.GroupKind().String()
+	}
+	t := reflect.TypeOf(obj)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Name()
+}
@@ -0,0 +1,125 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package predicates
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+var _ = ginkgo.Describe("UpdateDebug", func() {
+	ginkgo.AfterEach(func() {
+		SetUpdateDebug(false)
+	})
+
+	ginkgo.It("toggles at runtime", func() {
+		SetUpdateDebug(false)
+		gomega.Expect(UpdateDebugEnabled("ConfigMap")).To(gomega.BeFalse())
+		SetUpdateDebug(true)
+		gomega.Expect(UpdateDebugEnabled("ConfigMap")).To(gomega.BeTrue())
+		gomega.Expect(UpdateDebugEnabled("Deployment.apps")).To(gomega.BeTrue())
+		SetUpdateDebug(false)
+		gomega.Expect(UpdateDebugEnabled("ConfigMap")).To(gomega.BeFalse())
+	})
+
+	ginkgo.It("filters by kind", func() {
+		SetUpdateDebug(true, "Deployment", "Secret")
+		gomega.Expect(UpdateDebugEnabled("Deployment")).To(gomega.BeTrue())
+		gomega.Expect(UpdateDebugEnabled("Deployment.apps")).To(gomega.BeTrue())
+		gomega.Expect(UpdateDebugEnabled("Secret")).To(gomega.BeTrue())
+		gomega.Expect(UpdateDebugEnabled("ConfigMap")).To(gomega.BeFalse())
+
+		SetUpdateDebug(true, "Deployment.apps")
+		gomega.Expect(UpdateDebugEnabled("Deployment.apps")).To(gomega.BeTrue())
+		gomega.Expect(UpdateDebugEnabled("Deployment.example.com")).To(gomega.BeFalse())
+
+		// Turning it back on without kinds clears the filter.
+		SetUpdateDebug(true)
+		gomega.Expect(UpdateDebugEnabled("ConfigMap")).To(gomega.BeTrue())
+	})
+
+	ginkgo.Context("UpdateDebugHandler", func() {
+		serve := func(method, target string) *httptest.ResponseRecorder {
+			w := httptest.NewRecorder()
+			UpdateDebugHandler().ServeHTTP(w, httptest.NewRequest(method, target, nil))
+			return w
+		}
+
+		ginkgo.It("shows the current state", func() {
+			SetUpdateDebug(true, "Secret")
+			w := serve(http.MethodGet, "/")
+			gomega.Expect(w.Code).To(gomega.Equal(http.StatusOK))
+			gomega.Expect(w.Body.String()).To(gomega.Equal("enabled=true kinds=Secret\n"))
+		})
+
+		ginkgo.It("sets the state", func() {
+			w := serve(http.MethodPost, "/?enabled=true&kinds=Secret")
+			gomega.Expect(w.Code).To(gomega.Equal(http.StatusOK))
+			gomega.Expect(w.Body.String()).To(gomega.Equal("enabled=true kinds=Secret\n"))
+			gomega.Expect(UpdateDebugEnabled("Secret")).To(gomega.BeTrue())
+			gomega.Expect(UpdateDebugEnabled("ConfigMap")).To(gomega.BeFalse())
+
+			w = serve(http.MethodPost, "/?enabled=false")
+			gomega.Expect(w.Body.String()).To(gomega.Equal("enabled=false kinds=\n"))
+			gomega.Expect(UpdateDebugEnabled("Secret")).To(gomega.BeFalse())
+		})
+
+		ginkgo.It("rejects a bad enabled value", func() {
+			w := serve(http.MethodPost, "/?enabled=maybe")
+			gomega.Expect(w.Code).To(gomega.Equal(http.StatusBadRequest))
+		})
+
+		ginkgo.It("rejects other methods", func() {
+			w := serve(http.MethodDelete, "/")
+			gomega.Expect(w.Code).To(gomega.Equal(http.StatusMethodNotAllowed))
+		})
+	})
+
+	ginkgo.It("counts passed and suppressed updates", func() {
+		passed := updateDebugEvents.WithLabelValues("ConfigMap", "passed")
+		suppressed := updateDebugEvents.WithLabelValues("ConfigMap", "suppressed")
+		passedBefore := testutil.ToFloat64(passed)
+		suppressedBefore := testutil.ToFloat64(suppressed)
+
+		oldObj := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "testing"}, Data: map[string]string{"key": "1"}}
+		newObj := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "testing"}, Data: map[string]string{"key": "2"}}
+		evt := event.UpdateEvent{ObjectOld: oldObj, ObjectNew: newObj}
+		pass := predicate.NewPredicateFuncs(func(_ client.Object) bool { return true })
+		fail := predicate.NewPredicateFuncs(func(_ client.Object) bool { return false })
+
+		SetUpdateDebug(true)
+		gomega.Expect(UpdateDebug().Update(evt)).To(gomega.BeTrue())
+		gomega.Expect(UpdateDebug(pass).Update(evt)).To(gomega.BeTrue())
+		gomega.Expect(UpdateDebug(pass, fail).Update(evt)).To(gomega.BeFalse())
+		gomega.Expect(testutil.ToFloat64(passed)).To(gomega.Equal(passedBefore + 2))
+		gomega.Expect(testutil.ToFloat64(suppressed)).To(gomega.Equal(suppressedBefore + 1))
+
+		// Counting doesn't depend on diff logging.
+		SetUpdateDebug(false)
+		gomega.Expect(UpdateDebug(fail).Update(evt)).To(gomega.BeFalse())
+		gomega.Expect(testutil.ToFloat64(suppressed)).To(gomega.Equal(suppressedBefore + 2))
+	})
+})
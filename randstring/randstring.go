@@ -20,19 +20,228 @@ package randstring
 import (
 	"crypto/rand"
 	"encoding/base64"
+	"fmt"
+	"io"
+	"math/big"
+	mathrand "math/rand"
+	"strings"
+	"sync"
 )
 
-var RandEncoding = base64.NewEncoding("abcdefghijklmnopqrstuvwxyzabcdefghijklmnopqrstuvwxyzabcdefghijkl").WithPadding(base64.NoPadding)
+const (
+	// Lowercase letters only, safe for Kubernetes names and passwords that
+	// will work basically anywhere. This is the default charset.
+	Lowercase = "abcdefghijklmnopqrstuvwxyz"
+	Hex       = "0123456789abcdef"
+	// Letters of both cases and digits.
+	Alphanumeric = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+	// Alphanumeric plus the unreserved URL characters from RFC 3986.
+	URLSafe = Alphanumeric + "-_"
+	// Alphanumeric plus printable ASCII punctuation, excluding quotes and
+	// backslash which tend to need escaping in config files and shells.
+	Symbols = Alphanumeric + "!#$%&()*+,-./:;<=>?@[]^_{|}~"
+)
 
-func RandomBytes(size int) ([]byte, error) {
-	raw := make([]byte, size)
-	_, err := rand.Read(raw)
+const digits = "0123456789"
+
+// Policy controls the characters used in a random string, e.g. to satisfy an
+// external system's password rules. The zero value uses Lowercase.
+type Policy struct {
+	// Characters to choose from, each with equal probability.
+	Charset string
+	// Require at least one digit.
+	RequireDigit bool
+	// Require at least one character which is not a letter or digit.
+	RequireSymbol bool
+	// Source of randomness for this policy, defaults to Reader.
+	Reader io.Reader
+}
+
+// Source of randomness for all generated values. This is crypto/rand unless
+// replaced for testing, see Seed. Assigning it directly races with anything
+// generating values, so only do that before any are generated.
+var Reader io.Reader = rand.Reader
+
+// Guards Reader for Seed and its callers.
+var readerLock sync.RWMutex
+
+// A reader safe to share between goroutines, as math/rand sources are not.
+type lockedReader struct {
+	lock sync.Mutex
+	r    io.Reader
+}
+
+func (l *lockedReader) Read(p []byte) (int, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.r.Read(p)
+}
+
+// Replace Reader with a deterministic source so tests can assert exact
+// generated values. This is NOT secure and must never be used outside of
+// tests. Returns a function to restore the previous Reader.
+//
+//	defer randstring.Seed(42)()
+func Seed(seed int64) func() {
+	readerLock.Lock()
+	defer readerLock.Unlock()
+	previous := Reader
+	Reader = &lockedReader{r: mathrand.New(mathrand.NewSource(seed))}
+	return func() {
+		readerLock.Lock()
+		defer readerLock.Unlock()
+		Reader = previous
+	}
+}
+
+func (p Policy) reader() io.Reader {
+	if p.Reader != nil {
+		return p.Reader
+	}
+	readerLock.RLock()
+	defer readerLock.RUnlock()
+	return Reader
+}
+
+// The default policy, also used by RandomString and RandomBytes.
+var DefaultPolicy = Policy{Charset: Lowercase}
+
+// Deprecated: Use a Policy instead. This was a lossy lowercase-only encoding,
+// but Go no longer allows duplicate characters in an encoding alphabet so it
+// is now the URL-safe alphabet. Nothing in this package uses it.
+var RandEncoding = base64.RawURLEncoding
+
+func (p Policy) charset() string {
+	if p.Charset == "" {
+		return Lowercase
+	}
+	return p.Charset
+}
+
+func isSymbol(c rune) bool {
+	return !strings.ContainsRune(Alphanumeric, c)
+}
+
+// The characters in the charset satisfying each required class.
+func (p Policy) requiredClasses() ([]string, error) {
+	charset := p.charset()
+	classes := []string{}
+	if p.RequireDigit {
+		class := filterCharset(charset, func(c rune) bool { return strings.ContainsRune(digits, c) })
+		if class == "" {
+			return nil, fmt.Errorf("charset %q has no digits", charset)
+		}
+		classes = append(classes, class)
+	}
+	if p.RequireSymbol {
+		class := filterCharset(charset, isSymbol)
+		if class == "" {
+			return nil, fmt.Errorf("charset %q has no symbols", charset)
+		}
+		classes = append(classes, class)
+	}
+	return classes, nil
+}
+
+func filterCharset(charset string, f func(rune) bool) string {
+	var b strings.Builder
+	for _, c := range charset {
+		if f(c) {
+			b.WriteRune(c)
+		}
+	}
+	return b.String()
+}
+
+func randomIndex(r io.Reader, n int) (int, error) {
+	i, err := rand.Int(r, big.NewInt(int64(n)))
+	if err != nil {
+		return 0, err
+	}
+	return int(i.Int64()), nil
+}
+
+func randomChar(r io.Reader, charset []rune) (rune, error) {
+	i, err := randomIndex(r, len(charset))
 	if err != nil {
-		return nil, err
+		return 0, err
+	}
+	return charset[i], nil
+}
+
+// Generate a random string of length characters following the policy.
+func (p Policy) RandomString(length int) (string, error) {
+	r := p.reader()
+	charset := []rune(p.charset())
+	classes, err := p.requiredClasses()
+	if err != nil {
+		return "", err
+	}
+	if len(classes) > length {
+		return "", fmt.Errorf("length %d is too short for %d required character classes", length, len(classes))
+	}
+
+	out := make([]rune, length)
+	for i := range out {
+		out[i], err = randomChar(r, charset)
+		if err != nil {
+			return "", err
+		}
 	}
-	out := make([]byte, RandEncoding.EncodedLen(size))
-	RandEncoding.Encode(out, raw)
-	return out, nil
+
+	// Make sure each required class appears, replacing a random character if
+	// needed. Positions used for one class can't be reused for another.
+	reserved := map[int]bool{}
+	for _, class := range classes {
+		pos := -1
+		for i, c := range out {
+			if !reserved[i] && strings.ContainsRune(class, c) {
+				pos = i
+				break
+			}
+		}
+		if pos == -1 {
+			n, err := randomIndex(r, length-len(reserved))
+			if err != nil {
+				return "", err
+			}
+			for i := range out {
+				if reserved[i] {
+					continue
+				}
+				if n == 0 {
+					pos = i
+					break
+				}
+				n--
+			}
+			out[pos], err = randomChar(r, []rune(class))
+			if err != nil {
+				return "", err
+			}
+		}
+		reserved[pos] = true
+	}
+	return string(out), nil
+}
+
+func (p Policy) MustRandomString(length int) string {
+	out, err := p.RandomString(length)
+	if err != nil {
+		panic(err)
+	}
+	return out
+}
+
+// Generate a random value as long as the base64 encoding of size bytes would
+// be, using the policy's charset.
+func (p Policy) RandomBytes(size int) ([]byte, error) {
+	out, err := p.RandomString((size*8 + 5) / 6)
+	return []byte(out), err
+}
+
+func RandomBytes(size int) ([]byte, error) {
+	return DefaultPolicy.RandomBytes(size)
 }
 
 func RandomString(size int) (string, error) {
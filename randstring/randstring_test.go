@@ -0,0 +1,100 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package randstring_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/coderanger/controller-utils/randstring"
+)
+
+var _ = Describe("RandomString", func() {
+	It("generates lowercase strings by default", func() {
+		Expect(randstring.MustRandomString(32)).To(MatchRegexp(`^[a-z]{43}$`))
+	})
+
+	It("uses the policy charset", func() {
+		p := randstring.Policy{Charset: randstring.Hex}
+		Expect(p.MustRandomString(20)).To(MatchRegexp(`^[0-9a-f]{20}$`))
+	})
+
+	It("includes required classes", func() {
+		p := randstring.Policy{Charset: randstring.Symbols, RequireDigit: true, RequireSymbol: true}
+		for i := 0; i < 100; i++ {
+			out := p.MustRandomString(2)
+			Expect(out).To(MatchRegexp(`[0-9]`))
+			Expect(out).To(MatchRegexp(`[^A-Za-z0-9]`))
+		}
+	})
+
+	It("rejects a charset without a required class", func() {
+		p := randstring.Policy{Charset: randstring.Hex, RequireSymbol: true}
+		_, err := p.RandomString(10)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("rejects a length too short for the required classes", func() {
+		p := randstring.Policy{Charset: randstring.Symbols, RequireDigit: true, RequireSymbol: true}
+		_, err := p.RandomString(1)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("is deterministic when seeded", func() {
+		restore := randstring.Seed(42)
+		first := randstring.MustRandomString(16)
+		restore()
+		defer randstring.Seed(42)()
+		Expect(randstring.MustRandomString(16)).To(Equal(first))
+		Expect(first).To(Equal("tmwrexlusiuessjjzusjsx"))
+	})
+
+	It("can be seeded while generating", func() {
+		done := make(chan struct{})
+		go func() {
+			defer GinkgoRecover()
+			defer close(done)
+			for i := 0; i < 100; i++ {
+				randstring.MustRandomString(16)
+			}
+		}()
+		for i := 0; i < 100; i++ {
+			randstring.Seed(int64(i))()
+		}
+		<-done
+	})
+
+	It("uses a per-policy reader", func() {
+		p := randstring.Policy{Charset: randstring.Hex, Reader: zeroReader{}}
+		Expect(p.MustRandomString(8)).To(Equal("00000000"))
+	})
+})
+
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+	return len(p), nil
+}
+
+var _ = Describe("RandEncoding", func() {
+	It("still encodes for existing callers", func() {
+		Expect(randstring.RandEncoding.EncodeToString([]byte{0xff, 0xfe})).To(Equal("__4"))
+	})
+})
@@ -0,0 +1,295 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package templates
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	toolscache "k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+type configMapMount struct {
+	dir  string
+	name string
+}
+
+// A filesystem backed by ConfigMaps in the cluster, so templates can be
+// changed without rebuilding the operator image. Each ConfigMap is mounted
+// at a directory and each key becomes a file in it. Contents are kept up to
+// date by a watch on the ConfigMaps, so the next reconcile sees any changes.
+type ConfigMapFileSystem struct {
+	namespace string
+	mounts    []configMapMount
+	reader    client.Reader
+	synced    func() bool
+
+	lock  sync.RWMutex
+	files map[string]map[string][]byte
+}
+
+// Check interface compliance.
+var _ http.FileSystem = &ConfigMapFileSystem{}
+
+func NewConfigMapFileSystem(namespace string) *ConfigMapFileSystem {
+	return &ConfigMapFileSystem{namespace: namespace, files: map[string]map[string][]byte{}}
+}
+
+// Mount the keys of a ConfigMap in a directory, use "" or "/" for the root.
+func (cfs *ConfigMapFileSystem) Mount(dir string, name string) *ConfigMapFileSystem {
+	cfs.mounts = append(cfs.mounts, configMapMount{dir: path.Clean("/" + dir), name: name})
+	return cfs
+}
+
+// Start watching the ConfigMaps with a cache limited to their namespace, run
+// by the manager, rather than the manager's cache which would watch every
+// ConfigMap in the cluster. Components render templates during setup, before
+// the cache has started, so until then the ConfigMaps are read directly from
+// the API.
+func (cfs *ConfigMapFileSystem) SetupWithManager(mgr manager.Manager) error {
+	if cfs.synced != nil {
+		return nil
+	}
+	cfs.reader = mgr.GetAPIReader()
+	namespaceCache, err := cache.New(mgr.GetConfig(), cache.Options{Scheme: mgr.GetScheme(), Mapper: mgr.GetRESTMapper(), Namespace: cfs.namespace})
+	if err != nil {
+		return errors.Wrapf(err, "error creating ConfigMap cache for namespace %s", cfs.namespace)
+	}
+	err = mgr.Add(namespaceCache)
+	if err != nil {
+		return errors.Wrap(err, "error adding ConfigMap cache to manager")
+	}
+	return cfs.Watch(context.Background(), namespaceCache)
+}
+
+// Start watching the ConfigMaps using an informer source, ideally one limited
+// to the namespace. Only needs to be called once even if the filesystem is
+// shared by several controllers.
+func (cfs *ConfigMapFileSystem) Watch(ctx context.Context, informers cache.Informers) error {
+	if cfs.synced != nil {
+		return nil
+	}
+	informer, err := informers.GetInformer(ctx, &corev1.ConfigMap{})
+	if err != nil {
+		return errors.Wrap(err, "error getting ConfigMap informer")
+	}
+	informer.AddEventHandler(toolscache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			cfs.update(obj)
+		},
+		UpdateFunc: func(_, obj interface{}) {
+			cfs.update(obj)
+		},
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(toolscache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			cm, ok := obj.(*corev1.ConfigMap)
+			if !ok || !cfs.mounted(cm) {
+				return
+			}
+			cfs.lock.Lock()
+			defer cfs.lock.Unlock()
+			delete(cfs.files, cm.Name)
+		},
+	})
+	cfs.synced = informer.HasSynced
+	return nil
+}
+
+// Read any ConfigMaps the watch hasn't delivered yet.
+func (cfs *ConfigMapFileSystem) load() error {
+	if cfs.reader == nil || (cfs.synced != nil && cfs.synced()) {
+		return nil
+	}
+	for _, mount := range cfs.mounts {
+		cfs.lock.RLock()
+		_, ok := cfs.files[mount.name]
+		cfs.lock.RUnlock()
+		if ok {
+			continue
+		}
+		cm := &corev1.ConfigMap{}
+		err := cfs.reader.Get(context.Background(), types.NamespacedName{Name: mount.name, Namespace: cfs.namespace}, cm)
+		if err != nil {
+			if kerrors.IsNotFound(err) {
+				continue
+			}
+			return errors.Wrapf(err, "error reading ConfigMap %s/%s", cfs.namespace, mount.name)
+		}
+		cfs.update(cm)
+	}
+	return nil
+}
+
+// Check if a ConfigMap is one of ours, so we only keep what's mounted.
+func (cfs *ConfigMapFileSystem) mounted(cm *corev1.ConfigMap) bool {
+	if cm.Namespace != cfs.namespace {
+		return false
+	}
+	for _, mount := range cfs.mounts {
+		if mount.name == cm.Name {
+			return true
+		}
+	}
+	return false
+}
+
+func (cfs *ConfigMapFileSystem) update(obj interface{}) {
+	cm, ok := obj.(*corev1.ConfigMap)
+	if !ok || !cfs.mounted(cm) {
+		return
+	}
+	files := map[string][]byte{}
+	for key, val := range cm.Data {
+		files[key] = []byte(val)
+	}
+	for key, val := range cm.BinaryData {
+		files[key] = val
+	}
+	cfs.lock.Lock()
+	defer cfs.lock.Unlock()
+	cfs.files[cm.Name] = files
+}
+
+func (cfs *ConfigMapFileSystem) Open(name string) (http.File, error) {
+	err := cfs.load()
+	if err != nil {
+		return nil, err
+	}
+	name = path.Clean("/" + name)
+	cfs.lock.RLock()
+	defer cfs.lock.RUnlock()
+
+	// Check for a file first.
+	dir, base := path.Split(name)
+	dir = path.Clean(dir)
+	for _, mount := range cfs.mounts {
+		if mount.dir != dir {
+			continue
+		}
+		data, ok := cfs.files[mount.name][base]
+		if ok {
+			return &memFile{Reader: bytes.NewReader(data), info: &memFileInfo{name: base, size: int64(len(data))}}, nil
+		}
+	}
+
+	// Then look for a directory, either a mount point or a parent of one.
+	children := map[string]os.FileInfo{}
+	isDir := false
+	for _, mount := range cfs.mounts {
+		if mount.dir == name {
+			isDir = true
+			for key, data := range cfs.files[mount.name] {
+				children[key] = &memFileInfo{name: key, size: int64(len(data))}
+			}
+		} else if name == "/" || strings.HasPrefix(mount.dir, name+"/") {
+			isDir = true
+			rel := strings.TrimPrefix(strings.TrimPrefix(mount.dir, name), "/")
+			child := strings.SplitN(rel, "/", 2)[0]
+			children[child] = &memFileInfo{name: child, dir: true}
+		}
+	}
+	if !isDir {
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+	}
+	fis := make([]os.FileInfo, 0, len(children))
+	for _, fi := range children {
+		fis = append(fis, fi)
+	}
+	sort.Slice(fis, func(i, j int) bool { return fis[i].Name() < fis[j].Name() })
+	return &memFile{Reader: bytes.NewReader(nil), info: &memFileInfo{name: path.Base(name), dir: true}, children: fis}, nil
+}
+
+type memFile struct {
+	*bytes.Reader
+	info     *memFileInfo
+	children []os.FileInfo
+}
+
+func (f *memFile) Close() error {
+	return nil
+}
+
+func (f *memFile) Readdir(count int) ([]os.FileInfo, error) {
+	if !f.info.dir {
+		return nil, fmt.Errorf("%s is not a directory", f.info.name)
+	}
+	if count > 0 && len(f.children) == 0 {
+		return nil, io.EOF
+	}
+	if count <= 0 || count > len(f.children) {
+		count = len(f.children)
+	}
+	out := f.children[:count]
+	f.children = f.children[count:]
+	return out, nil
+}
+
+func (f *memFile) Stat() (os.FileInfo, error) {
+	return f.info, nil
+}
+
+type memFileInfo struct {
+	name string
+	size int64
+	dir  bool
+}
+
+func (fi *memFileInfo) Name() string {
+	return fi.name
+}
+
+func (fi *memFileInfo) Size() int64 {
+	return fi.size
+}
+
+func (fi *memFileInfo) Mode() fs.FileMode {
+	if fi.dir {
+		return fs.ModeDir | 0555
+	}
+	return 0444
+}
+
+func (fi *memFileInfo) ModTime() time.Time {
+	return time.Time{}
+}
+
+func (fi *memFileInfo) IsDir() bool {
+	return fi.dir
+}
+
+func (fi *memFileInfo) Sys() interface{} {
+	return nil
+}
@@ -0,0 +1,120 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package templates_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/cache/informertest"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllertest"
+
+	"github.com/coderanger/controller-utils/templates"
+)
+
+var _ = Describe("ConfigMapFileSystem", func() {
+	var cfs *templates.ConfigMapFileSystem
+	var informer *controllertest.FakeInformer
+	var mainCM, helpersCM *corev1.ConfigMap
+
+	BeforeEach(func() {
+		informers := &informertest.FakeInformers{}
+		cfs = templates.NewConfigMapFileSystem("default").Mount("", "templates").Mount("helpers", "helpers")
+		err := cfs.Watch(context.Background(), informers)
+		Expect(err).ToNot(HaveOccurred())
+		informer, err = informers.FakeInformerFor(&corev1.ConfigMap{})
+		Expect(err).ToNot(HaveOccurred())
+
+		mainCM = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "templates", Namespace: "default"},
+			Data: map[string]string{
+				"configmap.yml": "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: {{ template \"name\" . }}\n",
+			},
+		}
+		helpersCM = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "helpers", Namespace: "default"},
+			Data: map[string]string{
+				"name.tpl": `{{ define "name" }}one{{ end }}`,
+			},
+		}
+		informer.Add(mainCM)
+		informer.Add(helpersCM)
+	})
+
+	It("renders templates with helpers", func() {
+		obj, err := templates.Get(cfs, "configmap.yml", false, nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(obj.GetName()).To(Equal("one"))
+	})
+
+	It("lists directories", func() {
+		f, err := cfs.Open("/")
+		Expect(err).ToNot(HaveOccurred())
+		fis, err := f.Readdir(0)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(fis).To(HaveLen(2))
+		Expect(fis[0].Name()).To(Equal("configmap.yml"))
+		Expect(fis[1].Name()).To(Equal("helpers"))
+		Expect(fis[1].IsDir()).To(BeTrue())
+	})
+
+	It("picks up changes", func() {
+		newHelpersCM := helpersCM.DeepCopy()
+		newHelpersCM.Data["name.tpl"] = `{{ define "name" }}two{{ end }}`
+		informer.Update(helpersCM, newHelpersCM)
+
+		obj, err := templates.Get(cfs, "configmap.yml", false, nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(obj.GetName()).To(Equal("two"))
+	})
+
+	It("ignores other namespaces", func() {
+		otherCM := mainCM.DeepCopy()
+		otherCM.Namespace = "other"
+		otherCM.Data["configmap.yml"] = "bad"
+		informer.Add(otherCM)
+
+		obj, err := templates.Get(cfs, "configmap.yml", false, nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(obj.GetName()).To(Equal("one"))
+	})
+
+	It("ignores ConfigMaps which aren't mounted", func() {
+		otherCM := mainCM.DeepCopy()
+		otherCM.Name = "other"
+		otherCM.Data["other.yml"] = "bad"
+		informer.Add(otherCM)
+
+		_, err := cfs.Open("/other.yml")
+		Expect(err).To(HaveOccurred())
+		f, err := cfs.Open("/")
+		Expect(err).ToNot(HaveOccurred())
+		fis, err := f.Readdir(0)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(fis).To(HaveLen(2))
+	})
+
+	It("removes files when the ConfigMap is deleted", func() {
+		informer.Delete(mainCM)
+
+		_, err := cfs.Open("/configmap.yml")
+		Expect(err).To(HaveOccurred())
+	})
+})
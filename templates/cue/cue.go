@@ -0,0 +1,159 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cue renders .cue templates. It registers itself with the templates
+// package when imported, usually as a blank import:
+//
+//	import _ "github.com/coderanger/controller-utils/templates/cue"
+package cue
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+
+	cuelang "cuelang.org/go/cue"
+	"cuelang.org/go/cue/cuecontext"
+	"cuelang.org/go/cue/load"
+	"cuelang.org/go/cue/parser"
+	"github.com/shurcooL/httpfs/vfsutil"
+
+	"github.com/coderanger/controller-utils/templates"
+)
+
+// Where the template filesystem is mounted for the CUE loader, which only
+// works with absolute paths.
+const overlayRoot = "/controller-utils-templates"
+
+func init() {
+	templates.RegisterRenderer(".cue", &Renderer{})
+}
+
+// Render templates with CUE. The template is loaded along with the other files
+// of its package in the same directory, so schemas and helpers can be split
+// into their own files. A template without a package clause is loaded on its
+// own. Each top-level field of the template data is unified into the
+// top-level field with the same name, so a template can declare a schema like
+// `Object: spec: replicas: int & >0` and have the object checked against it.
+// The rendered object is read from the `output` field and must be fully
+// concrete.
+type Renderer struct{}
+
+var _ templates.Renderer = &Renderer{}
+
+func (_ *Renderer) Render(fs http.FileSystem, filename string, data interface{}, _ templates.Options) ([]byte, error) {
+	if fs == nil {
+		return nil, fmt.Errorf("template filesystem not set")
+	}
+	fileBytes, err := vfsutil.ReadFile(fs, filename)
+	if err != nil {
+		return nil, err
+	}
+	file, err := parser.ParseFile(filename, fileBytes, parser.PackageClauseOnly)
+	if err != nil {
+		return nil, err
+	}
+	// Without a package clause, the file stands alone.
+	args := []string{"./" + path.Base(filename)}
+	pkg := file.PackageName()
+	if pkg != "" {
+		args = []string{"."}
+	}
+
+	dir := path.Join("/", path.Dir(filename))
+	overlay, err := packageOverlay(fs, dir)
+	if err != nil {
+		return nil, err
+	}
+	insts := load.Instances(args, &load.Config{
+		Dir:        path.Join(overlayRoot, dir),
+		ModuleRoot: path.Join(overlayRoot, dir),
+		Package:    pkg,
+		Overlay:    overlay,
+	})
+	if len(insts) != 1 {
+		return nil, fmt.Errorf("cue template %s loaded %d instances", filename, len(insts))
+	}
+	if insts[0].Err != nil {
+		return nil, insts[0].Err
+	}
+
+	cctx := cuecontext.New()
+	value := cctx.BuildInstance(insts[0])
+	if value.Err() != nil {
+		return nil, value.Err()
+	}
+
+	// Round trip through JSON to get the same field names the object will
+	// have in the API.
+	rawData, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding template data: %w", err)
+	}
+	vars := map[string]json.RawMessage{}
+	if string(rawData) != "null" {
+		err = json.Unmarshal(rawData, &vars)
+		if err != nil {
+			return nil, fmt.Errorf("cue template data must be an object: %w", err)
+		}
+	}
+	for key, val := range vars {
+		dataValue := cctx.CompileBytes(val)
+		if dataValue.Err() != nil {
+			return nil, dataValue.Err()
+		}
+		value = value.FillPath(cuelang.MakePath(cuelang.Str(key)), dataValue)
+	}
+
+	output := value.LookupPath(cuelang.ParsePath("output"))
+	if !output.Exists() {
+		return nil, fmt.Errorf("cue template %s has no output field", filename)
+	}
+	err = output.Validate(cuelang.Concrete(true))
+	if err != nil {
+		return nil, err
+	}
+	return output.MarshalJSON()
+}
+
+// Read the .cue files in a directory of the template filesystem into a loader
+// overlay.
+func packageOverlay(fs http.FileSystem, dir string) (map[string]load.Source, error) {
+	d, err := fs.Open(dir)
+	if err != nil {
+		return nil, err
+	}
+	defer d.Close()
+	infos, err := d.Readdir(-1)
+	if err != nil {
+		return nil, err
+	}
+	overlay := map[string]load.Source{}
+	for _, info := range infos {
+		if info.IsDir() || !strings.HasSuffix(info.Name(), ".cue") {
+			continue
+		}
+		filename := path.Join(dir, info.Name())
+		fileBytes, err := vfsutil.ReadFile(fs, filename)
+		if err != nil {
+			return nil, err
+		}
+		overlay[path.Join(overlayRoot, filename)] = load.FromBytes(fileBytes)
+	}
+	return overlay, nil
+}
@@ -0,0 +1,76 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cue_test
+
+import (
+	"net/http"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/onsi/gomega/gstruct"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/coderanger/controller-utils/templates"
+	_ "github.com/coderanger/controller-utils/templates/cue"
+)
+
+var testTemplates http.FileSystem = http.Dir("test_templates")
+
+var _ = Describe("CUE renderer", func() {
+	It("should render the Deployment", func() {
+		rawObject, err := templates.Get(testTemplates, "deployment.cue", false, map[string]interface{}{"Name": "cue", "Replicas": 3})
+		Expect(err).ToNot(HaveOccurred())
+		deployment, ok := rawObject.(*appsv1.Deployment)
+		Expect(ok).To(BeTrue())
+		Expect(deployment.Name).To(Equal("test-cue"))
+		Expect(deployment.Spec.Replicas).To(PointTo(BeEquivalentTo(3)))
+		Expect(deployment.Spec.Template.Labels).To(HaveKeyWithValue("app", "test-cue"))
+	})
+
+	It("loads the package from a subdirectory", func() {
+		rawObject, err := templates.Get(http.Dir("."), "test_templates/deployment.cue", true, map[string]interface{}{"Name": "cue", "Replicas": 3})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(rawObject.GetName()).To(Equal("test-cue"))
+	})
+
+	It("validates the data against the schema from another file", func() {
+		_, err := templates.Get(testTemplates, "deployment.cue", true, map[string]interface{}{"Name": "cue", "Replicas": 11})
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("requires concrete output", func() {
+		_, err := templates.Get(testTemplates, "deployment.cue", true, map[string]interface{}{"Name": "cue"})
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("only loads files from the same package", func() {
+		rawObject, err := templates.Get(testTemplates, "service.cue", false, map[string]interface{}{"Name": "cue"})
+		Expect(err).ToNot(HaveOccurred())
+		service, ok := rawObject.(*corev1.Service)
+		Expect(ok).To(BeTrue())
+		Expect(service.Name).To(Equal("test-cue"))
+	})
+
+	It("loads a template without a package on its own", func() {
+		rawObject, err := templates.Get(testTemplates, "configmap.cue", false, map[string]interface{}{"Name": "cue"})
+		Expect(err).ToNot(HaveOccurred())
+		configMap, ok := rawObject.(*corev1.ConfigMap)
+		Expect(ok).To(BeTrue())
+		Expect(configMap.Name).To(Equal("test-cue"))
+	})
+})
@@ -0,0 +1,10 @@
+Name: string
+
+output: {
+	apiVersion: "v1"
+	kind:       "ConfigMap"
+	metadata: {
+		name:      "test-\(Name)"
+		namespace: "default"
+	}
+}
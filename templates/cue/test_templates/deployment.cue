@@ -0,0 +1,20 @@
+package deployment
+
+let name = "test-\(Name)"
+
+output: {
+	apiVersion: "apps/v1"
+	kind:       "Deployment"
+	metadata: {
+		"name":    name
+		namespace: "default"
+	}
+	spec: {
+		replicas: Replicas
+		selector: matchLabels: app: name
+		template: {
+			metadata: labels: app: name
+			spec: containers: [{"name": "default", image: "test"}]
+		}
+	}
+}
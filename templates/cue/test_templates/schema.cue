@@ -0,0 +1,4 @@
+package deployment
+
+Name:     string
+Replicas: int & >=0 & <=10
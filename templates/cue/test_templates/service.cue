@@ -0,0 +1,13 @@
+package service
+
+Name: string
+
+output: {
+	apiVersion: "v1"
+	kind:       "Service"
+	metadata: {
+		name:      "test-\(Name)"
+		namespace: "default"
+	}
+	spec: ports: [{port: 80}]
+}
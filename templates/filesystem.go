@@ -20,7 +20,8 @@ import (
 	"fmt"
 	"net/http"
 	"os"
-	"path/filepath"
+	"path"
+	"strings"
 )
 
 type filter struct {
@@ -35,15 +36,25 @@ type FilteredFileSystem struct {
 
 type FilteredFile struct {
 	http.File
+	name    string
 	filters []*filter
 }
 
-func allowedByFilters(name string, filters []*filter) error {
+// Globs are matched against the full path, and can use `**` to match any
+// number of directories. Directories are allowed through an include filter
+// using `**` if they could contain a match.
+func allowedByFilters(name string, isDir bool, filters []*filter) error {
 	for _, f := range filters {
-		matches, err := filepath.Match(f.glob, name[1:])
+		matches, err := matchGlob(f.glob, name)
 		if err != nil {
 			return err
 		}
+		if !matches && isDir && f.shouldMatch && strings.Contains(f.glob, "**") {
+			matches, err = matchGlobPrefix(f.glob, name)
+			if err != nil {
+				return err
+			}
+		}
 		if matches != f.shouldMatch {
 			return fmt.Errorf("Does not match filter %s", f.glob)
 		}
@@ -79,12 +90,18 @@ func (ffs *FilteredFileSystem) Open(name string) (http.File, error) {
 		return nil, err
 	}
 
-	err = allowedByFilters(name, ffs.filters)
+	fi, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	err = allowedByFilters(name, fi.IsDir(), ffs.filters)
 	if err != nil {
+		file.Close()
 		return nil, err
 	}
 
-	return &FilteredFile{File: file, filters: ffs.filters}, nil
+	return &FilteredFile{File: file, name: name, filters: ffs.filters}, nil
 }
 
 func (ff *FilteredFile) Readdir(count int) ([]os.FileInfo, error) {
@@ -94,7 +111,7 @@ func (ff *FilteredFile) Readdir(count int) ([]os.FileInfo, error) {
 	}
 	out := make([]os.FileInfo, 0, len(fis))
 	for _, fi := range fis {
-		if allowedByFilters(fi.Name(), ff.filters) == nil {
+		if allowedByFilters(path.Join(ff.name, fi.Name()), fi.IsDir(), ff.filters) == nil {
 			out = append(out, fi)
 		}
 	}
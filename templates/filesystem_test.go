@@ -29,14 +29,26 @@ import (
 
 var testFilteredTemplates *templates.FilteredFileSystem = templates.NewFilteredFileSystem(http.Dir("test_templates"))
 
+// List the names in a directory.
+func readdirNames(fs http.FileSystem, path string) []string {
+	f, err := fs.Open(path)
+	ExpectWithOffset(1, err).ToNot(HaveOccurred())
+	fis, err := f.Readdir(0)
+	ExpectWithOffset(1, err).ToNot(HaveOccurred())
+	names := []string{}
+	for _, fi := range fis {
+		names = append(names, fi.Name())
+	}
+	return names
+}
+
 var _ = Describe("FilteredFileSystem", func() {
 	Context("no filters", func() {
 		It("lists all files", func() {
-			f, err := testFilteredTemplates.Open("/")
-			Expect(err).ToNot(HaveOccurred())
-			fis, err := f.Readdir(0)
-			Expect(err).ToNot(HaveOccurred())
-			Expect(fis).To(HaveLen(6))
+			Expect(readdirNames(testFilteredTemplates, "/")).To(ConsistOf(
+				"custom_funcs.yml.tpl", "empty.yml", "helpers", "nested",
+				"test.txt", "test1.yml.tpl", "test2.yml.tpl", "test3.yml.tpl",
+			))
 		})
 
 		It("can read test.txt", func() {
@@ -54,11 +66,10 @@ var _ = Describe("FilteredFileSystem", func() {
 		exclude := testFilteredTemplates.Exclude("*.txt")
 
 		It("lists the correct files", func() {
-			f, err := exclude.Open("/")
-			Expect(err).ToNot(HaveOccurred())
-			fis, err := f.Readdir(0)
-			Expect(err).ToNot(HaveOccurred())
-			Expect(fis).To(HaveLen(5))
+			Expect(readdirNames(exclude, "/")).To(ConsistOf(
+				"custom_funcs.yml.tpl", "empty.yml", "helpers", "nested",
+				"test1.yml.tpl", "test2.yml.tpl", "test3.yml.tpl",
+			))
 		})
 
 		It("cannot read test.txt", func() {
@@ -90,4 +101,50 @@ var _ = Describe("FilteredFileSystem", func() {
 			Expect(err).To(HaveOccurred())
 		})
 	})
+
+	Context("a recursive include filter", func() {
+		include := testFilteredTemplates.Include("**/*.tpl")
+
+		It("can list directories", func() {
+			f, err := include.Open("/nested")
+			Expect(err).ToNot(HaveOccurred())
+			fis, err := f.Readdir(0)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(fis).To(HaveLen(1))
+			Expect(fis[0].Name()).To(Equal("deep"))
+		})
+
+		It("can read nested files", func() {
+			_, err := include.Open("/nested/deep/configmap.yml.tpl")
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("can read top-level files", func() {
+			_, err := include.Open("/test1.yml.tpl")
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("cannot read test.txt", func() {
+			_, err := include.Open("/test.txt")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("a recursive exclude filter", func() {
+		exclude := testFilteredTemplates.Exclude("nested/**")
+
+		It("cannot read nested files", func() {
+			_, err := exclude.Open("/nested/deep/configmap.yml.tpl")
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("hides the directory", func() {
+			names := readdirNames(exclude, "/")
+			Expect(names).ToNot(ContainElement("nested"))
+			Expect(names).To(ConsistOf(
+				"custom_funcs.yml.tpl", "empty.yml", "helpers",
+				"test.txt", "test1.yml.tpl", "test2.yml.tpl", "test3.yml.tpl",
+			))
+		})
+	})
 })
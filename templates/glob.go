@@ -0,0 +1,121 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package templates
+
+import (
+	"net/http"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/shurcooL/httpfs/vfsutil"
+)
+
+func splitPath(name string) []string {
+	name = strings.Trim(name, "/")
+	if name == "" {
+		return []string{}
+	}
+	return strings.Split(name, "/")
+}
+
+// Match a slash-separated path against a glob, like path.Match but a `**`
+// segment matches zero or more whole path segments.
+func matchGlob(glob string, name string) (bool, error) {
+	return matchSegments(splitPath(glob), splitPath(name))
+}
+
+func matchSegments(globParts []string, nameParts []string) (bool, error) {
+	for len(globParts) > 0 {
+		if globParts[0] == "**" {
+			// Try consuming every possible number of segments.
+			for i := 0; i <= len(nameParts); i++ {
+				matched, err := matchSegments(globParts[1:], nameParts[i:])
+				if err != nil || matched {
+					return matched, err
+				}
+			}
+			return false, nil
+		}
+		if len(nameParts) == 0 {
+			return false, nil
+		}
+		matched, err := path.Match(globParts[0], nameParts[0])
+		if err != nil || !matched {
+			return false, err
+		}
+		globParts = globParts[1:]
+		nameParts = nameParts[1:]
+	}
+	return len(nameParts) == 0, nil
+}
+
+// Check if a directory could contain matches for a glob with a `**` in it.
+func matchGlobPrefix(glob string, dir string) (bool, error) {
+	globParts := splitPath(glob)
+	for i, dirPart := range splitPath(dir) {
+		if i >= len(globParts) {
+			return false, nil
+		}
+		if globParts[i] == "**" {
+			return true, nil
+		}
+		matched, err := path.Match(globParts[i], dirPart)
+		if err != nil || !matched {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// Find all files in a filesystem matching a glob, which may use `**`. The
+// results are in lexical order.
+func globFiles(fs http.FileSystem, glob string) ([]string, error) {
+	// Start walking from the part of the glob without any wildcards.
+	root := "/"
+	for _, part := range splitPath(glob) {
+		if strings.ContainsAny(part, "*?[\\") {
+			break
+		}
+		root = path.Join(root, part)
+	}
+
+	matches := []string{}
+	err := vfsutil.Walk(fs, root, func(filename string, fi os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if fi.IsDir() {
+			return nil
+		}
+		matched, err := matchGlob(glob, filename)
+		if err != nil {
+			return err
+		}
+		if matched {
+			matches = append(matches, strings.TrimPrefix(filename, "/"))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return matches, nil
+}
@@ -0,0 +1,100 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package jsonnet renders .jsonnet templates. It registers itself with the
+// templates package when imported, usually as a blank import:
+//
+//	import _ "github.com/coderanger/controller-utils/templates/jsonnet"
+package jsonnet
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+
+	gojsonnet "github.com/google/go-jsonnet"
+	"github.com/shurcooL/httpfs/vfsutil"
+
+	"github.com/coderanger/controller-utils/templates"
+)
+
+func init() {
+	templates.RegisterRenderer(".jsonnet", &Renderer{})
+}
+
+// Render templates with Jsonnet. Each top-level field of the template data is
+// available as an external variable, so `std.extVar("Object").metadata.name`
+// works for the usual component data. Imports are resolved relative to the
+// importing file in the same filesystem.
+type Renderer struct{}
+
+var _ templates.Renderer = &Renderer{}
+
+func (_ *Renderer) Render(fs http.FileSystem, filename string, data interface{}, _ templates.Options) ([]byte, error) {
+	if fs == nil {
+		return nil, fmt.Errorf("template filesystem not set")
+	}
+	vm := gojsonnet.MakeVM()
+	vm.Importer(&jsonnetImporter{fs: fs, cache: map[string]gojsonnet.Contents{}})
+
+	// Round trip through JSON to get the same field names the object will
+	// have in the API.
+	rawData, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding template data: %w", err)
+	}
+	vars := map[string]json.RawMessage{}
+	if string(rawData) != "null" {
+		err = json.Unmarshal(rawData, &vars)
+		if err != nil {
+			return nil, fmt.Errorf("jsonnet template data must be an object: %w", err)
+		}
+	}
+	for key, val := range vars {
+		vm.ExtCode(key, string(val))
+	}
+
+	out, err := vm.EvaluateFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(out), nil
+}
+
+type jsonnetImporter struct {
+	fs    http.FileSystem
+	cache map[string]gojsonnet.Contents
+}
+
+func (i *jsonnetImporter) Import(importedFrom, importedPath string) (gojsonnet.Contents, string, error) {
+	foundAt := importedPath
+	if !path.IsAbs(foundAt) {
+		foundAt = path.Join(path.Dir(importedFrom), importedPath)
+	}
+	// Jsonnet requires the same Contents for repeated imports of a file.
+	contents, ok := i.cache[foundAt]
+	if ok {
+		return contents, foundAt, nil
+	}
+	fileBytes, err := vfsutil.ReadFile(i.fs, foundAt)
+	if err != nil {
+		return gojsonnet.Contents{}, "", err
+	}
+	contents = gojsonnet.MakeContents(string(fileBytes))
+	i.cache[foundAt] = contents
+	return contents, foundAt, nil
+}
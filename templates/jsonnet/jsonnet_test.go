@@ -0,0 +1,55 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jsonnet_test
+
+import (
+	"net/http"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/onsi/gomega/gstruct"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/coderanger/controller-utils/templates"
+	_ "github.com/coderanger/controller-utils/templates/jsonnet"
+)
+
+var testTemplates http.FileSystem = http.Dir("test_templates")
+
+var _ = Describe("Jsonnet renderer", func() {
+	It("should render the Deployment", func() {
+		rawObject, err := templates.Get(testTemplates, "deployment.jsonnet", false, struct {
+			Name     string
+			Replicas int
+		}{Name: "jsonnet", Replicas: 2})
+		Expect(err).ToNot(HaveOccurred())
+		deployment, ok := rawObject.(*appsv1.Deployment)
+		Expect(ok).To(BeTrue())
+		Expect(deployment.Name).To(Equal("test-jsonnet"))
+		Expect(deployment.Spec.Replicas).To(PointTo(BeEquivalentTo(2)))
+		Expect(deployment.Spec.Selector.MatchLabels).To(HaveKeyWithValue("app", "test-jsonnet"))
+	})
+
+	It("should render unstructured", func() {
+		rawObject, err := templates.Get(testTemplates, "deployment.jsonnet", true, map[string]interface{}{"Name": "u", "Replicas": 1})
+		Expect(err).ToNot(HaveOccurred())
+		obj, ok := rawObject.(*unstructured.Unstructured)
+		Expect(ok).To(BeTrue())
+		Expect(obj.GetName()).To(Equal("test-u"))
+	})
+})
@@ -0,0 +1,140 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package templates
+
+import (
+	"fmt"
+	"math"
+	"text/template"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// Helpers for resource.Quantity math, e.g. working out a JVM heap from a
+// memory limit with `{{ .Object.Spec.Memory | mulQty 0.75 | memoryMiB }}m`.
+// Anything taking a quantity accepts a Quantity, a string, or a number. The
+// value being worked on comes last so they can be used in pipelines.
+var quantityFuncMap = template.FuncMap{
+	"parseQty": toQuantity,
+	"addQty": func(other interface{}, input interface{}) (*resource.Quantity, error) {
+		return combineQuantities(input, other, func(q *resource.Quantity, o resource.Quantity) { q.Add(o) })
+	},
+	"subQty": func(other interface{}, input interface{}) (*resource.Quantity, error) {
+		return combineQuantities(input, other, func(q *resource.Quantity, o resource.Quantity) { q.Sub(o) })
+	},
+	"mulQty": func(factor float64, input interface{}) (*resource.Quantity, error) {
+		return scaleQuantity(input, factor)
+	},
+	"divQty": func(divisor float64, input interface{}) (*resource.Quantity, error) {
+		if divisor == 0 {
+			return nil, fmt.Errorf("divQty: division by zero")
+		}
+		return scaleQuantity(input, 1/divisor)
+	},
+	// Conversions to plain integers, rounding down.
+	"memoryBytes": func(input interface{}) (int64, error) {
+		q, err := toQuantity(input)
+		if err != nil {
+			return 0, err
+		}
+		return q.Value(), nil
+	},
+	"memoryMiB": func(input interface{}) (int64, error) {
+		q, err := toQuantity(input)
+		if err != nil {
+			return 0, err
+		}
+		return q.Value() / (1024 * 1024), nil
+	},
+	"cpuMillis": func(input interface{}) (int64, error) {
+		q, err := toQuantity(input)
+		if err != nil {
+			return 0, err
+		}
+		return q.MilliValue(), nil
+	},
+	// Render in a specific format, one of BinarySI, DecimalSI, or DecimalExponent.
+	"formatQty": func(format string, input interface{}) (string, error) {
+		q, err := toQuantity(input)
+		if err != nil {
+			return "", err
+		}
+		switch resource.Format(format) {
+		case resource.BinarySI, resource.DecimalSI, resource.DecimalExponent:
+		default:
+			return "", fmt.Errorf("formatQty: unknown format %s", format)
+		}
+		return resource.NewDecimalQuantity(*q.AsDec(), resource.Format(format)).String(), nil
+	},
+}
+
+func toQuantity(input interface{}) (*resource.Quantity, error) {
+	switch v := input.(type) {
+	case resource.Quantity:
+		q := v.DeepCopy()
+		return &q, nil
+	case *resource.Quantity:
+		if v == nil {
+			return nil, fmt.Errorf("quantity is nil")
+		}
+		q := v.DeepCopy()
+		return &q, nil
+	case string:
+		q, err := resource.ParseQuantity(v)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing quantity %q: %w", v, err)
+		}
+		return &q, nil
+	case int:
+		return resource.NewQuantity(int64(v), resource.DecimalSI), nil
+	case int32:
+		return resource.NewQuantity(int64(v), resource.DecimalSI), nil
+	case int64:
+		return resource.NewQuantity(v, resource.DecimalSI), nil
+	case float64:
+		return resource.NewMilliQuantity(int64(math.Round(v*1000)), resource.DecimalSI), nil
+	default:
+		return nil, fmt.Errorf("unable to convert %T to a quantity", input)
+	}
+}
+
+func combineQuantities(input interface{}, other interface{}, op func(*resource.Quantity, resource.Quantity)) (*resource.Quantity, error) {
+	q, err := toQuantity(input)
+	if err != nil {
+		return nil, err
+	}
+	o, err := toQuantity(other)
+	if err != nil {
+		return nil, err
+	}
+	op(q, *o)
+	return q, nil
+}
+
+func scaleQuantity(input interface{}, factor float64) (*resource.Quantity, error) {
+	q, err := toQuantity(input)
+	if err != nil {
+		return nil, err
+	}
+	// Work in millis so CPU values keep their precision, then drop back to
+	// whole units when possible so memory stays formatted as e.g. 768Mi.
+	millis := int64(math.Round(float64(q.MilliValue()) * factor))
+	if millis%1000 == 0 {
+		return resource.NewQuantity(millis/1000, q.Format), nil
+	}
+	return resource.NewMilliQuantity(millis, q.Format), nil
+}
@@ -0,0 +1,65 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package templates
+
+import (
+	"net/http"
+	"path"
+	"sync"
+)
+
+// A Renderer turns a template file into the YAML or JSON for an object.
+type Renderer interface {
+	Render(fs http.FileSystem, filename string, data interface{}, opts Options) ([]byte, error)
+}
+
+// The default renderer, using text/template with Sprig and our helpers.
+type GoTemplateRenderer struct{}
+
+var _ Renderer = &GoTemplateRenderer{}
+
+func (_ *GoTemplateRenderer) Render(fs http.FileSystem, filename string, data interface{}, opts Options) ([]byte, error) {
+	tmpl, err := parseTemplate(fs, filename, opts)
+	if err != nil {
+		return nil, err
+	}
+	return renderTemplate(tmpl, data)
+}
+
+var renderersLock sync.RWMutex
+var renderers = map[string]Renderer{
+	".tpl": &GoTemplateRenderer{},
+}
+
+// Use a different renderer for files with the given extension, including the
+// leading dot, e.g. ".jsonnet". Files with no registered extension use the
+// GoTemplateRenderer. Usually called from an init function.
+func RegisterRenderer(ext string, renderer Renderer) {
+	renderersLock.Lock()
+	defer renderersLock.Unlock()
+	renderers[ext] = renderer
+}
+
+func rendererFor(filename string) Renderer {
+	renderersLock.RLock()
+	defer renderersLock.RUnlock()
+	renderer, ok := renderers[path.Ext(filename)]
+	if !ok {
+		return &GoTemplateRenderer{}
+	}
+	return renderer
+}
@@ -0,0 +1,55 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package templates_test
+
+import (
+	"fmt"
+	"net/http"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/coderanger/controller-utils/templates"
+)
+
+type fakeRenderer struct{}
+
+func (_ *fakeRenderer) Render(_ http.FileSystem, filename string, data interface{}, _ templates.Options) ([]byte, error) {
+	return []byte(fmt.Sprintf(`{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": %q}, "data": {"value": %q}}`, filename, data)), nil
+}
+
+var _ = Describe("Renderer", func() {
+	BeforeEach(func() {
+		templates.RegisterRenderer(".fake", &fakeRenderer{})
+	})
+
+	It("uses a registered renderer by extension", func() {
+		rawObject, err := templates.Get(testTemplates, "test.fake", false, "hello")
+		Expect(err).ToNot(HaveOccurred())
+		cmap, ok := rawObject.(*corev1.ConfigMap)
+		Expect(ok).To(BeTrue())
+		Expect(cmap.Name).To(Equal("test.fake"))
+		Expect(cmap.Data).To(HaveKeyWithValue("value", "hello"))
+	})
+
+	It("uses go templates by default", func() {
+		rawObject, err := templates.Get(testTemplates, "test1.yml.tpl", true, struct{}{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(rawObject.GetName()).To(Equal("test"))
+	})
+})
@@ -19,45 +19,163 @@ package templates
 
 import (
 	"bytes"
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"net/http"
 	"path"
 	"reflect"
+	"strings"
 	"text/template"
 
 	"github.com/Masterminds/sprig"
-	"github.com/shurcooL/httpfs/path/vfspath"
 	"github.com/shurcooL/httpfs/vfsutil"
-	"gopkg.in/yaml.v2"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	utiljson "k8s.io/apimachinery/pkg/util/json"
 	"k8s.io/client-go/kubernetes/scheme"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
 )
 
-func parseTemplate(fs http.FileSystem, filename string) (*template.Template, error) {
-	if fs == nil {
-		return nil, errors.New("template filesystem not set")
+// Wrote this because if statements with pointers don't work how you'd think they would
+var customFuncMap = template.FuncMap{
+	"deref": func(input interface{}) interface{} {
+		val := reflect.ValueOf(input)
+		if val.IsNil() {
+			return nil
+		}
+		return val.Elem().Interface()
+	},
+	// Like the Sprig version but also accepts []byte, which is how secret
+	// values usually end up in ctx.Data.
+	"b64enc": func(input interface{}) string {
+		return base64.StdEncoding.EncodeToString(toBytes(input))
+	},
+	// Base64 encode every value in a map, for use in the data of a Secret.
+	// e.g. `data: {{ .Data.creds | b64encMap | toYaml | nindent 2 }}`.
+	"b64encMap": func(input interface{}) (map[string]string, error) {
+		val := reflect.ValueOf(input)
+		if val.Kind() != reflect.Map {
+			return nil, fmt.Errorf("b64encMap: expected a map, got %T", input)
+		}
+		result := map[string]string{}
+		iter := val.MapRange()
+		for iter.Next() {
+			result[fmt.Sprintf("%v", iter.Key().Interface())] = base64.StdEncoding.EncodeToString(toBytes(iter.Value().Interface()))
+		}
+		return result, nil
+	},
+	// Helm-compatible helpers, to make porting charts easier.
+	"toYaml": func(input interface{}) (string, error) {
+		out, err := yaml.Marshal(input)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSuffix(string(out), "\n"), nil
+	},
+	"fromYaml": func(input string) (map[string]interface{}, error) {
+		return parseYAMLMap([]byte(input))
+	},
+	"required": func(message string, input interface{}) (interface{}, error) {
+		if input == nil {
+			return nil, errors.New(message)
+		}
+		if str, ok := input.(string); ok && str == "" {
+			return nil, errors.New(message)
+		}
+		return input, nil
+	},
+}
+
+func toBytes(input interface{}) []byte {
+	switch v := input.(type) {
+	case []byte:
+		return v
+	case string:
+		return []byte(v)
+	case nil:
+		return []byte{}
+	default:
+		return []byte(fmt.Sprintf("%v", v))
 	}
+}
 
-	// Wrote this because if statements with pointers don't work how you'd think they would
-	customFuncMap := template.FuncMap{
-		"deref": func(input interface{}) interface{} {
-			val := reflect.ValueOf(input)
-			if val.IsNil() {
-				return nil
+// Helpers which need access to the template being rendered.
+func templateFuncMap(tmpl *template.Template) template.FuncMap {
+	return template.FuncMap{
+		"include": func(name string, data interface{}) (string, error) {
+			var buffer strings.Builder
+			err := tmpl.ExecuteTemplate(&buffer, name, data)
+			if err != nil {
+				return "", err
+			}
+			return buffer.String(), nil
+		},
+		"tpl": func(text string, data interface{}) (string, error) {
+			// Clone so any defines in the text don't leak into the main template.
+			clone, err := tmpl.Clone()
+			if err != nil {
+				return "", err
 			}
-			return val.Elem().Interface()
+			inline, err := clone.New("tpl").Parse(text)
+			if err != nil {
+				return "", err
+			}
+			var buffer strings.Builder
+			err = inline.Execute(&buffer, data)
+			if err != nil {
+				return "", err
+			}
+			return buffer.String(), nil
 		},
 	}
+}
+
+// Options for rendering templates.
+type Options struct {
+	// Extra template functions, these override the built-in ones with the same name.
+	Funcs template.FuncMap
+	// Globs for helper templates to load before the main template, which may
+	// use `**`. Defaults to helpers/**/*.tpl.
+	Helpers []string
+	// Scheme used to decode typed objects. Defaults to the client-go scheme,
+	// so pass the manager's scheme to decode custom types.
+	Scheme *runtime.Scheme
+}
+
+var defaultHelpers = []string{"helpers/**/*.tpl"}
+
+func newTemplate(name string, opts Options) *template.Template {
+	tmpl := template.New(name).Funcs(sprig.TxtFuncMap()).Funcs(customFuncMap).Funcs(quantityFuncMap)
+	tmpl = tmpl.Funcs(templateFuncMap(tmpl))
+	if opts.Funcs != nil {
+		tmpl = tmpl.Funcs(opts.Funcs)
+	}
+	return tmpl
+}
+
+func parseTemplate(fs http.FileSystem, filename string, opts Options) (*template.Template, error) {
+	if fs == nil {
+		return nil, errors.New("template filesystem not set")
+	}
 
 	// Create a template object.
-	tmpl := template.New(path.Base(filename)).Funcs(sprig.TxtFuncMap()).Funcs(customFuncMap)
+	tmpl := newTemplate(path.Base(filename), opts)
 
 	// Parse any helpers if present.
-	helpers, err := vfspath.Glob(fs, "helpers/*.tpl")
-	if err != nil {
-		return nil, err
+	helperGlobs := opts.Helpers
+	if helperGlobs == nil {
+		helperGlobs = defaultHelpers
+	}
+	helpers := []string{}
+	for _, glob := range helperGlobs {
+		matches, err := globFiles(fs, glob)
+		if err != nil {
+			return nil, err
+		}
+		helpers = append(helpers, matches...)
 	}
 	for _, helperFilename := range helpers {
 		fileBytes, err := vfsutil.ReadFile(fs, helperFilename)
@@ -97,8 +215,12 @@ func renderTemplate(tmpl *template.Template, data interface{}) ([]byte, error) {
 
 // Parse the rendered data into an object. The caller has to cast it from a
 // core.Object into the correct type.
-func parseObject(rawObject []byte) (client.Object, error) {
-	obj, _, err := scheme.Codecs.UniversalDeserializer().Decode(rawObject, nil, nil)
+func parseObject(rawObject []byte, s *runtime.Scheme) (client.Object, error) {
+	codecs := scheme.Codecs
+	if s != nil {
+		codecs = serializer.NewCodecFactory(s)
+	}
+	obj, _, err := codecs.UniversalDeserializer().Decode(rawObject, nil, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -109,53 +231,47 @@ func parseObject(rawObject []byte) (client.Object, error) {
 	return coreObj, nil
 }
 
-func castArray(in []interface{}) []interface{} {
-	result := make([]interface{}, len(in))
-	for i, v := range in {
-		result[i] = castValue(v)
+// Parse YAML the same way the API server would, via JSON so that numbers
+// come out as int64 or float64 like in any other Unstructured.
+func parseYAMLMap(raw []byte) (map[string]interface{}, error) {
+	jsonData, err := yaml.YAMLToJSON(raw)
+	if err != nil {
+		return nil, err
 	}
-	return result
-}
-
-func castMap(in map[interface{}]interface{}) map[string]interface{} {
-	result := make(map[string]interface{})
-	for k, v := range in {
-		kString, ok := k.(string)
-		if !ok {
-			kString = fmt.Sprintf("%v", k)
-		}
-		result[kString] = castValue(v)
+	data := map[string]interface{}{}
+	if bytes.Equal(bytes.TrimSpace(jsonData), []byte("null")) {
+		// Empty document.
+		return data, nil
 	}
-	return result
-}
-
-func castValue(v interface{}) interface{} {
-	switch v := v.(type) {
-	case []interface{}:
-		return castArray(v)
-	case map[interface{}]interface{}:
-		return castMap(v)
-	default:
-		return v
+	err = utiljson.Unmarshal(jsonData, &data)
+	if err != nil {
+		return nil, err
 	}
+	return data, nil
 }
 
 // Parse the rendered data into an Unstructured.
 func parseUnstructured(rawObject []byte) (client.Object, error) {
-	data := map[interface{}]interface{}{}
-	err := yaml.Unmarshal(rawObject, data)
+	data, err := parseYAMLMap(rawObject)
 	if err != nil {
 		return nil, err
 	}
-	return &unstructured.Unstructured{Object: castMap(data)}, nil
+	return &unstructured.Unstructured{Object: data}, nil
 }
 
 func Get(fs http.FileSystem, filename string, unstructured bool, data interface{}) (client.Object, error) {
-	tmpl, err := parseTemplate(fs, filename)
-	if err != nil {
-		return nil, err
-	}
-	out, err := renderTemplate(tmpl, data)
+	return GetWithOptions(fs, filename, unstructured, data, Options{})
+}
+
+// Render a template file without parsing the result, for things that aren't
+// a single object like patches.
+func RenderFile(fs http.FileSystem, filename string, data interface{}, opts Options) ([]byte, error) {
+	return rendererFor(filename).Render(fs, filename, data, opts)
+}
+
+// Get with extra options, like custom template functions.
+func GetWithOptions(fs http.FileSystem, filename string, unstructured bool, data interface{}, opts Options) (client.Object, error) {
+	out, err := RenderFile(fs, filename, data, opts)
 	if err != nil {
 		return nil, err
 	}
@@ -163,10 +279,29 @@ func Get(fs http.FileSystem, filename string, unstructured bool, data interface{
 	if unstructured {
 		obj, err = parseUnstructured(out)
 	} else {
-		obj, err = parseObject(out)
+		obj, err = parseObject(out, opts.Scheme)
 	}
 	if err != nil {
 		return nil, err
 	}
 	return obj, nil
 }
+
+// Render a single inline template string, for small things like names rather
+// than whole objects. Helpers are not available.
+func RenderString(text string, data interface{}) (string, error) {
+	return RenderStringWithOptions(text, data, Options{})
+}
+
+// RenderString with extra options, like custom template functions.
+func RenderStringWithOptions(text string, data interface{}, opts Options) (string, error) {
+	tmpl, err := newTemplate("inline", opts).Parse(text)
+	if err != nil {
+		return "", err
+	}
+	out, err := renderTemplate(tmpl, data)
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
@@ -19,12 +19,18 @@ package templates_test
 
 import (
 	"net/http"
+	"strings"
+	"text/template"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	. "github.com/onsi/gomega/gstruct"
 	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 
 	"github.com/coderanger/controller-utils/templates"
 )
@@ -87,4 +93,222 @@ var _ = Describe("Templates", func() {
 			Expect(obj.UnstructuredContent()).To(HaveLen(0))
 		})
 	})
+
+	Context("custom functions", func() {
+		opts := templates.Options{Funcs: template.FuncMap{
+			"shout": strings.ToUpper,
+		}}
+
+		It("should render the Deployment", func() {
+			rawObject, err := templates.GetWithOptions(testTemplates, "custom_funcs.yml.tpl", false, struct{ Name string }{Name: "quatro"}, opts)
+			Expect(err).ToNot(HaveOccurred())
+			deployment, ok := rawObject.(*appsv1.Deployment)
+			Expect(ok).To(BeTrue())
+			Expect(deployment.Name).To(Equal("test-quatro"))
+			Expect(deployment.Labels).To(HaveKeyWithValue("app", "QUATRO"))
+		})
+
+		It("should render an inline template", func() {
+			out, err := templates.RenderStringWithOptions("{{ shout .Name }}", struct{ Name string }{Name: "cinco"}, opts)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(out).To(Equal("CINCO"))
+		})
+
+		It("should fail without the functions", func() {
+			_, err := templates.RenderString("{{ shout .Name }}", struct{ Name string }{Name: "cinco"})
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("helm helpers", func() {
+		data := map[string]interface{}{
+			"Name":   "test",
+			"Labels": map[string]interface{}{"app": "test", "tier": "web"},
+			"Greet":  "hello {{ .Name }}",
+		}
+
+		It("supports include", func() {
+			out, err := templates.RenderString(`{{ define "name" }}{{ .Name }}-app{{ end }}{{ include "name" . | upper }}`, data)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(out).To(Equal("TEST-APP"))
+		})
+
+		It("supports nested include", func() {
+			out, err := templates.RenderString(`{{ define "inner" }}{{ .Name }}{{ end }}{{ define "outer" }}[{{ include "inner" . }}]{{ end }}{{ include "outer" . }}`, data)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(out).To(Equal("[test]"))
+		})
+
+		It("supports toYaml", func() {
+			out, err := templates.RenderString(`{{ toYaml .Labels | indent 2 }}`, data)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(out).To(Equal("  app: test\n  tier: web"))
+		})
+
+		It("supports fromYaml", func() {
+			out, err := templates.RenderString(`{{ (fromYaml "a:\n  b: c").a.b }}`, data)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(out).To(Equal("c"))
+		})
+
+		It("supports required", func() {
+			out, err := templates.RenderString(`{{ required "name is required" .Name }}`, data)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(out).To(Equal("test"))
+			_, err = templates.RenderString(`{{ required "other is required" .Other }}`, data)
+			Expect(err).To(MatchError(ContainSubstring("other is required")))
+		})
+
+		It("supports tpl", func() {
+			out, err := templates.RenderString(`{{ tpl .Greet . }}`, data)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(out).To(Equal("hello test"))
+		})
+	})
+
+	Context("secret helpers", func() {
+		data := map[string]interface{}{
+			"Password": []byte("hunter2"),
+			"Creds":    map[string][]byte{"user": []byte("admin")},
+		}
+
+		It("supports b64enc with bytes", func() {
+			out, err := templates.RenderString(`{{ .Password | b64enc }}`, data)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(out).To(Equal("aHVudGVyMg=="))
+		})
+
+		It("supports b64enc with strings", func() {
+			out, err := templates.RenderString(`{{ "hunter2" | b64enc }}`, data)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(out).To(Equal("aHVudGVyMg=="))
+		})
+
+		It("supports b64encMap", func() {
+			out, err := templates.RenderString(`{{ .Creds | b64encMap | toYaml }}`, data)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(out).To(Equal("user: YWRtaW4="))
+		})
+	})
+
+	Context("quantity helpers", func() {
+		data := map[string]interface{}{
+			"Memory": resource.MustParse("1Gi"),
+			"CPU":    resource.MustParse("500m"),
+		}
+
+		It("computes a heap size from a memory limit", func() {
+			out, err := templates.RenderString(`{{ .Memory | mulQty 0.75 | memoryMiB }}m`, data)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(out).To(Equal("768m"))
+		})
+
+		It("keeps the quantity format", func() {
+			out, err := templates.RenderString(`{{ .Memory | subQty "256Mi" }} {{ .Memory | divQty 4 }} {{ .CPU | mulQty 3 }}`, data)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(out).To(Equal("768Mi 256Mi 1500m"))
+		})
+
+		It("parses and formats quantities", func() {
+			out, err := templates.RenderString(`{{ parseQty "1500m" | addQty .CPU | cpuMillis }} {{ "2G" | memoryBytes }} {{ .Memory | formatQty "DecimalSI" }}`, data)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(out).To(Equal("2000 2000000000 1073741824"))
+		})
+
+		It("rejects invalid quantities", func() {
+			_, err := templates.RenderString(`{{ "lots" | memoryMiB }}`, data)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("nested directories", func() {
+		It("should render with nested helpers", func() {
+			rawObject, err := templates.Get(testTemplates, "nested/deep/configmap.yml.tpl", true, struct{ Name string }{Name: "deep"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(rawObject.GetName()).To(Equal("nested-deep"))
+		})
+	})
+
+	Context("custom helper locations", func() {
+		data := struct{ Name string }{Name: "deep"}
+
+		It("loads helpers from the given globs", func() {
+			opts := templates.Options{Helpers: []string{"helpers/nested/*.tpl"}}
+			rawObject, err := templates.GetWithOptions(testTemplates, "nested/deep/configmap.yml.tpl", true, data, opts)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(rawObject.GetName()).To(Equal("nested-deep"))
+			_, err = templates.GetWithOptions(testTemplates, "test2.yml.tpl", true, data, opts)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("loads helpers from multiple globs", func() {
+			opts := templates.Options{Helpers: []string{"helpers/*.tpl", "helpers/nested/*.tpl"}}
+			rawObject, err := templates.GetWithOptions(testTemplates, "nested/deep/configmap.yml.tpl", true, data, opts)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(rawObject.GetName()).To(Equal("nested-deep"))
+			rawObject, err = templates.GetWithOptions(testTemplates, "test2.yml.tpl", true, data, opts)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(rawObject.GetName()).To(Equal("test-two"))
+		})
+
+		It("can disable helpers", func() {
+			opts := templates.Options{Helpers: []string{}}
+			_, err := templates.GetWithOptions(testTemplates, "nested/deep/configmap.yml.tpl", true, data, opts)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("unstructured parsing", func() {
+		It("uses Kubernetes-compatible types", func() {
+			rawObject, err := templates.Get(testTemplates, "test1.yml.tpl", true, struct{}{})
+			Expect(err).ToNot(HaveOccurred())
+			obj := rawObject.(*unstructured.Unstructured)
+			replicas, ok, err := unstructured.NestedFieldNoCopy(obj.Object, "spec", "replicas")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ok).To(BeTrue())
+			Expect(replicas).To(Equal(int64(1)))
+		})
+
+		It("handles fromYaml types and merge keys", func() {
+			out, err := templates.RenderString(`{{ $v := fromYaml "base: &base\n  a: 1\n  b: true\nmerged:\n  <<: *base\n  c: \"2\"\n  d: 1.5" }}{{ printf "%T %T %T %T" $v.merged.a $v.merged.b $v.merged.c $v.merged.d }}`, nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(out).To(Equal("int64 bool string float64"))
+		})
+	})
+
+	Context("a custom scheme", func() {
+		validateTemplates := http.Dir("test_validate")
+		gv := schema.GroupVersion{Group: "example.com", Version: "v1"}
+		customScheme := runtime.NewScheme()
+		customScheme.AddKnownTypeWithName(gv.WithKind("Widget"), &testWidget{})
+		metav1.AddToGroupVersion(customScheme, gv)
+
+		It("decodes custom types", func() {
+			rawObject, err := templates.GetWithOptions(validateTemplates, "custom.yml", false, map[string]interface{}{"Name": "one"}, templates.Options{Scheme: customScheme})
+			Expect(err).ToNot(HaveOccurred())
+			widget, ok := rawObject.(*testWidget)
+			Expect(ok).To(BeTrue())
+			Expect(widget.Name).To(Equal("test-one"))
+			Expect(widget.Spec.Anything).To(Equal("goes"))
+		})
+
+		It("fails without the scheme", func() {
+			_, err := templates.Get(validateTemplates, "custom.yml", false, map[string]interface{}{"Name": "one"})
+			Expect(err).To(HaveOccurred())
+		})
+	})
 })
+
+type testWidget struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              struct {
+		Anything string `json:"anything"`
+	} `json:"spec"`
+}
+
+func (w *testWidget) DeepCopyObject() runtime.Object {
+	out := *w
+	w.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	return &out
+}
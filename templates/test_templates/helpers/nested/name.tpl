@@ -0,0 +1 @@
+{{ define "nestedName" }}nested-{{ .Name }}{{ end }}
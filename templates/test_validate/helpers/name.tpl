@@ -0,0 +1 @@
+{{ define "name" }}test-{{ .Name }}{{ end }}
@@ -0,0 +1,157 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package templates
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/shurcooL/httpfs/vfsutil"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+// A problem found with one template when rendered with one sample.
+type ValidationError struct {
+	Filename string
+	// Index of the sample data which triggered the error.
+	Sample int
+	Err    error
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%s (sample %d): %s", e.Filename, e.Sample, e.Err)
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// All problems found by ValidateAll.
+type ValidationErrors []*ValidationError
+
+func (errs ValidationErrors) Error() string {
+	msgs := make([]string, len(errs))
+	for i, err := range errs {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// Options for ValidateAll.
+type ValidateOptions struct {
+	// Objects with a type known to the Scheme are checked for unknown or
+	// mistyped fields.
+	Options
+	// Globs of paths to skip, matched against the path without the leading
+	// slash. Anything matching the helper globs is always skipped.
+	Exclude []string
+}
+
+// Render every template in a filesystem with each of the sample data values
+// and check that the result is a valid object, for use in CI so broken
+// templates are caught before they reach a cluster. Returns nil if all
+// templates are valid.
+func ValidateAll(fs http.FileSystem, samples []interface{}) error {
+	return ValidateAllWithOptions(fs, samples, ValidateOptions{})
+}
+
+// ValidateAll with extra options.
+func ValidateAllWithOptions(fs http.FileSystem, samples []interface{}, opts ValidateOptions) error {
+	errs := ValidationErrors{}
+	err := vfsutil.Walk(fs, "/", func(filename string, fi os.FileInfo, err error) error {
+		if err != nil {
+			errs = append(errs, &ValidationError{Filename: filename, Err: err})
+			return nil
+		}
+		if fi.IsDir() {
+			return nil
+		}
+		name := strings.TrimPrefix(filename, "/")
+		helpers := opts.Helpers
+		if helpers == nil {
+			helpers = defaultHelpers
+		}
+		skip := append(append([]string{}, helpers...), opts.Exclude...)
+		for _, glob := range skip {
+			matched, err := matchGlob(glob, name)
+			if err != nil {
+				return err
+			}
+			if matched {
+				return nil
+			}
+		}
+		for i, data := range samples {
+			err := validateTemplate(fs, filename, data, opts)
+			if err != nil {
+				errs = append(errs, &ValidationError{Filename: name, Sample: i, Err: err})
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+func validateTemplate(fs http.FileSystem, filename string, data interface{}, opts ValidateOptions) error {
+	obj, err := GetWithOptions(fs, filename, true, data, opts.Options)
+	if err != nil {
+		return err
+	}
+	u := obj.(*unstructured.Unstructured)
+	if len(u.Object) == 0 {
+		// Empty templates are allowed, they render nothing.
+		return nil
+	}
+	if u.GetAPIVersion() == "" || u.GetKind() == "" {
+		return fmt.Errorf("apiVersion and kind are required")
+	}
+	if u.GetName() == "" && u.GetGenerateName() == "" {
+		return fmt.Errorf("metadata.name is required")
+	}
+
+	s := opts.Scheme
+	if s == nil {
+		s = scheme.Scheme
+	}
+	typed, err := s.New(u.GroupVersionKind())
+	if err != nil {
+		// Not a type we know about, nothing else to check.
+		return nil
+	}
+	raw, err := u.MarshalJSON()
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.DisallowUnknownFields()
+	err = decoder.Decode(typed)
+	if err != nil {
+		return fmt.Errorf("invalid %s: %w", u.GetKind(), err)
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package templates_test
+
+import (
+	"net/http"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/coderanger/controller-utils/templates"
+)
+
+var _ = Describe("ValidateAll", func() {
+	validateTemplates := http.Dir("test_validate")
+	samples := []interface{}{
+		map[string]interface{}{"Name": "one"},
+		map[string]interface{}{"Name": "two", "Other": "other"},
+	}
+
+	It("reports broken templates", func() {
+		err := templates.ValidateAll(validateTemplates, samples)
+		Expect(err).To(HaveOccurred())
+		errs, ok := err.(templates.ValidationErrors)
+		Expect(ok).To(BeTrue())
+		Expect(errs).To(HaveLen(3))
+		Expect(errs[0].Filename).To(Equal("missing_name.yml"))
+		Expect(errs[0].Sample).To(Equal(0))
+		Expect(errs[0].Err).To(MatchError(ContainSubstring("metadata.name is required")))
+		Expect(errs[1].Filename).To(Equal("unknown_field.yml"))
+		Expect(errs[1].Sample).To(Equal(0))
+		Expect(errs[1].Err).To(MatchError(ContainSubstring("datas")))
+		Expect(errs[2].Filename).To(Equal("unknown_field.yml"))
+		Expect(errs[2].Sample).To(Equal(1))
+	})
+
+	It("skips excluded files", func() {
+		err := templates.ValidateAllWithOptions(validateTemplates, samples, templates.ValidateOptions{
+			Exclude: []string{"missing_*", "unknown_*"},
+		})
+		Expect(err).ToNot(HaveOccurred())
+	})
+})
@@ -0,0 +1,96 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tests
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// The fake client doesn't support server-side apply, so this emulates it
+// well enough for unit tests. An apply creates the object if it doesn't
+// exist, otherwise it is sent as a JSON merge patch. Unlike the real thing,
+// fields dropped from the applied object are not removed and there is no
+// conflict detection.
+type applyClient struct {
+	client.Client
+}
+
+type applyStatusWriter struct {
+	client.StatusWriter
+	client client.Client
+}
+
+func newApplyClient(c client.Client) client.Client {
+	return &applyClient{Client: c}
+}
+
+func (c *applyClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	if patch.Type() != types.ApplyPatchType {
+		return c.Client.Patch(ctx, obj, patch, opts...)
+	}
+	data, err := patch.Data(obj)
+	if err != nil {
+		return errors.Wrap(err, "error getting apply patch data")
+	}
+
+	existing := obj.DeepCopyObject().(client.Object)
+	err = c.Client.Get(ctx, client.ObjectKeyFromObject(obj), existing)
+	if kerrors.IsNotFound(err) {
+		obj.SetResourceVersion("")
+		return c.Client.Create(ctx, obj, applyCreateOptions(opts)...)
+	} else if err != nil {
+		return err
+	}
+	return c.Client.Patch(ctx, obj, client.RawPatch(types.MergePatchType, data), applyPatchOptions(opts)...)
+}
+
+func (c *applyClient) Status() client.StatusWriter {
+	return &applyStatusWriter{StatusWriter: c.Client.Status(), client: c.Client}
+}
+
+func (w *applyStatusWriter) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	if patch.Type() != types.ApplyPatchType {
+		return w.StatusWriter.Patch(ctx, obj, patch, opts...)
+	}
+	data, err := patch.Data(obj)
+	if err != nil {
+		return errors.Wrap(err, "error getting apply patch data")
+	}
+	return w.StatusWriter.Patch(ctx, obj, client.RawPatch(types.MergePatchType, data), applyPatchOptions(opts)...)
+}
+
+// Keep only the options that mean something without apply.
+func applyPatchOptions(opts []client.PatchOption) []client.PatchOption {
+	patchOpts := &client.PatchOptions{}
+	patchOpts.ApplyOptions(opts)
+	if len(patchOpts.DryRun) == 0 {
+		return nil
+	}
+	return []client.PatchOption{client.DryRunAll}
+}
+
+func applyCreateOptions(opts []client.PatchOption) []client.CreateOption {
+	if len(applyPatchOptions(opts)) == 0 {
+		return nil
+	}
+	return []client.CreateOption{client.DryRunAll}
+}
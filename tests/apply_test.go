@@ -0,0 +1,82 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tests_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/coderanger/controller-utils/tests"
+)
+
+var _ = Describe("UnitHelper apply emulation", func() {
+	var uh *tests.UnitHelper
+	ctx := context.Background()
+
+	BeforeEach(func() {
+		uh = tests.Unit().MustBuild().SetupComponents(&corev1.ConfigMap{})
+	})
+
+	apply := func(data map[string]string, opts ...client.PatchOption) error {
+		configMap := &corev1.ConfigMap{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"},
+			ObjectMeta: metav1.ObjectMeta{Name: "applied", Namespace: "default"},
+			Data:       data,
+		}
+		opts = append(opts, client.ForceOwnership, client.FieldOwner("unit-tests"))
+		return uh.Client.Patch(ctx, configMap, client.Apply, opts...)
+	}
+
+	It("creates a missing object", func() {
+		Expect(apply(map[string]string{"key": "one"})).To(Succeed())
+		configMap := &corev1.ConfigMap{}
+		uh.TestClient.GetName("applied", configMap)
+		Expect(configMap.Data).To(Equal(map[string]string{"key": "one"}))
+	})
+
+	It("merges into an existing object", func() {
+		Expect(apply(map[string]string{"key": "one", "other": "one"})).To(Succeed())
+		Expect(apply(map[string]string{"key": "two"})).To(Succeed())
+		configMap := &corev1.ConfigMap{}
+		uh.TestClient.GetName("applied", configMap)
+		// Fields dropped from the applied object are kept, unlike a real apply.
+		Expect(configMap.Data).To(Equal(map[string]string{"key": "two", "other": "one"}))
+	})
+
+	It("doesn't write on a dry run", func() {
+		Expect(apply(map[string]string{"key": "one"}, client.DryRunAll)).To(Succeed())
+		err := uh.Client.Get(ctx, client.ObjectKey{Name: "applied", Namespace: "default"}, &corev1.ConfigMap{})
+		Expect(kerrors.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("passes other patches through", func() {
+		Expect(apply(map[string]string{"key": "one"})).To(Succeed())
+		configMap := &corev1.ConfigMap{}
+		uh.TestClient.GetName("applied", configMap)
+		clean := configMap.DeepCopy()
+		configMap.Data["key"] = "two"
+		Expect(uh.Client.Patch(ctx, configMap, client.MergeFrom(clean))).To(Succeed())
+		uh.TestClient.GetName("applied", configMap)
+		Expect(configMap.Data).To(HaveKeyWithValue("key", "two"))
+	})
+})
@@ -19,35 +19,44 @@ package tests
 
 import (
 	"context"
-	"reflect"
 	"time"
 
 	"github.com/onsi/gomega"
 	gtypes "github.com/onsi/gomega/types"
 	"github.com/pkg/errors"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/util/retry"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
 )
 
 // The default timeout for EventuallyGet().
 var DefaultTimeout = 30 * time.Second
 
+// The default duration for ConsistentlyGet().
+var DefaultConsistentlyDuration = 2 * time.Second
+
 // Implementation to match controller-runtime's client.Client interface.
 type testClient struct {
 	client    client.Client
 	namespace string
+	// Only set in functional tests, used by As.
+	config *rest.Config
 }
 
 type testStatusClient struct {
 	client    client.StatusWriter
+	reader    client.Reader
 	namespace string
 }
 
 func defaultNamespace(obj client.Object, namespace string) {
-	metaobj := obj.(metav1.Object)
-	if namespace != "" && metaobj.GetNamespace() == "" {
-		metaobj.SetNamespace(namespace)
+	if namespace != "" && obj.GetNamespace() == "" {
+		obj.SetNamespace(namespace)
 	}
 }
 
@@ -99,9 +108,48 @@ func (c *testClient) Patch(obj client.Object, patch client.Patch, opts ...client
 	gomega.ExpectWithOffset(1, err).ToNot(gomega.HaveOccurred())
 }
 
+// Re-fetch the object, apply the mutation, and update it, retrying on
+// conflicts with the controller writing concurrently. The mutation must be
+// safe to run more than once.
+func (c *testClient) UpdateWithRetry(obj client.Object, mutate func()) {
+	defaultNamespace(obj, c.namespace)
+	err := updateWithRetry(c.client, obj, mutate, func() error {
+		return c.client.Update(context.Background(), obj)
+	})
+	gomega.ExpectWithOffset(1, err).ToNot(gomega.HaveOccurred())
+}
+
+func updateWithRetry(reader client.Reader, obj client.Object, mutate func(), update func() error) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		err := reader.Get(context.Background(), client.ObjectKeyFromObject(obj), obj)
+		if err != nil {
+			return err
+		}
+		mutate()
+		return update()
+	})
+}
+
+// Server-side apply the object as the given field manager, to simulate
+// another controller co-managing it. Ownership is forced, as most controllers
+// do, so use Patch with client.Apply directly to test conflicts. The
+// resourceVersion and managedFields are cleared so a previously fetched
+// object can be reused.
+func (c *testClient) Apply(obj client.Object, fieldManager string, opts ...client.PatchOption) {
+	defaultNamespace(obj, c.namespace)
+	gvk, err := apiutil.GVKForObject(obj, c.client.Scheme())
+	gomega.ExpectWithOffset(1, err).ToNot(gomega.HaveOccurred())
+	obj.GetObjectKind().SetGroupVersionKind(gvk)
+	obj.SetResourceVersion("")
+	obj.SetManagedFields(nil)
+	opts = append([]client.PatchOption{client.FieldOwner(fieldManager), client.ForceOwnership}, opts...)
+	err = c.client.Patch(context.Background(), obj, client.Apply, opts...)
+	gomega.ExpectWithOffset(1, err).ToNot(gomega.HaveOccurred())
+}
+
 // Implementation to match StatusClient.
 func (c *testClient) Status() *testStatusClient {
-	return &testStatusClient{client: c.client.Status(), namespace: c.namespace}
+	return &testStatusClient{client: c.client.Status(), reader: c.client, namespace: c.namespace}
 }
 
 func (c *testStatusClient) Update(obj client.Object) {
@@ -110,6 +158,15 @@ func (c *testStatusClient) Update(obj client.Object) {
 	gomega.ExpectWithOffset(1, err).ToNot(gomega.HaveOccurred())
 }
 
+// Like testClient.UpdateWithRetry but for the status subresource.
+func (c *testStatusClient) UpdateWithRetry(obj client.Object, mutate func()) {
+	defaultNamespace(obj, c.namespace)
+	err := updateWithRetry(c.reader, obj, mutate, func() error {
+		return c.client.Update(context.Background(), obj)
+	})
+	gomega.ExpectWithOffset(1, err).ToNot(gomega.HaveOccurred())
+}
+
 func (c *testStatusClient) Patch(obj client.Object, patch client.Patch, opts ...client.PatchOption) {
 	defaultNamespace(obj, c.namespace)
 	err := c.client.Patch(context.Background(), obj, patch, opts...)
@@ -118,13 +175,15 @@ func (c *testStatusClient) Patch(obj client.Object, patch client.Patch, opts ...
 
 // Flexible helper, mostly used for waiting for an object to be available.
 type eventuallyGetOptions struct {
-	timeout     time.Duration
-	valueGetter EventuallyGetValueGetter
-	matcher     gtypes.GomegaMatcher
+	timeout         time.Duration
+	valueGetter     EventuallyGetValueGetter
+	listValueGetter EventuallyListValueGetter
+	matcher         gtypes.GomegaMatcher
 }
 
 type eventuallyGetOptionsSetter func(*eventuallyGetOptions)
 type EventuallyGetValueGetter func(client.Object) (interface{}, error)
+type EventuallyListValueGetter func(client.ObjectList) (interface{}, error)
 
 // Set the timeout to a non-default value for EventuallyGet().
 func (_ *testClient) EventuallyTimeout(timeout time.Duration) eventuallyGetOptionsSetter {
@@ -141,22 +200,49 @@ func (_ *testClient) EventuallyValue(matcher gtypes.GomegaMatcher, getter Eventu
 	}
 }
 
-// A common case of a value getter for status conditions.
+// Set a value getter for EventuallyList(), to poll until the requested value matches.
+func (_ *testClient) EventuallyListValue(matcher gtypes.GomegaMatcher, getter EventuallyListValueGetter) eventuallyGetOptionsSetter {
+	return func(o *eventuallyGetOptions) {
+		o.matcher = matcher
+		o.listValueGetter = getter
+	}
+}
+
+// A common case of a list value getter, matching against the list items
+// e.g. `c.EventuallyItems(HaveLen(3))`.
+func (c *testClient) EventuallyItems(matcher gtypes.GomegaMatcher) eventuallyGetOptionsSetter {
+	return c.EventuallyListValue(matcher, func(list client.ObjectList) (interface{}, error) {
+		return meta.ExtractList(list)
+	})
+}
+
+// A common case of a value getter for status conditions. Works with any
+// object that has status.conditions, including metav1.Condition.
 func (c *testClient) EventuallyCondition(conditionType string, status string) eventuallyGetOptionsSetter {
 	return c.EventuallyValue(gomega.Equal(status), func(obj client.Object) (interface{}, error) {
-		// Yes using reflect is kind of gross but it's test-only code so meh. Some day this can be a bit less reflect-y and use metav1.Condition, when everything is on that.
-		conditions := reflect.ValueOf(obj).Elem().FieldByName("Status").FieldByName("Conditions")
-		count := conditions.Len()
-		for i := 0; i < count; i++ {
-			cond := conditions.Index(i)
-			if cond.FieldByName("Type").String() == conditionType {
-				return cond.FieldByName("Status").String(), nil
-			}
-		}
-		return nil, errors.Errorf("Condition type %s not found", conditionType)
+		return getConditionStatus(obj, conditionType)
 	})
 }
 
+func getConditionStatus(obj client.Object, conditionType string) (string, error) {
+	data, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return "", errors.Wrap(err, "error converting object to unstructured")
+	}
+	conditions, _, err := unstructured.NestedSlice(data, "status", "conditions")
+	if err != nil {
+		return "", errors.Wrap(err, "error reading status conditions")
+	}
+	for _, cond := range conditions {
+		condMap, ok := cond.(map[string]interface{})
+		if ok && condMap["type"] == conditionType {
+			status, _ := condMap["status"].(string)
+			return status, nil
+		}
+	}
+	return "", errors.Errorf("Condition type %s not found", conditionType)
+}
+
 // Even more common case of Ready and True.
 func (c *testClient) EventuallyReady() eventuallyGetOptionsSetter {
 	return c.EventuallyCondition("Ready", "True")
@@ -198,3 +284,82 @@ func (c *testClient) EventuallyGet(key client.ObjectKey, obj client.Object, optS
 func (c *testClient) EventuallyGetName(name string, obj client.Object, optSetters ...eventuallyGetOptionsSetter) {
 	c.eventuallyGet(types.NamespacedName{Name: name}, obj, optSetters...)
 }
+
+// Implementation used by ConsistentlyGet and ConsistentlyGetName, to keep the stack depth the same.
+func (c *testClient) consistentlyGet(key client.ObjectKey, obj client.Object, optSetters ...eventuallyGetOptionsSetter) {
+	if c.namespace != "" && key.Namespace == "" {
+		key.Namespace = c.namespace
+	}
+	opts := eventuallyGetOptions{timeout: DefaultConsistentlyDuration}
+	for _, optSetter := range optSetters {
+		optSetter(&opts)
+	}
+
+	if opts.valueGetter != nil {
+		gomega.ConsistentlyWithOffset(2, func() (interface{}, error) {
+			var value interface{}
+			err := c.client.Get(context.Background(), key, obj)
+			if err == nil {
+				value, err = opts.valueGetter(obj)
+			}
+			return value, err
+		}, opts.timeout).Should(opts.matcher)
+	} else {
+		gomega.ConsistentlyWithOffset(2, func() error {
+			err := c.client.Get(context.Background(), key, obj)
+			return err
+		}, opts.timeout).Should(gomega.Succeed())
+	}
+}
+
+// Like EventuallyGet but checks that the object exists, and the value matches
+// if one is given, for the whole duration. Used to show something does not
+// change, e.g. that an unowned object is left alone. EventuallyTimeout sets
+// the duration.
+func (c *testClient) ConsistentlyGet(key client.ObjectKey, obj client.Object, optSetters ...eventuallyGetOptionsSetter) {
+	c.consistentlyGet(key, obj, optSetters...)
+}
+
+// ConsistentlyGet but taking just a name.
+func (c *testClient) ConsistentlyGetName(name string, obj client.Object, optSetters ...eventuallyGetOptionsSetter) {
+	c.consistentlyGet(types.NamespacedName{Name: name}, obj, optSetters...)
+}
+
+// Like a normal List but run in a loop until the list value matches, for
+// waiting on a set of objects to converge. Options can be a mix of
+// EventuallyTimeout, EventuallyListValue or EventuallyItems, and
+// client.ListOptions. The list is limited to the test namespace unless
+// another namespace is given.
+func (c *testClient) EventuallyList(list client.ObjectList, opts ...interface{}) {
+	getOpts := eventuallyGetOptions{timeout: DefaultTimeout}
+	listOpts := []client.ListOption{}
+	if c.namespace != "" {
+		listOpts = append(listOpts, client.InNamespace(c.namespace))
+	}
+	for _, opt := range opts {
+		switch o := opt.(type) {
+		case eventuallyGetOptionsSetter:
+			o(&getOpts)
+		case client.ListOption:
+			listOpts = append(listOpts, o)
+		default:
+			gomega.ExpectWithOffset(1, opt).To(gomega.BeNil(), "EventuallyList options must be EventuallyGet options or client.ListOptions")
+		}
+	}
+	gomega.ExpectWithOffset(1, getOpts.valueGetter).To(gomega.BeNil(), "Use EventuallyListValue rather than EventuallyValue with EventuallyList")
+
+	if getOpts.listValueGetter != nil {
+		gomega.EventuallyWithOffset(1, func() (interface{}, error) {
+			var value interface{}
+			err := c.client.List(context.Background(), list, listOpts...)
+			if err == nil {
+				value, err = getOpts.listValueGetter(list)
+			}
+			return value, err
+		}, getOpts.timeout).Should(getOpts.matcher)
+	} else {
+		gomega.EventuallyWithOffset(1, func() error {
+			return c.client.List(context.Background(), list, listOpts...)
+		}, getOpts.timeout).Should(gomega.Succeed())
+	}
+}
@@ -0,0 +1,79 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tests
+
+import (
+	"reflect"
+
+	"github.com/pkg/errors"
+	apiextv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-tools/pkg/crd"
+	"sigs.k8s.io/controller-tools/pkg/loader"
+	"sigs.k8s.io/controller-tools/pkg/markers"
+)
+
+// Generate CRDs at suite start for every kind in the Go packages defining
+// the given types, instead of loading pre-generated YAML. This parses the
+// package source the same way controller-gen does, so the types must be
+// buildable from the test's working directory.
+func (b *functionalBuilder) GenerateCRDs(types ...runtime.Object) *functionalBuilder {
+	for _, obj := range types {
+		t := reflect.TypeOf(obj)
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		b.crdPackages = append(b.crdPackages, t.PkgPath())
+	}
+	return b
+}
+
+func generateCRDs(pkgPaths ...string) ([]*apiextv1.CustomResourceDefinition, error) {
+	roots, err := loader.LoadRoots(pkgPaths...)
+	if err != nil {
+		return nil, errors.Wrap(err, "error loading API packages")
+	}
+	registry := &markers.Registry{}
+	err = crd.Generator{}.RegisterMarkers(registry)
+	if err != nil {
+		return nil, errors.Wrap(err, "error registering CRD markers")
+	}
+	parser := &crd.Parser{
+		Collector: &markers.Collector{Registry: registry},
+		Checker:   &loader.TypeChecker{NodeFilters: []loader.NodeFilter{crd.Generator{}.CheckFilter()}},
+	}
+	crd.AddKnownTypes(parser)
+	for _, root := range roots {
+		parser.NeedPackage(root)
+	}
+	if loader.PrintErrors(roots) {
+		return nil, errors.Errorf("error parsing API packages %v", pkgPaths)
+	}
+
+	metav1Pkg := crd.FindMetav1(roots)
+	if metav1Pkg == nil {
+		return nil, errors.Errorf("no API types found in %v", pkgPaths)
+	}
+	crds := []*apiextv1.CustomResourceDefinition{}
+	for _, groupKind := range crd.FindKubeKinds(parser, metav1Pkg) {
+		parser.NeedCRDFor(groupKind, nil)
+		generated := parser.CustomResourceDefinitions[groupKind]
+		crd.FixTopLevelMetadata(generated)
+		crds = append(crds, generated.DeepCopy())
+	}
+	return crds, nil
+}
@@ -0,0 +1,250 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tests
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/go-logr/logr"
+	"github.com/onsi/ginkgo"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/yaml"
+)
+
+// A cache which remembers every kind the controllers asked about, so a
+// failed test can dump all the relevant objects.
+type recordingCache struct {
+	cache.Cache
+	scheme *runtime.Scheme
+
+	kindsLock sync.Mutex
+	kinds     map[schema.GroupVersionKind]bool
+}
+
+func newRecordingCache(newCache cache.NewCacheFunc) (cache.NewCacheFunc, *recordingCache) {
+	rc := &recordingCache{kinds: map[schema.GroupVersionKind]bool{}}
+	return func(config *rest.Config, opts cache.Options) (cache.Cache, error) {
+		c, err := newCache(config, opts)
+		if err != nil {
+			return nil, err
+		}
+		rc.Cache = c
+		rc.scheme = opts.Scheme
+		return rc, nil
+	}, rc
+}
+
+func (c *recordingCache) record(obj runtime.Object) {
+	gvk, err := apiutil.GVKForObject(obj, c.scheme)
+	if err != nil {
+		return
+	}
+	gvk.Kind = strings.TrimSuffix(gvk.Kind, "List")
+	c.recordKind(gvk)
+}
+
+func (c *recordingCache) recordKind(gvk schema.GroupVersionKind) {
+	c.kindsLock.Lock()
+	defer c.kindsLock.Unlock()
+	c.kinds[gvk] = true
+}
+
+// The recorded kinds, in a stable order.
+func (c *recordingCache) Kinds() []schema.GroupVersionKind {
+	c.kindsLock.Lock()
+	defer c.kindsLock.Unlock()
+	kinds := []schema.GroupVersionKind{}
+	for gvk := range c.kinds {
+		kinds = append(kinds, gvk)
+	}
+	sort.Slice(kinds, func(i, j int) bool { return kinds[i].String() < kinds[j].String() })
+	return kinds
+}
+
+func (c *recordingCache) GetInformer(ctx context.Context, obj client.Object) (cache.Informer, error) {
+	c.record(obj)
+	return c.Cache.GetInformer(ctx, obj)
+}
+
+func (c *recordingCache) GetInformerForKind(ctx context.Context, gvk schema.GroupVersionKind) (cache.Informer, error) {
+	c.recordKind(gvk)
+	return c.Cache.GetInformerForKind(ctx, gvk)
+}
+
+func (c *recordingCache) Get(ctx context.Context, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+	c.record(obj)
+	return c.Cache.Get(ctx, key, obj, opts...)
+}
+
+func (c *recordingCache) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	c.record(list)
+	return c.Cache.List(ctx, list, opts...)
+}
+
+// A fixed size buffer of recent log lines.
+type logRing struct {
+	lock  sync.Mutex
+	lines []string
+	next  int
+	full  bool
+}
+
+var recentLogs = &logRing{lines: make([]string, 500)}
+
+func (r *logRing) add(line string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.lines[r.next] = line
+	r.next = (r.next + 1) % len(r.lines)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// Lines containing filter, oldest first.
+func (r *logRing) matching(filter string) []string {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	lines := []string{}
+	if r.full {
+		lines = append(lines, r.lines[r.next:]...)
+	}
+	lines = append(lines, r.lines[:r.next]...)
+	matches := []string{}
+	for _, line := range lines {
+		if strings.Contains(line, filter) {
+			matches = append(matches, line)
+		}
+	}
+	return matches
+}
+
+type recordingSink struct {
+	base   logr.LogSink
+	name   string
+	values []interface{}
+}
+
+// Wrap a logger to remember recent lines, which are included in the dump
+// when a functional test fails, and to write each spec's logs to a file in
+// LogDir, e.g.
+// `ctrl.SetLogger(tests.RecordingLogger(zap.New(zap.WriteTo(GinkgoWriter))))`.
+func RecordingLogger(base logr.Logger) logr.Logger {
+	return logr.New(&recordingSink{base: base.GetSink()})
+}
+
+func (s *recordingSink) Init(info logr.RuntimeInfo) {
+	info.CallDepth++
+	s.base.Init(info)
+}
+
+func (s *recordingSink) Enabled(level int) bool {
+	return s.base.Enabled(level)
+}
+
+func (s *recordingSink) Info(level int, msg string, keysAndValues ...interface{}) {
+	line := s.format("INFO", msg, nil, keysAndValues)
+	recentLogs.add(line)
+	writeSpecLogs(line)
+	s.base.Info(level, msg, keysAndValues...)
+}
+
+func (s *recordingSink) Error(err error, msg string, keysAndValues ...interface{}) {
+	line := s.format("ERROR", msg, err, keysAndValues)
+	recentLogs.add(line)
+	writeSpecLogs(line)
+	s.base.Error(err, msg, keysAndValues...)
+}
+
+func (s *recordingSink) WithValues(keysAndValues ...interface{}) logr.LogSink {
+	values := append(append([]interface{}{}, s.values...), keysAndValues...)
+	return &recordingSink{base: s.base.WithValues(keysAndValues...), name: s.name, values: values}
+}
+
+func (s *recordingSink) WithName(name string) logr.LogSink {
+	fullName := name
+	if s.name != "" {
+		fullName = s.name + "." + name
+	}
+	return &recordingSink{base: s.base.WithName(name), name: fullName, values: s.values}
+}
+
+func (s *recordingSink) format(level string, msg string, err error, keysAndValues []interface{}) string {
+	line := strings.Builder{}
+	fmt.Fprintf(&line, "%s\t%s\t%s", level, s.name, msg)
+	if err != nil {
+		fmt.Fprintf(&line, "\terror=%v", err)
+	}
+	values := append(append([]interface{}{}, s.values...), keysAndValues...)
+	for i := 0; i+1 < len(values); i += 2 {
+		fmt.Fprintf(&line, "\t%v=%v", values[i], values[i+1])
+	}
+	return line.String()
+}
+
+// Dump all objects of the kinds the controllers used, the events, and the
+// recent logs for the test namespace. Called automatically by Stop when the
+// current spec failed.
+func (fh *FunctionalHelper) DumpDebug() {
+	w := ginkgo.GinkgoWriter
+	fmt.Fprintf(w, "\n##### Debug dump for namespace %s #####\n", fh.Namespace)
+	if fh.cache != nil {
+		for _, gvk := range fh.cache.Kinds() {
+			fh.debugListKind(w, gvk)
+		}
+	}
+	fh.debugListKind(w, corev1.SchemeGroupVersion.WithKind("Event"))
+	lines := recentLogs.matching(fh.Namespace)
+	if len(lines) != 0 {
+		fmt.Fprintf(w, "\nLogs\n====\n%s\n", strings.Join(lines, "\n"))
+	}
+}
+
+func (fh *FunctionalHelper) debugListKind(w io.Writer, gvk schema.GroupVersionKind) {
+	list := &unstructured.UnstructuredList{}
+	list.SetGroupVersionKind(gvk.GroupVersion().WithKind(gvk.Kind + "List"))
+	err := fh.UncachedClient.List(context.Background(), list, client.InNamespace(fh.Namespace))
+	if err != nil {
+		fmt.Fprintf(w, "\nError listing %s: %v\n", gvk, err)
+		return
+	}
+	if len(list.Items) == 0 {
+		return
+	}
+	output := map[string]interface{}{}
+	for _, item := range list.Items {
+		output[item.GetName()] = item.Object
+	}
+	outputBytes, err := yaml.Marshal(output)
+	if err != nil {
+		fmt.Fprintf(w, "\nError marshalling %s: %v\n", gvk, err)
+		return
+	}
+	fmt.Fprintf(w, "\n%s\n%s\n%s\n", gvk.Kind, strings.Repeat("=", len(gvk.Kind)), string(outputBytes))
+}
@@ -0,0 +1,274 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tests
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/onsi/gomega"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/envtest"
+
+	"github.com/coderanger/controller-utils/randstring"
+)
+
+// Name of the kind cluster used if none is given.
+const DEFAULT_KIND_CLUSTER = "controller-utils-e2e"
+
+type e2eBuilder struct {
+	clusterName string
+	kindConfig  string
+	keepCluster bool
+	images      []string
+	crdPaths    []string
+	manifests   []string
+	apis        []schemeAdder
+}
+
+// Suite-level helper for end-to-end tests against a kind cluster with the
+// operator deployed in it, rather than running controllers in the test
+// process. Requires the kind and kubectl binaries, override with $KIND and
+// $KUBECTL.
+type E2ESuiteHelper struct {
+	cfg         *rest.Config
+	clusterName string
+	created     bool
+	keepCluster bool
+}
+
+type E2EHelper struct {
+	Client       client.Client
+	TestClient   *testClient
+	Namespace    string
+	namespaceObj *corev1.Namespace
+}
+
+func E2E() *e2eBuilder {
+	return &e2eBuilder{clusterName: DEFAULT_KIND_CLUSTER}
+}
+
+// Use the named kind cluster, creating it if it doesn't exist.
+func (b *e2eBuilder) KindCluster(name string) *e2eBuilder {
+	b.clusterName = name
+	return b
+}
+
+// Config file to use when creating the kind cluster.
+func (b *e2eBuilder) KindConfig(path string) *e2eBuilder {
+	b.kindConfig = path
+	return b
+}
+
+// Don't delete the kind cluster in Stop even if we created it, to speed up
+// local iteration.
+func (b *e2eBuilder) KeepCluster() *e2eBuilder {
+	b.keepCluster = true
+	return b
+}
+
+// Load locally built images into the cluster nodes.
+func (b *e2eBuilder) LoadImage(images ...string) *e2eBuilder {
+	b.images = append(b.images, images...)
+	return b
+}
+
+func (b *e2eBuilder) CRDPath(path string) *e2eBuilder {
+	b.crdPaths = append(b.crdPaths, path)
+	return b
+}
+
+// Manifests to deploy the operator, applied in order after the CRDs. A
+// directory containing a kustomization.yaml is applied with -k.
+func (b *e2eBuilder) Deploy(paths ...string) *e2eBuilder {
+	b.manifests = append(b.manifests, paths...)
+	return b
+}
+
+func (b *e2eBuilder) API(adder schemeAdder) *e2eBuilder {
+	b.apis = append(b.apis, adder)
+	return b
+}
+
+func (b *e2eBuilder) Build() (*E2ESuiteHelper, error) {
+	helper := &E2ESuiteHelper{clusterName: b.clusterName, keepCluster: b.keepCluster}
+	// Set up default paths for standard kubebuilder usage.
+	if len(b.crdPaths) == 0 {
+		b.crdPaths = append(b.crdPaths, filepath.Join("..", "config", "crd", "bases"))
+	}
+
+	// Create the cluster unless it's already there.
+	out, err := runCommand(kindBinary(), "get", "clusters")
+	if err != nil {
+		return nil, errors.Wrap(err, "error listing kind clusters")
+	}
+	exists := false
+	for _, line := range strings.Split(out, "\n") {
+		if strings.TrimSpace(line) == b.clusterName {
+			exists = true
+		}
+	}
+	if !exists {
+		args := []string{"create", "cluster", "--name", b.clusterName, "--wait", "5m"}
+		if b.kindConfig != "" {
+			args = append(args, "--config", b.kindConfig)
+		}
+		_, err := runCommand(kindBinary(), args...)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error creating kind cluster %s", b.clusterName)
+		}
+		helper.created = true
+	}
+
+	// Connect to it.
+	kubeconfig, err := runCommand(kindBinary(), "get", "kubeconfig", "--name", b.clusterName)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error getting kubeconfig for kind cluster %s", b.clusterName)
+	}
+	helper.cfg, err = clientcmd.RESTConfigFromKubeConfig([]byte(kubeconfig))
+	if err != nil {
+		return nil, errors.Wrap(err, "error parsing kind kubeconfig")
+	}
+
+	// Add all requested APIs to the global scheme.
+	for _, adder := range b.apis {
+		err = adder(scheme.Scheme)
+		if err != nil {
+			return nil, errors.Wrap(err, "error adding scheme")
+		}
+	}
+
+	for _, image := range b.images {
+		_, err := runCommand(kindBinary(), "load", "docker-image", image, "--name", b.clusterName)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error loading image %s", image)
+		}
+	}
+
+	_, err = envtest.InstallCRDs(helper.cfg, envtest.CRDInstallOptions{Paths: b.crdPaths, Scheme: scheme.Scheme})
+	if err != nil {
+		return nil, errors.Wrap(err, "error installing CRDs")
+	}
+
+	for _, path := range b.manifests {
+		flag := "-f"
+		if _, err := os.Stat(filepath.Join(path, "kustomization.yaml")); err == nil {
+			flag = "-k"
+		}
+		_, err := runCommand(kubectlBinary(), "--context", "kind-"+b.clusterName, "apply", flag, path)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error deploying %s", path)
+		}
+	}
+
+	return helper, nil
+}
+
+func (b *e2eBuilder) MustBuild() *E2ESuiteHelper {
+	esh, err := b.Build()
+	gomega.Expect(err).NotTo(gomega.HaveOccurred())
+	return esh
+}
+
+func (esh *E2ESuiteHelper) Stop() error {
+	if esh != nil && esh.created && !esh.keepCluster {
+		_, err := runCommand(kindBinary(), "delete", "cluster", "--name", esh.clusterName)
+		if err != nil {
+			return errors.Wrapf(err, "error deleting kind cluster %s", esh.clusterName)
+		}
+	}
+	return nil
+}
+
+func (esh *E2ESuiteHelper) MustStop() {
+	err := esh.Stop()
+	gomega.Expect(err).NotTo(gomega.HaveOccurred())
+}
+
+// Create a random namespace and a test client bound to it.
+func (esh *E2ESuiteHelper) Start() (*E2EHelper, error) {
+	eh := &E2EHelper{Namespace: "test-" + randstring.MustRandomString(10)}
+	var err error
+	eh.Client, err = client.New(esh.cfg, client.Options{Scheme: scheme.Scheme})
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating client")
+	}
+	namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: eh.Namespace}}
+	err = eh.Client.Create(context.Background(), namespace)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error creating test namespace %s", eh.Namespace)
+	}
+	eh.namespaceObj = namespace
+	eh.TestClient = &testClient{client: eh.Client, namespace: eh.Namespace}
+	return eh, nil
+}
+
+func (esh *E2ESuiteHelper) MustStart() *E2EHelper {
+	eh, err := esh.Start()
+	gomega.Expect(err).NotTo(gomega.HaveOccurred())
+	return eh
+}
+
+func (eh *E2EHelper) Stop() error {
+	if eh != nil && eh.namespaceObj != nil {
+		err := eh.Client.Delete(context.Background(), eh.namespaceObj)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (eh *E2EHelper) MustStop() {
+	err := eh.Stop()
+	gomega.Expect(err).NotTo(gomega.HaveOccurred())
+}
+
+func kindBinary() string {
+	if bin := os.Getenv("KIND"); bin != "" {
+		return bin
+	}
+	return "kind"
+}
+
+func kubectlBinary() string {
+	if bin := os.Getenv("KUBECTL"); bin != "" {
+		return bin
+	}
+	return "kubectl"
+}
+
+// Run a command, returning stdout and including stderr in any error.
+func runCommand(name string, args ...string) (string, error) {
+	cmd := exec.Command(name, args...)
+	stderr := &strings.Builder{}
+	cmd.Stderr = stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", errors.Wrapf(err, "%s %s: %s", name, strings.Join(args, " "), stderr.String())
+	}
+	return string(out), nil
+}
@@ -0,0 +1,56 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tests
+
+import (
+	"context"
+
+	"github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Get all the events recorded so far, in the "Type Reason message" format
+// used by record.FakeRecorder. Events are drained from the Events channel
+// and kept, so this can be called repeatedly, e.g.
+// `Expect(uh.RecordedEvents()).To(matchers.HaveEvent("Normal", "Created"))`.
+func (uh *UnitHelper) RecordedEvents() []string {
+	for {
+		select {
+		case event := <-uh.Events:
+			uh.recordedEvents = append(uh.recordedEvents, event)
+		default:
+			return uh.recordedEvents
+		}
+	}
+}
+
+// Get the events emitted for an object. Use with Eventually since events
+// are sent asynchronously, e.g.
+// `Eventually(func() []corev1.Event { return fh.Events(obj) }).Should(matchers.HaveEvent("Normal", "Created"))`.
+func (fh *FunctionalHelper) Events(obj client.Object) []corev1.Event {
+	list := &corev1.EventList{}
+	err := fh.UncachedClient.List(context.Background(), list, client.InNamespace(obj.GetNamespace()))
+	gomega.ExpectWithOffset(1, err).ToNot(gomega.HaveOccurred())
+	events := []corev1.Event{}
+	for _, event := range list.Items {
+		if event.InvolvedObject.UID == obj.GetUID() {
+			events = append(events, event)
+		}
+	}
+	return events
+}
@@ -0,0 +1,191 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tests
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// Verbs for FaultClient.Fail.
+const (
+	VERB_GET           = "get"
+	VERB_LIST          = "list"
+	VERB_CREATE        = "create"
+	VERB_UPDATE        = "update"
+	VERB_PATCH         = "patch"
+	VERB_DELETE        = "delete"
+	VERB_DELETE_ALL_OF = "deletecollection"
+	VERB_STATUS_UPDATE = "status-update"
+	VERB_STATUS_PATCH  = "status-patch"
+)
+
+type fault struct {
+	verb      string
+	gvk       schema.GroupVersionKind
+	remaining int
+	err       error
+}
+
+// A client wrapper which can be programmed to fail requests, for exercising
+// error handling. The helpers use one for the controller client, available
+// as UnitHelper.Faults and FunctionalHelper.Faults.
+type FaultClient struct {
+	client.Client
+
+	lock   sync.Mutex
+	faults []*fault
+}
+
+type faultStatusWriter struct {
+	client.StatusWriter
+	faults *FaultClient
+}
+
+func NewFaultClient(c client.Client) *FaultClient {
+	return &FaultClient{Client: c}
+}
+
+// Fail the next n requests with the given verb for objects of the given kind
+// with err. An empty verb or kind matches anything, an n of zero or less fails
+// forever.
+func (c *FaultClient) Fail(verb string, gvk schema.GroupVersionKind, n int, err error) *FaultClient {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.faults = append(c.faults, &fault{verb: verb, gvk: gvk, remaining: n, err: err})
+	return c
+}
+
+// Remove all pending faults.
+func (c *FaultClient) Reset() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.faults = nil
+}
+
+// Common errors to inject.
+func ConflictError(gvk schema.GroupVersionKind) error {
+	return kerrors.NewConflict(schema.GroupResource{Group: gvk.Group, Resource: strings.ToLower(gvk.Kind)}, "", errFaultInjected)
+}
+
+func TimeoutError() error {
+	return kerrors.NewTimeoutError("fault injected", 1)
+}
+
+func ForbiddenError(gvk schema.GroupVersionKind) error {
+	return kerrors.NewForbidden(schema.GroupResource{Group: gvk.Group, Resource: strings.ToLower(gvk.Kind)}, "", errFaultInjected)
+}
+
+var errFaultInjected = errors.New("fault injected")
+
+func (c *FaultClient) check(verb string, obj runtime.Object) error {
+	gvk, _ := apiutil.GVKForObject(obj, c.Scheme())
+	gvk.Kind = strings.TrimSuffix(gvk.Kind, "List")
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for i, f := range c.faults {
+		if f.verb != "" && f.verb != verb {
+			continue
+		}
+		if !f.gvk.Empty() && f.gvk != gvk {
+			continue
+		}
+		if f.remaining > 0 {
+			f.remaining--
+			if f.remaining == 0 {
+				c.faults = append(c.faults[:i], c.faults[i+1:]...)
+			}
+		}
+		return f.err
+	}
+	return nil
+}
+
+func (c *FaultClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+	if err := c.check(VERB_GET, obj); err != nil {
+		return err
+	}
+	return c.Client.Get(ctx, key, obj, opts...)
+}
+
+func (c *FaultClient) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	if err := c.check(VERB_LIST, list); err != nil {
+		return err
+	}
+	return c.Client.List(ctx, list, opts...)
+}
+
+func (c *FaultClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	if err := c.check(VERB_CREATE, obj); err != nil {
+		return err
+	}
+	return c.Client.Create(ctx, obj, opts...)
+}
+
+func (c *FaultClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	if err := c.check(VERB_UPDATE, obj); err != nil {
+		return err
+	}
+	return c.Client.Update(ctx, obj, opts...)
+}
+
+func (c *FaultClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	if err := c.check(VERB_PATCH, obj); err != nil {
+		return err
+	}
+	return c.Client.Patch(ctx, obj, patch, opts...)
+}
+
+func (c *FaultClient) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+	if err := c.check(VERB_DELETE, obj); err != nil {
+		return err
+	}
+	return c.Client.Delete(ctx, obj, opts...)
+}
+
+func (c *FaultClient) DeleteAllOf(ctx context.Context, obj client.Object, opts ...client.DeleteAllOfOption) error {
+	if err := c.check(VERB_DELETE_ALL_OF, obj); err != nil {
+		return err
+	}
+	return c.Client.DeleteAllOf(ctx, obj, opts...)
+}
+
+func (c *FaultClient) Status() client.StatusWriter {
+	return &faultStatusWriter{StatusWriter: c.Client.Status(), faults: c}
+}
+
+func (w *faultStatusWriter) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	if err := w.faults.check(VERB_STATUS_UPDATE, obj); err != nil {
+		return err
+	}
+	return w.StatusWriter.Update(ctx, obj, opts...)
+}
+
+func (w *faultStatusWriter) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	if err := w.faults.check(VERB_STATUS_PATCH, obj); err != nil {
+		return err
+	}
+	return w.StatusWriter.Patch(ctx, obj, patch, opts...)
+}
@@ -0,0 +1,140 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tests_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/coderanger/controller-utils/tests"
+)
+
+var _ = Describe("FaultClient", func() {
+	var c *tests.FaultClient
+	ctx := context.Background()
+	configMapGVK := schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}
+	secretGVK := schema.GroupVersionKind{Version: "v1", Kind: "Secret"}
+	key := client.ObjectKey{Name: "testing", Namespace: "default"}
+
+	BeforeEach(func() {
+		c = tests.NewFaultClient(fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(
+			&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "testing", Namespace: "default"}},
+			&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "testing", Namespace: "default"}},
+		).Build())
+	})
+
+	getConfigMap := func() error {
+		return c.Get(ctx, key, &corev1.ConfigMap{})
+	}
+
+	getSecret := func() error {
+		return c.Get(ctx, key, &corev1.Secret{})
+	}
+
+	It("passes requests through with no faults", func() {
+		Expect(getConfigMap()).To(Succeed())
+		Expect(c.List(ctx, &corev1.ConfigMapList{})).To(Succeed())
+	})
+
+	It("matches by verb", func() {
+		c.Fail(tests.VERB_UPDATE, configMapGVK, 0, tests.TimeoutError())
+		Expect(getConfigMap()).To(Succeed())
+		configMap := &corev1.ConfigMap{}
+		Expect(c.Get(ctx, key, configMap)).To(Succeed())
+		Expect(c.Update(ctx, configMap)).To(WithTransform(kerrors.IsTimeout, BeTrue()))
+	})
+
+	It("matches by kind", func() {
+		c.Fail(tests.VERB_GET, secretGVK, 0, tests.ForbiddenError(secretGVK))
+		Expect(getConfigMap()).To(Succeed())
+		Expect(getSecret()).To(WithTransform(kerrors.IsForbidden, BeTrue()))
+	})
+
+	It("matches anything with an empty verb and kind", func() {
+		c.Fail("", schema.GroupVersionKind{}, 0, tests.TimeoutError())
+		Expect(getConfigMap()).To(HaveOccurred())
+		Expect(getSecret()).To(HaveOccurred())
+		Expect(c.Delete(ctx, &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "testing", Namespace: "default"}})).To(HaveOccurred())
+	})
+
+	It("matches list requests by the item kind", func() {
+		c.Fail(tests.VERB_LIST, configMapGVK, 0, tests.TimeoutError())
+		Expect(c.List(ctx, &corev1.ConfigMapList{})).To(WithTransform(kerrors.IsTimeout, BeTrue()))
+		Expect(c.List(ctx, &corev1.SecretList{})).To(Succeed())
+		Expect(getConfigMap()).To(Succeed())
+	})
+
+	It("matches status writes", func() {
+		c.Fail(tests.VERB_STATUS_UPDATE, configMapGVK, 0, tests.ConflictError(configMapGVK))
+		configMap := &corev1.ConfigMap{}
+		Expect(c.Get(ctx, key, configMap)).To(Succeed())
+		Expect(c.Status().Update(ctx, configMap)).To(WithTransform(kerrors.IsConflict, BeTrue()))
+		Expect(c.Update(ctx, configMap)).To(Succeed())
+	})
+
+	It("fails n times and then removes the fault", func() {
+		c.Fail(tests.VERB_GET, configMapGVK, 2, tests.TimeoutError())
+		Expect(getConfigMap()).To(HaveOccurred())
+		Expect(getSecret()).To(Succeed())
+		Expect(getConfigMap()).To(HaveOccurred())
+		Expect(getConfigMap()).To(Succeed())
+		Expect(getConfigMap()).To(Succeed())
+	})
+
+	It("uses faults in the order they were added", func() {
+		c.Fail(tests.VERB_GET, configMapGVK, 1, tests.TimeoutError())
+		c.Fail(tests.VERB_GET, configMapGVK, 1, tests.ForbiddenError(configMapGVK))
+		Expect(getConfigMap()).To(WithTransform(kerrors.IsTimeout, BeTrue()))
+		Expect(getConfigMap()).To(WithTransform(kerrors.IsForbidden, BeTrue()))
+		Expect(getConfigMap()).To(Succeed())
+	})
+
+	forever := []struct {
+		name string
+		n    int
+	}{
+		{"zero", 0},
+		{"less than zero", -1},
+	}
+	for _, tc := range forever {
+		tc := tc
+		It("fails forever with an n of "+tc.name, func() {
+			c.Fail(tests.VERB_GET, configMapGVK, tc.n, tests.TimeoutError())
+			for i := 0; i < 5; i++ {
+				Expect(getConfigMap()).To(HaveOccurred())
+			}
+		})
+	}
+
+	It("removes all faults on reset", func() {
+		c.Fail(tests.VERB_GET, configMapGVK, 0, tests.TimeoutError())
+		c.Fail(tests.VERB_GET, secretGVK, 3, tests.TimeoutError())
+		Expect(getConfigMap()).To(HaveOccurred())
+		c.Reset()
+		Expect(getConfigMap()).To(Succeed())
+		Expect(getSecret()).To(Succeed())
+	})
+})
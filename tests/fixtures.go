@@ -0,0 +1,133 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tests
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/onsi/gomega"
+	"github.com/pkg/errors"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+// Read all the objects from the YAML files in a directory, in filename
+// order. Files can contain multiple documents. Objects of types known to the
+// scheme are decoded as those types, others are left as Unstructured.
+func readFixtures(dir string, scheme *runtime.Scheme) ([]client.Object, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error reading fixtures directory %s", dir)
+	}
+	filenames := []string{}
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		if !entry.IsDir() && (ext == ".yml" || ext == ".yaml") {
+			filenames = append(filenames, entry.Name())
+		}
+	}
+	sort.Strings(filenames)
+
+	objs := []client.Object{}
+	for _, filename := range filenames {
+		path := filepath.Join(dir, filename)
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error reading fixture %s", path)
+		}
+		for i, doc := range bytes.Split(raw, []byte("\n---")) {
+			if strings.TrimSpace(string(doc)) == "" {
+				continue
+			}
+			obj, err := decodeFixture(doc, scheme)
+			if err != nil {
+				return nil, errors.Wrapf(err, "error decoding document %d in fixture %s", i, path)
+			}
+			objs = append(objs, obj)
+		}
+	}
+	return objs, nil
+}
+
+func decodeFixture(doc []byte, scheme *runtime.Scheme) (client.Object, error) {
+	u := &unstructured.Unstructured{}
+	err := yaml.Unmarshal(doc, &u.Object)
+	if err != nil {
+		return nil, err
+	}
+	if scheme == nil || !scheme.Recognizes(u.GroupVersionKind()) {
+		return u, nil
+	}
+	typed, err := scheme.New(u.GroupVersionKind())
+	if err != nil {
+		return nil, err
+	}
+	err = runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, typed)
+	if err != nil {
+		return nil, err
+	}
+	obj, ok := typed.(client.Object)
+	if !ok {
+		return u, nil
+	}
+	return obj, nil
+}
+
+// Create all the objects from the YAML files in a directory, defaulting to
+// the test namespace. Returns the created objects, which can be cleaned up
+// with DeleteFixtures.
+func (c *testClient) LoadFixtures(dir string) []client.Object {
+	objs, err := readFixtures(dir, c.client.Scheme())
+	gomega.ExpectWithOffset(1, err).ToNot(gomega.HaveOccurred())
+	for _, obj := range objs {
+		defaultNamespace(obj, c.namespace)
+		err := c.client.Create(context.Background(), obj)
+		gomega.ExpectWithOffset(1, err).ToNot(gomega.HaveOccurred(), "error creating fixture %s %s", obj.GetObjectKind().GroupVersionKind().Kind, obj.GetName())
+	}
+	return objs
+}
+
+// Delete objects created by LoadFixtures, ignoring any already gone.
+func (c *testClient) DeleteFixtures(objs []client.Object) {
+	for _, obj := range objs {
+		err := c.client.Delete(context.Background(), obj)
+		if !kerrors.IsNotFound(err) {
+			gomega.ExpectWithOffset(1, err).ToNot(gomega.HaveOccurred())
+		}
+	}
+}
+
+// Create all the objects from the YAML files in a directory in the test
+// namespace, see testClient.LoadFixtures.
+func (fh *FunctionalHelper) LoadFixtures(dir string) []client.Object {
+	objs, err := readFixtures(dir, fh.Client.Scheme())
+	gomega.ExpectWithOffset(1, err).ToNot(gomega.HaveOccurred())
+	for _, obj := range objs {
+		defaultNamespace(obj, fh.Namespace)
+		err := fh.Client.Create(context.Background(), obj)
+		gomega.ExpectWithOffset(1, err).ToNot(gomega.HaveOccurred(), "error creating fixture %s %s", obj.GetObjectKind().GroupVersionKind().Kind, obj.GetName())
+	}
+	return objs
+}
@@ -20,13 +20,14 @@ import (
 	"context"
 	"fmt"
 	"math/rand"
+	"os"
 	"path/filepath"
 	"strings"
 	"time"
 
+	"github.com/onsi/ginkgo"
 	"github.com/onsi/gomega"
 	"github.com/pkg/errors"
-	"github.com/prometheus/client_golang/prometheus"
 	corev1 "k8s.io/api/core/v1"
 	apiextv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -34,13 +35,16 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
+	clocktesting "k8s.io/utils/clock/testing"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/cluster"
 	"sigs.k8s.io/controller-runtime/pkg/envtest"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
-	"sigs.k8s.io/controller-runtime/pkg/metrics"
 	"sigs.k8s.io/yaml"
 
+	"github.com/coderanger/controller-utils/core"
 	"github.com/coderanger/controller-utils/randstring"
 )
 
@@ -50,15 +54,20 @@ type managerAdder func(ctrl.Manager) error
 type functionalBuilder struct {
 	crdPaths     []string
 	crds         []*apiextv1.CustomResourceDefinition
+	crdPackages  []string
 	webhookPaths []string
 	apis         []schemeAdder
 	externalName *string
+	attachConfig []byte
+	fakeClock    bool
 }
 
 type FunctionalSuiteHelper struct {
 	environment *envtest.Environment
 	cfg         *rest.Config
 	external    bool
+	attached    bool
+	fakeClock   bool
 }
 
 type FunctionalHelper struct {
@@ -69,6 +78,18 @@ type FunctionalHelper struct {
 	TestClient     *testClient
 	Namespace      string
 	namespaceObj   *corev1.Namespace
+	// Fault injection for the manager's client, see FaultClient.
+	Faults *FaultClient
+	// Fake clock used by the reconcilers if the suite was built with
+	// FakeClock, otherwise nil. Requeues still happen in real time so step
+	// the clock before triggering the reconcile under test.
+	Clock      *clocktesting.FakeClock
+	cache      *recordingCache
+	elected    <-chan struct{}
+	reconciles *reconcileCounter
+	logFile    *os.File
+	rbac       *rbacRecorder
+	cfg        *rest.Config
 }
 
 func Functional() *functionalBuilder {
@@ -95,13 +116,23 @@ func (b *functionalBuilder) API(adder schemeAdder) *functionalBuilder {
 	return b
 }
 
+// Run reconcilers with a fake clock, see FunctionalHelper.Clock. Otherwise
+// they use the real clock.
+func (b *functionalBuilder) FakeClock() *functionalBuilder {
+	b.fakeClock = true
+	return b
+}
+
 func (b *functionalBuilder) UseExistingCluster(externalName string) *functionalBuilder {
 	b.externalName = &externalName
 	return b
 }
 
 func (b *functionalBuilder) Build() (*FunctionalSuiteHelper, error) {
-	helper := &FunctionalSuiteHelper{}
+	if b.attachConfig != nil {
+		return b.attach()
+	}
+	helper := &FunctionalSuiteHelper{fakeClock: b.fakeClock}
 	// Set up default paths for standard kubebuilder usage.
 	if len(b.crdPaths) == 0 {
 		b.crdPaths = append(b.crdPaths, filepath.Join("..", "config", "crd", "bases"))
@@ -112,6 +143,15 @@ func (b *functionalBuilder) Build() (*FunctionalSuiteHelper, error) {
 		defaultWebhookPaths = true
 	}
 
+	// Generate any CRDs requested from Go types.
+	if len(b.crdPackages) != 0 {
+		crds, err := generateCRDs(b.crdPackages...)
+		if err != nil {
+			return nil, errors.Wrap(err, "error generating CRDs")
+		}
+		b.crds = append(b.crds, crds...)
+	}
+
 	// Configure the test environment.
 	helper.environment = &envtest.Environment{
 		CRDDirectoryPaths: b.crdPaths,
@@ -157,7 +197,7 @@ func (b *functionalBuilder) MustBuild() *FunctionalSuiteHelper {
 }
 
 func (fsh *FunctionalSuiteHelper) Stop() error {
-	if fsh != nil && fsh.environment != nil {
+	if fsh != nil && fsh.environment != nil && !fsh.attached {
 		err := fsh.environment.Stop()
 		if err != nil {
 			return err
@@ -172,30 +212,137 @@ func (fsh *FunctionalSuiteHelper) MustStop() {
 }
 
 func (fsh *FunctionalSuiteHelper) Start(controllers ...managerAdder) (*FunctionalHelper, error) {
-	fh := &FunctionalHelper{}
+	return fsh.StartWithOptions(manager.Options{}, controllers...)
+}
+
+// Start with custom manager options, e.g. a cache SyncPeriod, a NewCache
+// with selectors, or a NewClient which sets QPS on its config. Unset
+// listener, namespace, and webhook options get the test defaults, and the
+// cache is wrapped to support DumpDebug.
+func (fsh *FunctionalSuiteHelper) StartWithOptions(opts manager.Options, controllers ...managerAdder) (*FunctionalHelper, error) {
+	return fsh.start(opts, nil, controllers...)
+}
 
+// Start with the manager impersonating a user or ServiceAccount (see
+// ServiceAccountUser), to check the controllers work with only the
+// permissions it is granted. The TestClient is not impersonated.
+func (fsh *FunctionalSuiteHelper) StartAs(impersonate rest.ImpersonationConfig, controllers ...managerAdder) (*FunctionalHelper, error) {
+	return fsh.start(manager.Options{}, &impersonate, controllers...)
+}
+
+func (fsh *FunctionalSuiteHelper) start(opts manager.Options, impersonate *rest.ImpersonationConfig, controllers ...managerAdder) (*FunctionalHelper, error) {
 	// Pick a randomize namespace so tests don't cross-talk as much.
-	fh.Namespace = "test-" + randstring.MustRandomString(10)
-
-	mgr, err := manager.New(fsh.cfg, manager.Options{
-		// Disable both listeners so tests don't raise a "Do you want to allow ... to listen" dialog on macOS.
-		MetricsBindAddress:     "0",
-		HealthProbeBindAddress: "0",
-		Namespace:              fh.Namespace,
-		Host:                   fsh.environment.WebhookInstallOptions.LocalServingHost,
-		Port:                   fsh.environment.WebhookInstallOptions.LocalServingPort,
-		CertDir:                fsh.environment.WebhookInstallOptions.LocalServingCertDir,
-		LeaderElection:         false,
-	})
+	fh, err := fsh.startManager("test-"+randstring.MustRandomString(10), opts, impersonate, controllers...)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create the actual random namespace.
+	namespace, err := fh.createNamespace()
+	if err != nil {
+		return nil, err
+	}
+	if fsh.external {
+		fh.namespaceObj = namespace
+	}
+
+	return fh, nil
+}
+
+// Create and start a manager watching the given namespace.
+func (fsh *FunctionalSuiteHelper) startManager(namespace string, opts manager.Options, impersonate *rest.ImpersonationConfig, controllers ...managerAdder) (*FunctionalHelper, error) {
+	fh := &FunctionalHelper{Namespace: namespace, cfg: fsh.cfg}
+
+	// Capture this spec's logs to a file, see RecordingLogger.
+	var err error
+	fh.logFile, err = openSpecLog(namespace)
 	if err != nil {
-		return nil, errors.Wrap(err, "error creating manager")
+		return nil, err
+	}
+	err = fsh.runManager(fh, opts, impersonate, controllers...)
+	if err != nil {
+		closeSpecLog(fh.logFile)
+		return nil, err
+	}
+	return fh, nil
+}
+
+func (fsh *FunctionalSuiteHelper) runManager(fh *FunctionalHelper, opts manager.Options, impersonate *rest.ImpersonationConfig, controllers ...managerAdder) error {
+	// Disable both listeners so tests don't raise a "Do you want to allow ... to listen" dialog on macOS.
+	if opts.MetricsBindAddress == "" {
+		opts.MetricsBindAddress = "0"
+	}
+	if opts.HealthProbeBindAddress == "" {
+		opts.HealthProbeBindAddress = "0"
+	}
+	if opts.Namespace == "" {
+		opts.Namespace = fh.Namespace
+	}
+	if opts.Host == "" {
+		opts.Host = fsh.environment.WebhookInstallOptions.LocalServingHost
+	}
+	if opts.Port == 0 {
+		opts.Port = fsh.environment.WebhookInstallOptions.LocalServingPort
+	}
+	if opts.CertDir == "" {
+		opts.CertDir = fsh.environment.WebhookInstallOptions.LocalServingCertDir
+	}
+
+	// Record which kinds the controllers use, for DumpDebug.
+	if opts.NewCache == nil {
+		opts.NewCache = cache.New
+	}
+	newCache, recCache := newRecordingCache(opts.NewCache)
+	opts.NewCache = newCache
+	fh.cache = recCache
+
+	// Wrap the manager's client for fault injection.
+	newClient := opts.NewClient
+	if newClient == nil {
+		newClient = cluster.DefaultNewClient
+	}
+	opts.NewClient = func(cache cache.Cache, config *rest.Config, options client.Options, uncachedObjects ...client.Object) (client.Client, error) {
+		c, err := newClient(cache, config, options, uncachedObjects...)
+		if err != nil {
+			return nil, err
+		}
+		fh.Faults = NewFaultClient(c)
+		return fh.Faults, nil
+	}
+
+	// Count reconciles, see ReconcileCount.
+	fh.reconciles = newReconcileCounter()
+	baseContext := opts.BaseContext
+	if baseContext == nil {
+		baseContext = context.Background
+	}
+	if fsh.fakeClock {
+		fh.Clock = clocktesting.NewFakeClock(time.Now())
+	}
+	opts.BaseContext = func() context.Context {
+		ctx := core.WithReconcileObserver(baseContext(), fh.reconciles)
+		if fh.Clock != nil {
+			ctx = core.WithClock(ctx, fh.Clock)
+		}
+		return ctx
+	}
+
+	// Record the permissions used, see VerifyRBAC.
+	fh.rbac = newRBACRecorder()
+	cfg := fh.rbac.wrapConfig(fsh.cfg)
+	if impersonate != nil {
+		cfg.Impersonate = *impersonate
+	}
+	mgr, err := manager.New(cfg, opts)
+	if err != nil {
+		return errors.Wrap(err, "error creating manager")
 	}
 
 	// Add the requested controllers.
 	for _, adder := range controllers {
 		err := adder(mgr)
 		if err != nil {
-			return nil, errors.Wrap(err, "error adding controller")
+			return errors.Wrap(err, "error adding controller")
 		}
 	}
 
@@ -211,27 +358,42 @@ func (fsh *FunctionalSuiteHelper) Start(controllers ...managerAdder) (*Functiona
 		}
 	}()
 
+	// Don't return until webhooks are serving, or tests race the startup.
+	if fsh.hasWebhooks() {
+		err = fsh.waitForWebhooks(opts.Port)
+		if err != nil {
+			fh.managerCancel()
+			return err
+		}
+	}
+
 	// Grab the clients.
+	fh.elected = mgr.Elected()
 	fh.Client = mgr.GetClient()
 	fh.UncachedClient, err = client.New(fsh.cfg, client.Options{Scheme: mgr.GetScheme()})
 	if err != nil {
-		return nil, errors.Wrap(err, "error creating raw client")
+		return errors.Wrap(err, "error creating raw client")
 	}
 
-	// Create the actual random namespace.
+	// Create a namespace-bound test client.
+	// Bypass the faults so test setup and assertions aren't affected.
+	testClientBase := fh.Faults.Client
+	if impersonate != nil {
+		// The manager's client is impersonated, so use the admin one.
+		testClientBase = fh.UncachedClient
+	}
+	fh.TestClient = &testClient{client: testClientBase, namespace: fh.Namespace, config: fsh.cfg}
+
+	return nil
+}
+
+func (fh *FunctionalHelper) createNamespace() (*corev1.Namespace, error) {
 	namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: fh.Namespace}}
-	err = fh.UncachedClient.Create(context.Background(), namespace)
+	err := fh.UncachedClient.Create(context.Background(), namespace)
 	if err != nil {
 		return nil, errors.Wrapf(err, "error creating test namespace %s", fh.Namespace)
 	}
-	if fsh.external {
-		fh.namespaceObj = namespace
-	}
-
-	// Create a namespace-bound test client.
-	fh.TestClient = &testClient{client: fh.Client, namespace: fh.Namespace}
-
-	return fh, nil
+	return namespace, nil
 }
 
 func (fsh *FunctionalSuiteHelper) MustStart(controllers ...managerAdder) *FunctionalHelper {
@@ -240,7 +402,23 @@ func (fsh *FunctionalSuiteHelper) MustStart(controllers ...managerAdder) *Functi
 	return fh
 }
 
+func (fsh *FunctionalSuiteHelper) MustStartAs(impersonate rest.ImpersonationConfig, controllers ...managerAdder) *FunctionalHelper {
+	fh, err := fsh.StartAs(impersonate, controllers...)
+	gomega.Expect(err).ToNot(gomega.HaveOccurred())
+	return fh
+}
+
+func (fsh *FunctionalSuiteHelper) MustStartWithOptions(opts manager.Options, controllers ...managerAdder) *FunctionalHelper {
+	fh, err := fsh.StartWithOptions(opts, controllers...)
+	gomega.Expect(err).ToNot(gomega.HaveOccurred())
+	return fh
+}
+
 func (fh *FunctionalHelper) Stop() error {
+	// Show what was going on if the test failed.
+	if fh != nil && ginkgo.CurrentGinkgoTestDescription().Failed {
+		fh.DumpDebug()
+	}
 	// Clean up the namespace if using an extneral control plane.
 	if fh.namespaceObj != nil {
 		err := fh.UncachedClient.Delete(context.Background(), fh.namespaceObj)
@@ -253,8 +431,16 @@ func (fh *FunctionalHelper) Stop() error {
 		// TODO maybe replace this with my own timeout so it doesn't use Gomega.
 		gomega.Eventually(fh.managerDone, 30*time.Second).Should(gomega.BeClosed())
 	}
-	// TODO This is not needed in controller-runtime 0.6 or above, revisit.
-	metrics.Registry = prometheus.NewRegistry()
+	// Close the log file once the manager is done writing to it.
+	if fh != nil && fh.logFile != nil {
+		if ginkgo.CurrentGinkgoTestDescription().Failed {
+			fmt.Fprintf(ginkgo.GinkgoWriter, "\nController logs written to %s\n", fh.logFile.Name())
+		}
+		err := closeSpecLog(fh.logFile)
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -0,0 +1,56 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tests
+
+import (
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Typed versions of the test client getters, which allocate and return the
+// object so there's no need to declare it first, e.g.
+// `deployment := tests.EventuallyGetName[appsv1.Deployment](c, "testing")`.
+// Methods can't have type parameters, so these take the client.
+
+// An object type T where *T is a client.Object.
+type objectPointer[T any] interface {
+	*T
+	client.Object
+}
+
+func Get[T any, PT objectPointer[T]](c *testClient, key client.ObjectKey) PT {
+	obj := PT(new(T))
+	c.get(key, obj)
+	return obj
+}
+
+func GetName[T any, PT objectPointer[T]](c *testClient, name string) PT {
+	obj := PT(new(T))
+	c.get(client.ObjectKey{Name: name}, obj)
+	return obj
+}
+
+func EventuallyGet[T any, PT objectPointer[T]](c *testClient, key client.ObjectKey, optSetters ...eventuallyGetOptionsSetter) PT {
+	obj := PT(new(T))
+	c.eventuallyGet(key, obj, optSetters...)
+	return obj
+}
+
+func EventuallyGetName[T any, PT objectPointer[T]](c *testClient, name string, optSetters ...eventuallyGetOptionsSetter) PT {
+	obj := PT(new(T))
+	c.eventuallyGet(client.ObjectKey{Name: name}, obj, optSetters...)
+	return obj
+}
@@ -0,0 +1,68 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tests
+
+import (
+	"fmt"
+
+	"github.com/onsi/gomega"
+	"github.com/pkg/errors"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// The impersonation config for a ServiceAccount, with the groups the API
+// server would give it.
+func ServiceAccountUser(namespace, name string) rest.ImpersonationConfig {
+	return rest.ImpersonationConfig{
+		UserName: fmt.Sprintf("system:serviceaccount:%s:%s", namespace, name),
+		Groups:   []string{"system:serviceaccounts", "system:serviceaccounts:" + namespace, "system:authenticated"},
+	}
+}
+
+func impersonatedClient(config *rest.Config, opts client.Options, impersonate rest.ImpersonationConfig) (client.Client, error) {
+	config = rest.CopyConfig(config)
+	config.Impersonate = impersonate
+	c, err := client.New(config, opts)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error creating client impersonating %s", impersonate.UserName)
+	}
+	return c, nil
+}
+
+// A test client which makes requests as the given user, e.g.
+// `c.As(tests.ServiceAccountUser(helper.Namespace, "tenant")).Create(obj)`.
+// Only available in functional tests.
+func (c *testClient) As(impersonate rest.ImpersonationConfig) *testClient {
+	gomega.ExpectWithOffset(1, c.config).ToNot(gomega.BeNil(), "impersonation requires a functional test client")
+	raw, err := impersonatedClient(c.config, client.Options{Scheme: c.client.Scheme()}, impersonate)
+	gomega.ExpectWithOffset(1, err).ToNot(gomega.HaveOccurred())
+	return &testClient{client: raw, namespace: c.namespace, config: c.config}
+}
+
+// An uncached client making requests as the given user, for checking
+// requests are denied since the test client helpers expect success, e.g.
+// `Expect(kerrors.IsForbidden(c.Create(ctx, obj))).To(BeTrue())`.
+func (fh *FunctionalHelper) ImpersonatedClient(impersonate rest.ImpersonationConfig) (client.Client, error) {
+	return impersonatedClient(fh.cfg, client.Options{Scheme: fh.UncachedClient.Scheme()}, impersonate)
+}
+
+func (fh *FunctionalHelper) MustImpersonatedClient(impersonate rest.ImpersonationConfig) client.Client {
+	c, err := fh.ImpersonatedClient(impersonate)
+	gomega.ExpectWithOffset(1, err).ToNot(gomega.HaveOccurred())
+	return c
+}
@@ -0,0 +1,155 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tests
+
+import (
+	"context"
+	"time"
+
+	"github.com/onsi/gomega"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+
+	"github.com/coderanger/controller-utils/randstring"
+)
+
+// Two manager instances running the same controllers with leader election,
+// sharing one test namespace. Stop the leader to test failover. Both would
+// listen on the envtest webhook port, so controllers with webhooks aren't
+// supported.
+type LeaderElectionHelper struct {
+	Instances    []*FunctionalHelper
+	Namespace    string
+	namespaceObj *corev1.Namespace
+}
+
+// Lease timings, much shorter than the defaults so failover tests are quick.
+var (
+	LeaderElectionLeaseDuration = 2 * time.Second
+	LeaderElectionRenewDeadline = 1 * time.Second
+	LeaderElectionRetryPeriod   = 200 * time.Millisecond
+)
+
+func (fsh *FunctionalSuiteHelper) StartLeaderElection(controllers ...managerAdder) (*LeaderElectionHelper, error) {
+	leh := &LeaderElectionHelper{Namespace: "test-" + randstring.MustRandomString(10)}
+	for i := 0; i < 2; i++ {
+		opts := manager.Options{
+			LeaderElection:                true,
+			LeaderElectionID:              "controller-utils-test-leader",
+			LeaderElectionNamespace:       leh.Namespace,
+			LeaderElectionReleaseOnCancel: true,
+			LeaseDuration:                 &LeaderElectionLeaseDuration,
+			RenewDeadline:                 &LeaderElectionRenewDeadline,
+			RetryPeriod:                   &LeaderElectionRetryPeriod,
+		}
+		fh, err := fsh.startManager(leh.Namespace, opts, nil, controllers...)
+		if err != nil {
+			leh.Stop()
+			return nil, errors.Wrapf(err, "error starting instance %d", i)
+		}
+		leh.Instances = append(leh.Instances, fh)
+		if i == 0 {
+			namespace, err := fh.createNamespace()
+			if err != nil {
+				leh.Stop()
+				return nil, err
+			}
+			if fsh.external {
+				leh.namespaceObj = namespace
+			}
+		}
+	}
+	return leh, nil
+}
+
+func (fsh *FunctionalSuiteHelper) MustStartLeaderElection(controllers ...managerAdder) *LeaderElectionHelper {
+	leh, err := fsh.StartLeaderElection(controllers...)
+	gomega.Expect(err).ToNot(gomega.HaveOccurred())
+	return leh
+}
+
+// Check if this instance's manager has been elected leader.
+func (fh *FunctionalHelper) IsLeader() bool {
+	if fh.elected == nil {
+		return false
+	}
+	select {
+	case <-fh.elected:
+		return true
+	default:
+		return false
+	}
+}
+
+// The current leader among the running instances, or nil if there isn't one.
+func (leh *LeaderElectionHelper) Leader() *FunctionalHelper {
+	for _, fh := range leh.Instances {
+		if fh.managerCancel != nil && fh.IsLeader() {
+			return fh
+		}
+	}
+	return nil
+}
+
+// Wait for an instance to become leader.
+func (leh *LeaderElectionHelper) EventuallyLeader() *FunctionalHelper {
+	gomega.EventuallyWithOffset(1, leh.Leader, DefaultTimeout).ShouldNot(gomega.BeNil())
+	return leh.Leader()
+}
+
+// The running instances which are not the leader.
+func (leh *LeaderElectionHelper) Followers() []*FunctionalHelper {
+	followers := []*FunctionalHelper{}
+	for _, fh := range leh.Instances {
+		if fh.managerCancel != nil && !fh.IsLeader() {
+			followers = append(followers, fh)
+		}
+	}
+	return followers
+}
+
+// Stop one instance, e.g. the leader to force a failover.
+func (leh *LeaderElectionHelper) StopInstance(fh *FunctionalHelper) error {
+	if fh.managerCancel == nil {
+		return nil
+	}
+	err := fh.Stop()
+	fh.managerCancel = nil
+	return err
+}
+
+func (leh *LeaderElectionHelper) Stop() error {
+	for _, fh := range leh.Instances {
+		err := leh.StopInstance(fh)
+		if err != nil {
+			return err
+		}
+	}
+	if leh.namespaceObj != nil && len(leh.Instances) != 0 {
+		err := leh.Instances[0].UncachedClient.Delete(context.Background(), leh.namespaceObj)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (leh *LeaderElectionHelper) MustStop() {
+	err := leh.Stop()
+	gomega.Expect(err).ToNot(gomega.HaveOccurred())
+}
@@ -20,6 +20,7 @@ import (
 	"fmt"
 
 	"github.com/onsi/gomega"
+	gtypes "github.com/onsi/gomega/types"
 
 	"github.com/coderanger/controller-utils/conditions"
 	"github.com/coderanger/controller-utils/core"
@@ -27,9 +28,14 @@ import (
 )
 
 type haveConditionMatcher struct {
-	conditionType string
-	status        *string
-	reason        *string
+	conditionType      string
+	status             *string
+	healthy            *bool
+	reason             gtypes.GomegaMatcher
+	reasonExpected     interface{}
+	messageMatcher     gtypes.GomegaMatcher
+	messageExpected    interface{}
+	observedGeneration *int64
 }
 
 func HaveCondition(conditionType string) *haveConditionMatcher {
@@ -41,11 +47,49 @@ func (matcher *haveConditionMatcher) WithStatus(status string) *haveConditionMat
 	return matcher
 }
 
-func (matcher *haveConditionMatcher) WithReason(reason string) *haveConditionMatcher {
-	matcher.reason = &reason
+// Match a condition at its healthy status, as registered with
+// core.RegisterConditionPolarity.
+func (matcher *haveConditionMatcher) Healthy() *haveConditionMatcher {
+	healthy := true
+	matcher.healthy = &healthy
 	return matcher
 }
 
+// Match a condition which is set but not at its healthy status.
+func (matcher *haveConditionMatcher) Unhealthy() *haveConditionMatcher {
+	healthy := false
+	matcher.healthy = &healthy
+	return matcher
+}
+
+// Match the reason, either a string for an exact match or a Gomega matcher.
+func (matcher *haveConditionMatcher) WithReason(reason interface{}) *haveConditionMatcher {
+	matcher.reason = toMatcher(reason, gomega.Equal)
+	matcher.reasonExpected = reason
+	return matcher
+}
+
+// Match the message, either a string regular expression or a Gomega matcher.
+func (matcher *haveConditionMatcher) WithMessageMatching(message interface{}) *haveConditionMatcher {
+	matcher.messageMatcher = toMatcher(message, func(expected interface{}) gtypes.GomegaMatcher {
+		return gomega.MatchRegexp(fmt.Sprint(expected))
+	})
+	matcher.messageExpected = message
+	return matcher
+}
+
+func (matcher *haveConditionMatcher) WithObservedGeneration(generation int64) *haveConditionMatcher {
+	matcher.observedGeneration = &generation
+	return matcher
+}
+
+func toMatcher(expected interface{}, fallback func(interface{}) gtypes.GomegaMatcher) gtypes.GomegaMatcher {
+	if m, ok := expected.(gtypes.GomegaMatcher); ok {
+		return m
+	}
+	return fallback(expected)
+}
+
 func (matcher *haveConditionMatcher) Match(actual interface{}) (bool, error) {
 	obj, ok := actual.(client.Object)
 	if !ok {
@@ -68,13 +112,28 @@ func (matcher *haveConditionMatcher) Match(actual interface{}) (bool, error) {
 		}
 	}
 
+	if matcher.healthy != nil && (cond.Status == core.HealthyStatus(cond.Type)) != *matcher.healthy {
+		return false, nil
+	}
+
 	if matcher.reason != nil {
-		match, err := gomega.Equal(*matcher.reason).Match(cond.Reason)
+		match, err := matcher.reason.Match(cond.Reason)
+		if !match || err != nil {
+			return match, err
+		}
+	}
+
+	if matcher.messageMatcher != nil {
+		match, err := matcher.messageMatcher.Match(cond.Message)
 		if !match || err != nil {
 			return match, err
 		}
 	}
 
+	if matcher.observedGeneration != nil && cond.ObservedGeneration != *matcher.observedGeneration {
+		return false, nil
+	}
+
 	return true, nil
 }
 
@@ -91,8 +150,21 @@ func (matcher *haveConditionMatcher) message(actual interface{}, polarity bool)
 	if matcher.status != nil {
 		filters += fmt.Sprintf(" with status %s", *matcher.status)
 	}
+	if matcher.healthy != nil {
+		if *matcher.healthy {
+			filters += fmt.Sprintf(" with healthy status %s", core.HealthyStatus(matcher.conditionType))
+		} else {
+			filters += fmt.Sprintf(" with status other than %s", core.HealthyStatus(matcher.conditionType))
+		}
+	}
 	if matcher.reason != nil {
-		filters += fmt.Sprintf(" with reason %s", *matcher.reason)
+		filters += fmt.Sprintf(" with reason %v", matcher.reasonExpected)
+	}
+	if matcher.messageMatcher != nil {
+		filters += fmt.Sprintf(" with message matching %v", matcher.messageExpected)
+	}
+	if matcher.observedGeneration != nil {
+		filters += fmt.Sprintf(" with observedGeneration %d", *matcher.observedGeneration)
 	}
 
 	joiner := ""
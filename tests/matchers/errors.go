@@ -0,0 +1,69 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package matchers
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+type haveFieldErrorMatcher struct {
+	path string
+}
+
+// Match a validation error for the given field path, e.g. `spec.replicas`.
+// Understands field.ErrorList aggregates and Invalid API errors, and falls
+// back to looking for the path in the error message.
+func HaveFieldError(path string) *haveFieldErrorMatcher {
+	return &haveFieldErrorMatcher{path: path}
+}
+
+func (matcher *haveFieldErrorMatcher) Match(actual interface{}) (bool, error) {
+	if actual == nil {
+		return false, nil
+	}
+	err, ok := actual.(error)
+	if !ok {
+		return false, fmt.Errorf("HaveFieldError matcher expects an error")
+	}
+
+	var fieldErr *field.Error
+	if errors.As(err, &fieldErr) && fieldErr.Field == matcher.path {
+		return true, nil
+	}
+	var statusErr *kerrors.StatusError
+	if errors.As(err, &statusErr) && statusErr.ErrStatus.Details != nil {
+		for _, cause := range statusErr.ErrStatus.Details.Causes {
+			if cause.Field == matcher.path {
+				return true, nil
+			}
+		}
+	}
+	return strings.Contains(err.Error(), matcher.path+":"), nil
+}
+
+func (matcher *haveFieldErrorMatcher) FailureMessage(actual interface{}) string {
+	return fmt.Sprintf("Expected %v to have a field error for %s", actual, matcher.path)
+}
+
+func (matcher *haveFieldErrorMatcher) NegatedFailureMessage(actual interface{}) string {
+	return fmt.Sprintf("Expected %v not to have a field error for %s", actual, matcher.path)
+}
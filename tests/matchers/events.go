@@ -0,0 +1,101 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package matchers
+
+import (
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+type haveEventMatcher struct {
+	eventType string
+	reason    string
+	message   *string
+}
+
+// Match a list of events containing one with the given type and reason.
+// Accepts the strings from UnitHelper.RecordedEvents or the Event objects
+// from FunctionalHelper.Events.
+func HaveEvent(eventType string, reason string) *haveEventMatcher {
+	return &haveEventMatcher{eventType: eventType, reason: reason}
+}
+
+// Also require the message to contain the given text.
+func (matcher *haveEventMatcher) WithMessage(message string) *haveEventMatcher {
+	matcher.message = &message
+	return matcher
+}
+
+func (matcher *haveEventMatcher) Match(actual interface{}) (bool, error) {
+	switch events := actual.(type) {
+	case []string:
+		for _, event := range events {
+			parts := strings.SplitN(event, " ", 3)
+			if len(parts) < 2 {
+				continue
+			}
+			message := ""
+			if len(parts) == 3 {
+				message = parts[2]
+			}
+			if matcher.matches(parts[0], parts[1], message) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case []corev1.Event:
+		for _, event := range events {
+			if matcher.matches(event.Type, event.Reason, event.Message) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case *corev1.EventList:
+		return matcher.Match(events.Items)
+	default:
+		return false, fmt.Errorf("HaveEvent matcher expects []string, []corev1.Event, or *corev1.EventList")
+	}
+}
+
+func (matcher *haveEventMatcher) matches(eventType string, reason string, message string) bool {
+	if eventType != matcher.eventType || reason != matcher.reason {
+		return false
+	}
+	return matcher.message == nil || strings.Contains(message, *matcher.message)
+}
+
+func (matcher *haveEventMatcher) FailureMessage(actual interface{}) string {
+	return matcher.failureMessage(actual, true)
+}
+
+func (matcher *haveEventMatcher) NegatedFailureMessage(actual interface{}) string {
+	return matcher.failureMessage(actual, false)
+}
+
+func (matcher *haveEventMatcher) failureMessage(actual interface{}, polarity bool) string {
+	filters := ""
+	if matcher.message != nil {
+		filters = fmt.Sprintf(" with message %q", *matcher.message)
+	}
+	joiner := ""
+	if !polarity {
+		joiner = "not "
+	}
+	return fmt.Sprintf("Expected %#v to %shave event %s %s%s", actual, joiner, matcher.eventType, matcher.reason, filters)
+}
@@ -0,0 +1,189 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package matchers
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"reflect"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/yaml"
+
+	"github.com/coderanger/controller-utils/templates"
+)
+
+type renderToMatcher struct {
+	fs       http.FileSystem
+	filename string
+	golden   string
+	opts     templates.Options
+	rendered []byte
+	expected []byte
+}
+
+// Match template data which renders the given template to the same YAML as
+// the golden file, compared structurally so formatting doesn't matter. Set
+// $UPDATE_GOLDEN=true to write the golden file instead, e.g.
+// `Expect(data).To(RenderTo(fs, "deployment.yml", "testdata/deployment.yml"))`.
+func RenderTo(fs http.FileSystem, filename string, golden string) *renderToMatcher {
+	return &renderToMatcher{fs: fs, filename: filename, golden: golden}
+}
+
+// Render with extra options, like custom template functions.
+func (matcher *renderToMatcher) WithOptions(opts templates.Options) *renderToMatcher {
+	matcher.opts = opts
+	return matcher
+}
+
+func (matcher *renderToMatcher) Match(actual interface{}) (bool, error) {
+	var err error
+	matcher.rendered, err = templates.RenderFile(matcher.fs, matcher.filename, actual, matcher.opts)
+	if err != nil {
+		return false, fmt.Errorf("error rendering %s: %w", matcher.filename, err)
+	}
+	if os.Getenv("UPDATE_GOLDEN") == "true" {
+		err = os.WriteFile(matcher.golden, matcher.rendered, 0644)
+		if err != nil {
+			return false, fmt.Errorf("error writing golden file %s: %w", matcher.golden, err)
+		}
+		return true, nil
+	}
+	matcher.expected, err = os.ReadFile(matcher.golden)
+	if err != nil {
+		return false, fmt.Errorf("error reading golden file %s: %w", matcher.golden, err)
+	}
+
+	var renderedData, expectedData interface{}
+	err = yaml.Unmarshal(matcher.rendered, &renderedData)
+	if err != nil {
+		return false, fmt.Errorf("error parsing rendered %s: %w", matcher.filename, err)
+	}
+	err = yaml.Unmarshal(matcher.expected, &expectedData)
+	if err != nil {
+		return false, fmt.Errorf("error parsing golden file %s: %w", matcher.golden, err)
+	}
+	return reflect.DeepEqual(renderedData, expectedData), nil
+}
+
+func (matcher *renderToMatcher) FailureMessage(actual interface{}) string {
+	return fmt.Sprintf("Expected %s to render to %s\nRendered:\n%s\nGolden:\n%s", matcher.filename, matcher.golden, matcher.rendered, matcher.expected)
+}
+
+func (matcher *renderToMatcher) NegatedFailureMessage(actual interface{}) string {
+	return fmt.Sprintf("Expected %s not to render to %s", matcher.filename, matcher.golden)
+}
+
+type matchUnstructuredFieldsMatcher struct {
+	expected map[string]interface{}
+	mismatch string
+}
+
+// Match an object with all the fields in expected, ignoring any others, so
+// partial objects can be compared. Expected can be a map, a YAML string, or
+// an object. Nil values in expected match anything.
+func MatchUnstructuredFields(expected interface{}) *matchUnstructuredFieldsMatcher {
+	expectedMap, err := toUnstructuredMap(expected)
+	if err != nil {
+		panic(err)
+	}
+	return &matchUnstructuredFieldsMatcher{expected: expectedMap}
+}
+
+func toUnstructuredMap(obj interface{}) (map[string]interface{}, error) {
+	switch val := obj.(type) {
+	case map[string]interface{}:
+		return val, nil
+	case string:
+		data := map[string]interface{}{}
+		err := yaml.Unmarshal([]byte(val), &data)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing YAML: %w", err)
+		}
+		return data, nil
+	case *unstructured.Unstructured:
+		return val.Object, nil
+	case runtime.Object:
+		return runtime.DefaultUnstructuredConverter.ToUnstructured(val)
+	default:
+		return nil, fmt.Errorf("expected a map, YAML string, or object, got %T", obj)
+	}
+}
+
+func (matcher *matchUnstructuredFieldsMatcher) Match(actual interface{}) (bool, error) {
+	actualMap, err := toUnstructuredMap(actual)
+	if err != nil {
+		return false, fmt.Errorf("MatchUnstructuredFields matcher: %w", err)
+	}
+	// Round trip through YAML so numbers and nested types are normalized.
+	actualMap, err = normalizeMap(actualMap)
+	if err != nil {
+		return false, err
+	}
+	expectedMap, err := normalizeMap(matcher.expected)
+	if err != nil {
+		return false, err
+	}
+	matcher.mismatch = findMismatch(expectedMap, actualMap, "")
+	return matcher.mismatch == "", nil
+}
+
+func normalizeMap(data map[string]interface{}) (map[string]interface{}, error) {
+	raw, err := yaml.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	normalized := map[string]interface{}{}
+	err = yaml.Unmarshal(raw, &normalized)
+	return normalized, err
+}
+
+// Describe the first field set in expected which differs in actual, or ""
+// if they match.
+func findMismatch(expected, actual interface{}, path string) string {
+	if expected == nil {
+		return ""
+	}
+	expectedMap, ok := expected.(map[string]interface{})
+	if !ok {
+		if !reflect.DeepEqual(expected, actual) {
+			return fmt.Sprintf("%s: expected %#v, got %#v", path, expected, actual)
+		}
+		return ""
+	}
+	actualMap, ok := actual.(map[string]interface{})
+	if !ok {
+		return fmt.Sprintf("%s: expected a map, got %#v", path, actual)
+	}
+	for key, value := range expectedMap {
+		mismatch := findMismatch(value, actualMap[key], path+"."+key)
+		if mismatch != "" {
+			return mismatch
+		}
+	}
+	return ""
+}
+
+func (matcher *matchUnstructuredFieldsMatcher) FailureMessage(actual interface{}) string {
+	return fmt.Sprintf("Expected\n\t%#v\nto match fields, mismatch at %s", actual, matcher.mismatch)
+}
+
+func (matcher *matchUnstructuredFieldsMatcher) NegatedFailureMessage(actual interface{}) string {
+	return fmt.Sprintf("Expected\n\t%#v\nnot to match fields\n\t%#v", actual, matcher.expected)
+}
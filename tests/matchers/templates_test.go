@@ -0,0 +1,78 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package matchers_test
+
+import (
+	"net/http"
+	"testing/fstest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	. "github.com/coderanger/controller-utils/tests/matchers"
+)
+
+var _ = Describe("RenderTo matcher", func() {
+	fs := http.FS(fstest.MapFS{
+		"configmap.yml": &fstest.MapFile{Data: []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: {{ .Name }}\ndata:\n  key: {{ .Value }}\n")},
+	})
+
+	It("matches the golden file regardless of formatting", func() {
+		data := map[string]string{"Name": "testing", "Value": "value"}
+		Expect(data).To(RenderTo(fs, "configmap.yml", "testdata/configmap.yml"))
+	})
+
+	It("doesn't match a different render", func() {
+		data := map[string]string{"Name": "testing", "Value": "other"}
+		Expect(data).ToNot(RenderTo(fs, "configmap.yml", "testdata/configmap.yml"))
+	})
+
+	It("fails for a missing golden file", func() {
+		data := map[string]string{"Name": "testing", "Value": "value"}
+		_, err := RenderTo(fs, "configmap.yml", "testdata/missing.yml").Match(data)
+		Expect(err).To(MatchError(ContainSubstring("error reading golden file testdata/missing.yml")))
+	})
+})
+
+var _ = Describe("MatchUnstructuredFields matcher", func() {
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "testing", Namespace: "default", Labels: map[string]string{"app": "testing"}},
+		Data:       map[string]string{"key": "value", "other": "value"},
+	}
+
+	It("ignores fields which aren't expected", func() {
+		Expect(configMap).To(MatchUnstructuredFields("data: {key: value}"))
+		Expect(configMap).To(MatchUnstructuredFields(map[string]interface{}{"metadata": map[string]interface{}{"name": "testing"}}))
+	})
+
+	It("compares against an object", func() {
+		Expect(configMap).To(MatchUnstructuredFields(&corev1.ConfigMap{Data: map[string]string{"other": "value"}}))
+		Expect(configMap).ToNot(MatchUnstructuredFields(&corev1.ConfigMap{Data: map[string]string{"other": "nope"}}))
+	})
+
+	It("treats nil as matching anything", func() {
+		Expect(configMap).To(MatchUnstructuredFields("metadata: {labels: {app: null}}"))
+	})
+
+	It("reports the first mismatch", func() {
+		matcher := MatchUnstructuredFields("metadata: {labels: {app: other}}")
+		Expect(matcher.Match(configMap)).To(BeFalse())
+		Expect(matcher.FailureMessage(configMap)).To(ContainSubstring(`mismatch at .metadata.labels.app: expected "other", got "testing"`))
+	})
+})
@@ -0,0 +1,117 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tests
+
+import (
+	"encoding/json"
+
+	"github.com/pkg/errors"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/envtest"
+)
+
+// Support for running functional suites with `ginkgo -p`. Each process can
+// build its own helper and control plane as usual, since every test gets a
+// random namespace. To share one control plane instead, start it on the
+// first node and attach the others:
+//
+//	var _ = SynchronizedBeforeSuite(func() []byte {
+//		suiteHelper = tests.Functional().CRDPath("crds").MustBuild()
+//		return suiteHelper.MustConfig()
+//	}, func(config []byte) {
+//		if suiteHelper == nil {
+//			suiteHelper = tests.Functional().Attach(config).MustBuild()
+//		}
+//	})
+//
+// The first process owns the control plane, so it has to be stopped after
+// all the others are done. Stopping an attached helper does nothing.
+//
+//	var _ = SynchronizedAfterSuite(func() {}, func() {
+//		suiteHelper.MustStop()
+//	})
+//
+// Webhooks are served by each process's manager, so suites with webhooks
+// need a control plane per process.
+
+// The connection details needed to attach to a shared control plane.
+type sharedConfig struct {
+	Host     string `json:"host"`
+	CAData   []byte `json:"caData,omitempty"`
+	CertData []byte `json:"certData,omitempty"`
+	KeyData  []byte `json:"keyData,omitempty"`
+	Token    string `json:"token,omitempty"`
+}
+
+// Attach to a control plane started by another process rather than starting
+// one. The config comes from FunctionalSuiteHelper.Config. CRDs and webhooks
+// are not installed when attaching.
+func (b *functionalBuilder) Attach(config []byte) *functionalBuilder {
+	b.attachConfig = config
+	return b
+}
+
+func (b *functionalBuilder) attach() (*FunctionalSuiteHelper, error) {
+	shared := sharedConfig{}
+	err := json.Unmarshal(b.attachConfig, &shared)
+	if err != nil {
+		return nil, errors.Wrap(err, "error decoding shared config")
+	}
+	cfg := &rest.Config{
+		Host:        shared.Host,
+		BearerToken: shared.Token,
+		TLSClientConfig: rest.TLSClientConfig{
+			CAData:   shared.CAData,
+			CertData: shared.CertData,
+			KeyData:  shared.KeyData,
+		},
+	}
+
+	for _, adder := range b.apis {
+		err = adder(scheme.Scheme)
+		if err != nil {
+			return nil, errors.Wrap(err, "error adding scheme")
+		}
+	}
+
+	// Namespaces are cleaned up like with an external cluster since the
+	// control plane outlives this process.
+	return &FunctionalSuiteHelper{environment: &envtest.Environment{}, cfg: cfg, external: true, attached: true, fakeClock: b.fakeClock}, nil
+}
+
+// Serialize the connection details so other processes can Attach.
+func (fsh *FunctionalSuiteHelper) Config() ([]byte, error) {
+	if fsh.cfg == nil {
+		return nil, errors.New("control plane is not started")
+	}
+	return json.Marshal(sharedConfig{
+		Host:     fsh.cfg.Host,
+		CAData:   fsh.cfg.CAData,
+		CertData: fsh.cfg.CertData,
+		KeyData:  fsh.cfg.KeyData,
+		Token:    fsh.cfg.BearerToken,
+	})
+}
+
+func (fsh *FunctionalSuiteHelper) MustConfig() []byte {
+	config, err := fsh.Config()
+	if err != nil {
+		panic(err)
+	}
+	return config
+}
@@ -0,0 +1,240 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/onsi/gomega"
+	"github.com/pkg/errors"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/transport"
+)
+
+// One permission used by the controllers, as it would appear in a rule. Name
+// is set for requests on a single object, which rules restricted to
+// resourceNames can allow.
+type RBACRequest struct {
+	Verb     string
+	Group    string
+	Resource string
+	Name     string
+}
+
+func (r RBACRequest) String() string {
+	group := r.Group
+	if group == "" {
+		group = "core"
+	}
+	if r.Name != "" {
+		return fmt.Sprintf("%s %s/%s %s", r.Verb, group, r.Resource, r.Name)
+	}
+	return fmt.Sprintf("%s %s/%s", r.Verb, group, r.Resource)
+}
+
+// A transport wrapper which records the RBAC permission needed for every API
+// request made by the manager, including the cache and the uncached client.
+type rbacRecorder struct {
+	lock     sync.Mutex
+	requests map[RBACRequest]bool
+}
+
+type rbacRecordingTransport struct {
+	recorder *rbacRecorder
+	next     http.RoundTripper
+}
+
+func newRBACRecorder() *rbacRecorder {
+	return &rbacRecorder{requests: map[RBACRequest]bool{}}
+}
+
+// Return a copy of the config which records requests.
+func (r *rbacRecorder) wrapConfig(cfg *rest.Config) *rest.Config {
+	cfg = rest.CopyConfig(cfg)
+	cfg.WrapTransport = transport.Wrappers(cfg.WrapTransport, func(rt http.RoundTripper) http.RoundTripper {
+		return &rbacRecordingTransport{recorder: r, next: rt}
+	})
+	return cfg
+}
+
+func (t *rbacRecordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	rbacReqs := parseRBACRequest(req)
+	t.recorder.lock.Lock()
+	for _, rbacReq := range rbacReqs {
+		t.recorder.requests[rbacReq] = true
+	}
+	t.recorder.lock.Unlock()
+	return t.next.RoundTrip(req)
+}
+
+// Work out the verbs and resource for a request path like
+// /apis/apps/v1/namespaces/foo/deployments/bar/status. Non-resource requests
+// like discovery are ignored. Server-side apply can create the object as well
+// as patch it, so needs both verbs unless it's for a subresource. Like the API
+// server, a list or watch with a metadata.name field selector is for that name.
+func parseRBACRequest(req *http.Request) []RBACRequest {
+	parts := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+	var group string
+	switch {
+	case len(parts) >= 3 && parts[0] == "api":
+		parts = parts[2:]
+	case len(parts) >= 4 && parts[0] == "apis":
+		group = parts[1]
+		parts = parts[3:]
+	default:
+		return nil
+	}
+	if len(parts) >= 3 && parts[0] == "namespaces" {
+		parts = parts[2:]
+	}
+	resource := parts[0]
+	var name string
+	named := len(parts) >= 2
+	if named {
+		name = parts[1]
+	} else if selector := req.URL.Query().Get("fieldSelector"); strings.HasPrefix(selector, "metadata.name=") && !strings.Contains(selector, ",") {
+		name = strings.TrimPrefix(selector, "metadata.name=")
+	}
+	if len(parts) >= 3 {
+		resource = resource + "/" + parts[2]
+	}
+
+	var verb string
+	switch req.Method {
+	case http.MethodGet, http.MethodHead:
+		if req.URL.Query().Get("watch") == "true" {
+			verb = "watch"
+		} else if named {
+			verb = "get"
+		} else {
+			verb = "list"
+		}
+	case http.MethodPost:
+		verb = "create"
+	case http.MethodPut:
+		verb = "update"
+	case http.MethodPatch:
+		if req.Header.Get("Content-Type") == string(types.ApplyPatchType) && !strings.Contains(resource, "/") {
+			return []RBACRequest{
+				{Verb: "patch", Group: group, Resource: resource, Name: name},
+				{Verb: "create", Group: group, Resource: resource, Name: name},
+			}
+		}
+		verb = "patch"
+	case http.MethodDelete:
+		if named {
+			verb = "delete"
+		} else {
+			verb = "deletecollection"
+		}
+	default:
+		return nil
+	}
+	return []RBACRequest{{Verb: verb, Group: group, Resource: resource, Name: name}}
+}
+
+// All permissions used so far, in a stable order.
+func (r *rbacRecorder) Requests() []RBACRequest {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	requests := []RBACRequest{}
+	for req := range r.requests {
+		requests = append(requests, req)
+	}
+	sort.Slice(requests, func(i, j int) bool { return requests[i].String() < requests[j].String() })
+	return requests
+}
+
+// The permissions the controllers have used so far in this test.
+func (fh *FunctionalHelper) RBACRequests() []RBACRequest {
+	return fh.rbac.Requests()
+}
+
+// Check every permission the controllers have used so far is granted by a
+// Role or ClusterRole in the YAML files in the given directories, usually
+// config/rbac. Call this at the end of a test which exercises the
+// controllers, so missing RBAC rules fail in tests rather than after deploy.
+func (fh *FunctionalHelper) VerifyRBAC(dirs ...string) error {
+	rules := []rbacv1.PolicyRule{}
+	for _, dir := range dirs {
+		objs, err := readFixtures(dir, fh.Client.Scheme())
+		if err != nil {
+			return err
+		}
+		for _, obj := range objs {
+			switch role := obj.(type) {
+			case *rbacv1.ClusterRole:
+				rules = append(rules, role.Rules...)
+			case *rbacv1.Role:
+				rules = append(rules, role.Rules...)
+			}
+		}
+	}
+
+	missing := []string{}
+	for _, req := range fh.RBACRequests() {
+		if !rulesAllow(rules, req) {
+			missing = append(missing, req.String())
+		}
+	}
+	if len(missing) != 0 {
+		return errors.Errorf("controllers used permissions not granted by RBAC in %v:\n  %s", dirs, strings.Join(missing, "\n  "))
+	}
+	return nil
+}
+
+func (fh *FunctionalHelper) MustVerifyRBAC(dirs ...string) {
+	err := fh.VerifyRBAC(dirs...)
+	gomega.ExpectWithOffset(1, err).ToNot(gomega.HaveOccurred())
+}
+
+func rulesAllow(rules []rbacv1.PolicyRule, req RBACRequest) bool {
+	for _, rule := range rules {
+		// Rules limited to names only cover requests for one of those names.
+		if len(rule.ResourceNames) != 0 && !resourceNamesMatch(rule.ResourceNames, req.Name) {
+			continue
+		}
+		if ruleMatches(rule.Verbs, req.Verb) && ruleMatches(rule.APIGroups, req.Group) && ruleMatches(rule.Resources, req.Resource) {
+			return true
+		}
+	}
+	return false
+}
+
+func resourceNamesMatch(names []string, name string) bool {
+	for _, n := range names {
+		if name != "" && n == name {
+			return true
+		}
+	}
+	return false
+}
+
+func ruleMatches(values []string, value string) bool {
+	for _, v := range values {
+		if v == rbacv1.VerbAll || v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,118 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+var _ = ginkgo.Describe("parseRBACRequest", func() {
+	cases := []struct {
+		name        string
+		method      string
+		target      string
+		contentType string
+		expected    []RBACRequest
+	}{
+		{"a core get", http.MethodGet, "/api/v1/namespaces/default/configmaps/testing", "", []RBACRequest{{Verb: "get", Resource: "configmaps", Name: "testing"}}},
+		{"a named group get", http.MethodGet, "/apis/apps/v1/namespaces/default/deployments/testing", "", []RBACRequest{{Verb: "get", Group: "apps", Resource: "deployments", Name: "testing"}}},
+		{"a cluster scoped get", http.MethodGet, "/api/v1/nodes/testing", "", []RBACRequest{{Verb: "get", Resource: "nodes", Name: "testing"}}},
+		{"a cluster scoped group get", http.MethodGet, "/apis/rbac.authorization.k8s.io/v1/clusterroles/testing", "", []RBACRequest{{Verb: "get", Group: "rbac.authorization.k8s.io", Resource: "clusterroles", Name: "testing"}}},
+		{"a namespaced list", http.MethodGet, "/api/v1/namespaces/default/configmaps", "", []RBACRequest{{Verb: "list", Resource: "configmaps"}}},
+		{"a cluster list", http.MethodGet, "/apis/apps/v1/deployments", "", []RBACRequest{{Verb: "list", Group: "apps", Resource: "deployments"}}},
+		{"a list of namespaces", http.MethodGet, "/api/v1/namespaces", "", []RBACRequest{{Verb: "list", Resource: "namespaces"}}},
+		{"a get of a namespace", http.MethodGet, "/api/v1/namespaces/default", "", []RBACRequest{{Verb: "get", Resource: "namespaces", Name: "default"}}},
+		{"a watch", http.MethodGet, "/apis/apps/v1/namespaces/default/deployments?watch=true", "", []RBACRequest{{Verb: "watch", Group: "apps", Resource: "deployments"}}},
+		{"a watch by name", http.MethodGet, "/api/v1/namespaces/default/secrets?watch=true&fieldSelector=metadata.name%3Dtesting", "", []RBACRequest{{Verb: "watch", Resource: "secrets", Name: "testing"}}},
+		{"a list by name", http.MethodGet, "/api/v1/namespaces/default/secrets?fieldSelector=metadata.name%3Dtesting", "", []RBACRequest{{Verb: "list", Resource: "secrets", Name: "testing"}}},
+		{"a list with another field selector", http.MethodGet, "/api/v1/namespaces/default/secrets?fieldSelector=type%3Dopaque", "", []RBACRequest{{Verb: "list", Resource: "secrets"}}},
+		{"a subresource get", http.MethodGet, "/apis/apps/v1/namespaces/default/deployments/testing/scale", "", []RBACRequest{{Verb: "get", Group: "apps", Resource: "deployments/scale", Name: "testing"}}},
+		{"a create", http.MethodPost, "/api/v1/namespaces/default/configmaps", "", []RBACRequest{{Verb: "create", Resource: "configmaps"}}},
+		{"an update", http.MethodPut, "/api/v1/namespaces/default/configmaps/testing", "", []RBACRequest{{Verb: "update", Resource: "configmaps", Name: "testing"}}},
+		{"a status update", http.MethodPut, "/apis/apps/v1/namespaces/default/deployments/testing/status", "", []RBACRequest{{Verb: "update", Group: "apps", Resource: "deployments/status", Name: "testing"}}},
+		{"a merge patch", http.MethodPatch, "/api/v1/namespaces/default/configmaps/testing", string(types.MergePatchType), []RBACRequest{{Verb: "patch", Resource: "configmaps", Name: "testing"}}},
+		{"a server-side apply patch", http.MethodPatch, "/api/v1/namespaces/default/configmaps/testing", string(types.ApplyPatchType), []RBACRequest{
+			{Verb: "patch", Resource: "configmaps", Name: "testing"},
+			{Verb: "create", Resource: "configmaps", Name: "testing"},
+		}},
+		{"a server-side apply status patch", http.MethodPatch, "/apis/apps/v1/namespaces/default/deployments/testing/status", string(types.ApplyPatchType), []RBACRequest{{Verb: "patch", Group: "apps", Resource: "deployments/status", Name: "testing"}}},
+		{"a delete", http.MethodDelete, "/api/v1/namespaces/default/configmaps/testing", "", []RBACRequest{{Verb: "delete", Resource: "configmaps", Name: "testing"}}},
+		{"a deletecollection", http.MethodDelete, "/api/v1/namespaces/default/configmaps", "", []RBACRequest{{Verb: "deletecollection", Resource: "configmaps"}}},
+		{"core discovery", http.MethodGet, "/api/v1", "", nil},
+		{"group discovery", http.MethodGet, "/apis/apps/v1", "", nil},
+		{"a non-resource path", http.MethodGet, "/healthz", "", nil},
+		{"an unknown method", http.MethodOptions, "/api/v1/namespaces/default/configmaps", "", nil},
+	}
+	for _, tc := range cases {
+		tc := tc
+		ginkgo.It("parses "+tc.name, func() {
+			req := httptest.NewRequest(tc.method, tc.target, nil)
+			if tc.contentType != "" {
+				req.Header.Set("Content-Type", tc.contentType)
+			}
+			Expect(parseRBACRequest(req)).To(Equal(tc.expected))
+		})
+	}
+})
+
+var _ = ginkgo.Describe("rulesAllow", func() {
+	rules := []rbacv1.PolicyRule{
+		{APIGroups: []string{""}, Resources: []string{"configmaps"}, Verbs: []string{"get", "list", "watch"}},
+		{APIGroups: []string{"apps"}, Resources: []string{"deployments", "deployments/status"}, Verbs: []string{"*"}},
+		{APIGroups: []string{""}, Resources: []string{"secrets"}, Verbs: []string{"get", "watch", "list", "update", "patch", "delete"}, ResourceNames: []string{"allowed"}},
+		{APIGroups: []string{"*"}, Resources: []string{"events"}, Verbs: []string{"create"}},
+	}
+
+	cases := []struct {
+		name     string
+		req      RBACRequest
+		expected bool
+	}{
+		{"a listed core verb", RBACRequest{Verb: "get", Resource: "configmaps", Name: "testing"}, true},
+		{"a core list", RBACRequest{Verb: "list", Resource: "configmaps"}, true},
+		{"an unlisted core verb", RBACRequest{Verb: "update", Resource: "configmaps", Name: "testing"}, false},
+		{"the wrong group", RBACRequest{Verb: "get", Group: "apps", Resource: "configmaps", Name: "testing"}, false},
+		{"a wildcard verb", RBACRequest{Verb: "deletecollection", Group: "apps", Resource: "deployments"}, true},
+		{"a listed subresource", RBACRequest{Verb: "patch", Group: "apps", Resource: "deployments/status", Name: "testing"}, true},
+		{"an unlisted subresource", RBACRequest{Verb: "update", Group: "apps", Resource: "deployments/scale", Name: "testing"}, false},
+		{"a wildcard group", RBACRequest{Verb: "create", Group: "events.k8s.io", Resource: "events"}, true},
+		{"a listed resource name", RBACRequest{Verb: "get", Resource: "secrets", Name: "allowed"}, true},
+		{"a watch of a listed resource name", RBACRequest{Verb: "watch", Resource: "secrets", Name: "allowed"}, true},
+		{"an unlisted resource name", RBACRequest{Verb: "get", Resource: "secrets", Name: "other"}, false},
+		{"a list without a name against resource names", RBACRequest{Verb: "list", Resource: "secrets"}, false},
+		{"a deletecollection against resource names", RBACRequest{Verb: "deletecollection", Resource: "secrets"}, false},
+		{"a create without a name against resource names", RBACRequest{Verb: "create", Resource: "secrets"}, false},
+		{"an unknown resource", RBACRequest{Verb: "get", Resource: "pods", Name: "testing"}, false},
+	}
+	for _, tc := range cases {
+		tc := tc
+		ginkgo.It("checks "+tc.name, func() {
+			Expect(rulesAllow(rules, tc.req)).To(Equal(tc.expected))
+		})
+	}
+
+	ginkgo.It("formats requests", func() {
+		Expect(RBACRequest{Verb: "list", Resource: "configmaps"}.String()).To(Equal("list core/configmaps"))
+		Expect(RBACRequest{Verb: "get", Group: "apps", Resource: "deployments", Name: "testing"}.String()).To(Equal("get apps/deployments testing"))
+	})
+})
@@ -0,0 +1,80 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tests
+
+import (
+	"sync"
+	"time"
+
+	"github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Counts reconciles per object across all controllers in the manager.
+type reconcileCounter struct {
+	lock   sync.Mutex
+	counts map[types.NamespacedName]int
+}
+
+func newReconcileCounter() *reconcileCounter {
+	return &reconcileCounter{counts: map[types.NamespacedName]int{}}
+}
+
+func (c *reconcileCounter) ObserveReconcile(_ string, req ctrl.Request, _ ctrl.Result, _ error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.counts[req.NamespacedName]++
+}
+
+func (c *reconcileCounter) count(key types.NamespacedName) int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.counts[key]
+}
+
+func (fh *FunctionalHelper) reconcileKey(obj client.Object) types.NamespacedName {
+	key := client.ObjectKeyFromObject(obj)
+	if key.Namespace == "" {
+		key.Namespace = fh.Namespace
+	}
+	return key
+}
+
+// How many times the object has been reconciled by a core.Reconciler.
+func (fh *FunctionalHelper) ReconcileCount(obj client.Object) int {
+	return fh.reconciles.count(fh.reconcileKey(obj))
+}
+
+// Wait for the object to have been reconciled at least n times.
+func (fh *FunctionalHelper) EventuallyReconciledAtLeast(obj client.Object, n int) {
+	key := fh.reconcileKey(obj)
+	gomega.EventuallyWithOffset(1, func() int {
+		return fh.reconciles.count(key)
+	}, DefaultTimeout).Should(gomega.BeNumerically(">=", n))
+}
+
+// Check that the object is not reconciled again for the given duration, for
+// showing that a predicate suppresses an update.
+func (fh *FunctionalHelper) ExpectNoReconcilesFor(obj client.Object, duration time.Duration) {
+	key := fh.reconcileKey(obj)
+	start := fh.reconciles.count(key)
+	gomega.ConsistentlyWithOffset(1, func() int {
+		return fh.reconciles.count(key)
+	}, duration).Should(gomega.Equal(start))
+}
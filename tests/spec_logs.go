@@ -0,0 +1,82 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tests
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/onsi/ginkgo"
+	"github.com/pkg/errors"
+)
+
+// Directory for per-spec log files written through RecordingLogger.
+// Defaults to $TEST_LOG_DIR, or a directory under the system temp dir.
+var LogDir = os.Getenv("TEST_LOG_DIR")
+
+// The open per-spec log files, every recorded line goes to all of them.
+var specLogs = struct {
+	lock  sync.Mutex
+	files map[*os.File]bool
+}{files: map[*os.File]bool{}}
+
+var unsafeFilenameChars = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+// Open the log file for the current spec, named by the spec text and the
+// test namespace.
+func openSpecLog(namespace string) (*os.File, error) {
+	dir := LogDir
+	if dir == "" {
+		dir = filepath.Join(os.TempDir(), "controller-utils-test-logs")
+	}
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error creating log directory %s", dir)
+	}
+	name := unsafeFilenameChars.ReplaceAllString(ginkgo.CurrentGinkgoTestDescription().FullTestText, "_")
+	if len(name) > 100 {
+		name = name[:100]
+	}
+	path := filepath.Join(dir, fmt.Sprintf("%s-%s.log", name, namespace))
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error opening log file %s", path)
+	}
+	specLogs.lock.Lock()
+	defer specLogs.lock.Unlock()
+	specLogs.files[f] = true
+	return f, nil
+}
+
+func closeSpecLog(f *os.File) error {
+	specLogs.lock.Lock()
+	delete(specLogs.files, f)
+	specLogs.lock.Unlock()
+	return f.Close()
+}
+
+func writeSpecLogs(line string) {
+	specLogs.lock.Lock()
+	defer specLogs.lock.Unlock()
+	for f := range specLogs.files {
+		fmt.Fprintf(f, "%s\t%s\n", time.Now().Format(time.RFC3339Nano), line)
+	}
+}
@@ -0,0 +1,91 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tests
+
+import (
+	"context"
+	"reflect"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+type staleKey struct {
+	gvk schema.GroupVersionKind
+	key types.NamespacedName
+}
+
+// A client emulating the cached client in unit tests. Normally reads go
+// straight through, but while stale it returns the last version it read of
+// each object, like an informer which hasn't seen recent writes yet. Lists
+// are never stale.
+type staleClient struct {
+	client.Client
+
+	lock  sync.Mutex
+	stale bool
+	seen  map[staleKey]client.Object
+}
+
+func newStaleClient(c client.Client) *staleClient {
+	return &staleClient{Client: c, seen: map[staleKey]client.Object{}}
+}
+
+func (c *staleClient) setStale(stale bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.stale = stale
+}
+
+func (c *staleClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+	gvk, err := apiutil.GVKForObject(obj, c.Scheme())
+	if err != nil {
+		return err
+	}
+	sk := staleKey{gvk: gvk, key: key}
+
+	c.lock.Lock()
+	seen, ok := c.seen[sk]
+	stale := c.stale
+	c.lock.Unlock()
+	if stale && ok && reflect.TypeOf(seen) == reflect.TypeOf(obj) {
+		reflect.ValueOf(obj).Elem().Set(reflect.ValueOf(seen.DeepCopyObject()).Elem())
+		return nil
+	}
+
+	err = c.Client.Get(ctx, key, obj, opts...)
+	if err != nil {
+		return err
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if !c.stale {
+		c.seen[sk] = obj.DeepCopyObject().(client.Object)
+	}
+	return nil
+}
+
+// Make the components' cached client return the last version of each object
+// it read, while the uncached client sees the current state. Use this to
+// test components handle a lagging cache, e.g. by using the uncached client
+// for objects they just wrote.
+func (uh *UnitHelper) SetCacheStale(stale bool) {
+	uh.cachedClient.setStale(stale)
+}
@@ -0,0 +1,92 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tests_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/coderanger/controller-utils/core"
+	"github.com/coderanger/controller-utils/tests"
+)
+
+var _ = Describe("UnitHelper stale cache", func() {
+	var uh *tests.UnitHelper
+	var cached, uncached, listed string
+	key := client.ObjectKey{Name: "stale", Namespace: "default"}
+
+	BeforeEach(func() {
+		comp := reconcileFunc(func(ctx *core.Context) (core.Result, error) {
+			configMap := &corev1.ConfigMap{}
+			err := ctx.Client.Get(ctx, key, configMap)
+			if err != nil {
+				return core.Result{}, err
+			}
+			cached = configMap.Data["version"]
+			err = ctx.UncachedClient.Get(ctx, key, configMap)
+			if err != nil {
+				return core.Result{}, err
+			}
+			uncached = configMap.Data["version"]
+			list := &corev1.ConfigMapList{}
+			err = ctx.Client.List(ctx, list, client.InNamespace("default"))
+			if err != nil {
+				return core.Result{}, err
+			}
+			listed = list.Items[0].Data["version"]
+			return core.Result{}, nil
+		})
+		uh = tests.Unit().API(TestObjectSchemeBuilder.AddToScheme).MustBuild().SetupComponents(&TestObject{}, comp)
+		uh.TestClient.Create(&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "stale"},
+			Data:       map[string]string{"version": "1"},
+		})
+		uh.MustReconcile()
+	})
+
+	setVersion := func(version string) {
+		configMap := &corev1.ConfigMap{}
+		Expect(uh.Client.Get(context.Background(), key, configMap)).To(Succeed())
+		configMap.Data["version"] = version
+		Expect(uh.Client.Update(context.Background(), configMap)).To(Succeed())
+	}
+
+	It("reads through by default", func() {
+		setVersion("2")
+		uh.MustReconcile()
+		Expect(cached).To(Equal("2"))
+		Expect(uncached).To(Equal("2"))
+	})
+
+	It("returns the last read from the cached client while stale", func() {
+		uh.SetCacheStale(true)
+		setVersion("2")
+		uh.MustReconcile()
+		Expect(cached).To(Equal("1"))
+		Expect(uncached).To(Equal("2"))
+		Expect(listed).To(Equal("2"))
+
+		uh.SetCacheStale(false)
+		uh.MustReconcile()
+		Expect(cached).To(Equal("2"))
+	})
+})
@@ -0,0 +1,137 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tests
+
+import (
+	"context"
+
+	"github.com/onsi/gomega"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+
+	"github.com/coderanger/controller-utils/core"
+	"github.com/coderanger/controller-utils/tests/matchers"
+)
+
+// A table of reconcile cases run against fresh components and a fake
+// client, for exhaustive component tests without a Ginkgo block per case.
+//
+//	ush.RunReconcileTable(tests.ReconcileTable{
+//		Components: func() []core.Component { return []core.Component{NewThing()} },
+//		Cases: []tests.ReconcileCase{{
+//			Name:             "creates the thing",
+//			Object:           &MyObject{Spec: MySpec{Field: "foo"}},
+//			ExpectObjects:    []client.Object{&corev1.ConfigMap{...}},
+//			ExpectConditions: map[string]string{"ThingReady": "True"},
+//		}},
+//	})
+type ReconcileTable struct {
+	// Builds the components for each case, so no state leaks between cases.
+	Components func() []core.Component
+	Cases      []ReconcileCase
+}
+
+type ReconcileCase struct {
+	Name string
+	// Objects which exist before the reconcile, defaulting to the object's namespace.
+	Existing []client.Object
+	// The object to reconcile.
+	Object client.Object
+	// Objects expected after the reconcile. Only fields set here are
+	// compared, see matchers.MatchUnstructuredFields.
+	ExpectObjects []client.Object
+	// Objects expected not to exist after the reconcile.
+	ExpectAbsent []client.Object
+	// Expected status of each condition type on the reconciled object.
+	ExpectConditions map[string]string
+	ExpectError      bool
+	// If set, the expected merged result.
+	ExpectResult *core.Result
+}
+
+// Run every case in the table, failing with the name of the first case
+// which doesn't match.
+func (ush *UnitSuiteHelper) RunReconcileTable(table ReconcileTable) {
+	for _, tc := range table.Cases {
+		ush.runReconcileCase(table.Components(), tc)
+	}
+}
+
+func (ush *UnitSuiteHelper) runReconcileCase(comps []core.Component, tc ReconcileCase) {
+	uh := ush.SetupComponents(tc.Object.DeepCopyObject().(client.Object), comps...)
+	ctx := context.Background()
+	for _, existing := range tc.Existing {
+		obj := existing.DeepCopyObject().(client.Object)
+		defaultNamespace(obj, uh.Object.GetNamespace())
+		err := uh.Client.Create(ctx, obj)
+		gomega.ExpectWithOffset(2, err).ToNot(gomega.HaveOccurred(), "%s: error creating existing %T %s", tc.Name, obj, obj.GetName())
+	}
+
+	result, err := uh.Reconcile()
+	if tc.ExpectError {
+		gomega.ExpectWithOffset(2, err).To(gomega.HaveOccurred(), "%s: expected a reconcile error", tc.Name)
+	} else {
+		gomega.ExpectWithOffset(2, err).ToNot(gomega.HaveOccurred(), "%s: unexpected reconcile error", tc.Name)
+	}
+	if tc.ExpectResult != nil {
+		gomega.ExpectWithOffset(2, result).To(gomega.Equal(*tc.ExpectResult), "%s: unexpected result", tc.Name)
+	}
+
+	for conditionType, expected := range tc.ExpectConditions {
+		status, err := getConditionStatus(uh.Object, conditionType)
+		gomega.ExpectWithOffset(2, err).ToNot(gomega.HaveOccurred(), "%s: error reading condition %s", tc.Name, conditionType)
+		gomega.ExpectWithOffset(2, status).To(gomega.Equal(expected), "%s: unexpected status for condition %s", tc.Name, conditionType)
+	}
+
+	for _, expectedObj := range tc.ExpectObjects {
+		expected, actual, err := ush.fetchExpected(uh, expectedObj)
+		gomega.ExpectWithOffset(2, err).ToNot(gomega.HaveOccurred(), "%s: error getting %T %s", tc.Name, expectedObj, expectedObj.GetName())
+		gomega.ExpectWithOffset(2, actual).To(matchers.MatchUnstructuredFields(expected), "%s: %T %s does not match", tc.Name, expectedObj, expectedObj.GetName())
+	}
+
+	for _, absentObj := range tc.ExpectAbsent {
+		_, _, err := ush.fetchExpected(uh, absentObj)
+		gomega.ExpectWithOffset(2, kerrors.IsNotFound(err)).To(gomega.BeTrue(), "%s: expected %T %s to not exist, got %v", tc.Name, absentObj, absentObj.GetName(), err)
+	}
+}
+
+// Convert the expected object to unstructured and fetch the matching
+// object from the fake client.
+func (ush *UnitSuiteHelper) fetchExpected(uh *UnitHelper, obj client.Object) (*unstructured.Unstructured, *unstructured.Unstructured, error) {
+	gvk, err := apiutil.GVKForObject(obj, ush.scheme)
+	if err != nil {
+		return nil, nil, err
+	}
+	data, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return nil, nil, err
+	}
+	expected := &unstructured.Unstructured{Object: data}
+	expected.SetGroupVersionKind(gvk)
+	defaultNamespace(expected, uh.Object.GetNamespace())
+
+	actual := &unstructured.Unstructured{}
+	actual.SetGroupVersionKind(gvk)
+	err = uh.Client.Get(context.Background(), client.ObjectKeyFromObject(expected), actual)
+	if err != nil {
+		return nil, nil, err
+	}
+	return expected, actual, nil
+}
@@ -0,0 +1,95 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tests_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/coderanger/controller-utils/core"
+	"github.com/coderanger/controller-utils/tests"
+)
+
+// Writes Spec.Field into a ConfigMap, or deletes it if the field is "delete".
+func tableComponent() reconcileFunc {
+	return func(ctx *core.Context) (core.Result, error) {
+		obj := ctx.Object.(*TestObject)
+		configMap := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: obj.Name + "-table", Namespace: obj.Namespace}}
+		switch obj.Spec.Field {
+		case "delete":
+			err := ctx.Client.Delete(ctx, configMap)
+			if err != nil {
+				return core.Result{}, client.IgnoreNotFound(err)
+			}
+			ctx.Conditions.SetfFalse("TableReady", "Deleted", "Deleted the ConfigMap")
+			return core.Result{}, nil
+		case "fail":
+			return core.Result{}, errors.New("asked to fail")
+		}
+		configMap.Data = map[string]string{"field": obj.Spec.Field}
+		err := ctx.Client.Create(ctx, configMap)
+		if err != nil {
+			return core.Result{}, err
+		}
+		ctx.Conditions.SetfTrue("TableReady", "Created", "Created the ConfigMap")
+		return core.Result{RequeueAfter: time.Minute}, nil
+	}
+}
+
+var _ = Describe("ReconcileTable", func() {
+	It("runs each case", func() {
+		ush := tests.Unit().API(TestObjectSchemeBuilder.AddToScheme).MustBuild()
+		ush.RunReconcileTable(tests.ReconcileTable{
+			Components: func() []core.Component { return []core.Component{tableComponent()} },
+			Cases: []tests.ReconcileCase{
+				{
+					Name:   "creates the ConfigMap",
+					Object: &TestObject{Spec: TestObjectSpec{Field: "foo"}},
+					ExpectObjects: []client.Object{
+						&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "testing-table"}, Data: map[string]string{"field": "foo"}},
+					},
+					ExpectConditions: map[string]string{"TableReady": "True"},
+					ExpectResult:     &core.Result{RequeueAfter: time.Minute},
+				},
+				{
+					Name:   "deletes an existing ConfigMap",
+					Object: &TestObject{Spec: TestObjectSpec{Field: "delete"}},
+					Existing: []client.Object{
+						&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "testing-table"}},
+					},
+					ExpectAbsent: []client.Object{
+						&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "testing-table"}},
+					},
+					ExpectConditions: map[string]string{"TableReady": "False"},
+				},
+				{
+					Name:        "fails",
+					Object:      &TestObject{Spec: TestObjectSpec{Field: "fail"}},
+					ExpectError: true,
+					ExpectAbsent: []client.Object{
+						&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "testing-table"}},
+					},
+				},
+			},
+		})
+	})
+})
@@ -18,17 +18,22 @@ package tests
 
 import (
 	"context"
+	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/onsi/gomega"
 	"github.com/pkg/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/tools/record"
+	clocktesting "k8s.io/utils/clock/testing"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 
 	"github.com/coderanger/controller-utils/core"
@@ -45,12 +50,22 @@ type UnitSuiteHelper struct {
 }
 
 type UnitHelper struct {
-	Comp       core.Component
+	// The first component, for the common single component case.
+	Comp core.Component
+	// All components, run in order against the same Context.
+	Comps      []core.Component
 	Client     client.Client
 	TestClient *testClient
-	Object     client.Object
-	Events     chan string
-	Ctx        *core.Context
+	// Fault injection for the client used by the components.
+	Faults *FaultClient
+	// Fake clock used by the components, step it to test time-based behavior.
+	Clock  *clocktesting.FakeClock
+	Object client.Object
+	Events chan string
+	Ctx    *core.Context
+
+	recordedEvents []string
+	cachedClient   *staleClient
 }
 
 func Unit() *unitBuilder {
@@ -94,7 +109,16 @@ func (b *unitBuilder) MustBuild() *UnitSuiteHelper {
 }
 
 func (ush *UnitSuiteHelper) Setup(comp core.Component, obj client.Object) *UnitHelper {
-	uh := &UnitHelper{Comp: comp}
+	return ush.SetupComponents(obj, comp)
+}
+
+// Set up a pipeline of components which run in order and share ctx.Data and
+// conditions, like they would in a Reconciler.
+func (ush *UnitSuiteHelper) SetupComponents(obj client.Object, comps ...core.Component) *UnitHelper {
+	uh := &UnitHelper{Comps: comps}
+	if len(comps) != 0 {
+		uh.Comp = comps[0]
+	}
 
 	metaObj := obj.(metav1.Object)
 	if metaObj.GetName() == "" {
@@ -105,8 +129,11 @@ func (ush *UnitSuiteHelper) Setup(comp core.Component, obj client.Object) *UnitH
 	}
 	uh.Object = obj
 
-	uh.Client = fake.NewFakeClientWithScheme(ush.scheme, uh.Object)
+	uh.Client = newApplyClient(fake.NewFakeClientWithScheme(ush.scheme, uh.Object))
 	uh.TestClient = &testClient{client: uh.Client, namespace: metaObj.GetNamespace()}
+	uh.Faults = NewFaultClient(uh.Client)
+	uh.cachedClient = newStaleClient(uh.Faults)
+	uh.Clock = clocktesting.NewFakeClock(time.Now())
 
 	events := record.NewFakeRecorder(100)
 	uh.Events = events.Events
@@ -114,33 +141,84 @@ func (ush *UnitSuiteHelper) Setup(comp core.Component, obj client.Object) *UnitH
 	ctx := &core.Context{
 		Context:        context.Background(),
 		Object:         uh.Object,
-		Client:         uh.Client,
-		UncachedClient: uh.Client,
+		Client:         uh.cachedClient,
+		UncachedClient: uh.Faults,
 		Templates:      ush.templates,
 		FieldManager:   "unit-tests",
 		Scheme:         ush.scheme,
 		Data:           core.ContextData{},
 		Events:         events,
-		Conditions:     core.NewConditionsHelper(uh.Object),
+		Conditions:     core.NewConditionsHelperWithClock(uh.Object, uh.Clock),
 		Log:            ctrl.Log.WithName("component"),
+		Clock:          uh.Clock,
 	}
 	uh.Ctx = ctx
 
 	return uh
 }
 
+// Run all the components in order, stopping early if one sets SkipRemaining.
+// Results are merged and errors collected as in a Reconciler.
 func (uh *UnitHelper) Reconcile() (core.Result, error) {
 	defaulter, ok := uh.Object.(admission.Defaulter)
 	if ok {
 		defaulter.Default()
 	}
 	uh.TestClient.Update(uh.Object)
-	res, err := uh.Comp.Reconcile(uh.Ctx)
-	compErr := uh.Ctx.Conditions.Flush()
-	if compErr != nil && err == nil {
-		err = compErr
+	result := core.Result{}
+	errs := []error{}
+	for i, comp := range uh.Comps {
+		err := uh.setComponentClient(comp)
+		if err != nil {
+			return result, err
+		}
+		res, err := comp.Reconcile(uh.Ctx)
+		compErr := uh.Ctx.Conditions.Flush()
+		if compErr != nil && err == nil {
+			err = compErr
+		}
+		if err != nil && len(uh.Comps) > 1 {
+			err = errors.Wrapf(err, "error in component %d", i)
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+		if needsFinalizer(comp) {
+			controllerutil.AddFinalizer(uh.Object, uh.FinalizerName(i))
+		}
+		result = mergeUnitResult(result, res)
+		if res.SkipRemaining {
+			break
+		}
 	}
-	return res, err
+	return result, unitError(errs)
+}
+
+// Point Ctx.Client at the client the Reconciler would give the component,
+// see core.UncachedComponent.
+func (uh *UnitHelper) setComponentClient(comp core.Component) error {
+	c, err := core.ClientForComponent(comp, uh.cachedClient, uh.Faults, uh.Ctx.Scheme)
+	if err != nil {
+		return errors.Wrap(err, "error building component client")
+	}
+	uh.Ctx.Client = c
+	return nil
+}
+
+func mergeUnitResult(result core.Result, res core.Result) core.Result {
+	result.Requeue = result.Requeue || res.Requeue
+	if res.RequeueAfter != 0 && (result.RequeueAfter == 0 || result.RequeueAfter > res.RequeueAfter) {
+		result.RequeueAfter = res.RequeueAfter
+	}
+	result.SkipRemaining = result.SkipRemaining || res.SkipRemaining
+	return result
+}
+
+func unitError(errs []error) error {
+	if len(errs) == 1 {
+		return errs[0]
+	}
+	return utilerrors.NewAggregate(errs)
 }
 
 func (uh *UnitHelper) MustReconcile() core.Result {
@@ -149,22 +227,54 @@ func (uh *UnitHelper) MustReconcile() core.Result {
 	return res
 }
 
+// Mark the object as deleted and run all components which are finalizers.
+// Done is true only if all of them are, and each finalizer which is done is
+// removed from the object.
 func (uh *UnitHelper) Finalize() (core.Result, bool, error) {
-	finalizer, ok := uh.Comp.(core.FinalizerComponent)
-	if !ok {
-		return core.Result{}, false, errors.New("component is not a finalizer")
+	if uh.Object.GetDeletionTimestamp() == nil {
+		now := metav1.NewTime(uh.Clock.Now())
+		uh.Object.SetDeletionTimestamp(&now)
 	}
 	defaulter, ok := uh.Object.(admission.Defaulter)
 	if ok {
 		defaulter.Default()
 	}
 	uh.TestClient.Update(uh.Object)
-	res, done, err := finalizer.Finalize(uh.Ctx)
-	compErr := uh.Ctx.Conditions.Flush()
-	if compErr != nil && err == nil {
-		err = compErr
+	result := core.Result{}
+	allDone := true
+	errs := []error{}
+	found := false
+	for i, comp := range uh.Comps {
+		finalizer, ok := comp.(core.FinalizerComponent)
+		if !ok {
+			continue
+		}
+		found = true
+		err := uh.setComponentClient(comp)
+		if err != nil {
+			return result, false, err
+		}
+		res, done, err := finalizer.Finalize(uh.Ctx)
+		compErr := uh.Ctx.Conditions.Flush()
+		if compErr != nil && err == nil {
+			err = compErr
+		}
+		if err != nil && len(uh.Comps) > 1 {
+			err = errors.Wrapf(err, "error in component %d", i)
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+		if done {
+			controllerutil.RemoveFinalizer(uh.Object, uh.FinalizerName(i))
+		}
+		result = mergeUnitResult(result, res)
+		allDone = allDone && done
+	}
+	if !found {
+		return core.Result{}, false, errors.New("component is not a finalizer")
 	}
-	return res, done, err
+	return result, allDone, unitError(errs)
 }
 
 func (uh *UnitHelper) MustFinalize() (core.Result, bool) {
@@ -172,3 +282,34 @@ func (uh *UnitHelper) MustFinalize() (core.Result, bool) {
 	gomega.Expect(err).NotTo(gomega.HaveOccurred())
 	return res, done
 }
+
+// The finalizer added for the component at index i, the unit test version
+// of the per-component finalizers a Reconciler adds.
+func (uh *UnitHelper) FinalizerName(i int) string {
+	return fmt.Sprintf("unit-tests/component%d", i)
+}
+
+func needsFinalizer(comp core.Component) bool {
+	optionalFinalizer, ok := comp.(core.OptionalFinalizerComponent)
+	if ok {
+		return optionalFinalizer.NeedsFinalizer()
+	}
+	_, ok = comp.(core.FinalizerComponent)
+	return ok
+}
+
+// Assert that the object has the finalizer for every component that needs one.
+func (uh *UnitHelper) ExpectFinalizers() {
+	for i, comp := range uh.Comps {
+		if needsFinalizer(comp) {
+			gomega.ExpectWithOffset(1, uh.Object.GetFinalizers()).To(gomega.ContainElement(uh.FinalizerName(i)))
+		}
+	}
+}
+
+// Assert that all the component finalizers have been removed.
+func (uh *UnitHelper) ExpectNoFinalizers() {
+	for i := range uh.Comps {
+		gomega.ExpectWithOffset(1, uh.Object.GetFinalizers()).ToNot(gomega.ContainElement(uh.FinalizerName(i)))
+	}
+}
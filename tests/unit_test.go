@@ -0,0 +1,161 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tests_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+
+	"github.com/coderanger/controller-utils/conditions"
+	"github.com/coderanger/controller-utils/core"
+	"github.com/coderanger/controller-utils/tests"
+)
+
+type reconcileFunc func(*core.Context) (core.Result, error)
+
+func (f reconcileFunc) Reconcile(ctx *core.Context) (core.Result, error) {
+	return f(ctx)
+}
+
+type finalizerComponent struct {
+	done      bool
+	finalized int
+}
+
+func (comp *finalizerComponent) Reconcile(_ *core.Context) (core.Result, error) {
+	return core.Result{}, nil
+}
+
+func (comp *finalizerComponent) Finalize(_ *core.Context) (core.Result, bool, error) {
+	comp.finalized++
+	return core.Result{}, comp.done, nil
+}
+
+var _ = Describe("UnitHelper components", func() {
+	var ush *tests.UnitSuiteHelper
+
+	BeforeEach(func() {
+		ush = tests.Unit().API(TestObjectSchemeBuilder.AddToScheme).MustBuild()
+	})
+
+	It("shares the context between components", func() {
+		first := reconcileFunc(func(ctx *core.Context) (core.Result, error) {
+			ctx.Data["value"] = "from first"
+			return core.Result{}, nil
+		})
+		var seen interface{}
+		second := reconcileFunc(func(ctx *core.Context) (core.Result, error) {
+			seen = ctx.Data["value"]
+			return core.Result{}, nil
+		})
+		uh := ush.SetupComponents(&TestObject{}, first, second)
+		Expect(uh.Comp).To(BeAssignableToTypeOf(first))
+		Expect(uh.Comps).To(HaveLen(2))
+		uh.MustReconcile()
+		Expect(seen).To(Equal("from first"))
+	})
+
+	It("flushes conditions after each component", func() {
+		var seen *conditions.Condition
+		uh := ush.SetupComponents(&TestObject{},
+			reconcileFunc(func(ctx *core.Context) (core.Result, error) {
+				ctx.Conditions.SetfTrue("FirstReady", "Done", "First is done")
+				return core.Result{}, nil
+			}),
+			reconcileFunc(func(ctx *core.Context) (core.Result, error) {
+				seen = conditions.FindStatusCondition(ctx.Object.(*TestObject).Status.Conditions, "FirstReady")
+				return core.Result{}, nil
+			}),
+		)
+		uh.MustReconcile()
+		Expect(seen).ToNot(BeNil())
+		Expect(seen.Reason).To(Equal("Done"))
+	})
+
+	It("merges results", func() {
+		uh := ush.SetupComponents(&TestObject{},
+			reconcileFunc(func(_ *core.Context) (core.Result, error) {
+				return core.Result{RequeueAfter: time.Minute}, nil
+			}),
+			reconcileFunc(func(_ *core.Context) (core.Result, error) {
+				return core.Result{Requeue: true, RequeueAfter: time.Second}, nil
+			}),
+		)
+		Expect(uh.MustReconcile()).To(Equal(core.Result{Requeue: true, RequeueAfter: time.Second}))
+	})
+
+	It("stops after SkipRemaining", func() {
+		called := false
+		uh := ush.SetupComponents(&TestObject{},
+			reconcileFunc(func(_ *core.Context) (core.Result, error) {
+				return core.Result{SkipRemaining: true}, nil
+			}),
+			reconcileFunc(func(_ *core.Context) (core.Result, error) {
+				called = true
+				return core.Result{}, nil
+			}),
+		)
+		uh.MustReconcile()
+		Expect(called).To(BeFalse())
+	})
+
+	It("collects errors from each component", func() {
+		uh := ush.SetupComponents(&TestObject{},
+			reconcileFunc(func(_ *core.Context) (core.Result, error) {
+				return core.Result{}, errors.New("first failed")
+			}),
+			reconcileFunc(func(_ *core.Context) (core.Result, error) {
+				return core.Result{}, errors.New("second failed")
+			}),
+		)
+		_, err := uh.Reconcile()
+		Expect(err).To(MatchError(ContainSubstring("error in component 0: first failed")))
+		Expect(err).To(MatchError(ContainSubstring("error in component 1: second failed")))
+	})
+
+	It("finalizes components which are finalizers", func() {
+		comp := &finalizerComponent{}
+		uh := ush.SetupComponents(&TestObject{}, reconcileFunc(func(_ *core.Context) (core.Result, error) {
+			return core.Result{}, nil
+		}), comp)
+		uh.MustReconcile()
+		uh.ExpectFinalizers()
+		Expect(uh.Object.GetFinalizers()).To(ConsistOf(uh.FinalizerName(1)))
+
+		_, done := uh.MustFinalize()
+		Expect(done).To(BeFalse())
+		Expect(comp.finalized).To(Equal(1))
+		Expect(uh.Object.GetDeletionTimestamp()).ToNot(BeNil())
+		uh.ExpectFinalizers()
+
+		comp.done = true
+		_, done = uh.MustFinalize()
+		Expect(done).To(BeTrue())
+		uh.ExpectNoFinalizers()
+	})
+
+	It("rejects finalizing without a finalizer", func() {
+		uh := ush.SetupComponents(&TestObject{}, reconcileFunc(func(_ *core.Context) (core.Result, error) {
+			return core.Result{}, nil
+		}))
+		_, _, err := uh.Finalize()
+		Expect(err).To(MatchError("component is not a finalizer"))
+	})
+})
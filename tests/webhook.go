@@ -0,0 +1,107 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tests
+
+import (
+	"github.com/onsi/gomega"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// Helpers to call webhook implementations directly, without serving certs.
+// Validation errors are returned so they can be checked with matchers like
+// HaveOccurred or matchers.HaveFieldError.
+
+func defaultObject(obj runtime.Object) {
+	defaulter, ok := obj.(admission.Defaulter)
+	gomega.ExpectWithOffset(2, ok).To(gomega.BeTrue(), "%T is not an admission.Defaulter", obj)
+	defaulter.Default()
+}
+
+func validator(obj runtime.Object) (admission.Validator, error) {
+	validator, ok := obj.(admission.Validator)
+	if !ok {
+		return nil, errors.Errorf("%T is not an admission.Validator", obj)
+	}
+	return validator, nil
+}
+
+func validateCreate(obj runtime.Object) error {
+	v, err := validator(obj)
+	if err != nil {
+		return err
+	}
+	return v.ValidateCreate()
+}
+
+func validateUpdate(obj runtime.Object, old runtime.Object) error {
+	v, err := validator(obj)
+	if err != nil {
+		return err
+	}
+	return v.ValidateUpdate(old)
+}
+
+func validateDelete(obj runtime.Object) error {
+	v, err := validator(obj)
+	if err != nil {
+		return err
+	}
+	return v.ValidateDelete()
+}
+
+// Run the defaulting webhook on the object.
+func (uh *UnitHelper) Default() {
+	defaultObject(uh.Object)
+}
+
+// Run the validating webhook as if the object was being created.
+func (uh *UnitHelper) ValidateCreate() error {
+	return validateCreate(uh.Object)
+}
+
+// Run the validating webhook as if the object was being updated from old.
+func (uh *UnitHelper) ValidateUpdate(old client.Object) error {
+	return validateUpdate(uh.Object, old)
+}
+
+// Run the validating webhook as if the object was being deleted.
+func (uh *UnitHelper) ValidateDelete() error {
+	return validateDelete(uh.Object)
+}
+
+// Run the defaulting webhook on obj, without going through the API server.
+func (fh *FunctionalHelper) Default(obj client.Object) {
+	defaultObject(obj)
+}
+
+// Run the validating webhook as if obj was being created.
+func (fh *FunctionalHelper) ValidateCreate(obj client.Object) error {
+	return validateCreate(obj)
+}
+
+// Run the validating webhook as if obj was being updated from old.
+func (fh *FunctionalHelper) ValidateUpdate(obj client.Object, old client.Object) error {
+	return validateUpdate(obj, old)
+}
+
+// Run the validating webhook as if obj was being deleted.
+func (fh *FunctionalHelper) ValidateDelete(obj client.Object) error {
+	return validateDelete(obj)
+}
@@ -0,0 +1,78 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tests
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// How long Start waits for the webhook server to come up.
+var WebhookReadyTimeout = 30 * time.Second
+
+// Check if any webhook configurations were installed into the control plane.
+func (fsh *FunctionalSuiteHelper) hasWebhooks() bool {
+	if fsh.environment == nil {
+		return false
+	}
+	opts := fsh.environment.WebhookInstallOptions
+	return len(opts.MutatingWebhooks) != 0 || len(opts.ValidatingWebhooks) != 0
+}
+
+// Wait until the webhook server is accepting TLS connections with the
+// envtest serving cert, otherwise early requests fail with connection refused.
+func (fsh *FunctionalSuiteHelper) waitForWebhooks(port int) error {
+	opts := fsh.environment.WebhookInstallOptions
+	host := opts.LocalServingHost
+	if host == "" || host == "0.0.0.0" {
+		host = "127.0.0.1"
+	}
+	if port == 0 {
+		port = opts.LocalServingPort
+	}
+	addr := net.JoinHostPort(host, strconv.Itoa(port))
+
+	config := &tls.Config{ServerName: host}
+	if len(opts.LocalServingCAData) != 0 {
+		pool := x509.NewCertPool()
+		pool.AppendCertsFromPEM(opts.LocalServingCAData)
+		config.RootCAs = pool
+	} else {
+		// No CA to check against, just wait for the listener.
+		config.InsecureSkipVerify = true
+	}
+
+	dialer := &net.Dialer{Timeout: time.Second}
+	err := wait.PollImmediate(100*time.Millisecond, WebhookReadyTimeout, func() (bool, error) {
+		conn, err := tls.DialWithDialer(dialer, "tcp", addr, config)
+		if err != nil {
+			return false, nil
+		}
+		conn.Close()
+		return true, nil
+	})
+	if err != nil {
+		return errors.Wrapf(err, "error waiting for webhook server on %s", addr)
+	}
+	return nil
+}
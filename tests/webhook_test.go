@@ -0,0 +1,85 @@
+/*
+Copyright 2020 Noah Kantrowitz
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tests_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/coderanger/controller-utils/tests"
+)
+
+func (o *TestObject) Default() {
+	if o.Spec.Field == "" {
+		o.Spec.Field = "default"
+	}
+}
+
+func (o *TestObject) ValidateCreate() error {
+	if o.Spec.Field == "invalid" {
+		return errors.New("field is invalid")
+	}
+	return nil
+}
+
+func (o *TestObject) ValidateUpdate(old runtime.Object) error {
+	if old.(*TestObject).Spec.Field != o.Spec.Field {
+		return errors.New("field is immutable")
+	}
+	return nil
+}
+
+func (o *TestObject) ValidateDelete() error {
+	if o.Spec.Field == "protected" {
+		return errors.New("object is protected")
+	}
+	return nil
+}
+
+var _ = Describe("UnitHelper webhooks", func() {
+	var ush *tests.UnitSuiteHelper
+
+	BeforeEach(func() {
+		ush = tests.Unit().API(TestObjectSchemeBuilder.AddToScheme).MustBuild()
+	})
+
+	It("runs the defaulting webhook", func() {
+		uh := ush.SetupComponents(&TestObject{})
+		uh.Default()
+		Expect(uh.Object.(*TestObject).Spec.Field).To(Equal("default"))
+	})
+
+	It("runs the validating webhook", func() {
+		uh := ush.SetupComponents(&TestObject{Spec: TestObjectSpec{Field: "invalid"}})
+		Expect(uh.ValidateCreate()).To(MatchError("field is invalid"))
+		Expect(uh.ValidateUpdate(&TestObject{Spec: TestObjectSpec{Field: "invalid"}})).To(Succeed())
+		Expect(uh.ValidateUpdate(&TestObject{Spec: TestObjectSpec{Field: "other"}})).To(MatchError("field is immutable"))
+		Expect(uh.ValidateDelete()).To(Succeed())
+
+		uh = ush.SetupComponents(&TestObject{Spec: TestObjectSpec{Field: "protected"}})
+		Expect(uh.ValidateCreate()).To(Succeed())
+		Expect(uh.ValidateDelete()).To(MatchError("object is protected"))
+	})
+
+	It("fails for objects without a validating webhook", func() {
+		uh := ush.SetupComponents(&corev1.ConfigMap{})
+		Expect(uh.ValidateCreate()).To(MatchError("*v1.ConfigMap is not an admission.Validator"))
+	})
+})